@@ -0,0 +1,79 @@
+// Command importusers imports users of other systems with their password hashes.
+// The file has a json object per line like {"login": "...", "algorithm": "pbkdf2_sha256", "hash": "..."},
+// see models.ImportedUser. Mongo settings are taken from config at CFG_PATH
+//
+//	CFG_PATH=config/config_debug.yaml go run ./cmd/importusers -file users.jsonl
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	repository "github.com/DMA8/authService/internal/adapters/mongodb"
+	"github.com/DMA8/authService/internal/config"
+	"github.com/DMA8/authService/internal/domain/auth"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/logging"
+)
+
+const batchSize = 500
+
+func main() {
+	path := flag.String("file", "", "json lines file with users to import")
+	flag.Parse()
+	if *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	ctx := context.Background()
+	cfg := config.NewConfig()
+	logger := logging.New(cfg.Log.Level)
+	repo, err := repository.NewRepository(ctx, cfg.Mongo)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("repo init fail")
+	}
+	// import issues no tokens, so no keys are loaded
+	jwtCfg := cfg.JWT
+	jwtCfg.TokenFormat, jwtCfg.AcceptTokenFormats = config.JWTTokenFormat, nil
+	authService, err := auth.NewAuth(jwtCfg, nil, nil, repo, repo, repo, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("auth init fail")
+	}
+
+	file, err := os.Open(filepath.Clean(*path))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("couldn't open users file")
+	}
+	defer file.Close()
+	total := &models.ImportResult{}
+	decoder := json.NewDecoder(file)
+	for done := false; !done; {
+		batch := make([]models.ImportedUser, 0, batchSize)
+		for len(batch) < batchSize {
+			var user models.ImportedUser
+			if err = decoder.Decode(&user); errors.Is(err, io.EOF) {
+				done = true
+				break
+			} else if err != nil {
+				logger.Fatal().Err(err).Msgf("bad users file after %d users", total.Imported+len(total.Failed)+len(batch))
+			}
+			batch = append(batch, user)
+		}
+		result := authService.ImportUsers(ctx, batch)
+		total.Imported += result.Imported
+		total.Failed = append(total.Failed, result.Failed...)
+	}
+	for _, failure := range total.Failed {
+		fmt.Printf("%s: %s\n", failure.Login, failure.Error)
+	}
+	fmt.Printf("imported %d users, %d failed\n", total.Imported, len(total.Failed))
+	if len(total.Failed) > 0 {
+		os.Exit(1)
+	}
+}
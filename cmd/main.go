@@ -2,29 +2,86 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	grpc "github.com/DMA8/authService/internal/adapters/grpc"
 	entrypoint "github.com/DMA8/authService/internal/adapters/http"
+	"github.com/DMA8/authService/internal/adapters/memory"
 	repository "github.com/DMA8/authService/internal/adapters/mongodb"
+	"github.com/DMA8/authService/internal/adapters/notifier"
 	"github.com/DMA8/authService/internal/config"
 	"github.com/DMA8/authService/internal/domain/auth"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/domain/policy"
+	"github.com/DMA8/authService/internal/ports"
 	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/passwords"
+	"github.com/DMA8/authService/pkg/tokens"
+	"github.com/DMA8/authService/pkg/webauthn"
 )
 
 func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	cfg := config.NewConfig()
 	logger := logging.New(cfg.Log.Level)
-	logger.Info().Msgf("config\n %+v", cfg)
+	logger.Info().Msgf("config\n %s", cfg)
 
 	repo, err := repository.NewRepository(ctx, cfg.Mongo)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("repo init fail")
 	}
-	authService := auth.NewAuth(cfg.JWT, repo, logger)
+	var revocations ports.RevocationStorage = repo
+	if cfg.JWT.RevocationStorage == "memory" {
+		revocations = memory.NewRevocationStorage(ctx, time.Minute)
+	}
+	keyring, err := newKeyring(cfg.JWT, accessKeyRetention(cfg))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("jwt signing key init fail")
+	}
+	refreshKeyring, err := newRefreshKeyring(cfg.JWT)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("jwt refresh signing key init fail")
+	}
+	hasher, err := passwords.New(cfg.Password.Hashing)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("password hasher init fail")
+	}
+	peppers, err := passwords.LoadPeppers(cfg.Password.Pepper)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("password pepper init fail")
+	}
+	hasher = hasher.WithPeppers(peppers)
+	passwordPolicy, err := policy.New(cfg.Password.Policy)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("password policy init fail")
+	}
+	passwordNotifier, err := newNotifier(cfg.Notifier)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("notifier init fail")
+	}
+	relyingParty, err := newRelyingParty(cfg.WebAuthn)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("webauthn init fail")
+	}
+	var attempts ports.AttemptStorage = repo
+	if cfg.Lockout.Storage == "memory" {
+		attempts = memory.NewAttemptStorage(ctx, time.Minute)
+	}
+	authService, err := auth.NewAuth(cfg.JWT, keyring, refreshKeyring, repo, repo, revocations, logger,
+		auth.WithHasher(hasher), auth.WithPasswordPolicy(passwordPolicy),
+		auth.WithPasswordHistory(cfg.Password.History, cfg.Password.MinAge),
+		auth.WithPasswordMaxAge(cfg.Password.MaxAge),
+		auth.WithNotifier(passwordNotifier), auth.WithResetTokenTTL(cfg.Password.ResetTTL),
+		auth.WithLockout(attempts, cfg.Lockout), auth.WithMFA(cfg.MFA), auth.WithWebAuthn(relyingParty, repo),
+		auth.WithOTP(repo), auth.WithTrustedDevices(repo))
+	if err != nil {
+		logger.Fatal().Err(err).Msg("auth init fail")
+	}
+	go reloadKeysOnSIGHUP(ctx, authService, logger)
 	handler := entrypoint.NewHandler(cfg.HTTP, authService, logger)
 	server := entrypoint.NewHTTPServer(cfg.HTTP, handler)
 	grpcAuth := grpc.NewAuthServer(cfg.GRPC, authService, logger)
@@ -50,3 +107,113 @@ func main() {
 	}
 	cancel()
 }
+
+// newKeyring loads keyring file or wraps the single configured key.
+// Retired keys are kept for retention, the longest lifetime of tokens they signed
+func newKeyring(cfg config.JWTConfig, retention time.Duration) (*tokens.Keyring, error) {
+	if cfg.KeyringPath != "" {
+		return tokens.LoadKeyring(cfg.KeyringPath, retention)
+	}
+	key, err := tokens.NewKey(cfg.Algorithm, cfg.Secret, cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return tokens.NewKeyring(retention, key)
+}
+
+// newRefreshKeyring returns nil if refresh tokens are signed with access tokens keys
+func newRefreshKeyring(cfg config.JWTConfig) (*tokens.Keyring, error) {
+	switch {
+	case cfg.RefreshKeyringPath != "":
+		return tokens.LoadKeyring(cfg.RefreshKeyringPath, maxTokenTTL(cfg))
+	case cfg.RefreshSecret != "":
+		key, err := tokens.NewHMACKey(cfg.RefreshSecret)
+		if err != nil {
+			return nil, err
+		}
+		key.ID = "refresh"
+		return tokens.NewKeyring(maxTokenTTL(cfg), key)
+	}
+	return nil, nil
+}
+
+func newNotifier(cfg config.NotifierConfig) (ports.Notifier, error) {
+	var fallback ports.Notifier
+	switch cfg.Type {
+	case "", "file":
+		if cfg.OutboxPath == "" {
+			return nil, fmt.Errorf("notifier outbox_path is not set")
+		}
+		fallback = notifier.NewFileNotifier(cfg.OutboxPath)
+	case "memory":
+		fallback = notifier.NewMemoryNotifier()
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+	channels := map[string]ports.Notifier{}
+	if cfg.SMTP.Host != "" {
+		if password := os.Getenv("SMTP_PASSWORD"); password != "" {
+			cfg.SMTP.Password = password
+		}
+		email, err := notifier.NewSMTPNotifier(cfg.SMTP)
+		if err != nil {
+			return nil, err
+		}
+		channels[models.EmailChannel] = email
+	}
+	if cfg.SMS.URL != "" {
+		if token := os.Getenv("SMS_WEBHOOK_TOKEN"); token != "" {
+			cfg.SMS.Token = token
+		}
+		channels[models.SMSChannel] = notifier.NewWebhookNotifier(cfg.SMS)
+	}
+	return notifier.NewRouter(fallback, channels), nil
+}
+
+func maxTokenTTL(cfg config.JWTConfig) time.Duration {
+	if cfg.AccesTTL > cfg.RefreshTTL {
+		return cfg.AccesTTL
+	}
+	return cfg.RefreshTTL
+}
+
+// accessKeyRetention is how long retired access keys verify: trusted device tokens are signed by them too
+func accessKeyRetention(cfg *config.Config) time.Duration {
+	if ttl := maxTokenTTL(cfg.JWT); ttl > cfg.MFA.TrustedDeviceTTL {
+		return ttl
+	}
+	return cfg.MFA.TrustedDeviceTTL
+}
+
+func reloadKeysOnSIGHUP(ctx context.Context, authService *auth.Auth, logger logging.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			keys, err := authService.ReloadKeys(ctx)
+			if err != nil {
+				logger.Error().Err(err).Msg("keyring reload fail")
+				continue
+			}
+			logger.Info().Msgf("keyring reloaded %+v", keys)
+		}
+	}
+}
+
+// newRelyingParty is nil when webauthn is not configured
+func newRelyingParty(cfg config.WebAuthnConfig) (*webauthn.RelyingParty, error) {
+	if cfg.RPID == "" {
+		return nil, nil
+	}
+	return webauthn.New(webauthn.Config{
+		RPID:        cfg.RPID,
+		RPName:      cfg.RPName,
+		Origins:     cfg.Origins,
+		Timeout:     cfg.Timeout,
+		Attestation: cfg.Attestation,
+	})
+}
@@ -2,14 +2,17 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
 	"log"
 	"net"
+	"time"
 
 	"github.com/DMA8/authService/internal/config"
 	"github.com/DMA8/authService/internal/domain/models"
 	"github.com/DMA8/authService/internal/ports"
 	"github.com/DMA8/authService/pkg/grpc_auth"
 	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
 
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
@@ -21,6 +24,8 @@ import (
 
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var tracer trace.Tracer
@@ -72,29 +77,55 @@ func (a *AuthServer) Validate(ctx context.Context, credentials *grpc_auth.Creden
 
 	ctx, span := tracer.Start(ctx, "auth grpc Validate")
 	defer span.End()
-	accessLogin, err := a.authService.ValidateToken(ctx, credentials.AccessToken)
+	claims, err := a.authService.ValidateToken(ctx, credentials.AccessToken, models.AccessTokenType)
 	if err != nil {
 		a.logger.Debug().Err(err).Msgf("auth.Validate couldn't validate access token! %+v", credentials)
-		loginFromRefreshToken, err := a.authService.ValidateToken(ctx, credentials.RefreshToken)
+		pair, err := a.authService.RefreshTokens(ctx, credentials.RefreshToken)
 		if err != nil {
-			a.logger.Debug().Err(err).Msgf("auth.Validate couldn't validate refresh token! %+v", credentials)
-			return createResponse("", "", "", fail, notUpdated), err
-		}
-		accessToken, err := a.authService.CreateToken(ctx, loginFromRefreshToken, models.AccessTokenType)
-		if err != nil {
-			a.logger.Debug().Err(err).Msgf("auth.Validate couldn't create access token! %+v", credentials)
-			return createResponse("", "", "", fail, notUpdated), err
-		}
-		refreshToken, err := a.authService.CreateToken(ctx, loginFromRefreshToken, models.RefreshTokenType)
-		if err != nil {
-			a.logger.Debug().Err(err).Msgf("auth.Validate couldn't create refresh token! %+v", credentials)
-			return createResponse("", "", "", fail, notUpdated), err
+			a.logger.Debug().Err(err).Msgf("auth.Validate couldn't refresh tokens! %+v", credentials)
+			return createResponse("", "", "", fail, notUpdated, nil), err
 		}
 		a.logger.Info().Err(err).Msgf("auth.Validate tokens are updated %+v", credentials)
-		return createResponse(accessToken, refreshToken, loginFromRefreshToken, success, isUpdated), nil
+		// previous refresh token within grace window gives only access token, the client keeps its refresh token
+		refreshToken := pair.RefreshToken
+		if refreshToken == "" {
+			refreshToken = credentials.RefreshToken
+		}
+		return createResponse(pair.AccessToken, refreshToken, pair.Login, success, isUpdated, pair.Claims), nil
 	}
 	a.logger.Info().Err(err).Msgf("auth.Validate accessToken is alive. no need to update %+v", credentials)
-	return createResponse("", "", accessLogin, success, notUpdated), nil
+	return createResponse("", "", claims.Subject, success, notUpdated, claims), nil
+}
+
+// Introspect answers Unauthenticated to unknown clients and never tells why a token is not active
+func (a *AuthServer) Introspect(ctx context.Context, req *grpc_auth.IntrospectRequest) (*grpc_auth.IntrospectResponse, error) {
+	ctx, span := tracer.Start(ctx, "auth grpc Introspect")
+	defer span.End()
+	if err := a.authService.AuthClient(ctx, req.ClientID, req.ClientSecret); err != nil {
+		a.logger.Debug().Err(err).Msgf("auth.Introspect client %s is not authenticated", req.ClientID)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+	introspection := a.authService.Introspect(ctx, req.Token, req.TokenTypeHint)
+	return &grpc_auth.IntrospectResponse{
+		Active:    introspection.Active,
+		Scope:     introspection.Scope,
+		ClientID:  introspection.ClientID,
+		Username:  introspection.Username,
+		TokenType: introspection.TokenType,
+		ExpiresAt: introspection.ExpiresAt,
+		IssuedAt:  introspection.IssuedAt,
+		NotBefore: introspection.NotBefore,
+		Subject:   introspection.Subject,
+		Audience:  introspection.Audience,
+		Issuer:    introspection.Issuer,
+		TokenID:   introspection.TokenID,
+		AuthTime:  introspection.AuthTime,
+		AMR:       introspection.AMR,
+		ACR:       introspection.ACR,
+	}, nil
 }
 
 func (a *AuthServer) LaunchGRPCServer() chan error {
@@ -115,12 +146,47 @@ func (a *AuthServer) LaunchGRPCServer() chan error {
 	return chanErr
 }
 
-func createResponse(access, refresh, login string, success, isUpdate bool) *grpc_auth.ValidateResponse {
+func createResponse(access, refresh, login string, success, isUpdate bool, claims *tokens.Claims) *grpc_auth.ValidateResponse {
 	return &grpc_auth.ValidateResponse{
 		AccessToken:  access,
 		RefreshToken: refresh,
 		Login:        login,
 		Success:      success,
 		IsUpdate:     isUpdate,
+		Claims:       claimsToProto(claims),
+	}
+}
+
+func claimsToProto(claims *tokens.Claims) *grpc_auth.Claims {
+	if claims == nil {
+		return nil
+	}
+	custom := make(map[string]string, len(claims.Custom))
+	for name, value := range claims.Custom {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		custom[name] = string(encoded)
+	}
+	return &grpc_auth.Claims{
+		ID:        claims.ID,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		Subject:   claims.Subject,
+		UserID:    claims.UserID,
+		TokenType: claims.TokenType,
+		Roles:     claims.Roles,
+		IssuedAt:  unix(claims.IssuedAt),
+		NotBefore: unix(claims.NotBefore),
+		ExpiresAt: unix(claims.ExpiresAt),
+		Custom:    custom,
+	}
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
 	}
+	return t.Unix()
 }
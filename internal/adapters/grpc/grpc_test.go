@@ -5,17 +5,21 @@ import (
 	"testing"
 	"time"
 
-	"github.com/DMA8/authService/internal/domain/models"
 	"github.com/DMA8/authService/internal/adapters/grpc"
+	"github.com/DMA8/authService/internal/adapters/memory"
 	"github.com/DMA8/authService/internal/config"
 	"github.com/DMA8/authService/internal/domain/auth"
-	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/internal/domain/models"
 	mock_ports "github.com/DMA8/authService/internal/mocks"
 	"github.com/DMA8/authService/pkg/grpc_auth"
+	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestValidate(t *testing.T) {
@@ -26,18 +30,34 @@ func TestValidate(t *testing.T) {
 		Transport: "tcp",
 	}
 	jwtConfig := config.JWTConfig{
-		Secret:     "test",
-		AccesTTL:   time.Minute,
-		RefreshTTL: time.Hour,
+		Secret:       "test",
+		AccesTTL:     time.Minute,
+		RefreshTTL:   time.Hour,
+		RefreshGrace: time.Minute,
 	}
 	ctr := gomock.NewController(t)
 	mockRepo := mock_ports.NewMockAuthStorage(ctr)
-	authBussiness := auth.NewAuth(jwtConfig, mockRepo, l)
+	mockTokenRepo := mock_ports.NewMockTokenStorage(ctr)
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	secret, err := tokens.NewHMACKey(jwtConfig.Secret)
+	require.NoError(t, err)
+	key, err := tokens.NewKeyring(time.Hour, secret)
+	require.NoError(t, err)
+	authBussiness, err := auth.NewAuth(jwtConfig, key, nil, mockRepo, mockTokenRepo, revocations, l)
+	require.NoError(t, err)
 	serv := grpc.NewAuthServer(cfgGRPC, authBussiness, l)
 	serv.LaunchGRPCServer()
 
+	mockRepo.EXPECT().GetUser(gomock.Any(), "admin").Return(
+		&models.Credentials{Login: "admin", Roles: []string{"admin"}}, nil).AnyTimes()
 	token1Access, err := authBussiness.CreateToken(ctx, "admin", models.AccessTokenType)
 	require.NoError(t, err)
+	var family *models.TokenFamily
+	mockTokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, f *models.TokenFamily) error {
+			family = f
+			return nil
+		}).Times(1)
 	token1Refresh, err := authBussiness.CreateToken(ctx, "admin", models.RefreshTokenType)
 	require.NoError(t, err)
 
@@ -49,12 +69,84 @@ func TestValidate(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, true, resp.Success)
 	assert.Equal(t, false, resp.IsUpdate)
+	assert.Equal(t, "admin", resp.Claims.Subject)
+	assert.Equal(t, []string{"admin"}, resp.Claims.Roles)
+	assert.Equal(t, string(models.AccessTokenType), resp.Claims.TokenType)
 
 	test2 := grpc_auth.Credential{
 		RefreshToken: token1Refresh,
 	}
+	mockTokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(family, nil).Times(1)
+	mockTokenRepo.EXPECT().RotateFamily(gomock.Any(), gomock.Any(), 0).Return(nil).Times(1)
 	resp2, err := serv.Validate(ctx, &test2)
 	require.NoError(t, err)
 	assert.Equal(t, true, resp2.Success)
 	assert.Equal(t, true, resp2.IsUpdate)
+	assert.Equal(t, "admin", resp2.Login)
+	assert.NotEmpty(t, resp2.RefreshToken)
+	assert.Equal(t, "admin", resp2.Claims.Subject)
+
+	//same refresh token again within grace window -> new access token, the client keeps its refresh token
+	rotated := *family
+	rotated.Generation = 1
+	rotated.RotatedAt = time.Now()
+	mockTokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&rotated, nil).Times(1)
+	respGrace, err := serv.Validate(ctx, &test2)
+	require.NoError(t, err)
+	assert.Equal(t, true, respGrace.Success)
+	assert.Equal(t, true, respGrace.IsUpdate)
+	assert.NotEmpty(t, respGrace.AccessToken)
+	assert.Equal(t, token1Refresh, respGrace.RefreshToken)
+
+	//same refresh token again after grace window -> whole family is revoked
+	rotated.RotatedAt = time.Now().Add(-time.Hour)
+	mockTokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&rotated, nil).Times(1)
+	mockTokenRepo.EXPECT().RevokeFamily(gomock.Any(), family.ID).Return(nil).Times(1)
+	resp3, err := serv.Validate(ctx, &test2)
+	require.Error(t, err)
+	assert.Equal(t, false, resp3.Success)
+}
+
+func TestIntrospect(t *testing.T) {
+	ctx := context.TODO()
+	l := logging.New("debug")
+	jwtConfig := config.JWTConfig{
+		Secret:               "test",
+		AccesTTL:             time.Minute,
+		RefreshTTL:           time.Hour,
+		Audience:             "team31",
+		IntrospectionClients: map[string]string{"gateway": "secret"},
+	}
+	ctr := gomock.NewController(t)
+	mockRepo := mock_ports.NewMockAuthStorage(ctr)
+	mockRepo.EXPECT().GetUser(gomock.Any(), "admin").Return(&models.Credentials{Login: "admin"}, nil).AnyTimes()
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	secret, err := tokens.NewHMACKey(jwtConfig.Secret)
+	require.NoError(t, err)
+	keys, err := tokens.NewKeyring(time.Hour, secret)
+	require.NoError(t, err)
+	authBussiness, err := auth.NewAuth(jwtConfig, keys, nil, mockRepo, mock_ports.NewMockTokenStorage(ctr), revocations, l)
+	require.NoError(t, err)
+	serv := grpc.NewAuthServer(config.GRPCConfig{}, authBussiness, l)
+
+	accessToken, err := authBussiness.CreateToken(models.WithAuthMethods(ctx, models.AMRPassword, models.AMROTP), "admin",
+		models.AccessTokenType)
+	require.NoError(t, err)
+	resp, err := serv.Introspect(ctx, &grpc_auth.IntrospectRequest{Token: accessToken, ClientID: "gateway", ClientSecret: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, true, resp.Active)
+	assert.Equal(t, "admin", resp.Subject)
+	assert.Equal(t, "access_token", resp.TokenType)
+	assert.Equal(t, "team31", resp.ClientID)
+	assert.Equal(t, []string{models.AMRPassword, models.AMROTP, models.AMRMultiFactor}, resp.AMR)
+	assert.Equal(t, models.ACRMultiFactor, resp.ACR)
+	assert.NotZero(t, resp.AuthTime)
+
+	_, err = serv.Introspect(ctx, &grpc_auth.IntrospectRequest{Token: accessToken, ClientID: "gateway"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	require.NoError(t, authBussiness.RevokeTokens(ctx, accessToken, ""))
+	resp, err = serv.Introspect(ctx, &grpc_auth.IntrospectRequest{Token: accessToken, ClientID: "gateway", ClientSecret: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, &grpc_auth.IntrospectResponse{Active: false}, resp)
 }
@@ -1,17 +1,28 @@
 package http
 
 import (
-	e "github.com/DMA8/authService/internal/domain/errors"
-	"github.com/DMA8/authService/internal/domain/models"
+	"encoding/json"
 	"fmt"
 	"net/http"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/tokens"
 )
 
 // Login godoc
 // @Summary Login with basic auth
 // @Description It accepts parameters from basic auth and return access and refresh tokens
 // @Produce json
+// @Description Login and client ip which fail too often get 429 with Retry-After, locked login gets 423
+// @Description Unknown login and wrong password both get 403, unless reveal_unknown_users is set
+// @Description User with second factor gets 401 and mfa token to send with the code to /login/mfa
+// @Description Second factor is skipped on device trusted at /login/mfa, it sends its trusted device cookie
 // @Success 200 {object} TestMessage
+// @Failure 401 {object} TestMessage
+// @Failure 403 {object} Message
+// @Failure 423 {object} Message
+// @Failure 429 {object} Message
 // @Router /login [post]
 // @Accept       json
 // @Produce      json
@@ -27,7 +38,18 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		WriteAnswer(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	AuthErr := h.auth.AuthUser(r.Context(), credentials)
+	ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+	ctx = models.WithDeviceToken(ctx, h.deviceToken(r))
+	AuthErr := h.auth.AuthUser(ctx, credentials)
+	if writeRetryError(w, AuthErr) {
+		h.logger.Info().Msgf("h.Login %s: %s", credentials.Login, AuthErr.Error())
+		return
+	}
+	if (AuthErr == e.ErrNoUserInDB || AuthErr == e.ErrWrongPass) && !h.cfg.RevealUnknownUsers {
+		h.logger.Debug().Msgf("h.Login %s: %s", credentials.Login, AuthErr.Error())
+		WriteAnswer(w, http.StatusForbidden, e.ErrBadLoginOrPassword.Error())
+		return
+	}
 	if AuthErr != nil {
 		switch AuthErr {
 		case e.ErrNoUserInDB:
@@ -36,18 +58,30 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 		case e.ErrWrongPass:
 			WriteAnswer(w, http.StatusForbidden, AuthErr.Error())
 			return
+		case e.ErrPasswordChangeRequired:
+			h.passwordChangeRequired(w, r, credentials.Login)
+			return
+		case e.ErrMFARequired:
+			h.mfaRequired(w, r, credentials.Login)
+			return
 		}
 		WriteAnswer(w, http.StatusInternalServerError, AuthErr.Error())
 		return
 	}
-	accessToken, err := h.auth.CreateToken(r.Context(), credentials.Login, models.AccessTokenType)
+	h.startSession(w, r, credentials.Login, models.AMRPassword)
+}
+
+// startSession gives access and refresh tokens to user who passed login with amr methods
+func (h *Handler) startSession(w http.ResponseWriter, r *http.Request, login string, amr ...string) {
+	ctx := models.WithAuthMethods(r.Context(), amr...)
+	accessToken, err := h.auth.CreateToken(ctx, login, models.AccessTokenType)
 	if err != nil {
 		h.logger.Warn().Msgf("h.Login couldn't create accessToken %s", err.Error())
 		WriteAnswer(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 	SetCookie(w, h.cfg.AccessCookieName, accessToken, "/")
-	refreshToken, err := h.auth.CreateToken(r.Context(), credentials.Login, models.RefreshTokenType)
+	refreshToken, err := h.auth.CreateToken(ctx, login, models.RefreshTokenType)
 	if err != nil {
 		h.logger.Warn().Msgf("h.Login couldn't create refreshToken %s", err.Error())
 		WriteAnswer(w, http.StatusInternalServerError, err.Error())
@@ -57,17 +91,202 @@ func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
 	sendCookie(w, "OK", accessToken, refreshToken, http.StatusOK)
 }
 
+// passwordChangeRequired gives a token which is accepted only by /me/password
+func (h *Handler) passwordChangeRequired(w http.ResponseWriter, r *http.Request, login string) {
+	token, err := h.auth.CreateToken(r.Context(), login, models.PasswordChangeTokenType)
+	if err != nil {
+		h.logger.Warn().Msgf("h.Login couldn't create password change token %s", err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.logger.Info().Msgf("h.Login %s has to change password", login)
+	SetCookie(w, h.cfg.AccessCookieName, token, "/")
+	sendCookie(w, e.ErrPasswordChangeRequired.Error(), token, "", http.StatusForbidden)
+}
+
 // Logout godoc
-// @Summary removes client's access and refresh tokens
-// @Description It accepts token and return user login if token is alive
+// @Summary revokes and removes client's access and refresh tokens
+// @Description It revokes tokens from cookies so they can't be used anymore and removes cookies
 // @Router /logout [get]
 func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	initHeaders(w)
+	var revokeErr error
+	if cookies, err := GetCookieValue(r.Header["Cookie"]); err == nil {
+		revokeErr = h.auth.RevokeTokens(r.Context(), cookies[h.cfg.AccessCookieName], cookies[h.cfg.RefreshCookieName])
+	}
 	resetCookie(w, []string{h.cfg.AccessCookieName, h.cfg.RefreshCookieName})
+	if revokeErr != nil && revokeErr != e.ErrNoTokensToRevoke {
+		h.logger.Warn().Msgf("h.Logout couldn't revoke tokens %s", revokeErr.Error())
+		WriteAnswer(w, http.StatusInternalServerError, revokeErr.Error())
+		return
+	}
 	WriteAnswer(w, http.StatusOK, "cookies removed successfully")
 }
 
+// RevokeTokens godoc
+// @Summary revokes given access and refresh tokens
+// @Description Admin only. Tokens are rejected everywhere until they expire
+// @Router /admin/revoke [post]
+// @Accept       json
+// @Produce      json
+// @Param input body RevokeRequest true "tokens to revoke"
+// @Success 200 {object} Message
+func (h *Handler) RevokeTokens(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var revokeReq RevokeRequest
+	if err := json.NewDecoder(r.Body).Decode(&revokeReq); err != nil {
+		h.logger.Debug().Msgf("h.RevokeTokens bad input err: %s", err.Error())
+		WriteAnswer(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err := h.auth.RevokeTokens(r.Context(), revokeReq.AccessToken, revokeReq.RefreshToken)
+	if err == e.ErrNoTokensToRevoke {
+		WriteAnswer(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		h.logger.Warn().Msgf("h.RevokeTokens couldn't revoke tokens %s", err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "tokens revoked")
+}
+
+// Unlock godoc
+// @Summary unlocks login or ip locked after failed logins
+// @Description Admin only. Forgets failed logins of given login and ip, at least one is required
+// @Router /admin/unlock [post]
+// @Accept       json
+// @Produce      json
+// @Param input body UnlockRequest true "login and ip to unlock"
+// @Success 200 {object} Message
+func (h *Handler) Unlock(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var unlockReq UnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&unlockReq); err != nil || unlockReq.Login == "" && unlockReq.IP == "" {
+		h.logger.Debug().Msg("h.Unlock bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed login and ip")
+		return
+	}
+	if err := h.auth.Unlock(r.Context(), unlockReq.Login, unlockReq.IP); err != nil {
+		h.logger.Warn().Msgf("h.Unlock couldn't unlock %s", err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "unlocked")
+}
+
+// ExpirePassword godoc
+// @Summary makes user change password at the next login
+// @Description Admin only. Ends all sessions of the user, the next login gives token which only lets to change password
+// @Router /admin/password/expire [post]
+// @Accept       json
+// @Produce      json
+// @Param input body ExpirePasswordRequest true "login of user"
+// @Success 200 {object} Message
+// @Failure 404 {object} Message
+func (h *Handler) ExpirePassword(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var expireReq ExpirePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&expireReq); err != nil || expireReq.Login == "" {
+		h.logger.Debug().Msg("h.ExpirePassword bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed login")
+		return
+	}
+	err := h.auth.ExpirePassword(r.Context(), expireReq.Login)
+	switch err {
+	case nil:
+		WriteAnswer(w, http.StatusOK, "password expired")
+	case e.ErrNoUserInDB:
+		WriteAnswer(w, http.StatusNotFound, err.Error())
+	default:
+		h.logger.Warn().Msgf("h.ExpirePassword couldn't expire password %s", err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// JWKS godoc
+// @Summary public keys to verify tokens
+// @Description JSON Web Key Set (RFC 7517). It is empty if tokens are signed with shared secret (HS256)
+// @Produce json
+// @Success 200 {object} tokens.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (h *Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.auth.JWKS(r.Context())); err != nil {
+		h.logger.Warn().Msgf("h.JWKS couldn't encode keys %s", err.Error())
+	}
+}
+
+// Introspect godoc
+// @Summary OAuth 2.0 token introspection (RFC 7662)
+// @Description Client authenticates with basic auth or client_id and client_secret form fields.
+// @Description Invalid, expired and revoked tokens are all reported as {"active": false}
+// @Router /introspect [post]
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param token formData string true "token to introspect"
+// @Param token_type_hint formData string false "access_token or refresh_token"
+// @Success 200 {object} models.Introspection
+// @Failure 401 {object} Message
+func (h *Handler) Introspect(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	if err := r.ParseForm(); err != nil {
+		h.logger.Debug().Msgf("h.Introspect bad form err: %s", err.Error())
+		WriteAnswer(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if err := h.auth.AuthClient(r.Context(), clientID, clientSecret); err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspection"`)
+		WriteAnswer(w, http.StatusUnauthorized, e.ErrBadClientCreds.Error())
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		WriteAnswer(w, http.StatusBadRequest, "token is required")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	introspection := h.auth.Introspect(r.Context(), token, r.PostForm.Get("token_type_hint"))
+	if err := json.NewEncoder(w).Encode(introspection); err != nil {
+		h.logger.Warn().Msgf("h.Introspect couldn't encode answer %s", err.Error())
+	}
+}
+
+// ReloadKeys godoc
+// @Summary rereads signing keyring file
+// @Description Admin only. Rotates signing keys without restart and returns keys in use
+// @Router /admin/keys/reload [post]
+// @Produce      json
+// @Success 200 {array} tokens.KeyInfo
+func (h *Handler) ReloadKeys(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	keys, err := h.auth.ReloadKeys(r.Context())
+	if err == tokens.ErrNoKeyringFile {
+		WriteAnswer(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		h.logger.Warn().Msgf("h.ReloadKeys couldn't reload keyring %s", err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if err = json.NewEncoder(w).Encode(keys); err != nil {
+		h.logger.Warn().Msgf("h.ReloadKeys couldn't encode keys %s", err.Error())
+	}
+}
+
 // I godoc
 // @Summary check token
 // @Description It accepts token and return user login if token is alive
@@ -86,7 +305,7 @@ func (h *Handler) I(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) Profiling(w http.ResponseWriter, r *http.Request) {
 	var profilingInputState bool
 	var answer string
-	
+
 	values := r.URL.Query()
 	toggle := values.Get("state")
 	if toggle == "on" {
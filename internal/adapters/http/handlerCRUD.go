@@ -1,19 +1,23 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 
 	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
 
 	"github.com/go-chi/chi"
 )
 
 // CreateUser godoc
 // @Summary CreateUser
-// @Description Creates user in db
+// @Description Creates user in db. Taken login gets the same answer as a new one, unless reveal_unknown_users is set
 // @Produce json
 // @Success 200 {object} TestMessage
+// @Failure 400 {object} ValidationMessage
+// @Failure 409 {object} Message
 // @Router /user [post]
 // @Accept       json
 // @Produce      json
@@ -32,7 +36,13 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	err = h.auth.CreateUser(r.Context(), credentials)
-	if err != nil {
+	if writeValidationError(w, err) {
+		h.logger.Debug().Msgf("h.CreateUser err: %s", err.Error())
+		return
+	} else if err == e.ErrUserExists && h.cfg.RevealUnknownUsers {
+		WriteAnswer(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil && err != e.ErrUserExists {
 		WriteAnswer(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -57,6 +67,15 @@ func (h *Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	WriteAnswer(w, http.StatusOK, fmt.Sprintf("userID %s userLogin %s", user.ID, user.Login))
 }
 
+// UpdateUser godoc
+// @Summary sets password of any user
+// @Description Admin only. Users change their own password with /me/password
+// @Router /user [put]
+// @Accept       json
+// @Produce      json
+// @Param input body models.Credentials true "account info"
+// @Success 200 {object} Message
+// @Failure 400 {object} ValidationMessage
 func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	credentials, err := GetCredsFromCtx(r.Context())
 	if err != nil {
@@ -65,7 +84,10 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	err = h.auth.UpdateUser(r.Context(), credentials)
-	if err == e.ErrNoUserInDB {
+	if writeValidationError(w, err) {
+		h.logger.Debug().Msgf("h.UpdateUser err: %s", err.Error())
+		return
+	} else if err == e.ErrNoUserInDB {
 		WriteAnswer(w, http.StatusNotFound, err.Error())
 		return
 	} else if err != nil {
@@ -75,6 +97,17 @@ func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	WriteAnswer(w, http.StatusOK, "update OK")
 }
 
+// DeleteUser godoc
+// @Summary deletes user
+// @Description User deletes own account, admin any. Session has to be recent and strong enough,
+// @Description otherwise 401 insufficient_user_authentication tells to go to /me/reauth
+// @Router /user/{login} [delete]
+// @Param login path string true "login"
+// @Produce      json
+// @Success 200 {object} Message
+// @Failure 401 {object} StepUpRequired
+// @Failure 403 {object} Message
+// @Failure 404 {object} Message
 func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	login := chi.URLParam(r, "login")
 	if login == "" {
@@ -92,3 +125,27 @@ func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	}
 	WriteAnswer(w, http.StatusOK, fmt.Sprintf("user %s deleted", login))
 }
+
+// ImportUsers godoc
+// @Summary imports users of other systems with their password hashes
+// @Description Admin only. Hashes are bcrypt, pbkdf2_sha256 and scrypt of Django or salted sha1.
+// @Description They are upgraded to configured algorithm on the first login of the user
+// @Router /admin/users/import [post]
+// @Accept       json
+// @Produce      json
+// @Param input body []models.ImportedUser true "users to import"
+// @Success 200 {object} models.ImportResult
+func (h *Handler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var users []models.ImportedUser
+	if err := json.NewDecoder(r.Body).Decode(&users); err != nil {
+		h.logger.Debug().Msgf("h.ImportUsers bad input err: %s", err.Error())
+		WriteAnswer(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	result := h.auth.ImportUsers(r.Context(), users)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		h.logger.Warn().Msgf("h.ImportUsers couldn't write answer %s", err.Error())
+	}
+}
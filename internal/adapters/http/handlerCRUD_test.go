@@ -3,9 +3,11 @@ package http_test
 import (
 	p "github.com/DMA8/authService/internal/adapters/http"
 	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
 	"github.com/DMA8/authService/internal/domain/models"
 	"github.com/DMA8/authService/pkg/logging"
 	mock_ports "github.com/DMA8/authService/internal/mocks"
+	"github.com/DMA8/authService/pkg/tokens"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -85,6 +87,61 @@ func TestCreateUser(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, targets2.StatusCode)
 }
 
+func TestCreateUserExisting(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	newRequest := func(login string) *http.Request {
+		creds := &models.Credentials{Login: login, Password: "long enough password"}
+		return httptest.NewRequest(http.MethodPost, "/user", nil).WithContext(
+			context.WithValue(context.Background(), p.CrudCreds, creds))
+	}
+	mockAuth.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	mockAuth.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(e.ErrUserExists).Times(2)
+
+	//taken login looks like a new one
+	handler := http.HandlerFunc(p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug")).CreateUser)
+	created := httptest.NewRecorder()
+	handler.ServeHTTP(created, newRequest("user"))
+	existing := httptest.NewRecorder()
+	handler.ServeHTTP(existing, newRequest("user"))
+	assert.Equal(t, http.StatusOK, existing.Code)
+	assert.Equal(t, created.Body.String(), existing.Body.String())
+
+	handler = http.HandlerFunc(p.NewHandler(config.HTTPConfig{RevealUnknownUsers: true}, mockAuth, logging.New("debug")).CreateUser)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest("user"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestCreateUserPolicyViolations(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug"))
+	creds := models.Credentials{Login: "test1", Password: "1"}
+	violations := &e.ValidationError{}
+	violations.Add("password", "too_short", "must be at least 8 characters")
+	violations.Add("password", "missing_symbol", "must contain a symbol")
+	mockAuth.EXPECT().CreateUser(gomock.Any(), &creds).Return(violations).Times(1)
+	mockAuth.EXPECT().UpdateUser(gomock.Any(), &creds).Return(violations).Times(1)
+
+	for _, handler := range []http.HandlerFunc{handlerObj.CreateUser, handlerObj.UpdateUser} {
+		rec := httptest.NewRecorder()
+		ctx := context.WithValue(context.TODO(), p.CrudCreds, &creds)
+		request, err := http.NewRequestWithContext(ctx, http.MethodPost, "/user", nil)
+		assert.NoError(t, err)
+		handler.ServeHTTP(rec, request)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		var answer p.ValidationMessage
+		err = json.Unmarshal(rec.Body.Bytes(), &answer)
+		assert.NoError(t, err)
+		assert.Equal(t, true, answer.IsError)
+		assert.Equal(t, []p.Violation{
+			{Field: "password", Code: "too_short", Message: "must be at least 8 characters"},
+			{Field: "password", Code: "missing_symbol", Message: "must contain a symbol"},
+		}, answer.Violations)
+	}
+}
+
 func TestGetUser(t *testing.T) {
 	cfg := &config.Config{
 		HTTP: config.HTTPConfig{
@@ -154,6 +211,39 @@ func TestGetUser(t *testing.T) {
 	assert.Equal(t, true, strings.Contains(targets2.Message, test2.Password))
 }
 
+func TestGetUserAccess(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "user", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "admin", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "admin", Roles: []string{p.AdminRole}}, nil).AnyTimes()
+	getUser := func(token, login string) int {
+		request := httptest.NewRequest(http.MethodGet, "/v1/user/"+login, nil)
+		if token != "" {
+			request.Header.Set("Cookie", "access="+token)
+		}
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, request)
+		return rec.Code
+	}
+
+	//anonymous callers can't find out which logins exist
+	assert.Equal(t, http.StatusForbidden, getUser("", "user"))
+	assert.Equal(t, http.StatusForbidden, getUser("", "nobody"))
+	assert.Equal(t, http.StatusForbidden, getUser("user", "other"))
+
+	mockAuth.EXPECT().GetUser(gomock.Any(), "user").Return(&models.Credentials{Login: "user"}, nil).Times(2)
+	assert.Equal(t, http.StatusOK, getUser("user", "user"))
+	assert.Equal(t, http.StatusOK, getUser("admin", "user"))
+}
+
 func TestUpdateUser(t *testing.T) {
 	cfg := &config.Config{
 		HTTP: config.HTTPConfig{
@@ -232,3 +322,26 @@ func TestDeleteUser(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, true, strings.Contains(targets.Message, test.Login))
 }
+
+func TestImportUsers(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.ImportUsers)
+
+	users := []models.ImportedUser{{Login: "old", Algorithm: "sha1", Hash: "sha1$salt$00"}}
+	result := &models.ImportResult{Failed: []models.ImportFailure{{Login: "old", Error: "password hash is corrupted"}}}
+	mockAuth.EXPECT().ImportUsers(gomock.Any(), users).Return(result).Times(1)
+	body, err := json.Marshal(users)
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/users/import", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var answer models.ImportResult
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &answer))
+	assert.Equal(t, *result, answer)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/users/import", strings.NewReader(`{"login":"old"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"net/http"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+
+	"github.com/go-chi/chi"
+)
+
+// TrustedDevices godoc
+// @Summary lists devices where user skips second factor
+// @Description Devices are trusted by rememberDevice of /login/mfa and /login/mfa/webauthn/finish
+// @Router /me/devices [get]
+// @Produce      json
+// @Success 200 {array} models.TrustedDevice
+// @Failure 404 {object} Message
+func (h *Handler) TrustedDevices(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	devices, err := h.auth.TrustedDevices(r.Context(), login)
+	if err != nil {
+		h.writeDeviceError(w, err, "h.TrustedDevices")
+		return
+	}
+	h.writeJSON(w, devices, "h.TrustedDevices")
+}
+
+// RevokeDevice godoc
+// @Summary makes user pass second factor on the device again
+// @Router /me/devices/{id} [delete]
+// @Param id path string true "device id"
+// @Produce      json
+// @Success 200 {object} Message
+// @Failure 404 {object} Message
+func (h *Handler) RevokeDevice(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err = h.auth.RevokeDevice(r.Context(), login, chi.URLParam(r, "id")); err != nil {
+		h.writeDeviceError(w, err, "h.RevokeDevice")
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "device is revoked")
+}
+
+// RevokeDevices godoc
+// @Summary makes user pass second factor on every device again
+// @Router /me/devices [delete]
+// @Produce      json
+// @Success 200 {object} Message
+// @Failure 404 {object} Message
+func (h *Handler) RevokeDevices(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err = h.auth.RevokeDevices(r.Context(), login); err != nil {
+		h.writeDeviceError(w, err, "h.RevokeDevices")
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "devices are revoked")
+}
+
+// deviceToken is token of trusted device from its cookie, "" when devices are not remembered
+func (h *Handler) deviceToken(r *http.Request) string {
+	if h.cfg.DeviceCookieName == "" {
+		return ""
+	}
+	cookies, err := GetCookieValue(r.Header["Cookie"])
+	if err != nil {
+		return ""
+	}
+	return cookies[h.cfg.DeviceCookieName]
+}
+
+// rememberDevice trusts device of login which has just passed second factor. Login goes on when it fails
+func (h *Handler) rememberDevice(w http.ResponseWriter, r *http.Request, login string) {
+	if h.cfg.DeviceCookieName == "" {
+		return
+	}
+	token, device, err := h.auth.TrustDevice(r.Context(), login, r.UserAgent())
+	if err != nil {
+		h.logger.Warn().Msgf("h.rememberDevice couldn't trust device of %s %s", login, err.Error())
+		return
+	}
+	SetPersistentCookie(w, h.cfg.DeviceCookieName, token, "/", device.ExpiresAt)
+}
+
+func (h *Handler) writeDeviceError(w http.ResponseWriter, err error, name string) {
+	switch err {
+	case e.ErrTrustedDevicesDisabled, e.ErrNoTrustedDevice:
+		WriteAnswer(w, http.StatusNotFound, err.Error())
+	default:
+		h.logger.Warn().Msgf("%s err: %s", name, err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+	}
+}
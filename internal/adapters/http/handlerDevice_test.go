@@ -0,0 +1,140 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	p "github.com/DMA8/authService/internal/adapters/http"
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	mock_ports "github.com/DMA8/authService/internal/mocks"
+	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRememberDevice(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		DeviceCookieName:  "device",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "pending", models.MFAPendingTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.AccessTokenType).Return("access", nil).AnyTimes()
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.RefreshTokenType).Return("refresh", nil).AnyTimes()
+	loginMFA := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/v1/login/mfa", strings.NewReader(body))
+		request.Header.Set("Cookie", "access=pending")
+		request.Header.Set("User-Agent", "laptop")
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, request)
+		return rec
+	}
+
+	//device is not remembered unless asked
+	mockAuth.EXPECT().VerifyMFA(gomock.Any(), "pending", "123456").Return("user", nil).Times(2)
+	rec := loginMFA(`{"code":"123456"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	for _, cookie := range rec.Result().Cookies() {
+		assert.NotEqual(t, "device", cookie.Name)
+	}
+
+	expires := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	mockAuth.EXPECT().TrustDevice(gomock.Any(), "user", "laptop").Return(
+		"trusted", &models.TrustedDevice{ID: "id", ExpiresAt: expires}, nil).Times(1)
+	rec = loginMFA(`{"code":"123456","rememberDevice":true}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var device *http.Cookie
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "device" {
+			device = cookie
+		}
+	}
+	if assert.NotNil(t, device) {
+		assert.Equal(t, "trusted", device.Value)
+		assert.True(t, device.HttpOnly)
+		assert.True(t, device.Expires.Equal(expires))
+		assert.Greater(t, device.MaxAge, 0)
+	}
+
+	//the cookie goes to the second factor check of login
+	mockAuth.EXPECT().AuthUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ *models.Credentials) error {
+			if models.DeviceToken(ctx) != "trusted" {
+				return e.ErrMFARequired
+			}
+			return nil
+		}).Times(2)
+	request := httptest.NewRequest(http.MethodPost, "/v1/login?login=user&password=password", nil)
+	request.Header.Set("Cookie", "device=trusted")
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var answer p.TestMessage
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &answer))
+	assert.Equal(t, "access", answer.AccessToken)
+
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.MFAPendingTokenType).Return("pending", nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/login?login=user&password=password", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestTrustedDevices(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		DeviceCookieName:  "device",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	newRequest := func(method, url string) *http.Request {
+		request := httptest.NewRequest(method, url, nil)
+		request.Header.Set("Cookie", "access=token")
+		return request
+	}
+
+	mockAuth.EXPECT().TrustedDevices(gomock.Any(), "user").Return(
+		[]models.TrustedDevice{{ID: "id", Login: "user", UserID: "1", Name: "laptop"}}, nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodGet, "/v1/me/devices"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var devices []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &devices))
+	if assert.Len(t, devices, 1) {
+		assert.Equal(t, "laptop", devices[0]["name"])
+		assert.NotContains(t, devices[0], "login")
+	}
+
+	mockAuth.EXPECT().RevokeDevice(gomock.Any(), "user", "other").Return(e.ErrNoTrustedDevice).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/devices/other"))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	mockAuth.EXPECT().RevokeDevice(gomock.Any(), "user", "id").Return(nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/devices/id"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().RevokeDevices(gomock.Any(), "user").Return(nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/devices"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
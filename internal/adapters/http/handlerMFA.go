@@ -0,0 +1,203 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// mfaRequired gives a token which is accepted only by /login/mfa
+func (h *Handler) mfaRequired(w http.ResponseWriter, r *http.Request, login string) {
+	token, err := h.auth.CreateToken(r.Context(), login, models.MFAPendingTokenType)
+	if err != nil {
+		h.logger.Warn().Msgf("h.Login couldn't create mfa token %s", err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	SetCookie(w, h.cfg.AccessCookieName, token, "/")
+	sendCookie(w, e.ErrMFARequired.Error(), token, "", http.StatusUnauthorized)
+}
+
+// LoginMFA godoc
+// @Summary second step of login for users with second factor
+// @Description Takes mfa token given by /login, from body or access cookie, and TOTP, recovery code
+// @Description or one-time code sent by /login/mfa/otp. Wrong codes count as failed logins, a few of them end the login
+// @Description With rememberDevice the device gets a cookie letting /login skip this step until it expires or is revoked
+// @Router /login/mfa [post]
+// @Accept       json
+// @Produce      json
+// @Param input body LoginMFARequest true "mfa token and code"
+// @Success 200 {object} TestMessage
+// @Failure 401 {object} Message
+// @Failure 403 {object} Message
+func (h *Handler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var mfaReq LoginMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&mfaReq); err != nil || mfaReq.Code == "" {
+		h.logger.Debug().Msg("h.LoginMFA bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed code")
+		return
+	}
+	if mfaReq.MFAToken = h.mfaToken(w, r, mfaReq.MFAToken, "h.LoginMFA"); mfaReq.MFAToken == "" {
+		return
+	}
+	ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+	login, err := h.auth.VerifyMFA(ctx, mfaReq.MFAToken, mfaReq.Code)
+	switch err {
+	case nil:
+		if mfaReq.RememberDevice {
+			h.rememberDevice(w, r, login)
+		}
+		h.startSession(w, r, login, models.AMRPassword, models.AMROTP)
+	case e.ErrPasswordChangeRequired:
+		h.passwordChangeRequired(w, r, login)
+	default:
+		h.writeMFAError(w, err, "h.LoginMFA")
+	}
+}
+
+// mfaToken checks token given by /login, from body or access cookie. Bad token is answered here and "" returned
+func (h *Handler) mfaToken(w http.ResponseWriter, r *http.Request, token, name string) string {
+	if token == "" {
+		if cookies, err := GetCookieValue(r.Header["Cookie"]); err == nil {
+			token = cookies[h.cfg.AccessCookieName]
+		}
+	}
+	if _, err := h.auth.ValidateToken(r.Context(), token, models.MFAPendingTokenType); err != nil {
+		h.logger.Debug().Msgf("%s bad mfa token %s", name, err.Error())
+		WriteAnswer(w, http.StatusUnauthorized, "mfa token is invalid or expired, login again")
+		return ""
+	}
+	return token
+}
+
+// EnrollTOTP godoc
+// @Summary starts TOTP enrollment
+// @Description Makes a new secret for authenticator app. It is used at login after /me/mfa/totp/confirm
+// @Router /me/mfa/totp [post]
+// @Produce      json
+// @Success 200 {object} models.TOTPEnrollment
+// @Failure 409 {object} Message
+func (h *Handler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	enrollment, err := h.auth.EnrollTOTP(r.Context(), login)
+	if err != nil {
+		h.writeMFAError(w, err, "h.EnrollTOTP")
+		return
+	}
+	if err = json.NewEncoder(w).Encode(enrollment); err != nil {
+		h.logger.Warn().Msgf("h.EnrollTOTP couldn't write answer %s", err.Error())
+	}
+}
+
+// ConfirmTOTP godoc
+// @Summary turns on second factor
+// @Description Takes a code from authenticator app and returns recovery codes, they are shown only once
+// @Router /me/mfa/totp/confirm [post]
+// @Accept       json
+// @Produce      json
+// @Param input body MFACodeRequest true "code from authenticator app"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 403 {object} Message
+func (h *Handler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	h.withMFACode(w, r, "h.ConfirmTOTP", func(login, code string) error {
+		codes, err := h.auth.ConfirmTOTP(r.Context(), login, code)
+		if err == nil {
+			writeRecoveryCodes(w, codes)
+		}
+		return err
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary replaces recovery codes
+// @Description Takes TOTP or recovery code, old recovery codes stop working
+// @Router /me/mfa/recovery-codes [post]
+// @Accept       json
+// @Produce      json
+// @Param input body MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 403 {object} Message
+func (h *Handler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	h.withMFACode(w, r, "h.RegenerateRecoveryCodes", func(login, code string) error {
+		ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+		codes, err := h.auth.RegenerateRecoveryCodes(ctx, login, code)
+		if err == nil {
+			writeRecoveryCodes(w, codes)
+		}
+		return err
+	})
+}
+
+// DisableMFA godoc
+// @Summary turns off second factor
+// @Description Takes TOTP or recovery code
+// @Router /me/mfa [delete]
+// @Accept       json
+// @Produce      json
+// @Param input body MFACodeRequest true "TOTP or recovery code"
+// @Success 200 {object} Message
+// @Failure 403 {object} Message
+func (h *Handler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	h.withMFACode(w, r, "h.DisableMFA", func(login, code string) error {
+		ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+		err := h.auth.DisableMFA(ctx, login, code)
+		if err == nil {
+			WriteAnswer(w, http.StatusOK, "second factor is disabled")
+		}
+		return err
+	})
+}
+
+// withMFACode runs action with login from token and code from body, errors of action are answered here
+func (h *Handler) withMFACode(w http.ResponseWriter, r *http.Request, name string, action func(login, code string) error) {
+	defer r.Body.Close()
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	var codeReq MFACodeRequest
+	if err = json.NewDecoder(r.Body).Decode(&codeReq); err != nil || codeReq.Code == "" {
+		h.logger.Debug().Msgf("%s bad input", name)
+		WriteAnswer(w, http.StatusBadRequest, "missed code")
+		return
+	}
+	if err = action(login, codeReq.Code); err != nil {
+		h.writeMFAError(w, err, name)
+	}
+}
+
+func (h *Handler) writeMFAError(w http.ResponseWriter, err error, name string) {
+	if writeRetryError(w, err) {
+		h.logger.Info().Msgf("%s %s", name, err.Error())
+		return
+	}
+	switch err {
+	case e.ErrBadMFACode:
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+	case e.ErrMFAEnabled:
+		WriteAnswer(w, http.StatusConflict, err.Error())
+	case e.ErrMFANotEnabled, e.ErrMFANotEnrolled, e.ErrBadOTPChannel, e.ErrBadOTPAddress:
+		WriteAnswer(w, http.StatusBadRequest, err.Error())
+	case e.ErrNoNotifier:
+		WriteAnswer(w, http.StatusNotFound, err.Error())
+	default:
+		h.logger.Warn().Msgf("%s err: %s", name, err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func writeRecoveryCodes(w http.ResponseWriter, codes []string) {
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,129 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	p "github.com/DMA8/authService/internal/adapters/http"
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	mock_ports "github.com/DMA8/authService/internal/mocks"
+	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginMFA(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+
+	//password gives only mfa token
+	mockAuth.EXPECT().AuthUser(gomock.Any(), gomock.Any()).Return(e.ErrMFARequired).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.MFAPendingTokenType).Return("pending", nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/login?login=user&password=password", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	var answer p.TestMessage
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &answer))
+	assert.Equal(t, "pending", answer.AccessToken)
+	assert.Empty(t, answer.RefreshToken)
+
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "pending", models.MFAPendingTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "stale", models.MFAPendingTokenType).Return(
+		nil, e.ErrTokenRevoked).AnyTimes()
+	newRequest := func(body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/v1/login/mfa", strings.NewReader(body))
+		request.Header.Set("Cookie", "access=pending")
+		return request
+	}
+
+	mockAuth.EXPECT().VerifyMFA(gomock.Any(), "pending", "000000").Return("", e.ErrBadMFACode).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(`{"code":"000000"}`))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(`{"mfaToken":"stale","code":"123456"}`))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	//the code gives real session
+	mockAuth.EXPECT().VerifyMFA(gomock.Any(), "pending", "123456").Return("user", nil).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.AccessTokenType).Return("access", nil).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.RefreshTokenType).Return("refresh", nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(`{"code":"123456"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Values("Set-Cookie"), "refresh=refresh; Path=/; HttpOnly")
+}
+
+func TestMFAEnrollment(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	newRequest := func(method, url, body string) *http.Request {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("Cookie", "access=token")
+		return request
+	}
+
+	mockAuth.EXPECT().EnrollTOTP(gomock.Any(), "user").Return(
+		&models.TOTPEnrollment{Secret: "SECRET", URI: "otpauth://totp/team31:user?secret=SECRET"}, nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/mfa/totp", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var enrollment models.TOTPEnrollment
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &enrollment))
+	assert.Equal(t, "SECRET", enrollment.Secret)
+
+	mockAuth.EXPECT().ConfirmTOTP(gomock.Any(), "user", "123456").Return([]string{"aaaa-bbbb"}, nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/mfa/totp/confirm", `{"code":"123456"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var codes p.RecoveryCodesResponse
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &codes))
+	assert.Equal(t, []string{"aaaa-bbbb"}, codes.RecoveryCodes)
+
+	mockAuth.EXPECT().EnrollTOTP(gomock.Any(), "user").Return(nil, e.ErrMFAEnabled).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/mfa/totp", ""))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	mockAuth.EXPECT().RegenerateRecoveryCodes(gomock.Any(), "user", "aaaa-bbbb").Return([]string{"cccc-dddd"}, nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/mfa/recovery-codes", `{"code":"aaaa-bbbb"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().DisableMFA(gomock.Any(), "user", "654321").Return(e.ErrBadMFACode).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/mfa", `{"code":"654321"}`))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/mfa", `{}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mockAuth.EXPECT().DisableMFA(gomock.Any(), "user", "123456").Return(nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/mfa", `{"code":"123456"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
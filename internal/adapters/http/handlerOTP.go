@@ -0,0 +1,105 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// SendMFACode godoc
+// @Summary sends one-time code for the second step of login
+// @Description Takes mfa token given by /login, from body or access cookie. The code is entered at /login/mfa
+// @Router /login/mfa/otp [post]
+// @Accept       json
+// @Produce      json
+// @Param input body MFATokenRequest false "mfa token"
+// @Success 200 {object} Message
+// @Failure 400 {object} Message
+// @Failure 401 {object} Message
+// @Failure 429 {object} Message
+func (h *Handler) SendMFACode(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var tokenReq MFATokenRequest
+	// token may come in cookie only
+	json.NewDecoder(r.Body).Decode(&tokenReq)
+	token := h.mfaToken(w, r, tokenReq.MFAToken, "h.SendMFACode")
+	if token == "" {
+		return
+	}
+	if err := h.auth.SendMFACode(r.Context(), token); err != nil {
+		h.writeMFAError(w, err, "h.SendMFACode")
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "code is sent")
+}
+
+// EnrollOTP godoc
+// @Summary starts enrollment of one-time codes by email or sms
+// @Description Sends a code to the address, it is used at login after /me/mfa/otp/confirm
+// @Router /me/mfa/otp [post]
+// @Accept       json
+// @Produce      json
+// @Param input body OTPEnrollRequest true "channel and address"
+// @Success 200 {object} Message
+// @Failure 400 {object} Message
+// @Failure 409 {object} Message
+// @Failure 429 {object} Message
+func (h *Handler) EnrollOTP(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	var enrollReq OTPEnrollRequest
+	if err = json.NewDecoder(r.Body).Decode(&enrollReq); err != nil {
+		h.logger.Debug().Msg("h.EnrollOTP bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed channel and address")
+		return
+	}
+	if err = h.auth.EnrollOTP(r.Context(), login, enrollReq.Channel, enrollReq.Address); err != nil {
+		h.writeMFAError(w, err, "h.EnrollOTP")
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "code is sent")
+}
+
+// ConfirmOTP godoc
+// @Summary turns on one-time codes
+// @Description Takes the code sent by /me/mfa/otp
+// @Router /me/mfa/otp/confirm [post]
+// @Accept       json
+// @Produce      json
+// @Param input body MFACodeRequest true "one-time code"
+// @Success 200 {object} Message
+// @Failure 403 {object} Message
+func (h *Handler) ConfirmOTP(w http.ResponseWriter, r *http.Request) {
+	h.withMFACode(w, r, "h.ConfirmOTP", func(login, code string) error {
+		err := h.auth.ConfirmOTP(r.Context(), login, code)
+		if err == nil {
+			WriteAnswer(w, http.StatusOK, "one-time codes are enabled")
+		}
+		return err
+	})
+}
+
+// DisableOTP godoc
+// @Summary turns off one-time codes
+// @Router /me/mfa/otp [delete]
+// @Produce      json
+// @Success 200 {object} Message
+// @Failure 400 {object} Message
+func (h *Handler) DisableOTP(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err = h.auth.DisableOTP(r.Context(), login); err != nil {
+		h.writeMFAError(w, err, "h.DisableOTP")
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "one-time codes are disabled")
+}
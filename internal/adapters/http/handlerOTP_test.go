@@ -0,0 +1,109 @@
+package http_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	p "github.com/DMA8/authService/internal/adapters/http"
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	mock_ports "github.com/DMA8/authService/internal/mocks"
+	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendMFACode(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "pending", models.MFAPendingTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "stale", models.MFAPendingTokenType).Return(
+		nil, e.ErrTokenRevoked).AnyTimes()
+	newRequest := func(body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/v1/login/mfa/otp", strings.NewReader(body))
+		request.Header.Set("Cookie", "access=pending")
+		return request
+	}
+
+	mockAuth.EXPECT().SendMFACode(gomock.Any(), "pending").Return(nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().SendMFACode(gomock.Any(), "pending").Return(
+		&e.RetryError{Err: e.ErrOTPSent, RetryAfter: 20 * time.Second}).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(`{"mfaToken":"pending"}`))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "20", rec.Header().Get("Retry-After"))
+
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(`{"mfaToken":"stale"}`))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	//the code is entered at the same step as TOTP
+	mockAuth.EXPECT().VerifyMFA(gomock.Any(), "pending", "482913").Return("user", nil).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.AccessTokenType).Return("access", nil).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.RefreshTokenType).Return("refresh", nil).Times(1)
+	rec = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/v1/login/mfa", strings.NewReader(`{"code":"482913"}`))
+	request.Header.Set("Cookie", "access=pending")
+	server.Handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestOTPEnrollment(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	newRequest := func(method, url, body string) *http.Request {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("Cookie", "access=token")
+		return request
+	}
+
+	mockAuth.EXPECT().EnrollOTP(gomock.Any(), "user", models.SMSChannel, "5550100").Return(e.ErrBadOTPAddress).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/mfa/otp", `{"channel":"sms","address":"5550100"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mockAuth.EXPECT().EnrollOTP(gomock.Any(), "user", models.SMSChannel, "+15550100").Return(nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/mfa/otp", `{"channel":"sms","address":"+15550100"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().ConfirmOTP(gomock.Any(), "user", "000000").Return(e.ErrBadMFACode).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/mfa/otp/confirm", `{"code":"000000"}`))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	mockAuth.EXPECT().ConfirmOTP(gomock.Any(), "user", "482913").Return(nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/mfa/otp/confirm", `{"code":"482913"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().DisableOTP(gomock.Any(), "user").Return(nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/mfa/otp", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,118 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// forgotPasswordAnswer is the same for known and unknown logins
+const forgotPasswordAnswer = "if the login exists, a password reset token has been sent"
+
+// ForgotPassword godoc
+// @Summary sends password reset token
+// @Description Sends short-lived single-use token through notifier.
+// @Description The answer doesn't tell whether the login exists
+// @Router /password/forgot [post]
+// @Accept       json
+// @Produce      json
+// @Param input body ForgotPasswordRequest true "login to reset password of"
+// @Success 202 {object} Message
+func (h *Handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var forgotReq ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&forgotReq); err != nil || forgotReq.Login == "" {
+		h.logger.Debug().Msg("h.ForgotPassword bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed login")
+		return
+	}
+	// failures are not told either, they may depend on whether the login exists
+	if err := h.auth.ForgotPassword(r.Context(), forgotReq.Login); err != nil {
+		h.logger.Warn().Msgf("h.ForgotPassword couldn't send reset token %s", err.Error())
+	}
+	WriteAnswer(w, http.StatusAccepted, forgotPasswordAnswer)
+}
+
+// ResetPassword godoc
+// @Summary sets new password with reset token
+// @Description Consumes reset token from /password/forgot and ends every session of the user
+// @Router /password/reset [post]
+// @Accept       json
+// @Produce      json
+// @Param input body ResetPasswordRequest true "reset token and new password"
+// @Success 200 {object} Message
+// @Failure 400 {object} ValidationMessage
+func (h *Handler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var resetReq ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&resetReq); err != nil || resetReq.Token == "" || resetReq.Password == "" {
+		h.logger.Debug().Msg("h.ResetPassword bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed token or password")
+		return
+	}
+	err := h.auth.ResetPassword(r.Context(), resetReq.Token, resetReq.Password)
+	if writeValidationError(w, err) {
+		h.logger.Debug().Msgf("h.ResetPassword err: %s", err.Error())
+		return
+	} else if err == e.ErrBadResetToken {
+		WriteAnswer(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		h.logger.Warn().Msgf("h.ResetPassword couldn't reset password %s", err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "password reset")
+}
+
+// ChangePassword godoc
+// @Summary changes password of the logged in user
+// @Description Requires the current password. Every other session of the user ends,
+// @Description the caller gets tokens of a new session in cookies
+// @Router /me/password [post]
+// @Accept       json
+// @Produce      json
+// @Param input body ChangePasswordRequest true "current and new passwords"
+// @Success 200 {object} TestMessage
+// @Failure 400 {object} ValidationMessage
+// @Failure 403 {object} Message
+// @Failure 423 {object} Message
+// @Failure 429 {object} Message
+func (h *Handler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var changeReq ChangePasswordRequest
+	if err = json.NewDecoder(r.Body).Decode(&changeReq); err != nil || changeReq.CurrentPassword == "" || changeReq.NewPassword == "" {
+		h.logger.Debug().Msg("h.ChangePassword bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed current or new password")
+		return
+	}
+	ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+	pair, err := h.auth.ChangePassword(ctx, login, changeReq.CurrentPassword, changeReq.NewPassword)
+	if writeRetryError(w, err) {
+		h.logger.Info().Msgf("h.ChangePassword %s", err.Error())
+		return
+	} else if writeValidationError(w, err) {
+		h.logger.Debug().Msgf("h.ChangePassword err: %s", err.Error())
+		return
+	} else if err == e.ErrWrongPass {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	} else if err != nil {
+		h.logger.Warn().Msgf("h.ChangePassword couldn't change password of %s %s", login, err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	SetCookie(w, h.cfg.AccessCookieName, pair.AccessToken, "/")
+	SetCookie(w, h.cfg.RefreshCookieName, pair.RefreshToken, "/")
+	sendCookie(w, "password changed", pair.AccessToken, pair.RefreshToken, http.StatusOK)
+}
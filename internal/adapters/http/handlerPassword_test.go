@@ -0,0 +1,228 @@
+package http_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	p "github.com/DMA8/authService/internal/adapters/http"
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	mock_ports "github.com/DMA8/authService/internal/mocks"
+	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForgotPassword(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.ForgotPassword)
+
+	//known and unknown logins get the same answer
+	mockAuth.EXPECT().ForgotPassword(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+	var answers []string
+	for _, login := range []string{"admin", "nobody"} {
+		rec := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"login":"`+login+`"}`))
+		handler.ServeHTTP(rec, request)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		answers = append(answers, rec.Body.String())
+	}
+	assert.Equal(t, answers[0], answers[1])
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	//failure looks the same as well
+	mockAuth.EXPECT().ForgotPassword(gomock.Any(), "admin").Return(errors.New("db is down")).Times(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"login":"admin"}`)))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, answers[0], rec.Body.String())
+}
+
+func TestResetPassword(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.ResetPassword)
+	body := `{"token":"token","password":"new password"}`
+
+	mockAuth.EXPECT().ResetPassword(gomock.Any(), "token", "new password").Return(nil).Times(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().ResetPassword(gomock.Any(), "token", "new password").Return(e.ErrBadResetToken).Times(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	var violations e.ValidationError
+	violations.Add("password", "too_short", "must be at least 8 characters")
+	mockAuth.EXPECT().ResetPassword(gomock.Any(), "token", "new password").Return(violations.Err()).Times(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	var answer p.ValidationMessage
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &answer))
+	assert.Equal(t, "too_short", answer.Violations[0].Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token":"token"}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestChangePassword(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	newRequest := func(body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/v1/me/password", strings.NewReader(body))
+		request.Header.Set("Cookie", "access=token")
+		return request
+	}
+	body := `{"currentPassword":"old password","newPassword":"new password"}`
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.PasswordChangeTokenType).Return(
+		nil, e.ErrWrongTokenType).AnyTimes()
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+
+	mockAuth.EXPECT().ChangePassword(gomock.Any(), "user", "old password", "new password").Return(
+		&models.TokenPair{Login: "user", AccessToken: "newAccess", RefreshToken: "newRefresh"}, nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Values("Set-Cookie"), "refresh=newRefresh; Path=/; HttpOnly")
+
+	mockAuth.EXPECT().ChangePassword(gomock.Any(), "user", "old password", "new password").Return(nil, e.ErrWrongPass).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(body))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	//wrong current passwords lock the login
+	mockAuth.EXPECT().ChangePassword(gomock.Any(), "user", "old password", "new password").Return(
+		nil, &e.RetryError{Err: e.ErrAccountLocked, RetryAfter: time.Minute}).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(body))
+	assert.Equal(t, http.StatusLocked, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(`{"newPassword":"new password"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	//without token
+	rec = httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/v1/me/password", strings.NewReader(body))
+	server.Handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestPasswordChangeRequired(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+
+	//login gives only restricted token
+	mockAuth.EXPECT().AuthUser(gomock.Any(), gomock.Any()).Return(e.ErrPasswordChangeRequired).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.PasswordChangeTokenType).Return("restricted", nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/login?login=user&password=temporary", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Header().Values("Set-Cookie"), "access=restricted; Path=/; HttpOnly")
+	assert.Contains(t, rec.Body.String(), e.ErrPasswordChangeRequired.Error())
+
+	newRequest := func(method, url, body string) *http.Request {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("Cookie", "access=restricted")
+		return request
+	}
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "restricted", models.PasswordChangeTokenType).Return(
+		&tokens.Claims{Subject: "user", TokenType: string(models.PasswordChangeTokenType)}, nil).AnyTimes()
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "restricted", models.AccessTokenType).Return(
+		nil, e.ErrWrongTokenType).AnyTimes()
+	mockAuth.EXPECT().RefreshTokens(gomock.Any(), gomock.Any()).Return(nil, e.ErrWrongTokenType).AnyTimes()
+
+	//restricted token is not accepted by other endpoints
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodGet, "/v1/i", ""))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	mockAuth.EXPECT().ChangePassword(gomock.Any(), "user", "temporary", "permanent password").Return(
+		&models.TokenPair{Login: "user", AccessToken: "newAccess", RefreshToken: "newRefresh"}, nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/password",
+		`{"currentPassword":"temporary","newPassword":"permanent password"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Values("Set-Cookie"), "access=newAccess; Path=/; HttpOnly")
+}
+
+func TestExpirePassword(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handler := http.HandlerFunc(p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug")).ExpirePassword)
+
+	mockAuth.EXPECT().ExpirePassword(gomock.Any(), "user").Return(nil).Times(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/password/expire", strings.NewReader(`{"login":"user"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().ExpirePassword(gomock.Any(), "nobody").Return(e.ErrNoUserInDB).Times(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/password/expire", strings.NewReader(`{"login":"nobody"}`)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/password/expire", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUpdateUserRequiresAdmin(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	newRequest := func() *http.Request {
+		request := httptest.NewRequest(http.MethodPut, "/v1/user", strings.NewReader(`{"login":"victim","password":"new password"}`))
+		request.Header.Set("Cookie", "access=token")
+		return request
+	}
+
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest())
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "root", Roles: []string{p.AdminRole}}, nil).Times(1)
+	mockAuth.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest())
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
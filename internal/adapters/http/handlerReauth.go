@@ -0,0 +1,144 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// Reauthenticate godoc
+// @Summary authenticates logged in user again
+// @Description Takes password, and TOTP, recovery or one-time code sent by /me/reauth/otp when user has them.
+// @Description Tokens of the session are replaced by tokens with fresh auth_time, routes answering
+// @Description 401 insufficient_user_authentication accept them. Failures count as failed logins.
+// @Description User whose only second factor is a security key gets 401 and uses /me/reauth/webauthn
+// @Router /me/reauth [post]
+// @Accept       json
+// @Produce      json
+// @Param input body ReauthRequest true "password and code"
+// @Success 200 {object} TestMessage
+// @Failure 401 {object} Message
+// @Failure 403 {object} Message
+// @Failure 429 {object} Message
+func (h *Handler) Reauthenticate(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	var reauthReq ReauthRequest
+	if err = json.NewDecoder(r.Body).Decode(&reauthReq); err != nil || reauthReq.Password == "" {
+		h.logger.Debug().Msg("h.Reauthenticate bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed password")
+		return
+	}
+	ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+	amr, err := h.auth.Reauthenticate(ctx, login, reauthReq.Password, reauthReq.Code)
+	switch err {
+	case nil:
+		h.upgradeSession(w, r, login, amr)
+	case e.ErrWrongPass, e.ErrNoUserInDB:
+		h.logger.Debug().Msgf("h.Reauthenticate %s: %s", login, err.Error())
+		WriteAnswer(w, http.StatusForbidden, e.ErrWrongPass.Error())
+	case e.ErrMFARequired:
+		WriteAnswer(w, http.StatusUnauthorized, err.Error())
+	default:
+		h.writeMFAError(w, err, "h.Reauthenticate")
+	}
+}
+
+// SendReauthCode godoc
+// @Summary sends one-time code for /me/reauth
+// @Description Codes are not sent more often than resend interval, 429 with Retry-After then
+// @Router /me/reauth/otp [post]
+// @Produce      json
+// @Success 200 {object} Message
+// @Failure 400 {object} Message
+// @Failure 429 {object} Message
+func (h *Handler) SendReauthCode(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err = h.auth.SendReauthCode(r.Context(), login); err != nil {
+		h.writeMFAError(w, err, "h.SendReauthCode")
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "code is sent")
+}
+
+// BeginWebAuthnReauth godoc
+// @Summary starts authenticating logged in user again with passkey or security key
+// @Description Returns options for navigator.credentials.get(), user verification is required
+// @Router /me/reauth/webauthn/begin [post]
+// @Produce      json
+// @Success 200 {object} webauthn.RequestOptions
+// @Failure 404 {object} Message
+func (h *Handler) BeginWebAuthnReauth(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	options, err := h.auth.BeginWebAuthnReauth(r.Context(), login)
+	if err != nil {
+		h.writeWebAuthnError(w, err, "h.BeginWebAuthnReauth")
+		return
+	}
+	h.writeJSON(w, options, "h.BeginWebAuthnReauth")
+}
+
+// FinishWebAuthnReauth godoc
+// @Summary authenticates logged in user again with passkey or security key
+// @Description Takes answer of navigator.credentials.get() and replaces tokens of the session like /me/reauth does.
+// @Description Failures count as failed logins
+// @Router /me/reauth/webauthn/finish [post]
+// @Accept       json
+// @Produce      json
+// @Param input body WebAuthnAssertionRequest true "credential"
+// @Success 200 {object} TestMessage
+// @Failure 400 {object} Message
+// @Failure 403 {object} Message
+func (h *Handler) FinishWebAuthnReauth(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	var assertionReq WebAuthnAssertionRequest
+	if err = json.NewDecoder(r.Body).Decode(&assertionReq); err != nil {
+		h.logger.Debug().Msg("h.FinishWebAuthnReauth bad input")
+		WriteAnswer(w, http.StatusBadRequest, "bad credential")
+		return
+	}
+	ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+	amr, err := h.auth.FinishWebAuthnReauth(ctx, login, &assertionReq.Credential)
+	if err != nil {
+		h.writeWebAuthnError(w, err, "h.FinishWebAuthnReauth")
+		return
+	}
+	h.upgradeSession(w, r, login, amr)
+}
+
+// upgradeSession revokes tokens of the session and gives new ones carrying amr of re-authentication
+func (h *Handler) upgradeSession(w http.ResponseWriter, r *http.Request, login string, amr []string) {
+	if cookies, err := GetCookieValue(r.Header["Cookie"]); err == nil {
+		err = h.auth.RevokeTokens(r.Context(), cookies[h.cfg.AccessCookieName], cookies[h.cfg.RefreshCookieName])
+		if err != nil && err != e.ErrNoTokensToRevoke {
+			h.logger.Warn().Msgf("h.upgradeSession couldn't revoke tokens of %s %s", login, err.Error())
+			WriteAnswer(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	h.logger.Info().Msgf("h.upgradeSession %s authenticated again with %v", login, amr)
+	h.startSession(w, r, login, amr...)
+}
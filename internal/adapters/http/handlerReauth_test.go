@@ -0,0 +1,139 @@
+package http_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	p "github.com/DMA8/authService/internal/adapters/http"
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	mock_ports "github.com/DMA8/authService/internal/mocks"
+	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStepUpRequired(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+		StepUpACR:         models.ACRMultiFactor,
+		StepUpMaxAge:      5 * time.Minute,
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	sessions := map[string]*tokens.Claims{
+		"weak":   {Subject: "user", ACR: models.ACRSingleFactor, AuthTime: time.Now()},
+		"old":    {Subject: "user", ACR: models.ACRMultiFactor, AuthTime: time.Now().Add(-time.Hour)},
+		"strong": {Subject: "user", ACR: models.ACRMultiFactor, AuthTime: time.Now()},
+		"other":  {Subject: "mallory", ACR: models.ACRMultiFactor, AuthTime: time.Now()},
+	}
+	for token, claims := range sessions {
+		mockAuth.EXPECT().ValidateToken(gomock.Any(), token, models.AccessTokenType).Return(claims, nil).AnyTimes()
+	}
+	deleteUser := func(token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodDelete, "/v1/user/user", nil)
+		request.Header.Set("Cookie", "access="+token)
+		rec := httptest.NewRecorder()
+		server.Handler.ServeHTTP(rec, request)
+		return rec
+	}
+
+	for _, token := range []string{"weak", "old"} {
+		rec := deleteUser(token)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code, token)
+		assert.Equal(t, `Bearer error="insufficient_user_authentication", `+
+			`error_description="recent stronger authentication required", acr_values="aal2", max_age=300`,
+			rec.Header().Get("WWW-Authenticate"))
+		var answer p.StepUpRequired
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &answer))
+		assert.Equal(t, "insufficient_user_authentication", answer.Error)
+		assert.Equal(t, models.ACRMultiFactor, answer.ACR)
+		assert.Equal(t, int64(300), answer.MaxAge)
+	}
+
+	//fresh session can't delete somebody else
+	assert.Equal(t, http.StatusForbidden, deleteUser("other").Code)
+
+	mockAuth.EXPECT().DeleteUser(gomock.Any(), "user").Return(nil).Times(1)
+	assert.Equal(t, http.StatusOK, deleteUser("strong").Code)
+}
+
+func TestReauthenticate(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "user", ACR: models.ACRSingleFactor}, nil).AnyTimes()
+	newRequest := func(url, body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		request.Header.Set("Cookie", "access=token; refresh=session")
+		return request
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/me/reauth", `{"code":"482913"}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mockAuth.EXPECT().Reauthenticate(gomock.Any(), "user", "wrong", "").Return(nil, e.ErrWrongPass).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/me/reauth", `{"password":"wrong"}`))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	mockAuth.EXPECT().Reauthenticate(gomock.Any(), "user", "password", "").Return(nil, e.ErrMFARequired).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/me/reauth", `{"password":"password"}`))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	//tokens of the session are replaced by tokens carrying methods of re-authentication
+	mockAuth.EXPECT().Reauthenticate(gomock.Any(), "user", "password", "482913").Return(
+		[]string{models.AMRPassword, models.AMROTP}, nil).Times(1)
+	mockAuth.EXPECT().RevokeTokens(gomock.Any(), "token", "session").Return(nil).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ string, tokenType models.TokenType) (string, error) {
+			assert.Equal(t, []string{models.AMRPassword, models.AMROTP}, models.AuthMethods(ctx))
+			return "upgraded-" + string(tokenType), nil
+		}).Times(2)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/me/reauth", `{"password":"password","code":"482913"}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Values("Set-Cookie"), "access=upgraded-access; Path=/; HttpOnly")
+	assert.Contains(t, rec.Header().Values("Set-Cookie"), "refresh=upgraded-refresh; Path=/; HttpOnly")
+
+	mockAuth.EXPECT().SendReauthCode(gomock.Any(), "user").Return(e.ErrMFANotEnabled).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/me/reauth/otp", ""))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mockAuth.EXPECT().FinishWebAuthnReauth(gomock.Any(), "user", gomock.Any()).Return(nil, e.ErrWebAuthnFailed).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/me/reauth/webauthn/finish", `{"credential":{"id":"cred"}}`))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	mockAuth.EXPECT().FinishWebAuthnReauth(gomock.Any(), "user", gomock.Any()).Return(
+		[]string{models.AMRHardwareKey}, nil).Times(1)
+	mockAuth.EXPECT().RevokeTokens(gomock.Any(), "token", "session").Return(nil).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ string, _ models.TokenType) (string, error) {
+			assert.Equal(t, []string{models.AMRHardwareKey}, models.AuthMethods(ctx))
+			return "upgraded", nil
+		}).Times(2)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/me/reauth/webauthn/finish", `{"credential":{"id":"cred"}}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,256 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"github.com/go-chi/chi"
+)
+
+// BeginWebAuthnRegistration godoc
+// @Summary starts registration of passkey or security key
+// @Description Returns options for navigator.credentials.create(), binary fields are base64url
+// @Router /me/webauthn/register/begin [post]
+// @Produce      json
+// @Success 200 {object} webauthn.CreationOptions
+// @Failure 404 {object} Message
+func (h *Handler) BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	options, err := h.auth.BeginWebAuthnRegistration(r.Context(), login)
+	if err != nil {
+		h.writeWebAuthnError(w, err, "h.BeginWebAuthnRegistration")
+		return
+	}
+	h.writeJSON(w, options, "h.BeginWebAuthnRegistration")
+}
+
+// FinishWebAuthnRegistration godoc
+// @Summary finishes registration of passkey or security key
+// @Description Takes answer of navigator.credentials.create(). Attestation formats "none" and "packed" are accepted
+// @Router /me/webauthn/register/finish [post]
+// @Accept       json
+// @Produce      json
+// @Param input body WebAuthnRegistrationRequest true "credential and its name"
+// @Success 200 {object} models.WebAuthnCredential
+// @Failure 400 {object} Message
+// @Failure 403 {object} Message
+// @Failure 409 {object} Message
+func (h *Handler) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	var regReq WebAuthnRegistrationRequest
+	if err = json.NewDecoder(r.Body).Decode(&regReq); err != nil {
+		h.logger.Debug().Msg("h.FinishWebAuthnRegistration bad input")
+		WriteAnswer(w, http.StatusBadRequest, "bad credential")
+		return
+	}
+	credential, err := h.auth.FinishWebAuthnRegistration(r.Context(), login, regReq.Name, &regReq.Credential)
+	if err != nil {
+		h.writeWebAuthnError(w, err, "h.FinishWebAuthnRegistration")
+		return
+	}
+	h.writeJSON(w, credential, "h.FinishWebAuthnRegistration")
+}
+
+// WebAuthnCredentials godoc
+// @Summary lists passkeys and security keys of user
+// @Router /me/webauthn/credentials [get]
+// @Produce      json
+// @Success 200 {array} models.WebAuthnCredential
+func (h *Handler) WebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	credentials, err := h.auth.WebAuthnCredentials(r.Context(), login)
+	if err != nil {
+		h.writeWebAuthnError(w, err, "h.WebAuthnCredentials")
+		return
+	}
+	h.writeJSON(w, credentials, "h.WebAuthnCredentials")
+}
+
+// DeleteWebAuthnCredential godoc
+// @Summary removes passkey or security key of user
+// @Router /me/webauthn/credentials/{id} [delete]
+// @Param id path string true "credential id"
+// @Produce      json
+// @Success 200 {object} Message
+// @Failure 404 {object} Message
+func (h *Handler) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	initHeaders(w)
+	login, err := GetLoginFromCtx(r.Context())
+	if err != nil {
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err = h.auth.DeleteWebAuthnCredential(r.Context(), login, chi.URLParam(r, "id")); err != nil {
+		h.writeWebAuthnError(w, err, "h.DeleteWebAuthnCredential")
+		return
+	}
+	WriteAnswer(w, http.StatusOK, "credential is deleted")
+}
+
+// BeginWebAuthnLogin godoc
+// @Summary starts passwordless login
+// @Description Returns options for navigator.credentials.get(). Without login user picks a passkey
+// @Router /login/webauthn/begin [post]
+// @Accept       json
+// @Produce      json
+// @Param input body WebAuthnLoginRequest false "login"
+// @Success 200 {object} webauthn.RequestOptions
+// @Failure 404 {object} Message
+func (h *Handler) BeginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var loginReq WebAuthnLoginRequest
+	// body is optional
+	json.NewDecoder(r.Body).Decode(&loginReq)
+	options, err := h.auth.BeginWebAuthnLogin(r.Context(), loginReq.Login)
+	if err != nil {
+		h.writeWebAuthnError(w, err, "h.BeginWebAuthnLogin")
+		return
+	}
+	h.writeJSON(w, options, "h.BeginWebAuthnLogin")
+}
+
+// FinishWebAuthnLogin godoc
+// @Summary finishes passwordless login
+// @Description Takes answer of navigator.credentials.get() and sets cookies like /login does.
+// @Description Failures count as failed logins
+// @Router /login/webauthn/finish [post]
+// @Accept       json
+// @Produce      json
+// @Param input body WebAuthnAssertionRequest true "credential"
+// @Success 200 {object} TestMessage
+// @Failure 403 {object} Message
+func (h *Handler) FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var assertionReq WebAuthnAssertionRequest
+	if err := json.NewDecoder(r.Body).Decode(&assertionReq); err != nil {
+		h.logger.Debug().Msg("h.FinishWebAuthnLogin bad input")
+		WriteAnswer(w, http.StatusBadRequest, "bad credential")
+		return
+	}
+	ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+	login, err := h.auth.FinishWebAuthnLogin(ctx, &assertionReq.Credential)
+	switch err {
+	case nil:
+		h.startSession(w, r, login, models.AMRHardwareKey)
+	case e.ErrPasswordChangeRequired:
+		h.passwordChangeRequired(w, r, login)
+	default:
+		h.writeWebAuthnError(w, err, "h.FinishWebAuthnLogin")
+	}
+}
+
+// BeginWebAuthnMFA godoc
+// @Summary starts second step of login with security key
+// @Description Takes mfa token given by /login, from body or access cookie
+// @Router /login/mfa/webauthn/begin [post]
+// @Accept       json
+// @Produce      json
+// @Param input body WebAuthnMFARequest false "mfa token"
+// @Success 200 {object} webauthn.RequestOptions
+// @Failure 401 {object} Message
+// @Failure 404 {object} Message
+func (h *Handler) BeginWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var mfaReq WebAuthnMFARequest
+	// token may come in cookie only
+	json.NewDecoder(r.Body).Decode(&mfaReq)
+	token := h.mfaToken(w, r, mfaReq.MFAToken, "h.BeginWebAuthnMFA")
+	if token == "" {
+		return
+	}
+	options, err := h.auth.BeginWebAuthnMFA(r.Context(), token)
+	if err != nil {
+		h.writeWebAuthnError(w, err, "h.BeginWebAuthnMFA")
+		return
+	}
+	h.writeJSON(w, options, "h.BeginWebAuthnMFA")
+}
+
+// FinishWebAuthnMFA godoc
+// @Summary second step of login with security key
+// @Description Takes mfa token given by /login, from body or access cookie, and answer of navigator.credentials.get().
+// @Description Failures count as failed logins
+// @Description rememberDevice trusts the device like /login/mfa does
+// @Router /login/mfa/webauthn/finish [post]
+// @Accept       json
+// @Produce      json
+// @Param input body WebAuthnMFARequest true "mfa token and credential"
+// @Success 200 {object} TestMessage
+// @Failure 401 {object} Message
+// @Failure 403 {object} Message
+func (h *Handler) FinishWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	initHeaders(w)
+	var mfaReq WebAuthnMFARequest
+	if err := json.NewDecoder(r.Body).Decode(&mfaReq); err != nil || mfaReq.Credential == nil {
+		h.logger.Debug().Msg("h.FinishWebAuthnMFA bad input")
+		WriteAnswer(w, http.StatusBadRequest, "missed credential")
+		return
+	}
+	token := h.mfaToken(w, r, mfaReq.MFAToken, "h.FinishWebAuthnMFA")
+	if token == "" {
+		return
+	}
+	ctx := models.WithClientIP(r.Context(), clientIP(r, h.cfg.TrustForwardedFor))
+	login, err := h.auth.FinishWebAuthnMFA(ctx, token, mfaReq.Credential)
+	switch err {
+	case nil:
+		if mfaReq.RememberDevice {
+			h.rememberDevice(w, r, login)
+		}
+		h.startSession(w, r, login, models.AMRPassword, models.AMRHardwareKey)
+	case e.ErrPasswordChangeRequired:
+		h.passwordChangeRequired(w, r, login)
+	default:
+		h.writeWebAuthnError(w, err, "h.FinishWebAuthnMFA")
+	}
+}
+
+func (h *Handler) writeWebAuthnError(w http.ResponseWriter, err error, name string) {
+	if writeRetryError(w, err) {
+		h.logger.Info().Msgf("%s %s", name, err.Error())
+		return
+	}
+	switch err {
+	case e.ErrWebAuthnFailed:
+		WriteAnswer(w, http.StatusForbidden, err.Error())
+	case e.ErrBadChallenge:
+		WriteAnswer(w, http.StatusBadRequest, err.Error())
+	case e.ErrWebAuthnCredentialExists:
+		WriteAnswer(w, http.StatusConflict, err.Error())
+	case e.ErrWebAuthnDisabled, e.ErrNoWebAuthnCredential:
+		WriteAnswer(w, http.StatusNotFound, err.Error())
+	default:
+		h.logger.Warn().Msgf("%s err: %s", name, err.Error())
+		WriteAnswer(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, v interface{}, name string) {
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.logger.Warn().Msgf("%s couldn't write answer %s", name, err.Error())
+	}
+}
@@ -0,0 +1,149 @@
+package http_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	p "github.com/DMA8/authService/internal/adapters/http"
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	mock_ports "github.com/DMA8/authService/internal/mocks"
+	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
+	"github.com/DMA8/authService/pkg/webauthn"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebAuthnLogin(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	credential := `{"credential":{"id":"cred","rawId":"cred","type":"public-key","response":{}}}`
+
+	//passwordless login without login
+	mockAuth.EXPECT().BeginWebAuthnLogin(gomock.Any(), "").Return(
+		&webauthn.RequestOptions{Challenge: "challenge", RPID: "example.com"}, nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/login/webauthn/begin", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var options webauthn.RequestOptions
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &options))
+	assert.Equal(t, "challenge", options.Challenge)
+
+	mockAuth.EXPECT().FinishWebAuthnLogin(gomock.Any(), gomock.Any()).Return("", e.ErrWebAuthnFailed).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/login/webauthn/finish", strings.NewReader(credential)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	//successful login sets the same cookies as password login
+	mockAuth.EXPECT().FinishWebAuthnLogin(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ interface{}, resp *webauthn.AssertionResponse) (string, error) {
+			assert.Equal(t, "cred", resp.ID)
+			return "user", nil
+		}).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.AccessTokenType).Return("access", nil).Times(2)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.RefreshTokenType).Return("refresh", nil).Times(2)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/login/webauthn/finish", strings.NewReader(credential)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Values("Set-Cookie"), "access=access; Path=/; HttpOnly")
+	assert.Contains(t, rec.Header().Values("Set-Cookie"), "refresh=refresh; Path=/; HttpOnly")
+
+	//second factor after password
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "pending", models.MFAPendingTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "stale", models.MFAPendingTokenType).Return(
+		nil, e.ErrTokenRevoked).AnyTimes()
+	newRequest := func(url, body string) *http.Request {
+		request := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		request.Header.Set("Cookie", "access=pending")
+		return request
+	}
+	mockAuth.EXPECT().BeginWebAuthnMFA(gomock.Any(), "pending").Return(nil, e.ErrNoWebAuthnCredential).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/login/mfa/webauthn/begin", ""))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/login/mfa/webauthn/finish", `{"mfaToken":"stale","credential":{"id":"cred"}}`))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	mockAuth.EXPECT().FinishWebAuthnMFA(gomock.Any(), "pending", gomock.Any()).Return("user", nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/login/mfa/webauthn/finish", credential))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().FinishWebAuthnMFA(gomock.Any(), "pending", gomock.Any()).Return("user", e.ErrPasswordChangeRequired).Times(1)
+	mockAuth.EXPECT().CreateToken(gomock.Any(), "user", models.PasswordChangeTokenType).Return("change", nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest("/v1/login/mfa/webauthn/finish", credential))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestWebAuthnCredentials(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "user"}, nil).AnyTimes()
+	newRequest := func(method, url, body string) *http.Request {
+		request := httptest.NewRequest(method, url, strings.NewReader(body))
+		request.Header.Set("Cookie", "access=token")
+		return request
+	}
+
+	mockAuth.EXPECT().BeginWebAuthnRegistration(gomock.Any(), "user").Return(
+		&webauthn.CreationOptions{Challenge: "challenge"}, nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/webauthn/register/begin", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().FinishWebAuthnRegistration(gomock.Any(), "user", "laptop", gomock.Any()).Return(
+		&models.WebAuthnCredential{ID: "cred", Name: "laptop", PublicKey: []byte("key")}, nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/webauthn/register/finish",
+		`{"name":"laptop","credential":{"id":"cred"}}`))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "publicKey")
+
+	mockAuth.EXPECT().FinishWebAuthnRegistration(gomock.Any(), "user", "", gomock.Any()).Return(
+		nil, e.ErrBadChallenge).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodPost, "/v1/me/webauthn/register/finish", `{"credential":{}}`))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	mockAuth.EXPECT().WebAuthnCredentials(gomock.Any(), "user").Return(
+		[]models.WebAuthnCredential{{ID: "cred", Name: "laptop"}}, nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodGet, "/v1/me/webauthn/credentials", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var credentials []models.WebAuthnCredential
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &credentials))
+	assert.Len(t, credentials, 1)
+
+	mockAuth.EXPECT().DeleteWebAuthnCredential(gomock.Any(), "user", "cred").Return(nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/webauthn/credentials/cred", ""))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	mockAuth.EXPECT().DeleteWebAuthnCredential(gomock.Any(), "user", "cred").Return(e.ErrNoWebAuthnCredential).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, newRequest(http.MethodDelete, "/v1/me/webauthn/credentials/cred", ""))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
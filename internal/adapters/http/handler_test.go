@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -25,9 +26,10 @@ import (
 func TestHandlerLogin(t *testing.T) {
 	cfg := &config.Config{
 		HTTP: config.HTTPConfig{
-			URI:               ":8080",
-			AccessCookieName:  "access",
-			RefreshCookieName: "refresh",
+			URI:                ":8080",
+			AccessCookieName:   "access",
+			RefreshCookieName:  "refresh",
+			RevealUnknownUsers: true,
 		},
 		JWT: config.JWTConfig{
 			Secret: "test",
@@ -35,22 +37,26 @@ func TestHandlerLogin(t *testing.T) {
 			RefreshTTL: time.Hour,
 		},
 	}
+	secret, err := tokens.NewHMACKey(cfg.JWT.Secret)
+	assert.NoError(t, err)
+	key, err := tokens.NewKeyring(time.Hour, secret)
+	assert.NoError(t, err)
 	ctr := gomock.NewController(t)
 	mockAuth := mock_ports.NewMockAuth(ctr)
 	handlerObj := p.NewHandler(cfg.HTTP, mockAuth, logging.New("info"))
 	handler := http.HandlerFunc(handlerObj.Login)
 	rec := httptest.NewRecorder()
 	reqBody := bytes.Buffer{}
-	ctx := context.Background()
+	ctx := models.WithAuthMethods(context.Background(), models.AMRPassword)
 	test := models.Credentials{
 		Login:    "test1",
 		Password: "test1",
 	}
 	request, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/login?login=%s&password=%s", cfg.HTTP.APIVersion, test.Login, test.Password), &reqBody)
 	assert.NoError(t, err)
-	mockAuth.EXPECT().AuthUser(request.Context(), &test).Return(nil).Times(1)
-	mockAuth.EXPECT().CreateToken(ctx, test.Login, models.AccessTokenType).Return(tokens.CreateToken(test.Login, cfg.JWT.Secret, cfg.JWT.AccesTTL)).Times(1)
-	mockAuth.EXPECT().CreateToken(ctx, test.Login, models.RefreshTokenType).Return(tokens.CreateToken(test.Login, cfg.JWT.Secret, cfg.JWT.AccesTTL)).Times(1)
+	mockAuth.EXPECT().AuthUser(gomock.Any(), &test).Return(nil).Times(1)
+	mockAuth.EXPECT().CreateToken(ctx, test.Login, models.AccessTokenType).Return(tokens.CreateToken(&tokens.Claims{Subject: test.Login}, key, cfg.JWT.AccesTTL)).Times(1)
+	mockAuth.EXPECT().CreateToken(ctx, test.Login, models.RefreshTokenType).Return(tokens.CreateToken(&tokens.Claims{Subject: test.Login}, key, cfg.JWT.AccesTTL)).Times(1)
 
 	handler.ServeHTTP(rec, request)
 	response := rec.Result()
@@ -64,14 +70,14 @@ func TestHandlerLogin(t *testing.T) {
 	assert.Equal(t, true, cookies[0].Name == cfg.HTTP.AccessCookieName)
 	assert.Equal(t, true, cookies[1].Name == cfg.HTTP.RefreshCookieName)
 
-	loginFromCookie, err := tokens.ValidateToken(cookies[0].Value, cfg.JWT.Secret)
+	claimsFromCookie, err := tokens.ValidateToken(cookies[0].Value, key, tokens.ValidationOptions{})
 	assert.NoError(t, err)
-	assert.Equal(t, true, loginFromCookie == test.Login)
+	assert.Equal(t, true, claimsFromCookie.Subject == test.Login)
 	assert.Equal(t, true, cookies[0].Name == cfg.HTTP.AccessCookieName)
 
-	loginFromCookie, err = tokens.ValidateToken(cookies[1].Value, cfg.JWT.Secret)
+	claimsFromCookie, err = tokens.ValidateToken(cookies[1].Value, key, tokens.ValidationOptions{})
 	assert.NoError(t, err)
-	assert.Equal(t, true, loginFromCookie == test.Login)
+	assert.Equal(t, true, claimsFromCookie.Subject == test.Login)
 	assert.Equal(t, true, cookies[1].Name == cfg.HTTP.RefreshCookieName)
 
 	test2 := models.Credentials{
@@ -111,7 +117,7 @@ func TestHandlerLogin(t *testing.T) {
 		Login:    "NoUser",
 		Password: "WrongPass",
 	}
-	mockAuth.EXPECT().AuthUser(request.Context(), &test4).Return(e.ErrNoUserInDB).Times(1)
+	mockAuth.EXPECT().AuthUser(gomock.Any(), &test4).Return(e.ErrNoUserInDB).Times(1)
 	rec4 := httptest.NewRecorder()
 	var targets4 p.Message
 	reqBody4 := bytes.Buffer{}
@@ -125,7 +131,7 @@ func TestHandlerLogin(t *testing.T) {
 	assert.Equal(t, true, targets4.IsError)
 
 
-	mockAuth.EXPECT().AuthUser(request.Context(), &test4).Return(e.ErrWrongPass).Times(1)
+	mockAuth.EXPECT().AuthUser(gomock.Any(), &test4).Return(e.ErrWrongPass).Times(1)
 	rec5 := httptest.NewRecorder()
 	var targets5 p.Message
 	reqBody5 := bytes.Buffer{}
@@ -270,3 +276,251 @@ func TestHandlerProfiling(t *testing.T) {
 	assert.Equal(t, test2ProffState, handlerObj.ProfEnabled) // state hasn't been changed
 }
 
+
+func TestHandlerLogoutRevokesTokens(t *testing.T) {
+	cfg := config.HTTPConfig{
+		URI:               ":8080",
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(cfg, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.Logout)
+
+	rec := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/logout", cfg.APIVersion), &bytes.Buffer{})
+	assert.NoError(t, err)
+	request.Header.Set("Cookie", "access=accessToken; refresh=refreshToken")
+	mockAuth.EXPECT().RevokeTokens(gomock.Any(), "accessToken", "refreshToken").Return(nil).Times(1)
+	handler.ServeHTTP(rec, request)
+	var targets p.Message
+	err = json.Unmarshal(rec.Body.Bytes(), &targets)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, targets.StatusCode)
+	assert.Equal(t, 2, len(rec.Result().Cookies()))
+
+	//storage problems
+	rec2 := httptest.NewRecorder()
+	mockAuth.EXPECT().RevokeTokens(gomock.Any(), gomock.Any(), gomock.Any()).Return(fmt.Errorf("db is down")).Times(1)
+	handler.ServeHTTP(rec2, request)
+	err = json.Unmarshal(rec2.Body.Bytes(), &targets)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, targets.StatusCode)
+	assert.Equal(t, 2, len(rec2.Result().Cookies()))
+}
+
+func TestHandlerRevokeTokens(t *testing.T) {
+	cfg := config.HTTPConfig{
+		URI:               ":8080",
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		Admins:            []string{"admin"},
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(cfg, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.RevokeTokens)
+
+	revokeReq := p.RevokeRequest{AccessToken: "access", RefreshToken: "refresh"}
+	body, err := json.Marshal(revokeReq)
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/admin/revoke", bytes.NewReader(body))
+	assert.NoError(t, err)
+	mockAuth.EXPECT().RevokeTokens(gomock.Any(), revokeReq.AccessToken, revokeReq.RefreshToken).Return(nil).Times(1)
+	handler.ServeHTTP(rec, request)
+	var targets p.Message
+	err = json.Unmarshal(rec.Body.Bytes(), &targets)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, targets.StatusCode)
+
+	rec2 := httptest.NewRecorder()
+	request2, err := http.NewRequest(http.MethodPost, "/admin/revoke", bytes.NewReader(body))
+	assert.NoError(t, err)
+	mockAuth.EXPECT().RevokeTokens(gomock.Any(), revokeReq.AccessToken, revokeReq.RefreshToken).Return(e.ErrNoTokensToRevoke).Times(1)
+	handler.ServeHTTP(rec2, request2)
+	err = json.Unmarshal(rec2.Body.Bytes(), &targets)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, targets.StatusCode)
+}
+
+func TestHandlerJWKS(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.JWKS)
+
+	keySet := tokens.JWKSet{Keys: []tokens.JWK{{Kty: "OKP", Crv: "Ed25519", X: "x", Use: "sig", Alg: "EdDSA"}}}
+	mockAuth.EXPECT().JWKS(gomock.Any()).Return(keySet).Times(1)
+	rec := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	assert.NoError(t, err)
+	handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var answer tokens.JWKSet
+	err = json.Unmarshal(rec.Body.Bytes(), &answer)
+	assert.NoError(t, err)
+	assert.Equal(t, keySet, answer)
+}
+
+func TestHandlerReloadKeys(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.ReloadKeys)
+
+	keys := []tokens.KeyInfo{{ID: "second", Alg: tokens.AlgHS256, Status: tokens.KeyActive}}
+	mockAuth.EXPECT().ReloadKeys(gomock.Any()).Return(keys, nil).Times(1)
+	rec := httptest.NewRecorder()
+	request, err := http.NewRequest(http.MethodPost, "/admin/keys/reload", nil)
+	assert.NoError(t, err)
+	handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var answer []tokens.KeyInfo
+	err = json.Unmarshal(rec.Body.Bytes(), &answer)
+	assert.NoError(t, err)
+	assert.Equal(t, keys, answer)
+
+	mockAuth.EXPECT().ReloadKeys(gomock.Any()).Return(nil, tokens.ErrNoKeyringFile).Times(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminRoutesRequireAdminRole(t *testing.T) {
+	cfg := config.HTTPConfig{
+		AccessCookieName:  "access",
+		RefreshCookieName: "refresh",
+		APIVersion:        "/v1",
+	}
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	server := p.NewHTTPServer(cfg, p.NewHandler(cfg, mockAuth, logging.New("debug")))
+
+	request, err := http.NewRequest(http.MethodPost, "/v1/admin/keys/reload", nil)
+	assert.NoError(t, err)
+	request.Header.Set("Cookie", "access=token")
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "support", Roles: []string{"support"}}, nil).Times(1)
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	mockAuth.EXPECT().ValidateToken(gomock.Any(), "token", models.AccessTokenType).Return(
+		&tokens.Claims{Subject: "root", Roles: []string{p.AdminRole}}, nil).Times(1)
+	mockAuth.EXPECT().ReloadKeys(gomock.Any()).Return([]tokens.KeyInfo{}, nil).Times(1)
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestHandlerIntrospect(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.Introspect)
+	newRequest := func(form url.Values) *http.Request {
+		request, err := http.NewRequest(http.MethodPost, "/introspect", strings.NewReader(form.Encode()))
+		assert.NoError(t, err)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return request
+	}
+
+	//client from basic auth
+	request := newRequest(url.Values{"token": {"token"}, "token_type_hint": {"access_token"}})
+	request.SetBasicAuth("gateway", "secret")
+	mockAuth.EXPECT().AuthClient(gomock.Any(), "gateway", "secret").Return(nil).Times(1)
+	answer := &models.Introspection{Active: true, ClientID: "team31", Subject: "admin", TokenType: "access_token", ExpiresAt: 100}
+	mockAuth.EXPECT().Introspect(gomock.Any(), "token", "access_token").Return(answer).Times(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	var introspection models.Introspection
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &introspection))
+	assert.Equal(t, *answer, introspection)
+
+	//inactive token is just active:false
+	request = newRequest(url.Values{"token": {"revoked"}, "client_id": {"gateway"}, "client_secret": {"secret"}})
+	mockAuth.EXPECT().AuthClient(gomock.Any(), "gateway", "secret").Return(nil).Times(1)
+	mockAuth.EXPECT().Introspect(gomock.Any(), "revoked", "").Return(&models.Introspection{}).Times(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"active":false}`, rec.Body.String())
+
+	//unknown client
+	request = newRequest(url.Values{"token": {"token"}})
+	mockAuth.EXPECT().AuthClient(gomock.Any(), "", "").Return(e.ErrBadClientCreds).Times(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, request)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestHandlerLoginHidesUnknownUsers(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handler := http.HandlerFunc(p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug")).Login)
+	login := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login?login=admin&password=secret", nil))
+		return rec
+	}
+
+	mockAuth.EXPECT().AuthUser(gomock.Any(), gomock.Any()).Return(e.ErrNoUserInDB).Times(1)
+	unknown := login()
+	mockAuth.EXPECT().AuthUser(gomock.Any(), gomock.Any()).Return(e.ErrWrongPass).Times(1)
+	wrongPass := login()
+	assert.Equal(t, http.StatusForbidden, unknown.Code)
+	assert.Equal(t, unknown.Code, wrongPass.Code)
+	assert.Equal(t, unknown.Body.String(), wrongPass.Body.String())
+	assert.Contains(t, unknown.Body.String(), e.ErrBadLoginOrPassword.Error())
+}
+
+func TestHandlerLoginLockout(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{TrustForwardedFor: true}, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.Login)
+	newRequest := func() *http.Request {
+		request := httptest.NewRequest(http.MethodPost, "/login?login=admin&password=secret", nil)
+		request.Header.Set("X-Forwarded-For", "1.1.1.1, 10.0.0.1")
+		return request
+	}
+
+	//client ip is the entry added by load balancer
+	mockAuth.EXPECT().AuthUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctx context.Context, _ *models.Credentials) error {
+			assert.Equal(t, "10.0.0.1", models.ClientIP(ctx))
+			return &e.RetryError{Err: e.ErrAccountLocked, RetryAfter: 1500 * time.Millisecond}
+		}).Times(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest())
+	assert.Equal(t, http.StatusLocked, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+
+	mockAuth.EXPECT().AuthUser(gomock.Any(), gomock.Any()).Return(
+		&e.RetryError{Err: e.ErrTooManyAttempts, RetryAfter: time.Second}).Times(1)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, newRequest())
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+}
+
+func TestHandlerUnlock(t *testing.T) {
+	ctr := gomock.NewController(t)
+	mockAuth := mock_ports.NewMockAuth(ctr)
+	handlerObj := p.NewHandler(config.HTTPConfig{}, mockAuth, logging.New("debug"))
+	handler := http.HandlerFunc(handlerObj.Unlock)
+
+	mockAuth.EXPECT().Unlock(gomock.Any(), "admin", "").Return(nil).Times(1)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/unlock", strings.NewReader(`{"login":"admin"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/unlock", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
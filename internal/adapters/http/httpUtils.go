@@ -1,12 +1,17 @@
 package http
 
 import (
-	"github.com/DMA8/authService/internal/domain/models"
 	"context"
 	"encoding/json"
 	"errors"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/tokens"
+	"github.com/DMA8/authService/pkg/webauthn"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -15,6 +20,8 @@ var (
 	ErrBadCookies     error = errors.New("bad cookie")
 	ErrBadCreateCreds error = errors.New("bad create creds")
 	ErrBadCredsType   error = errors.New("bad creds type")
+	ErrNoLoginInCtx   error = errors.New("no login in context")
+	ErrNoClaimsInCtx  error = errors.New("no token claims in context")
 )
 
 type Message struct {
@@ -23,6 +30,121 @@ type Message struct {
 	IsError    bool   `json:"is_error"`
 }
 
+// ValidationMessage is Message with what exactly is wrong in request
+type ValidationMessage struct {
+	StatusCode int         `json:"status_code"`
+	Message    string      `json:"message"`
+	IsError    bool        `json:"is_error"`
+	Violations []Violation `json:"violations"`
+}
+
+// Violation is a problem with a field of request. Code is machine-readable
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type RevokeRequest struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+type UnlockRequest struct {
+	Login string `json:"login"`
+	IP    string `json:"ip"`
+}
+
+type ExpirePasswordRequest struct {
+	Login string `json:"login"`
+}
+
+// MFACodeRequest carries TOTP code or recovery code
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// LoginMFARequest is the second step of login. MFAToken is taken from access cookie when empty.
+// RememberDevice lets the device skip second factor at next logins
+type LoginMFARequest struct {
+	MFAToken       string `json:"mfaToken"`
+	Code           string `json:"code"`
+	RememberDevice bool   `json:"rememberDevice"`
+}
+
+// OTPEnrollRequest tells where one-time codes go. Channel is "email" or "sms", phone numbers are like +15550100
+type OTPEnrollRequest struct {
+	Channel string `json:"channel"`
+	Address string `json:"address"`
+}
+
+// MFATokenRequest carries token given by /login. It is taken from access cookie when empty
+type MFATokenRequest struct {
+	MFAToken string `json:"mfaToken"`
+}
+
+// RecoveryCodesResponse is shown once, only hashes of the codes are kept
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// WebAuthnRegistrationRequest is answer of navigator.credentials.create() with name user gives the credential
+type WebAuthnRegistrationRequest struct {
+	Name       string                       `json:"name"`
+	Credential webauthn.AttestationResponse `json:"credential"`
+}
+
+// WebAuthnLoginRequest starts passwordless login, with empty login user picks a passkey
+type WebAuthnLoginRequest struct {
+	Login string `json:"login"`
+}
+
+// WebAuthnAssertionRequest is answer of navigator.credentials.get()
+type WebAuthnAssertionRequest struct {
+	Credential webauthn.AssertionResponse `json:"credential"`
+}
+
+// WebAuthnMFARequest is the second step of login with security key. MFAToken is taken from access cookie when empty.
+// RememberDevice lets the device skip second factor at next logins
+type WebAuthnMFARequest struct {
+	MFAToken       string                      `json:"mfaToken"`
+	Credential     *webauthn.AssertionResponse `json:"credential,omitempty"`
+	RememberDevice bool                        `json:"rememberDevice"`
+}
+
+// ReauthRequest authenticates logged in user again. Code is TOTP, recovery or one-time code,
+// it is required when user has them on
+type ReauthRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// StepUpRequired is answer to session which is older or weaker than the route needs, error is
+// insufficient_user_authentication of RFC 9470. ACR is the least assurance level and MaxAge
+// is the oldest authentication in seconds the route accepts. Session is upgraded by /me/reauth
+type StepUpRequired struct {
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
+	Message    string `json:"message"`
+	ACR        string `json:"acr_values,omitempty"`
+	MaxAge     int64  `json:"max_age,omitempty"`
+	IsError    bool   `json:"is_error"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type ForgotPasswordRequest struct {
+	Login string `json:"login"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
 type TestMessage struct {
 	StatusCode   int    `json:"status_code"`
 	Message      string `json:"message"`
@@ -40,6 +162,18 @@ func SetCookie(w http.ResponseWriter, cookieName, token, path string) {
 	http.SetCookie(w, &cookie)
 }
 
+// SetPersistentCookie sets cookie which outlives browser session until expires
+func SetPersistentCookie(w http.ResponseWriter, cookieName, token, path string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    token,
+		Path:     path,
+		Expires:  expires,
+		MaxAge:   int(time.Until(expires).Seconds()),
+		HttpOnly: true,
+	})
+}
+
 func sendCookie(writer http.ResponseWriter, message, access, refresh string, status int) {
 	msg := TestMessage{
 		StatusCode:   status,
@@ -79,6 +213,7 @@ func GetCookieValue(cookies []string) (map[string]string, error) {
 	for _, v := range cookies {
 		cookieFromLine := strings.Fields(v)
 		for _, v := range cookieFromLine {
+			v = strings.TrimSuffix(v, ";")
 			delimIndex := strings.Index(v, "=")
 			if delimIndex < 0 || delimIndex >= len(v)-1 {
 				continue
@@ -106,6 +241,61 @@ func WriteAnswer(writer http.ResponseWriter, status int, message string) {
 	}
 }
 
+// writeValidationError answers 400 with field level violations when err is validation error
+func writeValidationError(writer http.ResponseWriter, err error) bool {
+	var validationErr *e.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	msg := ValidationMessage{
+		StatusCode: http.StatusBadRequest,
+		Message:    validationErr.Error(),
+		IsError:    true,
+	}
+	for _, v := range validationErr.Violations {
+		msg.Violations = append(msg.Violations, Violation{Field: v.Field, Code: v.Code, Message: v.Message})
+	}
+	writer.WriteHeader(http.StatusBadRequest)
+	if err = json.NewEncoder(writer).Encode(msg); err != nil {
+		log.Println("BAD json") //FIX ME
+	}
+	return true
+}
+
+// writeRetryError answers 423 for locked account and 429 for other throttled logins with Retry-After header
+func writeRetryError(writer http.ResponseWriter, err error) bool {
+	var retryErr *e.RetryError
+	if !errors.As(err, &retryErr) {
+		return false
+	}
+	status := http.StatusTooManyRequests
+	if errors.Is(err, e.ErrAccountLocked) {
+		status = http.StatusLocked
+	}
+	seconds := int((retryErr.RetryAfter + time.Second - 1) / time.Second)
+	writer.Header().Set("Retry-After", strconv.Itoa(seconds))
+	WriteAnswer(writer, status, retryErr.Err.Error())
+	return true
+}
+
+// clientIP is ip of request sender. With trustForwardedFor it is the last X-Forwarded-For
+// entry, which is added by our load balancer, the entries before it are set by the client
+func clientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(entries[len(entries)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func getCredentials(r *http.Request) (*models.Credentials, error) {
 	values := r.URL.Query()
 	credentials := &models.Credentials{
@@ -154,6 +344,25 @@ func GetCredsFromCtx(ctx context.Context) (*models.Credentials, error) {
 	return nil, ErrBadCredsType
 }
 
+// GetLoginFromCtx returns login put to ctx by checkToken middleware
+func GetLoginFromCtx(ctx context.Context) (string, error) {
+	switch login := ctx.Value(NameInCtx).(type) {
+	case string:
+		return login, nil
+	case UsrNameFromCtxtType:
+		return string(login), nil
+	}
+	return "", ErrNoLoginInCtx
+}
+
+// GetClaimsFromCtx returns claims of access token put to ctx by checkToken middleware
+func GetClaimsFromCtx(ctx context.Context) (*tokens.Claims, error) {
+	if claims, ok := ctx.Value(ClaimsInCtx).(*tokens.Claims); ok && claims != nil {
+		return claims, nil
+	}
+	return nil, ErrNoClaimsInCtx
+}
+
 func GetReqID(ctx context.Context) string {
 	return ctx.Value(RidKey).(string)
 }
@@ -57,6 +57,12 @@ func Test_getCookieValue(t *testing.T) {
 			want:    test1Res,
 			wantErr: false,
 		},
+		{
+			name:    "one header",
+			args:    args{[]string{"access=1234; refresh=4321"}},
+			want:    test1Res,
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
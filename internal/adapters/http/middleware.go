@@ -7,21 +7,31 @@ import (
 	"net/http"
 	"time"
 
+	e "github.com/DMA8/authService/internal/domain/errors"
 	"github.com/DMA8/authService/internal/domain/models"
 	"github.com/DMA8/authService/pkg/logging"
 
+	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/v5/middleware"
 	uuid "github.com/satori/go.uuid"
 )
 
 type UsrNameFromCtxtType string
 type CredsCRUD string
+type ClaimsFromCtxType string
 
 const (
-	NameInCtx UsrNameFromCtxtType = "name"
-	CrudCreds CredsCRUD           = "creds"
+	NameInCtx   UsrNameFromCtxtType = "name"
+	CrudCreds   CredsCRUD           = "creds"
+	ClaimsInCtx ClaimsFromCtxType   = "claims"
 )
 
+// AdminRole in access token grants access to admin routes
+const AdminRole = "admin"
+
+// stepUpError is error code of RFC 9470 told to sessions which have to authenticate again
+const stepUpError = "insufficient_user_authentication"
+
 type ctxKey int
 
 const RidKey ctxKey = ctxKey(0)
@@ -36,28 +46,25 @@ func (h *Handler) checkToken(next http.Handler) http.Handler {
 			return
 		}
 		cfg := h.cfg
-		if userName, err := h.auth.ValidateToken(ctx, cookies[cfg.AccessCookieName]); err == nil {
-			ctx = context.WithValue(context.TODO(), NameInCtx, userName)
+		if claims, err := h.auth.ValidateToken(req.Context(), cookies[cfg.AccessCookieName], models.AccessTokenType); err == nil {
+			ctx = context.WithValue(req.Context(), NameInCtx, claims.Subject)
+			ctx = context.WithValue(ctx, ClaimsInCtx, claims)
 			h.logger.Debug().Msgf("checkToken middleware. access is alive")
 			next.ServeHTTP(w, req.WithContext(ctx))
-		} else if userName, err = h.auth.ValidateToken(ctx, cookies[cfg.RefreshCookieName]); err == nil {
+		} else if pair, err := h.auth.RefreshTokens(req.Context(), cookies[cfg.RefreshCookieName]); err == nil {
 			h.logger.Debug().Msgf("checkToken middleware. refresh is alive")
-			ctx = context.WithValue(context.TODO(), NameInCtx, userName)
-			accessToken, err := h.auth.CreateToken(ctx, userName, models.AccessTokenType)
-			if err != nil {
-				h.logger.Warn().Msgf("checkToken middleware. Сouldn't create accessToken! err: %s", err.Error())
-				WriteAnswer(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			refreshToken, err := h.auth.CreateToken(ctx, userName, models.RefreshTokenType)
-			if err != nil {
-				h.logger.Warn().Msgf("checkToken middleware. Сouldn't create refreshToken! err: %s", err.Error())
-				WriteAnswer(w, http.StatusInternalServerError, err.Error())
-				return
+			ctx = context.WithValue(req.Context(), NameInCtx, pair.Login)
+			ctx = context.WithValue(ctx, ClaimsInCtx, pair.Claims)
+			SetCookie(w, cfg.AccessCookieName, pair.AccessToken, "/")
+			if pair.RefreshToken != "" {
+				SetCookie(w, cfg.RefreshCookieName, pair.RefreshToken, "/")
 			}
-			SetCookie(w, cfg.AccessCookieName, accessToken, "/")
-			SetCookie(w, cfg.RefreshCookieName, refreshToken, "/")
 			next.ServeHTTP(w, req.WithContext(ctx))
+		} else if err == e.ErrRefreshTokenReused || err == e.ErrTokenFamilyRevoked ||
+			err == e.ErrSessionExpired || err == e.ErrSessionIdle {
+			h.logger.Warn().Msgf("checkToken middleware. %s", err.Error())
+			resetCookie(w, []string{cfg.AccessCookieName, cfg.RefreshCookieName})
+			WriteAnswer(w, http.StatusForbidden, fmt.Sprintf("auth didn't succeed %s", err))
 		} else {
 			h.logger.Debug().Msg("checkToken middleware. dull jwt tokens")
 			WriteAnswer(w, http.StatusForbidden, fmt.Sprintf("auth didn't succeed %s", err))
@@ -65,6 +72,109 @@ func (h *Handler) checkToken(next http.Handler) http.Handler {
 	})
 }
 
+// checkPasswordChangeToken lets user with expired password in by the restricted token from /login,
+// other users need usual tokens
+func (h *Handler) checkPasswordChangeToken(next http.Handler) http.Handler {
+	fallback := h.checkToken(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cookies, err := GetCookieValue(req.Header["Cookie"])
+		if err != nil {
+			fallback.ServeHTTP(w, req)
+			return
+		}
+		claims, err := h.auth.ValidateToken(req.Context(), cookies[h.cfg.AccessCookieName], models.PasswordChangeTokenType)
+		if err != nil {
+			fallback.ServeHTTP(w, req)
+			return
+		}
+		h.logger.Debug().Msgf("checkPasswordChangeToken middleware. password change token of %s", claims.Subject)
+		ctx := context.WithValue(req.Context(), NameInCtx, claims.Subject)
+		ctx = context.WithValue(ctx, ClaimsInCtx, claims)
+		next.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// adminOnly should go after checkToken. Admin has admin role in token or is listed in config
+func (h *Handler) adminOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login, err := GetLoginFromCtx(r.Context())
+		claims, _ := GetClaimsFromCtx(r.Context())
+		if err != nil || !(h.isAdmin(login) || claims != nil && claims.HasRole(AdminRole)) {
+			h.logger.Debug().Msgf("adminOnly middleware. %s is not an admin", login)
+			WriteAnswer(w, http.StatusForbidden, "admin rights required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// selfOrAdmin should go after checkToken. User may manage only own {login} of the route, admin any
+func (h *Handler) selfOrAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		login, err := GetLoginFromCtx(r.Context())
+		claims, _ := GetClaimsFromCtx(r.Context())
+		if err != nil || login != chi.URLParam(r, "login") && !(h.isAdmin(login) || claims != nil && claims.HasRole(AdminRole)) {
+			h.logger.Debug().Msgf("selfOrAdmin middleware. %s can't manage %s", login, chi.URLParam(r, "login"))
+			WriteAnswer(w, http.StatusForbidden, "admin rights required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAuth should go after checkToken. It lets in sessions of assurance level acr or higher
+// authenticated within maxAge, empty acr and zero maxAge are not checked.
+// Other sessions get 401 with StepUpRequired
+func (h *Handler) requireAuth(acr string, maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := GetClaimsFromCtx(r.Context())
+			if err != nil {
+				WriteAnswer(w, http.StatusForbidden, err.Error())
+				return
+			}
+			if models.ACRLevel(claims.ACR) < models.ACRLevel(acr) || maxAge > 0 && time.Since(claims.AuthTime) > maxAge {
+				h.logger.Debug().Msgf("requireAuth middleware. session of %s has acr %q and auth_time %s",
+					claims.Subject, claims.ACR, claims.AuthTime)
+				writeStepUpRequired(w, acr, maxAge)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeStepUpRequired(w http.ResponseWriter, acr string, maxAge time.Duration) {
+	answer := StepUpRequired{
+		StatusCode: http.StatusUnauthorized,
+		Error:      stepUpError,
+		Message:    e.ErrStepUpRequired.Error(),
+		ACR:        acr,
+		MaxAge:     int64(maxAge / time.Second),
+		IsError:    true,
+	}
+	challenge := fmt.Sprintf(`Bearer error=%q, error_description=%q`, answer.Error, answer.Message)
+	if acr != "" {
+		challenge += fmt.Sprintf(`, acr_values=%q`, acr)
+	}
+	if answer.MaxAge > 0 {
+		challenge += fmt.Sprintf(`, max_age=%d`, answer.MaxAge)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(answer)
+}
+
+func (h *Handler) isAdmin(login string) bool {
+	for _, admin := range h.cfg.Admins {
+		if admin == login {
+			return true
+		}
+	}
+	return false
+}
+
 func Logger(l logging.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(rw http.ResponseWriter, r *http.Request) {
@@ -119,11 +229,11 @@ func (h *Handler) validateInput(next http.Handler) http.Handler {
 		}
 		err = validateCreds(&credentials)
 		if err != nil {
-			h.logger.Debug().Msgf("validateInput middleware. couldn't validate creds %+v err: %s", credentials, err.Error())
+			h.logger.Debug().Msgf("validateInput middleware. couldn't validate creds of %s err: %s", credentials.Login, err.Error())
 			WriteAnswer(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		ctx = context.WithValue(context.TODO(), CrudCreds, &credentials)
+		ctx = context.WithValue(r.Context(), CrudCreds, &credentials)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -58,19 +58,78 @@ func initRouter(cfg config.HTTPConfig, handler *Handler) *chi.Mux {
 		r.Get(cfg.APIVersion+"/i", handler.I)
 		r.Get(cfg.APIVersion+"/validate", handler.I)
 		r.Get(cfg.APIVersion+"/profswitch", handler.Profiling)
+		r.Post(cfg.APIVersion+"/me/mfa/totp", handler.EnrollTOTP)
+		r.Post(cfg.APIVersion+"/me/mfa/totp/confirm", handler.ConfirmTOTP)
+		r.Post(cfg.APIVersion+"/me/mfa/recovery-codes", handler.RegenerateRecoveryCodes)
+		r.Delete(cfg.APIVersion+"/me/mfa", handler.DisableMFA)
+		r.Post(cfg.APIVersion+"/me/mfa/otp", handler.EnrollOTP)
+		r.Post(cfg.APIVersion+"/me/mfa/otp/confirm", handler.ConfirmOTP)
+		r.Post(cfg.APIVersion+"/me/webauthn/register/finish", handler.FinishWebAuthnRegistration)
+		r.Get(cfg.APIVersion+"/me/webauthn/credentials", handler.WebAuthnCredentials)
+		r.Post(cfg.APIVersion+"/me/reauth", handler.Reauthenticate)
+		r.Post(cfg.APIVersion+"/me/reauth/otp", handler.SendReauthCode)
+		r.Post(cfg.APIVersion+"/me/reauth/webauthn/begin", handler.BeginWebAuthnReauth)
+		r.Post(cfg.APIVersion+"/me/reauth/webauthn/finish", handler.FinishWebAuthnReauth)
+		r.Get(cfg.APIVersion+"/me/devices", handler.TrustedDevices)
+		r.Delete(cfg.APIVersion+"/me/devices", handler.RevokeDevices)
+		r.Delete(cfg.APIVersion+"/me/devices/{id}", handler.RevokeDevice)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(handler.checkToken)
+		r.Use(handler.requireAuth(cfg.StepUpACR, cfg.StepUpMaxAge))
+		r.Delete(cfg.APIVersion+"/me/mfa/otp", handler.DisableOTP)
+		r.Post(cfg.APIVersion+"/me/webauthn/register/begin", handler.BeginWebAuthnRegistration)
+		r.Delete(cfg.APIVersion+"/me/webauthn/credentials/{id}", handler.DeleteWebAuthnCredential)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(handler.checkPasswordChangeToken)
+		r.Post(cfg.APIVersion+"/me/password", handler.ChangePassword)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(handler.checkToken)
+		r.Use(handler.adminOnly)
+		r.Post(cfg.APIVersion+"/admin/revoke", handler.RevokeTokens)
+		r.Post(cfg.APIVersion+"/admin/keys/reload", handler.ReloadKeys)
+		r.Post(cfg.APIVersion+"/admin/unlock", handler.Unlock)
+		r.Post(cfg.APIVersion+"/admin/password/expire", handler.ExpirePassword)
+		r.Post(cfg.APIVersion+"/admin/users/import", handler.ImportUsers)
 	})
 	r.Group(func(r chi.Router) {
 		r.Use(handler.profilingCheck)
 		r.Mount(cfg.APIVersion+"/prof/", middleware.Profiler())
 	})
+	r.Get("/.well-known/jwks.json", handler.JWKS)
+	r.Post(cfg.APIVersion+"/introspect", handler.Introspect)
 	r.Post(cfg.APIVersion+"/login", handler.Login)
+	r.Post(cfg.APIVersion+"/login/mfa", handler.LoginMFA)
+	r.Post(cfg.APIVersion+"/login/mfa/otp", handler.SendMFACode)
+	r.Post(cfg.APIVersion+"/login/mfa/webauthn/begin", handler.BeginWebAuthnMFA)
+	r.Post(cfg.APIVersion+"/login/mfa/webauthn/finish", handler.FinishWebAuthnMFA)
+	r.Post(cfg.APIVersion+"/login/webauthn/begin", handler.BeginWebAuthnLogin)
+	r.Post(cfg.APIVersion+"/login/webauthn/finish", handler.FinishWebAuthnLogin)
 	r.Get(cfg.APIVersion+"/logout", handler.Logout)
+	r.Post(cfg.APIVersion+"/password/forgot", handler.ForgotPassword)
+	r.Post(cfg.APIVersion+"/password/reset", handler.ResetPassword)
 	r.Group(func(r chi.Router) {
 		r.Use(handler.validateInput)
 		r.Post(cfg.APIVersion+"/user", handler.CreateUser)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(handler.checkToken)
+		r.Use(handler.adminOnly)
+		r.Use(handler.validateInput)
 		r.Put(cfg.APIVersion+"/user", handler.UpdateUser)
 	})
-	r.Delete(cfg.APIVersion+"/user/{login}", handler.DeleteUser)
-	r.Get(cfg.APIVersion+"/user/{login}", handler.GetUser)
+	r.Group(func(r chi.Router) {
+		r.Use(handler.checkToken)
+		r.Use(handler.selfOrAdmin)
+		r.Use(handler.requireAuth(cfg.StepUpACR, cfg.StepUpMaxAge))
+		r.Delete(cfg.APIVersion+"/user/{login}", handler.DeleteUser)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(handler.checkToken)
+		r.Use(handler.selfOrAdmin)
+		r.Get(cfg.APIVersion+"/user/{login}", handler.GetUser)
+	})
 	return r
 }
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// AttemptStorage counts failed logins in memory.
+// It is not shared between replicas, so use it for local runs and tests
+type AttemptStorage struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempts
+}
+
+// NewAttemptStorage removes expired counters every pruneEvery until ctx is done
+func NewAttemptStorage(ctx context.Context, pruneEvery time.Duration) *AttemptStorage {
+	s := &AttemptStorage{
+		attempts: make(map[string]models.LoginAttempts),
+	}
+	go func() {
+		ticker := time.NewTicker(pruneEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.prune(now)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *AttemptStorage) GetAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts := s.current(key, time.Now())
+	return &attempts, nil
+}
+
+func (s *AttemptStorage) AddFailure(ctx context.Context, key string, at, expiresAt time.Time) (*models.LoginAttempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts := s.current(key, at)
+	attempts.Failures++
+	attempts.LastFailure = at
+	if expiresAt.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = expiresAt
+	}
+	s.attempts[key] = attempts
+	return &attempts, nil
+}
+
+func (s *AttemptStorage) Lock(ctx context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attempts := s.current(key, time.Now())
+	attempts.Failures = 0
+	attempts.LockedUntil = until
+	if until.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = until
+	}
+	s.attempts[key] = attempts
+	return nil
+}
+
+func (s *AttemptStorage) ResetAttempts(ctx context.Context, key string) error {
+	s.mu.Lock()
+	delete(s.attempts, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// current is not expired counter of key, caller holds the lock
+func (s *AttemptStorage) current(key string, now time.Time) models.LoginAttempts {
+	attempts, ok := s.attempts[key]
+	if !ok || !attempts.ExpiresAt.After(now) {
+		return models.LoginAttempts{Key: key}
+	}
+	return attempts
+}
+
+func (s *AttemptStorage) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, attempts := range s.attempts {
+		if !attempts.ExpiresAt.After(now) {
+			delete(s.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMA8/authService/internal/adapters/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttemptStorage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storage := memory.NewAttemptStorage(ctx, 10*time.Millisecond)
+	now := time.Now()
+
+	attempts, err := storage.AddFailure(ctx, "login:admin", now, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+	attempts, err = storage.AddFailure(ctx, "login:admin", now, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts.Failures)
+
+	assert.NoError(t, storage.Lock(ctx, "login:admin", now.Add(time.Minute)))
+	attempts, err = storage.GetAttempts(ctx, "login:admin")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, attempts.Failures)
+	assert.Equal(t, now.Add(time.Minute), attempts.LockedUntil)
+
+	assert.NoError(t, storage.ResetAttempts(ctx, "login:admin"))
+	attempts, err = storage.GetAttempts(ctx, "login:admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "login:admin", attempts.Key)
+	assert.Equal(t, true, attempts.LockedUntil.IsZero())
+
+	//expired counter starts anew
+	_, err = storage.AddFailure(ctx, "ip:10.0.0.1", now, now.Add(-time.Second))
+	assert.NoError(t, err)
+	attempts, err = storage.AddFailure(ctx, "ip:10.0.0.1", now, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempts.Failures)
+}
@@ -0,0 +1,123 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RevocationStorage is an in-memory list of revoked token ids.
+// It is not shared between replicas, so use it for local runs and tests
+type RevocationStorage struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	users   map[string]userRevocation
+	uses    map[string]tokenUses
+}
+
+type tokenUses struct {
+	count     int
+	expiresAt time.Time
+}
+
+type userRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// NewRevocationStorage removes expired entries every pruneEvery until ctx is done
+func NewRevocationStorage(ctx context.Context, pruneEvery time.Duration) *RevocationStorage {
+	s := &RevocationStorage{
+		revoked: make(map[string]time.Time),
+		users:   make(map[string]userRevocation),
+		uses:    make(map[string]tokenUses),
+	}
+	go func() {
+		ticker := time.NewTicker(pruneEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.prune(now)
+			}
+		}
+	}()
+	return s
+}
+
+func (s *RevocationStorage) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	s.revoked[tokenID] = expiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *RevocationStorage) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.RLock()
+	expiresAt, ok := s.revoked[tokenID]
+	s.mu.RUnlock()
+	return ok && expiresAt.After(time.Now()), nil
+}
+
+func (s *RevocationStorage) RevokeUser(ctx context.Context, login string, issuedBefore, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// earlier revocation may still be wider, it is kept then
+	if old, ok := s.users[login]; ok {
+		if old.issuedBefore.After(issuedBefore) {
+			issuedBefore = old.issuedBefore
+		}
+		if old.expiresAt.After(expiresAt) {
+			expiresAt = old.expiresAt
+		}
+	}
+	s.users[login] = userRevocation{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+func (s *RevocationStorage) UserRevokedBefore(ctx context.Context, login string) (time.Time, error) {
+	s.mu.RLock()
+	revocation, ok := s.users[login]
+	s.mu.RUnlock()
+	if !ok || !revocation.expiresAt.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return revocation.issuedBefore, nil
+}
+
+func (s *RevocationStorage) AddTokenAttempt(ctx context.Context, tokenID string, expiresAt time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uses := s.uses[tokenID]
+	if !uses.expiresAt.After(time.Now()) {
+		uses = tokenUses{}
+	}
+	uses.count++
+	if expiresAt.After(uses.expiresAt) {
+		uses.expiresAt = expiresAt
+	}
+	s.uses[tokenID] = uses
+	return uses.count, nil
+}
+
+func (s *RevocationStorage) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for tokenID, expiresAt := range s.revoked {
+		if !expiresAt.After(now) {
+			delete(s.revoked, tokenID)
+		}
+	}
+	for login, revocation := range s.users {
+		if !revocation.expiresAt.After(now) {
+			delete(s.users, login)
+		}
+	}
+	for tokenID, uses := range s.uses {
+		if !uses.expiresAt.After(now) {
+			delete(s.uses, tokenID)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMA8/authService/internal/adapters/memory"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevocationStorage(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storage := memory.NewRevocationStorage(ctx, 10*time.Millisecond)
+
+	assert.NoError(t, storage.Revoke(ctx, "alive", time.Now().Add(time.Hour)))
+	assert.NoError(t, storage.Revoke(ctx, "expired", time.Now().Add(-time.Second)))
+
+	revoked, err := storage.IsRevoked(ctx, "alive")
+	assert.NoError(t, err)
+	assert.Equal(t, true, revoked)
+
+	revoked, err = storage.IsRevoked(ctx, "expired")
+	assert.NoError(t, err)
+	assert.Equal(t, false, revoked)
+
+	revoked, err = storage.IsRevoked(ctx, "unknown")
+	assert.NoError(t, err)
+	assert.Equal(t, false, revoked)
+}
+
+func TestUserRevocation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storage := memory.NewRevocationStorage(ctx, 10*time.Millisecond)
+	changed := time.Now().Truncate(time.Second)
+
+	before, err := storage.UserRevokedBefore(ctx, "user")
+	assert.NoError(t, err)
+	assert.True(t, before.IsZero())
+
+	assert.NoError(t, storage.RevokeUser(ctx, "user", changed, time.Now().Add(time.Hour)))
+	before, err = storage.UserRevokedBefore(ctx, "user")
+	assert.NoError(t, err)
+	assert.True(t, before.Equal(changed))
+
+	//earlier cutoff doesn't narrow the later one
+	assert.NoError(t, storage.RevokeUser(ctx, "user", changed.Add(-time.Minute), time.Now().Add(time.Hour)))
+	before, err = storage.UserRevokedBefore(ctx, "user")
+	assert.NoError(t, err)
+	assert.True(t, before.Equal(changed))
+
+	//cutoff is forgotten when tokens it revoked have expired
+	assert.NoError(t, storage.RevokeUser(ctx, "other", changed, time.Now().Add(-time.Second)))
+	before, err = storage.UserRevokedBefore(ctx, "other")
+	assert.NoError(t, err)
+	assert.True(t, before.IsZero())
+}
+
+func TestTokenAttempts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	storage := memory.NewRevocationStorage(ctx, 10*time.Millisecond)
+
+	for i := 1; i <= 3; i++ {
+		attempts, err := storage.AddTokenAttempt(ctx, "token", time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, i, attempts)
+	}
+	attempts, err := storage.AddTokenAttempt(ctx, "other", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+
+	//count of expired token starts over
+	_, err = storage.AddTokenAttempt(ctx, "expired", time.Now().Add(-time.Second))
+	assert.NoError(t, err)
+	attempts, err = storage.AddTokenAttempt(ctx, "expired", time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, attempts)
+}
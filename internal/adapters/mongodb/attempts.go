@@ -0,0 +1,72 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (r *Repository) GetAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	var attempts models.LoginAttempts
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.attempts.FindOne(ctx, bson.M{"_id": key}).Decode(&attempts); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return &models.LoginAttempts{Key: key}, nil
+		}
+		return nil, err
+	}
+	// mongo TTL monitor runs once a minute, so the document may outlive its expiration a bit
+	if !attempts.ExpiresAt.After(time.Now()) {
+		return &models.LoginAttempts{Key: key}, nil
+	}
+	return &attempts, nil
+}
+
+// AddFailure starts counting anew if the counter expired but TTL monitor hasn't removed it yet
+func (r *Repository) AddFailure(ctx context.Context, key string, at, expiresAt time.Time) (*models.LoginAttempts, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if _, err := r.attempts.DeleteOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$lte": at}}); err != nil {
+		return nil, err
+	}
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$set": bson.M{"last_failure": at},
+		"$max": bson.M{"expires_at": expiresAt},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var attempts models.LoginAttempts
+	err := r.attempts.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempts)
+	if mongo.IsDuplicateKeyError(err) {
+		// concurrent upsert created the document first, now it is there to update
+		err = r.attempts.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&attempts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+func (r *Repository) Lock(ctx context.Context, key string, until time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.attempts.UpdateOne(ctx, bson.M{"_id": key}, bson.M{
+		"$set": bson.M{"failures": 0, "locked_until": until},
+		"$max": bson.M{"expires_at": until},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *Repository) ResetAttempts(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.attempts.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
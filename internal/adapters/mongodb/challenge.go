@@ -0,0 +1,38 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (r *Repository) SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.challenges.InsertOne(ctx, challenge)
+	return err
+}
+
+// TakeChallenge deletes challenge in the same operation it is read, so a response can't be replayed
+func (r *Repository) TakeChallenge(ctx context.Context, id string) (*models.WebAuthnChallenge, error) {
+	var challenge models.WebAuthnChallenge
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.challenges.FindOneAndDelete(ctx, bson.M{"_id": id}).Decode(&challenge); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, e.ErrBadChallenge
+		}
+		return nil, err
+	}
+	// mongo TTL monitor runs once a minute, so the document may outlive its expiration a bit
+	if !challenge.ExpiresAt.After(time.Now()) {
+		return nil, e.ErrBadChallenge
+	}
+	return &challenge, nil
+}
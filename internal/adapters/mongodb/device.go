@@ -0,0 +1,81 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (r *Repository) SaveDevice(ctx context.Context, device *models.TrustedDevice) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.devices.InsertOne(ctx, device)
+	return err
+}
+
+func (r *Repository) GetDevice(ctx context.Context, id string) (*models.TrustedDevice, error) {
+	var device models.TrustedDevice
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.devices.FindOne(ctx, bson.M{"_id": id}).Decode(&device); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, e.ErrNoTrustedDevice
+		}
+		return nil, err
+	}
+	// mongo TTL monitor runs once a minute, so the document may outlive its expiration a bit
+	if !device.ExpiresAt.After(time.Now()) {
+		return nil, e.ErrNoTrustedDevice
+	}
+	return &device, nil
+}
+
+// ListDevices returns unexpired devices of login, the latest first
+func (r *Repository) ListDevices(ctx context.Context, login string) ([]models.TrustedDevice, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	filter := bson.M{"login": login, "expires_at": bson.M{"$gt": time.Now()}}
+	cursor, err := r.devices.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	devices := []models.TrustedDevice{}
+	if err = cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (r *Repository) TouchDevice(ctx context.Context, id string, usedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.devices.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	return err
+}
+
+func (r *Repository) DeleteDevice(ctx context.Context, login, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	res, err := r.devices.DeleteOne(ctx, bson.M{"_id": id, "login": login})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return e.ErrNoTrustedDevice
+	}
+	return nil
+}
+
+func (r *Repository) DeleteDevices(ctx context.Context, login string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.devices.DeleteMany(ctx, bson.M{"login": login})
+	return err
+}
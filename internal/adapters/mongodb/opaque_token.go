@@ -0,0 +1,39 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (r *Repository) SaveOpaqueToken(ctx context.Context, token *models.OpaqueToken) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.opaque.InsertOne(ctx, token)
+	return err
+}
+
+func (r *Repository) GetOpaqueToken(ctx context.Context, tokenHash string) (*models.OpaqueToken, error) {
+	var token models.OpaqueToken
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.opaque.FindOne(ctx, bson.M{"_id": tokenHash}).Decode(&token); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, e.ErrNoOpaqueToken
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *Repository) DeleteOpaqueToken(ctx context.Context, tokenHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.opaque.DeleteOne(ctx, bson.M{"_id": tokenHash})
+	return err
+}
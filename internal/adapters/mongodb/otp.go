@@ -0,0 +1,68 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func (r *Repository) SaveOTP(ctx context.Context, code *models.OTPCode) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.otps.ReplaceOne(ctx, bson.M{"_id": code.Login}, code, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *Repository) GetOTP(ctx context.Context, login string) (*models.OTPCode, error) {
+	var code models.OTPCode
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.otps.FindOne(ctx, bson.M{"_id": login}).Decode(&code); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, e.ErrNoOTP
+		}
+		return nil, err
+	}
+	// mongo TTL monitor runs once a minute, so the document may outlive its expiration a bit
+	if !code.ExpiresAt.After(time.Now()) {
+		return nil, e.ErrNoOTP
+	}
+	return &code, nil
+}
+
+// AddOTPAttempt counts the guess before the code is compared, so parallel guesses can't exceed the limit
+func (r *Repository) AddOTPAttempt(ctx context.Context, login string) (*models.OTPCode, error) {
+	var code models.OTPCode
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	filter := bson.M{"_id": login, "expires_at": bson.M{"$gt": time.Now()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.otps.FindOneAndUpdate(ctx, filter, bson.M{"$inc": bson.M{"attempts": 1}}, opts).Decode(&code)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, e.ErrNoOTP
+		}
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (r *Repository) DeleteOTP(ctx context.Context, login string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	res, err := r.otps.DeleteOne(ctx, bson.M{"_id": login})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return e.ErrNoOTP
+	}
+	return nil
+}
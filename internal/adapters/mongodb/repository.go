@@ -1,8 +1,6 @@
 package mongodb
 
 import (
-	"github.com/DMA8/authService/internal/config"
-	"github.com/DMA8/authService/internal/domain/models"
 	"context"
 	"errors"
 	"fmt"
@@ -10,6 +8,9 @@ import (
 	"os"
 	"time"
 
+	"github.com/DMA8/authService/internal/config"
+	"github.com/DMA8/authService/internal/domain/models"
+
 	e "github.com/DMA8/authService/internal/domain/errors"
 	"github.com/DMA8/authService/pkg/client/mongodb"
 
@@ -19,7 +20,17 @@ import (
 )
 
 type Repository struct {
-	db *mongo.Collection
+	db       *mongo.Collection
+	families *mongo.Collection
+	revoked  *mongo.Collection
+	opaque   *mongo.Collection
+	resets   *mongo.Collection
+	attempts *mongo.Collection
+	// challenges are pending webauthn ceremonies
+	challenges *mongo.Collection
+	otps       *mongo.Collection
+	// devices are trusted devices which skip second factor
+	devices *mongo.Collection
 }
 
 const (
@@ -28,7 +39,8 @@ const (
 
 func NewRepository(ctx context.Context, cfg config.MongoConfig) (*Repository, error) {
 	var connStr string
-	ctx, _ = context.WithTimeout(ctx, timeOut)
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
 	mongoPass := os.Getenv("MONGO_PASSWORD")
 	if mongoPass == "" {
 		log.Println("mongopass not found in env. applying config creds for mongo")
@@ -54,18 +66,87 @@ func NewRepository(ctx context.Context, cfg config.MongoConfig) (*Repository, er
 	if err != nil {
 		return nil, err
 	}
-	return &Repository{db: collection}, nil
+	// one credential can't be registered to two users
+	_, err = collection.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys: bson.D{{Key: "webauthn.id", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"webauthn.id": bson.M{"$exists": true}}),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	families := mongodb.MongoCollection(mongoCli, cfg.DB, cfg.FamilyCollection)
+	if err = createExpireIndex(families); err != nil {
+		return nil, err
+	}
+	revoked := mongodb.MongoCollection(mongoCli, cfg.DB, cfg.RevokedCollection)
+	if err = createExpireIndex(revoked); err != nil {
+		return nil, err
+	}
+	opaque := mongodb.MongoCollection(mongoCli, cfg.DB, cfg.OpaqueCollection)
+	if err = createExpireIndex(opaque); err != nil {
+		return nil, err
+	}
+	resets := mongodb.MongoCollection(mongoCli, cfg.DB, cfg.ResetCollection)
+	if err = createExpireIndex(resets); err != nil {
+		return nil, err
+	}
+	attempts := mongodb.MongoCollection(mongoCli, cfg.DB, cfg.AttemptCollection)
+	if err = createExpireIndex(attempts); err != nil {
+		return nil, err
+	}
+	challenges := mongodb.MongoCollection(mongoCli, cfg.DB, cfg.ChallengeCollection)
+	if err = createExpireIndex(challenges); err != nil {
+		return nil, err
+	}
+	otps := mongodb.MongoCollection(mongoCli, cfg.DB, cfg.OTPCollection)
+	if err = createExpireIndex(otps); err != nil {
+		return nil, err
+	}
+	devices := mongodb.MongoCollection(mongoCli, cfg.DB, cfg.DeviceCollection)
+	if err = createExpireIndex(devices); err != nil {
+		return nil, err
+	}
+	_, err = devices.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{Keys: bson.D{{Key: "login", Value: 1}}},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Repository{db: collection, families: families, revoked: revoked, opaque: opaque, resets: resets,
+		attempts: attempts, challenges: challenges, otps: otps, devices: devices}, nil
+}
+
+// createExpireIndex makes mongo remove documents once their expires_at has passed
+func createExpireIndex(collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(
+		context.Background(),
+		mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	)
+	return err
 }
 
 func (r *Repository) CreateUser(ctx context.Context, user *models.Credentials) error {
-	ctx, _ = context.WithTimeout(ctx, timeOut)
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
 	_, err := r.db.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		return e.ErrUserExists
+	}
 	return err
 }
 
 func (r *Repository) GetUser(ctx context.Context, login string) (*models.Credentials, error) {
 	var user models.Credentials
-	ctx, _ = context.WithTimeout(ctx, timeOut)
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
 	if err := r.db.FindOne(ctx, bson.M{"login": login}).Decode(&user); err != nil {
 		if errors.Is(err, mongo.ErrNoDocuments) {
 			return nil, e.ErrNoUserInDB
@@ -76,15 +157,89 @@ func (r *Repository) GetUser(ctx context.Context, login string) (*models.Credent
 }
 
 func (r *Repository) UpdateUser(ctx context.Context, user *models.Credentials) error {
-	ctx, _ = context.WithTimeout(ctx, timeOut)
-	filter := bson.D{{"login", bson.D{{"$eq", user.Login}}}}
-	update := bson.D{{"$set", bson.D{{"pswrd_hash", user.Password}}}}
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	filter := bson.D{{Key: "login", Value: bson.D{{Key: "$eq", Value: user.Login}}}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "pswrd_hash", Value: user.Password},
+		{Key: "password_history", Value: user.PasswordHistory},
+		{Key: "password_changed_at", Value: user.PasswordChangedAt},
+		{Key: "must_change_password", Value: user.MustChangePassword},
+	}}}
 	_, err := r.db.UpdateOne(ctx, filter, update)
 	return err
 }
 
+func (r *Repository) UpdateMFA(ctx context.Context, login string, mfa *models.MFA) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "mfa", Value: mfa}}}}
+	if mfa == nil {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "mfa", Value: ""}}}}
+	}
+	res, err := r.db.UpdateOne(ctx, bson.M{"login": login}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return e.ErrNoUserInDB
+	}
+	return nil
+}
+
+func (r *Repository) UpdateWebAuthn(ctx context.Context, login string, credentials []models.WebAuthnCredential) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "webauthn", Value: credentials}}}}
+	if len(credentials) == 0 {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "webauthn", Value: ""}}}}
+	}
+	res, err := r.db.UpdateOne(ctx, bson.M{"login": login}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return e.ErrWebAuthnCredentialExists
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return e.ErrNoUserInDB
+	}
+	return nil
+}
+
+func (r *Repository) GetUserByCredentialID(ctx context.Context, credentialID string) (*models.Credentials, error) {
+	var user models.Credentials
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.db.FindOne(ctx, bson.M{"webauthn.id": credentialID}).Decode(&user); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, e.ErrNoUserInDB
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *Repository) UpdateOTP(ctx context.Context, login string, otp *models.OTPFactor) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "otp", Value: otp}}}}
+	if otp == nil {
+		update = bson.D{{Key: "$unset", Value: bson.D{{Key: "otp", Value: ""}}}}
+	}
+	res, err := r.db.UpdateOne(ctx, bson.M{"login": login}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return e.ErrNoUserInDB
+	}
+	return nil
+}
+
 func (r *Repository) DeleteUser(ctx context.Context, login string) error {
-	ctx, _ = context.WithTimeout(ctx, timeOut)
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
 	user, err := r.GetUser(ctx, login)
 	if err != nil {
 		return err
@@ -0,0 +1,52 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (r *Repository) SaveResetToken(ctx context.Context, token *models.ResetToken) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.resets.InsertOne(ctx, token)
+	return err
+}
+
+func (r *Repository) GetResetToken(ctx context.Context, tokenHash string) (*models.ResetToken, error) {
+	var token models.ResetToken
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.resets.FindOne(ctx, bson.M{"_id": tokenHash}).Decode(&token); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, e.ErrBadResetToken
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *Repository) DeleteResetToken(ctx context.Context, tokenHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	res, err := r.resets.DeleteOne(ctx, bson.M{"_id": tokenHash})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return e.ErrBadResetToken
+	}
+	return nil
+}
+
+func (r *Repository) DeleteResetTokens(ctx context.Context, login string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.resets.DeleteMany(ctx, bson.M{"login": login})
+	return err
+}
@@ -0,0 +1,88 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type revokedToken struct {
+	ID        string    `bson:"_id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+	// IssuedBefore is set for revocation of every token of a login
+	IssuedBefore time.Time `bson:"issued_before,omitempty"`
+	// Attempts counts uses of a token
+	Attempts int `bson:"attempts,omitempty"`
+}
+
+// prefixes keep revocations of logins and counts of token uses apart from token ids in the same collection
+const (
+	userRevocationPrefix = "login:"
+	tokenAttemptsPrefix  = "attempts:"
+)
+
+// Revoke stores token id till token expiration. TTL index removes it afterwards
+func (r *Repository) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.revoked.ReplaceOne(ctx, bson.M{"_id": tokenID},
+		revokedToken{ID: tokenID, ExpiresAt: expiresAt}, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *Repository) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	var token revokedToken
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.revoked.FindOne(ctx, bson.M{"_id": tokenID}).Decode(&token); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, err
+	}
+	// mongo TTL monitor runs once a minute, so the document may outlive the token a bit
+	return token.ExpiresAt.After(time.Now()), nil
+}
+
+// RevokeUser stores the cutoff of login till expiresAt. Later cutoff replaces earlier one
+func (r *Repository) RevokeUser(ctx context.Context, login string, issuedBefore, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.revoked.UpdateOne(ctx, bson.M{"_id": userRevocationPrefix + login},
+		bson.M{"$max": bson.M{"issued_before": issuedBefore, "expires_at": expiresAt}}, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *Repository) UserRevokedBefore(ctx context.Context, login string) (time.Time, error) {
+	var revocation revokedToken
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.revoked.FindOne(ctx, bson.M{"_id": userRevocationPrefix + login}).Decode(&revocation); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	if !revocation.ExpiresAt.After(time.Now()) {
+		return time.Time{}, nil
+	}
+	return revocation.IssuedBefore, nil
+}
+
+// AddTokenAttempt counts use of token atomically, TTL index removes the counter after expiresAt
+func (r *Repository) AddTokenAttempt(ctx context.Context, tokenID string, expiresAt time.Time) (int, error) {
+	var uses revokedToken
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	err := r.revoked.FindOneAndUpdate(ctx, bson.M{"_id": tokenAttemptsPrefix + tokenID},
+		bson.M{"$inc": bson.M{"attempts": 1}, "$max": bson.M{"expires_at": expiresAt}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&uses)
+	if err != nil {
+		return 0, err
+	}
+	return uses.Attempts, nil
+}
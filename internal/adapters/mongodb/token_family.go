@@ -0,0 +1,66 @@
+package mongodb
+
+import (
+	"context"
+	"errors"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func (r *Repository) CreateFamily(ctx context.Context, family *models.TokenFamily) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.families.InsertOne(ctx, family)
+	return err
+}
+
+func (r *Repository) GetFamily(ctx context.Context, familyID string) (*models.TokenFamily, error) {
+	var family models.TokenFamily
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	if err := r.families.FindOne(ctx, bson.M{"_id": familyID}).Decode(&family); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, e.ErrNoTokenFamily
+		}
+		return nil, err
+	}
+	return &family, nil
+}
+
+// RotateFamily is compare-and-set on generation, so only one of concurrent refreshes wins
+func (r *Repository) RotateFamily(ctx context.Context, family *models.TokenFamily, fromGeneration int) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	filter := bson.M{"_id": family.ID, "generation": fromGeneration, "revoked": false}
+	update := bson.M{"$set": bson.M{
+		"generation": family.Generation,
+		"rotated_at": family.RotatedAt,
+		"expires_at": family.ExpiresAt,
+	}}
+	res, err := r.families.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return e.ErrTokenFamilyRotated
+	}
+	return nil
+}
+
+func (r *Repository) RevokeUserFamilies(ctx context.Context, login string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.families.UpdateMany(ctx, bson.M{"login": login, "revoked": false}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
+
+func (r *Repository) RevokeFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, timeOut)
+	defer cancel()
+	_, err := r.families.UpdateOne(ctx, bson.M{"_id": familyID}, bson.M{"$set": bson.M{"revoked": true}})
+	return err
+}
@@ -0,0 +1,59 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// FileNotifier appends notifications as json lines to outbox file instead of sending them.
+// It is for local runs and tests, a real sender can consume the outbox
+type FileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: filepath.Clean(path)}
+}
+
+func (n *FileNotifier) Notify(ctx context.Context, notification *models.Notification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	file, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadOutbox returns notifications written to outbox file in order
+func ReadOutbox(path string) ([]models.Notification, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	var notifications []models.Notification
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var notification models.Notification
+		if err = decoder.Decode(&notification); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
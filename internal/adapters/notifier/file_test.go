@@ -0,0 +1,26 @@
+package notifier_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/DMA8/authService/internal/adapters/notifier"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	n := notifier.NewFileNotifier(path)
+	first := models.Notification{Recipient: "admin", Kind: models.PasswordResetNotification, Secret: "token1"}
+	second := models.Notification{Recipient: "user", Kind: models.PasswordResetNotification, Secret: "token2"}
+	require.NoError(t, n.Notify(context.Background(), &first))
+	require.NoError(t, n.Notify(context.Background(), &second))
+
+	notifications, err := notifier.ReadOutbox(path)
+	require.NoError(t, err)
+	assert.Equal(t, []models.Notification{first, second}, notifications)
+}
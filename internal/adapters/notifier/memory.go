@@ -0,0 +1,44 @@
+package notifier
+
+import (
+	"context"
+	"sync"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// MemoryNotifier keeps notifications in memory instead of sending them, for tests and local runs
+type MemoryNotifier struct {
+	mu   sync.Mutex
+	sent []models.Notification
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (n *MemoryNotifier) Notify(ctx context.Context, notification *models.Notification) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.sent = append(n.sent, *notification)
+	return nil
+}
+
+// Sent returns notifications in order they came
+func (n *MemoryNotifier) Sent() []models.Notification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]models.Notification{}, n.sent...)
+}
+
+// Last returns the last notification to recipient, false if there is none
+func (n *MemoryNotifier) Last(recipient string) (models.Notification, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for i := len(n.sent) - 1; i >= 0; i-- {
+		if n.sent[i].Recipient == recipient {
+			return n.sent[i], true
+		}
+	}
+	return models.Notification{}, false
+}
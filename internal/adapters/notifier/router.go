@@ -0,0 +1,26 @@
+package notifier
+
+import (
+	"context"
+
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/ports"
+)
+
+// Router sends notifications by the notifier of their channel. Notifications without channel
+// or with channel it has no notifier for go to fallback
+type Router struct {
+	fallback ports.Notifier
+	channels map[string]ports.Notifier
+}
+
+func NewRouter(fallback ports.Notifier, channels map[string]ports.Notifier) *Router {
+	return &Router{fallback: fallback, channels: channels}
+}
+
+func (r *Router) Notify(ctx context.Context, notification *models.Notification) error {
+	if n, ok := r.channels[notification.Channel]; ok {
+		return n.Notify(ctx, notification)
+	}
+	return r.fallback.Notify(ctx, notification)
+}
@@ -0,0 +1,28 @@
+package notifier_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DMA8/authService/internal/adapters/notifier"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/ports"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter(t *testing.T) {
+	fallback, sms := notifier.NewMemoryNotifier(), notifier.NewMemoryNotifier()
+	router := notifier.NewRouter(fallback, map[string]ports.Notifier{models.SMSChannel: sms})
+	require.NoError(t, router.Notify(context.Background(), &models.Notification{Recipient: "admin", Kind: models.PasswordResetNotification}))
+	require.NoError(t, router.Notify(context.Background(), &models.Notification{Recipient: "admin", Channel: models.SMSChannel}))
+	require.NoError(t, router.Notify(context.Background(), &models.Notification{Recipient: "user", Channel: models.EmailChannel}))
+	assert.Len(t, fallback.Sent(), 2)
+	assert.Len(t, sms.Sent(), 1)
+	last, ok := fallback.Last("admin")
+	assert.True(t, ok)
+	assert.Equal(t, models.PasswordResetNotification, last.Kind)
+	_, ok = sms.Last("user")
+	assert.False(t, ok)
+}
@@ -0,0 +1,79 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DMA8/authService/internal/config"
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+var ErrBadEmail = errors.New("bad email address")
+
+// SMTPNotifier sends notifications as plain text emails to their Address.
+// STARTTLS is used when server offers it, credentials are sent only over TLS or to localhost
+type SMTPNotifier struct {
+	addr string
+	auth smtp.Auth
+	from *mail.Address
+}
+
+func NewSMTPNotifier(cfg config.SMTPConfig) (*SMTPNotifier, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("smtp from: %w", err)
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	n := &SMTPNotifier{addr: net.JoinHostPort(cfg.Host, strconv.Itoa(port)), from: from}
+	if cfg.Username != "" {
+		n.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return n, nil
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, notification *models.Notification) error {
+	to, err := mail.ParseAddress(notification.Address)
+	if err != nil {
+		return ErrBadEmail
+	}
+	msg, err := n.message(to, notification)
+	if err != nil {
+		return err
+	}
+	// net/smtp has no context, the mail is sent even if ctx is done meanwhile
+	if err = ctx.Err(); err != nil {
+		return err
+	}
+	return smtp.SendMail(n.addr, n.auth, n.from.Address, []string{to.Address}, msg)
+}
+
+func (n *SMTPNotifier) message(to *mail.Address, notification *models.Notification) ([]byte, error) {
+	if strings.ContainsAny(notification.Subject, "\r\n") {
+		return nil, errors.New("subject has line breaks")
+	}
+	date := notification.CreatedAt
+	if date.IsZero() {
+		date = time.Now()
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", n.from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", to.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", notification.Subject)
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(notification.Text, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return []byte(msg.String()), nil
+}
@@ -0,0 +1,80 @@
+package notifier_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/DMA8/authService/internal/adapters/notifier"
+	"github.com/DMA8/authService/internal/config"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPNotifier(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go serveSMTP(listener, received)
+
+	n, err := notifier.NewSMTPNotifier(config.SMTPConfig{Host: "127.0.0.1",
+		Port: listener.Addr().(*net.TCPAddr).Port, From: "team31 <noreply@example.com>"})
+	require.NoError(t, err)
+	email := models.Notification{Recipient: "admin", Channel: models.EmailChannel, Address: "admin@example.com",
+		Kind: models.OTPNotification, Subject: "Login code", Text: "code 123456\nexpires in 5m"}
+	require.NoError(t, n.Notify(context.Background(), &email))
+	lines := <-received
+	assert.Contains(t, lines, "MAIL FROM:<noreply@example.com> BODY=8BITMIME")
+	assert.Contains(t, lines, "RCPT TO:<admin@example.com>")
+	assert.Contains(t, lines, "Subject: Login code")
+	assert.Contains(t, lines, "expires in 5m")
+
+	email.Address = "not an email"
+	assert.ErrorIs(t, n.Notify(context.Background(), &email), notifier.ErrBadEmail)
+}
+
+// serveSMTP answers one session like a mail server without extensions and sends lines client sent
+func serveSMTP(listener net.Listener, received chan<- []string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	var lines []string
+	reply("220 localhost ESMTP")
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			received <- lines
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		lines = append(lines, line)
+		switch {
+		case inData && line == ".":
+			inData = false
+			reply("250 accepted")
+		case inData:
+		case strings.HasPrefix(line, "EHLO"):
+			reply("250-localhost")
+			reply("250 8BITMIME")
+		case line == "DATA":
+			inData = true
+			reply("354 go ahead")
+		case line == "QUIT":
+			reply("221 bye")
+			received <- lines
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
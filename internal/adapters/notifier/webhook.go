@@ -0,0 +1,64 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/DMA8/authService/internal/config"
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+const defaultWebhookTimeout = 10 * time.Second
+
+// WebhookMessage is json body POSTed to sms provider
+type WebhookMessage struct {
+	To   string                  `json:"to"`
+	Text string                  `json:"text"`
+	Kind models.NotificationKind `json:"kind"`
+}
+
+// WebhookNotifier sends notifications to their Address through HTTP endpoint of sms provider.
+// Any 2xx answer means the message is accepted
+type WebhookNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewWebhookNotifier(cfg config.SMSWebhookConfig) *WebhookNotifier {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &WebhookNotifier{url: cfg.URL, token: cfg.Token, client: &http.Client{Timeout: timeout}}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, notification *models.Notification) error {
+	body, err := json.Marshal(WebhookMessage{To: notification.Address, Text: notification.Text, Kind: notification.Kind})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if n.token != "" {
+		request.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 4096))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("sms webhook answered %s", response.Status)
+	}
+	return nil
+}
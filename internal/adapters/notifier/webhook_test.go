@@ -0,0 +1,38 @@
+package notifier_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DMA8/authService/internal/adapters/notifier"
+	"github.com/DMA8/authService/internal/config"
+	"github.com/DMA8/authService/internal/domain/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	var got notifier.WebhookMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	sms := models.Notification{Recipient: "admin", Channel: models.SMSChannel, Address: "+15550100",
+		Kind: models.OTPNotification, Text: "code 123456"}
+
+	n := notifier.NewWebhookNotifier(config.SMSWebhookConfig{URL: server.URL, Token: "secret"})
+	require.NoError(t, n.Notify(context.Background(), &sms))
+	assert.Equal(t, notifier.WebhookMessage{To: "+15550100", Text: "code 123456", Kind: models.OTPNotification}, got)
+
+	n = notifier.NewWebhookNotifier(config.SMSWebhookConfig{URL: server.URL, Token: "wrong"})
+	assert.Error(t, n.Notify(context.Background(), &sms))
+}
@@ -1,40 +1,103 @@
 package config
 
 import (
+	"fmt"
 	"log"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 
+	"github.com/DMA8/authService/pkg/passwords"
 	str2duration "github.com/xhit/go-str2duration/v2"
 	"gopkg.in/yaml.v2"
 )
 
-
-
+// MongoConfig collections other than UserCollection take default names when they are not set
 type MongoConfig struct {
-	URI            string `yaml:"uri"`
-	URIFull         string `yaml:"uri_full"`
-	UserCollection string `yaml:"user_collection"`
-	DB             string `yaml:"db"`
-	Login          string `yaml:"login"`
-	Password       string `yaml:"password"`
+	URI                 string `yaml:"uri"`
+	URIFull             string `yaml:"uri_full"`
+	UserCollection      string `yaml:"user_collection"`
+	FamilyCollection    string `yaml:"family_collection"`
+	RevokedCollection   string `yaml:"revoked_collection"`
+	OpaqueCollection    string `yaml:"opaque_collection"`
+	ResetCollection     string `yaml:"reset_collection"`
+	AttemptCollection   string `yaml:"attempt_collection"`
+	ChallengeCollection string `yaml:"challenge_collection"`
+	OTPCollection       string `yaml:"otp_collection"`
+	DeviceCollection    string `yaml:"device_collection"`
+	DB                  string `yaml:"db"`
+	Login               string `yaml:"login"`
+	Password            string `yaml:"password"`
 }
 
 type HTTPConfig struct {
-	URI               string `yaml:"uri"`
-	AccessCookieName  string `yaml:"access_cookie_name"`
-	RefreshCookieName string `yaml:"refresh_cookie_name"`
-	APIVersion        string `yaml:"api_version"`
+	URI               string   `yaml:"uri"`
+	AccessCookieName  string   `yaml:"access_cookie_name"`
+	RefreshCookieName string   `yaml:"refresh_cookie_name"`
+	APIVersion        string   `yaml:"api_version"`
+	Admins            []string `yaml:"admins"`
+	// TrustForwardedFor takes client ip from the last X-Forwarded-For entry added by load balancer.
+	// Don't set it when clients reach the service directly, they could pick any ip then
+	TrustForwardedFor bool `yaml:"trust_forwarded_for"`
+	// RevealUnknownUsers makes /login answer 404 for unknown logins and /user 409 for taken ones.
+	// By default they get the same answer as wrong password and successful registration
+	RevealUnknownUsers bool `yaml:"reveal_unknown_users"`
+	// StepUpACR and StepUpMaxAge are what destructive routes, like DELETE /user/{login}, need:
+	// session of assurance level StepUpACR or higher, authenticated within StepUpMaxAge.
+	// Older or weaker sessions are upgraded by /me/reauth
+	StepUpACR          string `yaml:"step_up_acr"`
+	StepUpMaxAgeString string `yaml:"step_up_max_age"`
+	StepUpMaxAge       time.Duration
+	// DeviceCookieName keeps token of device trusted after second factor, devices are not remembered when it is empty
+	DeviceCookieName string `yaml:"device_cookie_name"`
 }
 
 type JWTConfig struct {
-	Secret           string `yaml:"secret"`
-	AccessTTLString  string `yaml:"accessTTL"`
-	AccesTTL         time.Duration
-	RefreshTTLString string `yaml:"refreshTTL"`
-	RefreshTTL       time.Duration
+	Secret string `yaml:"secret"`
+	// Algorithm is HS256 (default), RS256, ES256 or EdDSA.
+	// All but HS256 sign with private key from PrivateKeyPath
+	Algorithm      string `yaml:"algorithm"`
+	PrivateKeyPath string `yaml:"private_key_path"`
+	// KeyringPath is yaml file with rotated signing keys. When set Secret, Algorithm and PrivateKeyPath are not used
+	KeyringPath string `yaml:"keyring_path"`
+	// Issuer and Audience are put to every token and required on validation
+	Issuer   string `yaml:"issuer"`
+	Audience string `yaml:"audience"`
+	// Refresh tokens are signed with HS256 RefreshSecret or keys from RefreshKeyringPath
+	// and issued for RefreshAudience. Unset values are taken from access tokens settings
+	RefreshSecret      string `yaml:"refresh_secret"`
+	RefreshKeyringPath string `yaml:"refresh_keyring_path"`
+	RefreshAudience    string `yaml:"refresh_audience"`
+	// IntrospectionClients are client id -> secret of services allowed to introspect tokens
+	IntrospectionClients map[string]string `yaml:"introspection_clients"`
+	AccessTTLString      string            `yaml:"accessTTL"`
+	AccesTTL             time.Duration
+	RefreshTTLString     string `yaml:"refreshTTL"`
+	RefreshTTL           time.Duration
+	RefreshGraceString   string `yaml:"refreshGrace"`
+	RefreshGrace         time.Duration
+	// SessionLifetime limits session since login however often tokens are refreshed.
+	// IdleTimeout ends session which wasn't refreshed for that long. Zero values are not checked
+	SessionLifetimeString string `yaml:"session_lifetime"`
+	SessionLifetime       time.Duration
+	IdleTimeoutString     string `yaml:"idle_timeout"`
+	IdleTimeout           time.Duration
+	// Leeway is allowed clock skew between pods for exp and nbf checks
+	LeewayString string `yaml:"leeway"`
+	Leeway       time.Duration
+	// TokenFormat is "jwt" (default), "opaque", "paseto_v4_public" or "paseto_v4_local".
+	// Opaque tokens are random strings, claims are kept in db.
+	// v4.public signs with EdDSA keys, v4.local encrypts with key derived from HS256 secret
+	TokenFormat string `yaml:"token_format"`
+	// AcceptTokenFormats are formats which are still verified while migrating from them to TokenFormat.
+	// They are accepted until AcceptUntil (RFC 3339) or forever when it is not set
+	AcceptTokenFormats []string `yaml:"accept_token_formats"`
+	AcceptUntilString  string   `yaml:"accept_until"`
+	AcceptUntil        time.Time
+	// RevocationStorage is "mongo" (default) or "memory"
+	RevocationStorage string `yaml:"revocation_storage"`
 }
 
 type GRPCConfig struct {
@@ -42,26 +105,214 @@ type GRPCConfig struct {
 	Transport string `yaml:"transport"`
 }
 
+// PasswordConfig is about how user passwords are kept and what passwords are accepted.
+// New password can't be any of the last History passwords and can't be changed earlier than MinAge.
+// Password older than MaxAge has to be changed at login, zero MaxAge never expires passwords
+type PasswordConfig struct {
+	Hashing      passwords.Config     `yaml:"hashing"`
+	Policy       PasswordPolicyConfig `yaml:"policy"`
+	History      int                  `yaml:"history"`
+	MinAgeString string               `yaml:"min_age"`
+	MinAge       time.Duration
+	MaxAgeString string `yaml:"max_age"`
+	MaxAge       time.Duration
+	// ResetTTL is lifetime of password reset token, 15 minutes by default
+	ResetTTLString string `yaml:"reset_ttl"`
+	ResetTTL       time.Duration
+	// Pepper keys are never stored with hashes. Keys of PASSWORD_PEPPERS env are added to the file ones
+	Pepper passwords.PepperConfig `yaml:"pepper"`
+}
+
+// NotifierConfig tells how notifications reach users.
+// Type "file" (default) appends them as json lines to OutboxPath (outbox.jsonl by default), "memory" keeps them in process.
+// One-time codes go by email through SMTP and by sms through SMS webhook when they are configured,
+// otherwise they go the same way as other notifications
+type NotifierConfig struct {
+	Type       string           `yaml:"type"`
+	OutboxPath string           `yaml:"outbox_path"`
+	SMTP       SMTPConfig       `yaml:"smtp"`
+	SMS        SMSWebhookConfig `yaml:"sms"`
+}
+
+// SMTPConfig is mail server for emails, it is off when Host is empty.
+// STARTTLS is used when server offers it. Password may be set in SMTP_PASSWORD env
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	From     string `yaml:"from"`
+}
+
+// SMSWebhookConfig is HTTP endpoint of sms provider, it is off when URL is empty.
+// Messages are POSTed as json with Token as bearer token, it may be set in SMS_WEBHOOK_TOKEN env
+type SMSWebhookConfig struct {
+	URL           string `yaml:"url"`
+	Token         string `yaml:"token"`
+	TimeoutString string `yaml:"timeout"`
+	Timeout       time.Duration
+}
+
+// PasswordPolicyConfig is checked for every new password. Zero lengths take defaults 8 and 128.
+// BlocklistPath is a file with a password per line, BreachedPath is a directory
+// of HIBP range files named by sha1 prefix
+type PasswordPolicyConfig struct {
+	MinLength            int    `yaml:"min_length"`
+	MaxLength            int    `yaml:"max_length"`
+	RequireLowercase     bool   `yaml:"require_lowercase"`
+	RequireUppercase     bool   `yaml:"require_uppercase"`
+	RequireDigit         bool   `yaml:"require_digit"`
+	RequireSymbol        bool   `yaml:"require_symbol"`
+	RejectSimilarToLogin bool   `yaml:"reject_similar_to_login"`
+	BlocklistPath        string `yaml:"blocklist_path"`
+	BreachedPath         string `yaml:"breached_path"`
+}
+
+// LockoutConfig is brute-force protection of login. Failed attempts are counted per login
+// and per client ip. Every failure doubles delay before the next attempt from BaseDelay up to MaxDelay,
+// MaxFailures failures lock login and IPMaxFailures lock ip for LockDuration.
+// Failures are forgotten after Window without new ones. Zero values take defaults.
+// Storage is "mongo" (default, shared by replicas) or "memory"
+type LockoutConfig struct {
+	Storage            string `yaml:"storage"`
+	MaxFailures        int    `yaml:"max_failures"`
+	IPMaxFailures      int    `yaml:"ip_max_failures"`
+	BaseDelayString    string `yaml:"base_delay"`
+	BaseDelay          time.Duration
+	MaxDelayString     string `yaml:"max_delay"`
+	MaxDelay           time.Duration
+	LockDurationString string `yaml:"lock_duration"`
+	LockDuration       time.Duration
+	WindowString       string `yaml:"window"`
+	Window             time.Duration
+}
+
+// MFAConfig is TOTP second factor. Issuer names the service in authenticator apps, default is jwt issuer.
+// Codes up to Skew periods away from now are accepted. Token given after password
+// lives PendingTTL waiting for the code and allows PendingAttempts guesses. Zero values take defaults.
+// One-time codes sent by email or sms have OTPLength digits, live OTPTTL and allow OTPAttempts guesses.
+// A new code isn't sent sooner than OTPResendInterval after the previous one.
+// Device trusted after the second factor skips it for TrustedDeviceTTL
+type MFAConfig struct {
+	Issuer                  string `yaml:"issuer"`
+	Skew                    int    `yaml:"skew"`
+	RecoveryCodes           int    `yaml:"recovery_codes"`
+	PendingTTLString        string `yaml:"pending_ttl"`
+	PendingTTL              time.Duration
+	PendingAttempts         int    `yaml:"pending_attempts"`
+	OTPLength               int    `yaml:"otp_length"`
+	OTPAttempts             int    `yaml:"otp_attempts"`
+	OTPTTLString            string `yaml:"otp_ttl"`
+	OTPTTL                  time.Duration
+	OTPResendIntervalString string `yaml:"otp_resend_interval"`
+	OTPResendInterval       time.Duration
+	TrustedDeviceTTLString  string `yaml:"trusted_device_ttl"`
+	TrustedDeviceTTL        time.Duration
+}
+
+// WebAuthnConfig is passkeys and security keys, they are off when RPID is empty.
+// RPID is domain credentials are bound to, Origins are pages allowed to use them,
+// like https://login.example.com. Attestation is "none", "indirect" or "direct".
+// Ceremony has to end within Timeout
+type WebAuthnConfig struct {
+	RPID          string   `yaml:"rp_id"`
+	RPName        string   `yaml:"rp_name"`
+	Origins       []string `yaml:"origins"`
+	Attestation   string   `yaml:"attestation"`
+	TimeoutString string   `yaml:"timeout"`
+	Timeout       time.Duration
+}
+
 type LogConfig struct {
 	Level string `yaml:"level"`
 }
 
 type Config struct {
-	HTTP       HTTPConfig  `yaml:"http_server"`
-	GRPC       GRPCConfig  `yaml:"grpc_server"`
-	Mongo      MongoConfig `yaml:"mongo"`
-	JWT        JWTConfig   `yaml:"jwt"`
-	Log        LogConfig   `yaml:"logging"`
+	HTTP     HTTPConfig     `yaml:"http_server"`
+	GRPC     GRPCConfig     `yaml:"grpc_server"`
+	Mongo    MongoConfig    `yaml:"mongo"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	Password PasswordConfig `yaml:"password"`
+	Notifier NotifierConfig `yaml:"notifier"`
+	Lockout  LockoutConfig  `yaml:"lockout"`
+	MFA      MFAConfig      `yaml:"mfa"`
+	WebAuthn WebAuthnConfig `yaml:"webauthn"`
+	Log      LogConfig      `yaml:"logging"`
 }
 
 var once sync.Once
 var configG *Config
 
+// redacted replaces secrets in the logged config
+const redacted = "REDACTED"
+
+// plainConfig is Config without String, so it can be printed with %+v
+type plainConfig Config
+
+// String is config with secrets and credentials replaced, so it can be logged
+func (c *Config) String() string {
+	safe := *c
+	safe.Mongo.Password = redact(c.Mongo.Password)
+	safe.Mongo.URI = redactURI(c.Mongo.URI)
+	safe.Mongo.URIFull = redactURI(c.Mongo.URIFull)
+	safe.JWT.Secret = redact(c.JWT.Secret)
+	safe.JWT.RefreshSecret = redact(c.JWT.RefreshSecret)
+	if c.JWT.IntrospectionClients != nil {
+		safe.JWT.IntrospectionClients = make(map[string]string, len(c.JWT.IntrospectionClients))
+		for client, secret := range c.JWT.IntrospectionClients {
+			safe.JWT.IntrospectionClients[client] = redact(secret)
+		}
+	}
+	safe.Password.Pepper.Keys = redact(c.Password.Pepper.Keys)
+	safe.Notifier.SMTP.Password = redact(c.Notifier.SMTP.Password)
+	safe.Notifier.SMS.Token = redact(c.Notifier.SMS.Token)
+	safe.Notifier.SMS.URL = redactURI(c.Notifier.SMS.URL)
+	return fmt.Sprintf("%+v", plainConfig(safe))
+}
+
+// redact keeps empty secret empty, so the log still tells which ones are set
+func redact(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURI hides password and query of uri, query may carry tokens too
+func redactURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return redact(uri)
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = redacted
+	}
+	return u.String()
+}
+
 const (
-	defaultConfig = "config/config.yaml"
+	JWTTokenFormat          = "jwt"
+	OpaqueTokenFormat       = "opaque"
+	PasetoPublicTokenFormat = "paseto_v4_public"
+	PasetoLocalTokenFormat  = "paseto_v4_local"
 )
 
-//Parses config ONCE, then just returns ptr to cfg
+const (
+	defaultConfig          = "config/config.yaml"
+	defaultRefreshGrace    = 10 * time.Second
+	defaultResetTTL        = 15 * time.Minute
+	defaultWebAuthnTimeout = 5 * time.Minute
+	defaultStepUpACR       = "aal1"
+	defaultStepUpMaxAge    = 5 * time.Minute
+	// defaultTrustedDeviceTTL is known here, access keys are kept for it
+	defaultTrustedDeviceTTL = 30 * 24 * time.Hour
+	defaultOutboxPath       = "outbox.jsonl"
+)
+
+// Parses config ONCE, then just returns ptr to cfg
 func NewConfig() *Config {
 	var cfgPath, jwtSecret string
 	once.Do(func() {
@@ -71,9 +322,6 @@ func NewConfig() *Config {
 			log.Println("if you are running localy -> export CFG_PATH=config/config_debug.yaml")
 			cfgPath = defaultConfig
 			jwtSecret = os.Getenv("JWT_SECRET")
-			if jwtSecret == "" {
-				log.Fatal("config NO JWT SECRET!")
-			}
 		}
 		file, err := os.Open(filepath.Clean(cfgPath))
 		if err != nil {
@@ -102,11 +350,168 @@ func NewConfig() *Config {
 			log.Fatal("refreshTTL should be not zero")
 		}
 		configG.JWT.RefreshTTL = refreshDur
-		if jwtSecret != "" {
-			configG.JWT.Secret = jwtSecret
+		for _, c := range []struct {
+			name *string
+			def  string
+		}{
+			{&configG.Mongo.FamilyCollection, "token_families"},
+			{&configG.Mongo.RevokedCollection, "revoked_tokens"},
+			{&configG.Mongo.OpaqueCollection, "opaque_tokens"},
+			{&configG.Mongo.ResetCollection, "password_reset_tokens"},
+			{&configG.Mongo.AttemptCollection, "login_attempts"},
+			{&configG.Mongo.ChallengeCollection, "webauthn_challenges"},
+			{&configG.Mongo.OTPCollection, "otp_codes"},
+			{&configG.Mongo.DeviceCollection, "trusted_devices"},
+		} {
+			if *c.name == "" {
+				*c.name = c.def
+			}
+		}
+		if configG.Notifier.OutboxPath == "" {
+			configG.Notifier.OutboxPath = defaultOutboxPath
+		}
+		configG.JWT.RefreshGrace = defaultRefreshGrace
+		if configG.JWT.RefreshGraceString != "" {
+			graceDur, err := str2duration.ParseDuration(configG.JWT.RefreshGraceString)
+			if err != nil {
+				log.Fatal("Couldn't parse JWT refreshGrace config")
+			}
+			configG.JWT.RefreshGrace = graceDur
+		}
+		for _, d := range []struct {
+			name  string
+			value string
+			dur   *time.Duration
+		}{
+			{"session_lifetime", configG.JWT.SessionLifetimeString, &configG.JWT.SessionLifetime},
+			{"idle_timeout", configG.JWT.IdleTimeoutString, &configG.JWT.IdleTimeout},
+			{"leeway", configG.JWT.LeewayString, &configG.JWT.Leeway},
+		} {
+			if d.value == "" {
+				continue
+			}
+			if *d.dur, err = str2duration.ParseDuration(d.value); err != nil {
+				log.Fatalf("Couldn't parse JWT %s config", d.name)
+			}
+		}
+		if alg := configG.JWT.Algorithm; configG.JWT.KeyringPath != "" {
+			log.Println("jwt signing keys are taken from ", configG.JWT.KeyringPath)
+		} else if alg != "" && alg != "HS256" {
+			if configG.JWT.PrivateKeyPath == "" {
+				log.Fatalf("cfg jwt private_key_path is required for %s", alg)
+			}
+		} else {
+			if cfgPath == defaultConfig && jwtSecret == "" {
+				log.Fatal("config NO JWT SECRET!")
+			}
+			if jwtSecret != "" {
+				configG.JWT.Secret = jwtSecret
+			}
+			if configG.JWT.Secret == "" {
+				log.Fatal("cfg jwt secret should not be empty")
+			}
+		}
+		if configG.JWT.TokenFormat == "" {
+			configG.JWT.TokenFormat = JWTTokenFormat
+		}
+		for _, format := range append([]string{configG.JWT.TokenFormat}, configG.JWT.AcceptTokenFormats...) {
+			switch format {
+			case JWTTokenFormat, OpaqueTokenFormat, PasetoPublicTokenFormat, PasetoLocalTokenFormat:
+			default:
+				log.Fatalf("cfg jwt unknown token format %s", format)
+			}
+		}
+		if configG.JWT.AcceptUntilString != "" {
+			until, err := time.Parse(time.RFC3339, configG.JWT.AcceptUntilString)
+			if err != nil {
+				log.Fatal("Couldn't parse JWT accept_until config")
+			}
+			configG.JWT.AcceptUntil = until
+		}
+		for _, d := range []struct {
+			name  string
+			value string
+			dur   *time.Duration
+		}{
+			{"base_delay", configG.Lockout.BaseDelayString, &configG.Lockout.BaseDelay},
+			{"max_delay", configG.Lockout.MaxDelayString, &configG.Lockout.MaxDelay},
+			{"lock_duration", configG.Lockout.LockDurationString, &configG.Lockout.LockDuration},
+			{"window", configG.Lockout.WindowString, &configG.Lockout.Window},
+		} {
+			if d.value == "" {
+				continue
+			}
+			if *d.dur, err = str2duration.ParseDuration(d.value); err != nil {
+				log.Fatalf("Couldn't parse lockout %s config", d.name)
+			}
+		}
+		for _, d := range []struct {
+			name  string
+			value string
+			dur   *time.Duration
+		}{
+			{"mfa pending_ttl", configG.MFA.PendingTTLString, &configG.MFA.PendingTTL},
+			{"mfa otp_ttl", configG.MFA.OTPTTLString, &configG.MFA.OTPTTL},
+			{"mfa otp_resend_interval", configG.MFA.OTPResendIntervalString, &configG.MFA.OTPResendInterval},
+			{"mfa trusted_device_ttl", configG.MFA.TrustedDeviceTTLString, &configG.MFA.TrustedDeviceTTL},
+			{"notifier sms timeout", configG.Notifier.SMS.TimeoutString, &configG.Notifier.SMS.Timeout},
+		} {
+			if d.value == "" {
+				continue
+			}
+			if *d.dur, err = str2duration.ParseDuration(d.value); err != nil {
+				log.Fatalf("Couldn't parse %s config", d.name)
+			}
+		}
+		if configG.MFA.TrustedDeviceTTL <= 0 {
+			configG.MFA.TrustedDeviceTTL = defaultTrustedDeviceTTL
+		}
+		if configG.HTTP.StepUpACR == "" {
+			configG.HTTP.StepUpACR = defaultStepUpACR
+		}
+		configG.HTTP.StepUpMaxAge = defaultStepUpMaxAge
+		if configG.HTTP.StepUpMaxAgeString != "" {
+			maxAge, err := str2duration.ParseDuration(configG.HTTP.StepUpMaxAgeString)
+			if err != nil {
+				log.Fatal("Couldn't parse http step_up_max_age config")
+			}
+			configG.HTTP.StepUpMaxAge = maxAge
+		}
+		configG.WebAuthn.Timeout = defaultWebAuthnTimeout
+		if configG.WebAuthn.TimeoutString != "" {
+			timeout, err := str2duration.ParseDuration(configG.WebAuthn.TimeoutString)
+			if err != nil {
+				log.Fatal("Couldn't parse webauthn timeout config")
+			}
+			configG.WebAuthn.Timeout = timeout
+		}
+		if configG.Password.MinAgeString != "" {
+			minAge, err := str2duration.ParseDuration(configG.Password.MinAgeString)
+			if err != nil {
+				log.Fatal("Couldn't parse password min_age config")
+			}
+			configG.Password.MinAge = minAge
+		}
+		if configG.Password.MaxAgeString != "" {
+			maxAge, err := str2duration.ParseDuration(configG.Password.MaxAgeString)
+			if err != nil {
+				log.Fatal("Couldn't parse password max_age config")
+			}
+			configG.Password.MaxAge = maxAge
+		}
+		configG.Password.ResetTTL = defaultResetTTL
+		if configG.Password.ResetTTLString != "" {
+			resetTTL, err := str2duration.ParseDuration(configG.Password.ResetTTLString)
+			if err != nil {
+				log.Fatal("Couldn't parse password reset_ttl config")
+			}
+			configG.Password.ResetTTL = resetTTL
+		}
+		if peppers := os.Getenv("PASSWORD_PEPPERS"); peppers != "" {
+			configG.Password.Pepper.Keys = peppers
 		}
-		if configG.JWT.Secret == "" {
-			log.Fatal("cfg jwt secret should not be empty")
+		if refreshSecret := os.Getenv("JWT_REFRESH_SECRET"); refreshSecret != "" {
+			configG.JWT.RefreshSecret = refreshSecret
 		}
 	})
 	return configG
@@ -2,80 +2,357 @@ package auth
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/DMA8/authService/internal/config"
 	e "github.com/DMA8/authService/internal/domain/errors"
 	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/domain/policy"
 	"github.com/DMA8/authService/internal/ports"
 	"github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/passwords"
+	"github.com/DMA8/authService/pkg/webauthn"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/DMA8/authService/pkg/tokens"
 )
 
+// Auth signs refresh tokens with refreshKeys so they can't be used as access tokens.
+// refreshKeys may be the same keyring as keys. Issuers create tokens in configured format
 type Auth struct {
-	jwtcfg     config.JWTConfig
-	repository ports.AuthStorage
+	jwtcfg        config.JWTConfig
+	keys          *tokens.Keyring
+	refreshKeys   *tokens.Keyring
+	issuer        ports.TokenIssuer
+	refreshIssuer ports.TokenIssuer
+	repository    ports.AuthStorage
+	tokenStorage  ports.TokenStorage
+	revocations   ports.RevocationStorage
+	hasher        *passwords.Hasher
+	policy        *policy.Policy
+	// historySize last passwords can't be reused, password lives at least minPasswordAge
+	historySize    int
+	minPasswordAge time.Duration
+	maxPasswordAge time.Duration
+	notifier       ports.Notifier
+	resetTTL       time.Duration
+	attempts       ports.AttemptStorage
+	lockout        config.LockoutConfig
+	mfa            config.MFAConfig
+	webauthn       *webauthn.RelyingParty
+	challenges     ports.ChallengeStorage
+	otps           ports.OTPStorage
+	devices        ports.DeviceStorage
+	// dummyHash is verified for unknown logins so they take as long as wrong passwords
+	dummyOnce sync.Once
+	dummyHash string
+	// deliveries are reset tokens being sent in background
+	deliveries sync.WaitGroup
 	logger     logging.Logger
 }
 
-func NewAuth(cfg config.JWTConfig, repo ports.AuthStorage, l logging.Logger) *Auth {
-	return &Auth{
-		repository: repo,
-		logger:     l,
-		jwtcfg:     cfg,
+// Option sets optional dependency of Auth
+type Option func(*Auth)
+
+// WithPasswordPolicy sets policy for new passwords. Default is policy.Default()
+func WithPasswordPolicy(p *policy.Policy) Option {
+	return func(a *Auth) {
+		a.policy = p
+	}
+}
+
+// WithHasher sets hasher of passwords. Default is argon2id with default parameters
+func WithHasher(hasher *passwords.Hasher) Option {
+	return func(a *Auth) {
+		a.hasher = hasher
+	}
+}
+
+// NewAuth creates Auth. refreshKeys nil means refresh tokens are signed with keys.
+// Tokens are issued in cfg.TokenFormat, tokens in cfg.AcceptTokenFormats are still accepted.
+// It fails when keys have no key for a paseto format
+func NewAuth(cfg config.JWTConfig, keys, refreshKeys *tokens.Keyring, repo ports.AuthStorage, tokenRepo ports.TokenStorage,
+	revocations ports.RevocationStorage, l logging.Logger, opts ...Option) (*Auth, error) {
+	if refreshKeys == nil {
+		refreshKeys = keys
+	}
+	issuer, err := newIssuerWithMigration(cfg, keys, tokenRepo)
+	if err != nil {
+		return nil, err
 	}
+	refreshIssuer, err := newIssuerWithMigration(cfg, refreshKeys, tokenRepo)
+	if err != nil {
+		return nil, fmt.Errorf("refresh keys: %w", err)
+	}
+	a := &Auth{
+		keys:          keys,
+		refreshKeys:   refreshKeys,
+		issuer:        issuer,
+		refreshIssuer: refreshIssuer,
+		repository:    repo,
+		tokenStorage:  tokenRepo,
+		revocations:   revocations,
+		hasher:        passwords.Default(),
+		policy:        policy.Default(),
+		resetTTL:      defaultResetTTL,
+		mfa:           mfaDefaults(config.MFAConfig{}, cfg.Issuer),
+		logger:        l,
+		jwtcfg:        cfg,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a, nil
 }
 
+// AuthUser checks login and password. With lockout configured login and client ip from ctx
+// which failed too often get *errors.RetryError with ErrTooManyAttempts or ErrAccountLocked.
+// Second factor is skipped on device trusted by TrustDevice, see models.WithDeviceToken
 func (a *Auth) AuthUser(ctx context.Context, userData *models.Credentials) error {
+	ip := models.ClientIP(ctx)
+	if err := a.checkAttempts(ctx, userData.Login, ip); err != nil {
+		a.logger.Debug().Err(err).Msgf("auth.AuthUser: login %s from %s is throttled", userData.Login, ip)
+		return err
+	}
+	user, err := a.authUser(ctx, userData)
+	if isLoginFailure(err) {
+		a.registerFailure(ctx, userData.Login, ip)
+		return err
+	} else if err != nil {
+		return err
+	}
+	if mfaEnabled(user) && !a.deviceTrusted(ctx, user) {
+		// failures are forgotten after the second factor, so the password doesn't buy more code guesses
+		a.logger.Debug().Msgf("auth.AuthUser: %s has to pass second factor", userData.Login)
+		return e.ErrMFARequired
+	}
+	a.resetLoginFailures(ctx, userData.Login)
+	if a.passwordExpired(user) {
+		a.logger.Debug().Msgf("auth.AuthUser: password of %s has to be changed", userData.Login)
+		return e.ErrPasswordChangeRequired
+	}
+	return nil
+}
+
+func (a *Auth) authUser(ctx context.Context, userData *models.Credentials) (*models.Credentials, error) {
 	dbAnswer, err := a.repository.GetUser(ctx, userData.Login)
+	if err == e.ErrNoUserInDB {
+		a.verifyDummy(userData.Password)
+	}
 	if err != nil {
-		a.logger.Debug().Err(err).Msgf("auth.AuthUser: couldn't get user from repo %+v", userData)
-		return err
+		a.logger.Debug().Err(err).Msgf("auth.AuthUser: couldn't get user %s from repo", userData.Login)
+		return nil, err
+	}
+	goodPass, rehash, err := a.hasher.Verify(userData.Password, dbAnswer.Password)
+	if goodPass {
+		if rehash {
+			a.rehashPassword(ctx, dbAnswer, userData.Password)
+		}
+		return dbAnswer, nil
+	}
+	a.logger.Debug().Err(err).Msgf("auth.AuthUser: wrong password of %s id %s", userData.Login, userID(dbAnswer))
+	return nil, e.ErrWrongPass
+}
+
+// verifyDummy spends the same time on unknown login as on wrong password
+func (a *Auth) verifyDummy(password string) {
+	a.dummyOnce.Do(func() {
+		secret, err := newSecretToken(resetTokenBytes)
+		if err == nil {
+			a.dummyHash, err = a.hasher.Hash(secret)
+		}
+		if err != nil {
+			a.logger.Error().Err(err).Msg("auth.verifyDummy: couldn't make dummy hash")
+		}
+	})
+	if a.dummyHash != "" {
+		a.hasher.Verify(password, a.dummyHash)
+	}
+}
+
+// rehashPassword replaces outdated hash of the user. Login succeeds even if it fails
+func (a *Auth) rehashPassword(ctx context.Context, user *models.Credentials, password string) {
+	hash, err := a.hasher.Hash(password)
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("auth.rehashPassword: couldn't hash password of %s", user.Login)
+		return
 	}
-	if goodPass := CheckPasswordHash(userData.Password, dbAnswer.Password); goodPass {
-		return nil
+	updated := *user
+	updated.Password = hash
+	if err = a.repository.UpdateUser(ctx, &updated); err != nil {
+		a.logger.Error().Err(err).Msgf("auth.rehashPassword: couldn't save new hash of %s", user.Login)
+		return
 	}
-	a.logger.Debug().Err(err).Msgf("auth.AuthUser: wrong password inp:%+v, db:%+v", userData, dbAnswer)
-	return e.ErrWrongPass
+	a.logger.Debug().Msgf("auth.rehashPassword: password hash of %s is upgraded", user.Login)
 }
 
+// CreateToken creates access or refresh token for login.
+// Each refresh token created here starts a new token family, so a new session starts now.
+// Methods the user authenticated with are taken from ctx, see models.WithAuthMethods
 func (a *Auth) CreateToken(ctx context.Context, login string, tokenType models.TokenType) (string, error) {
-	var dur time.Duration
 	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth CreateToken")
 	span.SetAttributes(attribute.KeyValue{Key: "token_type", Value: attribute.StringValue(string(tokenType))})
 
 	defer span.End()
 
+	authTime := time.Now().Truncate(time.Second)
+	amr := authMethods(models.AuthMethods(ctx))
 	switch tokenType {
 	case models.AccessTokenType:
-		dur = a.jwtcfg.AccesTTL
+		token, _, err := a.createAccessToken(ctx, login, authTime, amr)
+		return token, err
 	case models.RefreshTokenType:
-		dur = a.jwtcfg.RefreshTTL
+		return a.startFamily(ctx, login, authTime, amr)
+	case models.PasswordChangeTokenType:
+		return a.createRestrictedToken(ctx, login, tokenType, passwordChangeTokenTTL)
+	case models.MFAPendingTokenType:
+		return a.createRestrictedToken(ctx, login, tokenType, a.mfa.PendingTTL)
 	default:
 		a.logger.Debug().Err(nil).Msgf("service.CreateToken bad token type")
-		return "", errors.New("wrong token type")
+		return "", e.ErrWrongTokenType
 	}
-	token, err := tokens.CreateToken(login, a.jwtcfg.Secret, dur)
+}
+
+// createRestrictedToken is a short-lived token without roles, accepted only where tokenType is expected
+func (a *Auth) createRestrictedToken(ctx context.Context, login string, tokenType models.TokenType, ttl time.Duration) (string, error) {
+	if login == "" {
+		return "", e.ErrNoLoginTokenCreation
+	}
+	token, err := a.issuerFor(tokenType).Issue(ctx, a.newClaims(login, tokenType), ttl)
 	if err != nil {
-		a.logger.Debug().Err(err).Msgf("service.CreateToken couldn't create token login: %s. tokenType %v ", login, tokenType)
+		a.logger.Debug().Err(err).Msgf("service.CreateToken couldn't create %s token login: %s", tokenType, login)
+		return "", err
 	}
-	a.logger.Debug().Err(err).Msgf("service.CreateToken tokent created! login: %s. tokenType %v ", login, tokenType)
-	return token, err
+	return token, nil
 }
 
-// accepts token and if it is valid return login that should be encoded in token
-func (a *Auth) ValidateToken(ctx context.Context, tokenStr string) (string, error) {
+// createAccessToken puts user id, roles and custom claims of the user to access token.
+// Token doesn't outlive the session started at authTime with amr
+func (a *Auth) createAccessToken(ctx context.Context, login string, authTime time.Time, amr []string) (string, *tokens.Claims, error) {
+	if login == "" {
+		return "", nil, e.ErrNoLoginTokenCreation
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.CreateToken couldn't get user %s", login)
+		return "", nil, err
+	}
+	claims := a.newClaims(login, models.AccessTokenType)
+	if !user.ID.IsZero() {
+		claims.UserID = user.ID.Hex()
+	}
+	claims.Roles = user.Roles
+	claims.Custom = user.CustomClaims
+	claims.AuthTime = authTime
+	claims.AMR, claims.ACR = amr, models.ACR(amr)
+	token, err := a.issuerFor(models.AccessTokenType).Issue(ctx, claims, a.sessionTTL(a.jwtcfg.AccesTTL, authTime))
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.CreateToken couldn't create access token login: %s", login)
+		return "", nil, err
+	}
+	a.logger.Debug().Msgf("service.CreateToken access token created! login: %s", login)
+	return token, claims, nil
+}
+
+// authMethods adds mfa to amr of two methods or more
+func authMethods(amr []string) []string {
+	if len(amr) < 2 {
+		return amr
+	}
+	for _, method := range amr {
+		if method == models.AMRMultiFactor {
+			return amr
+		}
+	}
+	return append(append([]string{}, amr...), models.AMRMultiFactor)
+}
+
+func (a *Auth) newClaims(login string, tokenType models.TokenType) *tokens.Claims {
+	claims := &tokens.Claims{
+		Issuer:    a.jwtcfg.Issuer,
+		Subject:   login,
+		TokenType: string(tokenType),
+	}
+	if audience := a.audience(tokenType); audience != "" {
+		claims.Audience = []string{audience}
+	}
+	return claims
+}
+
+// audience of refresh tokens is RefreshAudience if it is set
+func (a *Auth) audience(tokenType models.TokenType) string {
+	if tokenType == models.RefreshTokenType && a.jwtcfg.RefreshAudience != "" {
+		return a.jwtcfg.RefreshAudience
+	}
+	return a.jwtcfg.Audience
+}
+
+func (a *Auth) issuerFor(tokenType models.TokenType) ports.TokenIssuer {
+	if tokenType == models.RefreshTokenType {
+		return a.refreshIssuer
+	}
+	return a.issuer
+}
+
+// parseToken checks the token, its type, issuer and audience. Revocation list is not checked
+func (a *Auth) parseToken(ctx context.Context, tokenStr string, tokenType models.TokenType) (*tokens.Claims, error) {
+	switch tokenType {
+	case models.AccessTokenType, models.RefreshTokenType, models.PasswordChangeTokenType, models.MFAPendingTokenType,
+		models.TrustedDeviceTokenType:
+	default:
+		return nil, e.ErrWrongTokenType
+	}
+	return a.issuerFor(tokenType).Verify(ctx, tokenStr, tokens.ValidationOptions{
+		Issuer:    a.jwtcfg.Issuer,
+		Audience:  a.audience(tokenType),
+		TokenType: string(tokenType),
+		Leeway:    a.jwtcfg.Leeway,
+	})
+}
+
+// ValidateToken accepts token and returns its claims if it is valid, issued for us and has expected type
+func (a *Auth) ValidateToken(ctx context.Context, tokenStr string, tokenType models.TokenType) (*tokens.Claims, error) {
 	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth ValidateToken")
 	span.SetAttributes(attribute.KeyValue{Key: "token", Value: attribute.StringValue(tokenStr)})
+	span.SetAttributes(attribute.KeyValue{Key: "token_type", Value: attribute.StringValue(string(tokenType))})
 	defer span.End()
-	login, err := tokens.ValidateToken(tokenStr, a.jwtcfg.Secret)
+	claims, err := a.parseToken(ctx, tokenStr, tokenType)
 	if err != nil {
-		a.logger.Debug().Err(err).Msgf("service.ValidateToken couldn't validate jwt tokens")
+		a.logger.Debug().Err(err).Msgf("service.ValidateToken couldn't validate token")
+		return nil, err
+	}
+	if err = a.checkRevoked(ctx, claims); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ValidateToken token %s is not accepted", claims.ID)
+		return nil, err
+	}
+	a.logger.Debug().Msgf("service.ValidateToken token ok. login is %s", claims.Subject)
+	return claims, nil
+}
+
+// JWKS returns public keys for local token verification. HS256 keys are not published
+func (a *Auth) JWKS(ctx context.Context) tokens.JWKSet {
+	return a.keys.JWKS(time.Now())
+}
+
+// ReloadKeys rereads keyring files so rotated keys are used without restart.
+// Only keys of access tokens are returned
+func (a *Auth) ReloadKeys(ctx context.Context) ([]tokens.KeyInfo, error) {
+	_, span := otel.Tracer("team31_auth").Start(ctx, "service auth ReloadKeys")
+	defer span.End()
+	if err := a.keys.Reload(); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ReloadKeys couldn't reload keyring")
+		return nil, err
+	}
+	if a.refreshKeys != a.keys {
+		if err := a.refreshKeys.Reload(); err != nil && err != tokens.ErrNoKeyringFile {
+			a.logger.Debug().Err(err).Msgf("service.ReloadKeys couldn't reload refresh keyring")
+			return nil, err
+		}
 	}
-	a.logger.Debug().Err(err).Msgf("service.ValidateToken token ok. login is %s", login)
-	return login, err
+	keys := a.keys.Keys(time.Now())
+	a.logger.Debug().Msgf("service.ReloadKeys keyring reloaded. keys: %+v", keys)
+	return keys, nil
 }
@@ -5,16 +5,21 @@ import (
 	"context"
 )
 
+// CreateUser saves user with password accepted by policy. Policy violations are *errors.ValidationError
 func (a *Auth) CreateUser(ctx context.Context, userData *models.Credentials) error {
-	passwordHash, err := HashPassword(userData.Password)
+	if err := a.policy.Check(userData.Login, userData.Password); err != nil {
+		a.logger.Debug().Err(err).Msgf("auth.CreateUser: password of %s is not accepted", userData.Login)
+		return err
+	}
+	passwordHash, err := a.hasher.Hash(userData.Password)
 	if err != nil {
-		a.logger.Debug().Err(err).Msgf("auth.CreateUser: couldn't create passwordHash %+v", userData)
+		a.logger.Debug().Err(err).Msgf("auth.CreateUser: couldn't create passwordHash of %s", userData.Login)
 		return err
 	}
-	userData.Password = passwordHash
+	a.setPassword(userData, nil, passwordHash)
 	err = a.repository.CreateUser(ctx, userData)
 	if err != nil {
-		a.logger.Debug().Err(err).Msgf("auth.CreateUser: couldn't create user %+v", userData)
+		a.logger.Debug().Err(err).Msgf("auth.CreateUser: couldn't create user %s", userData.Login)
 	}
 	return err
 }
@@ -22,20 +27,34 @@ func (a *Auth) CreateUser(ctx context.Context, userData *models.Credentials) err
 func (a *Auth) GetUser(ctx context.Context, login string) (*models.Credentials, error) {
 	creds, err := a.repository.GetUser(ctx, login)
 	if err != nil {
-		a.logger.Debug().Msgf("auth.GetUser: couldn't get user %+v", login)
+		a.logger.Debug().Msgf("auth.GetUser: couldn't get user %s", login)
 	}
 	return creds, err
 }
 
+// UpdateUser sets new password accepted by policy and password history
 func (a *Auth) UpdateUser(ctx context.Context, userData *models.Credentials) error {
-	hash, err := HashPassword(userData.Password)
+	if err := a.policy.Check(userData.Login, userData.Password); err != nil {
+		a.logger.Debug().Err(err).Msgf("auth.UpdateUser: password of %s is not accepted", userData.Login)
+		return err
+	}
+	user, err := a.repository.GetUser(ctx, userData.Login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("auth.UpdateUser couldn't get user %s", userData.Login)
+		return err
+	}
+	if err = a.checkPasswordChange(user, userData.Password, a.minPasswordAge); err != nil {
+		a.logger.Debug().Err(err).Msgf("auth.UpdateUser: password of %s can't be changed", userData.Login)
+		return err
+	}
+	hash, err := a.hasher.Hash(userData.Password)
 	if err != nil {
 		return err
 	}
-	userData.Password = hash
+	a.setPassword(userData, user, hash)
 	err = a.repository.UpdateUser(ctx, userData)
 	if err != nil {
-		a.logger.Debug().Err(err).Msgf("auth.UpdateUser couldn't update user %+v", userData)
+		a.logger.Debug().Err(err).Msgf("auth.UpdateUser couldn't update user %s", userData.Login)
 	}
 	return err
 }
@@ -43,7 +62,7 @@ func (a *Auth) UpdateUser(ctx context.Context, userData *models.Credentials) err
 func (a *Auth) DeleteUser(ctx context.Context, login string) error {
 	err := a.repository.DeleteUser(ctx, login)
 	if err != nil {
-		a.logger.Debug().Err(err).Msgf("auth.Delete couldn't delete user %+v", login)
+		a.logger.Debug().Err(err).Msgf("auth.Delete couldn't delete user %s", login)
 	}
 	return err
 }
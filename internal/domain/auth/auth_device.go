@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/ports"
+
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	defaultTrustedDeviceTTL = 30 * 24 * time.Hour
+	maxDeviceNameLength     = 128
+)
+
+// WithTrustedDevices lets users skip second factor on devices they trusted, devices are kept in storage
+func WithTrustedDevices(storage ports.DeviceStorage) Option {
+	return func(a *Auth) {
+		a.devices = storage
+	}
+}
+
+// TrustDevice remembers device of login which has just passed second factor. Returned token
+// makes AuthUser skip second factor on that device until it expires or the device is revoked.
+// name is shown in the list of devices, like user agent
+func (a *Auth) TrustDevice(ctx context.Context, login, name string) (string, *models.TrustedDevice, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth TrustDevice")
+	defer span.End()
+
+	if a.devices == nil {
+		return "", nil, e.ErrTrustedDevicesDisabled
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.TrustDevice couldn't get user %s", login)
+		return "", nil, err
+	}
+	claims := a.newClaims(login, models.TrustedDeviceTokenType)
+	token, err := a.issuerFor(models.TrustedDeviceTokenType).Issue(ctx, claims, a.mfa.TrustedDeviceTTL)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.TrustDevice couldn't create device token login: %s", login)
+		return "", nil, err
+	}
+	if runes := []rune(name); len(runes) > maxDeviceNameLength {
+		name = string(runes[:maxDeviceNameLength])
+	}
+	device := &models.TrustedDevice{
+		ID:        claims.ID,
+		Login:     login,
+		UserID:    userID(user),
+		Name:      name,
+		CreatedAt: time.Now(),
+		ExpiresAt: claims.ExpiresAt,
+	}
+	if err = a.devices.SaveDevice(ctx, device); err != nil {
+		a.logger.Error().Err(err).Msgf("service.TrustDevice couldn't save device of %s", login)
+		return "", nil, err
+	}
+	a.logger.Info().Msgf("service.TrustDevice %s trusted device %s", login, device.ID)
+	return token, device, nil
+}
+
+// TrustedDevices lists devices where login skips second factor
+func (a *Auth) TrustedDevices(ctx context.Context, login string) ([]models.TrustedDevice, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth TrustedDevices")
+	defer span.End()
+
+	if a.devices == nil {
+		return nil, e.ErrTrustedDevicesDisabled
+	}
+	devices, err := a.devices.ListDevices(ctx, login)
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.TrustedDevices couldn't list devices of %s", login)
+		return nil, err
+	}
+	return devices, nil
+}
+
+// RevokeDevice makes login pass second factor on device id again
+func (a *Auth) RevokeDevice(ctx context.Context, login, id string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth RevokeDevice")
+	defer span.End()
+
+	if a.devices == nil {
+		return e.ErrTrustedDevicesDisabled
+	}
+	if err := a.devices.DeleteDevice(ctx, login, id); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.RevokeDevice couldn't delete device %s of %s", id, login)
+		return err
+	}
+	a.logger.Info().Msgf("service.RevokeDevice device %s of %s is revoked", id, login)
+	return nil
+}
+
+// RevokeDevices makes login pass second factor on every device again
+func (a *Auth) RevokeDevices(ctx context.Context, login string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth RevokeDevices")
+	defer span.End()
+
+	if a.devices == nil {
+		return e.ErrTrustedDevicesDisabled
+	}
+	if err := a.devices.DeleteDevices(ctx, login); err != nil {
+		a.logger.Error().Err(err).Msgf("service.RevokeDevices couldn't delete devices of %s", login)
+		return err
+	}
+	a.logger.Info().Msgf("service.RevokeDevices devices of %s are revoked", login)
+	return nil
+}
+
+// deviceTrusted is true if ctx has token of a device user trusted, and the device is not revoked
+func (a *Auth) deviceTrusted(ctx context.Context, user *models.Credentials) bool {
+	token := models.DeviceToken(ctx)
+	if a.devices == nil || token == "" {
+		return false
+	}
+	claims, err := a.parseToken(ctx, token, models.TrustedDeviceTokenType)
+	if err != nil || claims.Subject != user.Login {
+		a.logger.Debug().Err(err).Msgf("service.deviceTrusted device token is not of %s", user.Login)
+		return false
+	}
+	device, err := a.devices.GetDevice(ctx, claims.ID)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.deviceTrusted device %s of %s is revoked", claims.ID, user.Login)
+		return false
+	}
+	if device.Login != user.Login || device.UserID != userID(user) {
+		a.logger.Warn().Msgf("service.deviceTrusted device %s belongs to another user", device.ID)
+		return false
+	}
+	if err = a.devices.TouchDevice(ctx, device.ID, time.Now()); err != nil {
+		a.logger.Error().Err(err).Msgf("service.deviceTrusted couldn't save last use of device %s", device.ID)
+	}
+	return true
+}
+
+func userID(user *models.Credentials) string {
+	if user.ID.IsZero() {
+		return ""
+	}
+	return user.ID.Hex()
+}
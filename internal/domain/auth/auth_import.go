@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/passwords"
+
+	"go.opentelemetry.io/otel"
+)
+
+// ImportUsers creates users with password hashes of other systems stored as they are.
+// Passwords are rehashed with configured algorithm on the first successful login.
+// A bad user doesn't stop import of the others
+func (a *Auth) ImportUsers(ctx context.Context, users []models.ImportedUser) *models.ImportResult {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth ImportUsers")
+	defer span.End()
+
+	result := &models.ImportResult{}
+	for _, user := range users {
+		if err := a.importUser(ctx, user); err != nil {
+			a.logger.Debug().Err(err).Msgf("service.ImportUsers couldn't import %s", user.Login)
+			result.Failed = append(result.Failed, models.ImportFailure{Login: user.Login, Error: err.Error()})
+			continue
+		}
+		result.Imported++
+	}
+	a.logger.Info().Msgf("service.ImportUsers imported %d users, %d failed", result.Imported, len(result.Failed))
+	return result
+}
+
+func (a *Auth) importUser(ctx context.Context, user models.ImportedUser) error {
+	if user.Login == "" {
+		return e.ErrNoLogin
+	}
+	hash, err := passwords.ImportHash(user.Algorithm, user.Hash, user.Salt)
+	if err != nil {
+		return err
+	}
+	// password age policies apply to imported passwords too, a timestamp from the future is not trusted
+	changedAt := time.Now()
+	if !user.PasswordChangedAt.IsZero() && user.PasswordChangedAt.Before(changedAt) {
+		changedAt = user.PasswordChangedAt
+	}
+	return a.repository.CreateUser(ctx, &models.Credentials{
+		Login:             user.Login,
+		Password:          hash,
+		Roles:             user.Roles,
+		PasswordChangedAt: changedAt,
+	})
+}
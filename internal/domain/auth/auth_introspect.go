@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"go.opentelemetry.io/otel"
+)
+
+// token_type_hint values of RFC 7009
+const (
+	accessTokenHint  = "access_token"
+	refreshTokenHint = "refresh_token"
+)
+
+// AuthClient checks credentials of a service calling introspection
+func (a *Auth) AuthClient(ctx context.Context, clientID, clientSecret string) error {
+	_, span := otel.Tracer("team31_auth").Start(ctx, "service auth AuthClient")
+	defer span.End()
+	// unknown client is compared too, so it takes as long as a wrong secret
+	secret, known := a.jwtcfg.IntrospectionClients[clientID]
+	given, expected := sha256.Sum256([]byte(clientSecret)), sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(given[:], expected[:]) != 1 || !known || secret == "" {
+		a.logger.Debug().Msgf("service.AuthClient bad credentials of client %s", clientID)
+		return e.ErrBadClientCreds
+	}
+	return nil
+}
+
+// Introspect describes token as RFC 7662 asks. Token of hinted type is tried first.
+// Refresh token is active only while it is the current one of a live family.
+// Why token is not active is only logged
+func (a *Auth) Introspect(ctx context.Context, tokenStr, tokenTypeHint string) *models.Introspection {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth Introspect")
+	defer span.End()
+
+	tokenTypes := []models.TokenType{models.AccessTokenType, models.RefreshTokenType}
+	if tokenTypeHint == refreshTokenHint {
+		tokenTypes[0], tokenTypes[1] = tokenTypes[1], tokenTypes[0]
+	}
+	for _, tokenType := range tokenTypes {
+		claims, err := a.ValidateToken(ctx, tokenStr, tokenType)
+		if err != nil {
+			a.logger.Debug().Err(err).Msgf("service.Introspect token is not an active %s token", tokenType)
+			continue
+		}
+		if tokenType == models.RefreshTokenType {
+			if err = a.checkFamily(ctx, claims); err != nil {
+				a.logger.Debug().Err(err).Msgf("service.Introspect family %s is not active", claims.Family)
+				continue
+			}
+		}
+		return introspection(claims)
+	}
+	return &models.Introspection{Active: false}
+}
+
+func introspection(claims *tokens.Claims) *models.Introspection {
+	tokenType := accessTokenHint
+	if claims.TokenType == string(models.RefreshTokenType) {
+		tokenType = refreshTokenHint
+	}
+	// client the token was issued for, the first audience when no client is put to the token
+	clientID, _ := claims.Custom["client_id"].(string)
+	if clientID == "" && len(claims.Audience) > 0 {
+		clientID = claims.Audience[0]
+	}
+	return &models.Introspection{
+		Active:    true,
+		Scope:     strings.Join(claims.Roles, " "),
+		ClientID:  clientID,
+		Username:  claims.Subject,
+		TokenType: tokenType,
+		ExpiresAt: unixTime(claims.ExpiresAt),
+		IssuedAt:  unixTime(claims.IssuedAt),
+		NotBefore: unixTime(claims.NotBefore),
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		TokenID:   claims.ID,
+		AuthTime:  unixTime(claims.AuthTime),
+		AMR:       claims.AMR,
+		ACR:       claims.ACR,
+	}
+}
+
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/ports"
+
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	defaultMaxFailures   = 5
+	defaultIPMaxFailures = 50
+	defaultBaseDelay     = time.Second
+	defaultMaxDelay      = 30 * time.Second
+	defaultLockDuration  = 15 * time.Minute
+	defaultLockWindow    = 15 * time.Minute
+
+	loginAttemptsPrefix = "login:"
+	ipAttemptsPrefix    = "ip:"
+)
+
+// WithLockout counts failed logins in storage and slows down or locks logins and ips
+// which fail too often. Zero values of cfg take defaults
+func WithLockout(storage ports.AttemptStorage, cfg config.LockoutConfig) Option {
+	return func(a *Auth) {
+		if cfg.MaxFailures == 0 {
+			cfg.MaxFailures = defaultMaxFailures
+		}
+		if cfg.IPMaxFailures == 0 {
+			cfg.IPMaxFailures = defaultIPMaxFailures
+		}
+		if cfg.BaseDelay == 0 {
+			cfg.BaseDelay = defaultBaseDelay
+		}
+		if cfg.MaxDelay == 0 {
+			cfg.MaxDelay = defaultMaxDelay
+		}
+		if cfg.LockDuration == 0 {
+			cfg.LockDuration = defaultLockDuration
+		}
+		if cfg.Window == 0 {
+			cfg.Window = defaultLockWindow
+		}
+		a.attempts = storage
+		a.lockout = cfg
+	}
+}
+
+// Unlock forgets failed logins and lockouts of login and ip. Empty ones are skipped
+func (a *Auth) Unlock(ctx context.Context, login, ip string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth Unlock")
+	defer span.End()
+
+	if a.attempts == nil {
+		return nil
+	}
+	for _, key := range attemptKeys(login, ip) {
+		if err := a.attempts.ResetAttempts(ctx, key); err != nil {
+			a.logger.Error().Err(err).Msgf("service.Unlock couldn't unlock %s", key)
+			return err
+		}
+	}
+	a.logger.Info().Msgf("service.Unlock login %q ip %q unlocked", login, ip)
+	return nil
+}
+
+// checkAttempts returns *errors.RetryError if login or ip may not try to log in now
+func (a *Auth) checkAttempts(ctx context.Context, login, ip string) error {
+	if a.attempts == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, key := range attemptKeys(login, ip) {
+		attempts, err := a.attempts.GetAttempts(ctx, key)
+		if err != nil {
+			a.logger.Error().Err(err).Msgf("auth.checkAttempts: couldn't get attempts of %s", key)
+			return err
+		}
+		if attempts.LockedUntil.After(now) {
+			lockErr := e.ErrAccountLocked
+			if key != loginAttemptsPrefix+login {
+				lockErr = e.ErrTooManyAttempts
+			}
+			return &e.RetryError{Err: lockErr, RetryAfter: attempts.LockedUntil.Sub(now)}
+		}
+		if attempts.Failures == 0 {
+			continue
+		}
+		if next := attempts.LastFailure.Add(a.backoff(attempts.Failures)); next.After(now) {
+			return &e.RetryError{Err: e.ErrTooManyAttempts, RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// registerFailure counts failed login and locks login or ip which reached its threshold
+func (a *Auth) registerFailure(ctx context.Context, login, ip string) {
+	if a.attempts == nil {
+		return
+	}
+	now := time.Now()
+	for _, key := range attemptKeys(login, ip) {
+		attempts, err := a.attempts.AddFailure(ctx, key, now, now.Add(a.lockout.Window))
+		if err != nil {
+			a.logger.Error().Err(err).Msgf("auth.registerFailure: couldn't count failure of %s", key)
+			continue
+		}
+		threshold := a.lockout.MaxFailures
+		if key != loginAttemptsPrefix+login {
+			threshold = a.lockout.IPMaxFailures
+		}
+		if attempts.Failures < threshold {
+			continue
+		}
+		a.logger.Warn().Msgf("auth.registerFailure: %s is locked after %d failed logins", key, attempts.Failures)
+		if err = a.attempts.Lock(ctx, key, now.Add(a.lockout.LockDuration)); err != nil {
+			a.logger.Error().Err(err).Msgf("auth.registerFailure: couldn't lock %s", key)
+		}
+	}
+}
+
+// resetLoginFailures forgets failures of login after successful login.
+// Failures of ip are kept, one valid account must not help guessing passwords of the others
+func (a *Auth) resetLoginFailures(ctx context.Context, login string) {
+	if a.attempts == nil {
+		return
+	}
+	if err := a.attempts.ResetAttempts(ctx, loginAttemptsPrefix+login); err != nil {
+		a.logger.Error().Err(err).Msgf("auth.resetLoginFailures: couldn't reset failures of %s", login)
+	}
+}
+
+// backoff is delay after failures failed logins in a row: BaseDelay doubled for each failure up to MaxDelay
+func (a *Auth) backoff(failures int) time.Duration {
+	delay := a.lockout.BaseDelay
+	for i := 1; i < failures && delay < a.lockout.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > a.lockout.MaxDelay {
+		delay = a.lockout.MaxDelay
+	}
+	return delay
+}
+
+func attemptKeys(login, ip string) []string {
+	keys := make([]string, 0, 2)
+	if login != "" {
+		keys = append(keys, loginAttemptsPrefix+login)
+	}
+	if ip != "" {
+		keys = append(keys, ipAttemptsPrefix+ip)
+	}
+	return keys
+}
+
+// isLoginFailure tells if AuthUser error is a wrong guess of login or password
+func isLoginFailure(err error) bool {
+	return err == e.ErrWrongPass || err == e.ErrNoUserInDB
+}
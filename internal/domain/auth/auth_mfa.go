@@ -0,0 +1,353 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/tokens"
+	"github.com/DMA8/authService/pkg/totp"
+
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	defaultMFAIssuer        = "authService"
+	defaultMFASkew          = 1
+	defaultMFAPendingTTL    = 5 * time.Minute
+	defaultPendingAttempts  = 5
+	defaultRecoveryCodes    = 10
+	recoveryCodeBytes       = 10
+	recoveryCodeGroupLength = 4
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// WithMFA sets TOTP parameters, zero values take defaults
+func WithMFA(cfg config.MFAConfig) Option {
+	return func(a *Auth) {
+		a.mfa = mfaDefaults(cfg, a.jwtcfg.Issuer)
+	}
+}
+
+func mfaDefaults(cfg config.MFAConfig, issuer string) config.MFAConfig {
+	if cfg.Issuer == "" {
+		cfg.Issuer = issuer
+	}
+	if cfg.Issuer == "" {
+		cfg.Issuer = defaultMFAIssuer
+	}
+	if cfg.Skew <= 0 {
+		cfg.Skew = defaultMFASkew
+	}
+	if cfg.PendingTTL <= 0 {
+		cfg.PendingTTL = defaultMFAPendingTTL
+	}
+	if cfg.PendingAttempts <= 0 {
+		cfg.PendingAttempts = defaultPendingAttempts
+	}
+	if cfg.RecoveryCodes <= 0 {
+		cfg.RecoveryCodes = defaultRecoveryCodes
+	}
+	if cfg.OTPLength <= 0 || cfg.OTPLength > maxOTPLength {
+		cfg.OTPLength = defaultOTPLength
+	}
+	if cfg.OTPAttempts <= 0 {
+		cfg.OTPAttempts = defaultOTPAttempts
+	}
+	if cfg.OTPTTL <= 0 {
+		cfg.OTPTTL = defaultOTPTTL
+	}
+	if cfg.OTPResendInterval <= 0 {
+		cfg.OTPResendInterval = defaultOTPResendInterval
+	}
+	if cfg.TrustedDeviceTTL <= 0 {
+		cfg.TrustedDeviceTTL = defaultTrustedDeviceTTL
+	}
+	return cfg
+}
+
+// mfaEnabled is true if user has confirmed TOTP, one-time codes or a webauthn credential
+func mfaEnabled(user *models.Credentials) bool {
+	return totpEnabled(user) || otpEnabled(user) || len(user.WebAuthn) > 0
+}
+
+func totpEnabled(user *models.Credentials) bool {
+	return user.MFA != nil && user.MFA.Enabled
+}
+
+// EnrollTOTP makes a new TOTP secret for user. It is not used at login until ConfirmTOTP
+func (a *Auth) EnrollTOTP(ctx context.Context, login string) (*models.TOTPEnrollment, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth EnrollTOTP")
+	defer span.End()
+
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.EnrollTOTP couldn't get user %s", login)
+		return nil, err
+	}
+	if totpEnabled(user) {
+		return nil, e.ErrMFAEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		a.logger.Error().Err(err).Msg("service.EnrollTOTP couldn't generate secret")
+		return nil, err
+	}
+	if err = a.repository.UpdateMFA(ctx, login, &models.MFA{TOTPSecret: secret}); err != nil {
+		a.logger.Error().Err(err).Msgf("service.EnrollTOTP couldn't save secret of %s", login)
+		return nil, err
+	}
+	return &models.TOTPEnrollment{Secret: secret, URI: totp.URI(a.mfa.Issuer, login, secret)}, nil
+}
+
+// ConfirmTOTP turns second factor on once user sends a code of the enrolled secret.
+// It returns recovery codes, they are shown only once
+func (a *Auth) ConfirmTOTP(ctx context.Context, login, code string) ([]string, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth ConfirmTOTP")
+	defer span.End()
+
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ConfirmTOTP couldn't get user %s", login)
+		return nil, err
+	}
+	if totpEnabled(user) {
+		return nil, e.ErrMFAEnabled
+	}
+	if user.MFA == nil || user.MFA.TOTPSecret == "" {
+		return nil, e.ErrMFANotEnrolled
+	}
+	step, ok, err := totp.Validate(user.MFA.TOTPSecret, code, time.Now(), a.mfa.Skew)
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.ConfirmTOTP bad secret of %s", login)
+		return nil, err
+	}
+	if !ok {
+		return nil, e.ErrBadMFACode
+	}
+	codes, hashes, err := a.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa := &models.MFA{Enabled: true, TOTPSecret: user.MFA.TOTPSecret, LastStep: step, RecoveryCodes: hashes}
+	if err = a.repository.UpdateMFA(ctx, login, mfa); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ConfirmTOTP couldn't enable second factor of %s", login)
+		return nil, err
+	}
+	a.logger.Info().Msgf("service.ConfirmTOTP second factor of %s is enabled", login)
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of user, code is TOTP, recovery or one-time code
+func (a *Auth) RegenerateRecoveryCodes(ctx context.Context, login, code string) ([]string, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth RegenerateRecoveryCodes")
+	defer span.End()
+
+	user, err := a.mfaUser(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	mfa, err := a.verifyMFACode(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := a.newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		current := *user.MFA
+		mfa = &current
+	}
+	mfa.RecoveryCodes = hashes
+	if err = a.repository.UpdateMFA(ctx, login, mfa); err != nil {
+		a.logger.Error().Err(err).Msgf("service.RegenerateRecoveryCodes couldn't save codes of %s", login)
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableMFA turns TOTP off, code is TOTP, recovery or one-time code
+func (a *Auth) DisableMFA(ctx context.Context, login, code string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth DisableMFA")
+	defer span.End()
+
+	user, err := a.mfaUser(ctx, login)
+	if err != nil {
+		return err
+	}
+	if _, err = a.verifyMFACode(ctx, user, code); err != nil {
+		return err
+	}
+	if err = a.repository.UpdateMFA(ctx, login, nil); err != nil {
+		a.logger.Error().Err(err).Msgf("service.DisableMFA couldn't disable second factor of %s", login)
+		return err
+	}
+	a.logger.Info().Msgf("service.DisableMFA second factor of %s is disabled", login)
+	return nil
+}
+
+// VerifyMFA finishes login started with password with TOTP, recovery or one-time code. mfaToken is the token
+// given after password, it can't be used again and is revoked after PendingAttempts wrong codes even without lockout.
+// It returns login of the user, which may still have to change password
+func (a *Auth) VerifyMFA(ctx context.Context, mfaToken, code string) (string, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth VerifyMFA")
+	defer span.End()
+
+	claims, err := a.ValidateToken(ctx, mfaToken, models.MFAPendingTokenType)
+	if err != nil {
+		a.logger.Debug().Err(err).Msg("service.VerifyMFA bad mfa token")
+		return "", err
+	}
+	user, err := a.repository.GetUser(ctx, claims.Subject)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.VerifyMFA couldn't get user %s", claims.Subject)
+		return "", err
+	}
+	if !totpEnabled(user) && !otpEnabled(user) {
+		return "", e.ErrMFANotEnabled
+	}
+	// the attempt is counted before the check, so concurrent guesses can't go over the limit
+	attempts, err := a.revocations.AddTokenAttempt(ctx, claims.ID, claims.ExpiresAt)
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.VerifyMFA couldn't count attempt of token %s", claims.ID)
+		return "", err
+	}
+	if attempts > a.mfa.PendingAttempts {
+		a.logger.Debug().Msgf("service.VerifyMFA mfa token of %s is guessed too often", user.Login)
+		return "", a.revokePending(ctx, mfaToken, claims)
+	}
+	mfa, err := a.verifyMFACode(ctx, user, code)
+	if err == e.ErrBadMFACode && attempts == a.mfa.PendingAttempts {
+		a.logger.Debug().Msgf("service.VerifyMFA the last code for mfa token of %s is wrong", user.Login)
+		return "", a.revokePending(ctx, mfaToken, claims)
+	} else if err != nil {
+		return "", err
+	}
+	if mfa != nil {
+		if err = a.repository.UpdateMFA(ctx, user.Login, mfa); err != nil {
+			a.logger.Error().Err(err).Msgf("service.VerifyMFA couldn't save second factor of %s", user.Login)
+			return "", err
+		}
+	}
+	if err = a.revokeToken(ctx, mfaToken, claims, models.MFAPendingTokenType); err != nil {
+		return "", err
+	}
+	if a.passwordExpired(user) {
+		return user.Login, e.ErrPasswordChangeRequired
+	}
+	return user.Login, nil
+}
+
+// revokePending ends login whose mfa token has no guesses left. It returns ErrBadMFACode
+// for the guess itself, the token is rejected afterwards
+func (a *Auth) revokePending(ctx context.Context, mfaToken string, claims *tokens.Claims) error {
+	if err := a.revokeToken(ctx, mfaToken, claims, models.MFAPendingTokenType); err != nil {
+		return err
+	}
+	return e.ErrBadMFACode
+}
+
+// mfaUser gets user with enabled TOTP
+func (a *Auth) mfaUser(ctx context.Context, login string) (*models.Credentials, error) {
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.mfaUser couldn't get user %s", login)
+		return nil, err
+	}
+	if !totpEnabled(user) {
+		return nil, e.ErrMFANotEnabled
+	}
+	return user, nil
+}
+
+// verifyMFACode checks TOTP, recovery or one-time code under lockout rules of login, wrong codes count
+// as failed logins. It returns TOTP state with the code used up, the caller saves it. It is nil if one-time code was used
+func (a *Auth) verifyMFACode(ctx context.Context, user *models.Credentials, code string) (*models.MFA, error) {
+	ip := models.ClientIP(ctx)
+	if err := a.checkAttempts(ctx, user.Login, ip); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.verifyMFACode %s from %s is throttled", user.Login, ip)
+		return nil, err
+	}
+	var mfa *models.MFA
+	ok := false
+	if totpEnabled(user) {
+		mfa, ok = a.useMFACode(user.MFA, code, time.Now())
+	}
+	if !ok && otpEnabled(user) {
+		var err error
+		if ok, err = a.useOTP(ctx, user.Login, code); err != nil {
+			return nil, err
+		}
+	}
+	if !ok {
+		a.registerFailure(ctx, user.Login, ip)
+		a.logger.Debug().Msgf("service.verifyMFACode wrong code of %s", user.Login)
+		return nil, e.ErrBadMFACode
+	}
+	a.resetLoginFailures(ctx, user.Login)
+	return mfa, nil
+}
+
+// useMFACode accepts TOTP code newer than the last accepted one or unused recovery code
+func (a *Auth) useMFACode(mfa *models.MFA, code string, now time.Time) (*models.MFA, bool) {
+	used := *mfa
+	code = strings.TrimSpace(code)
+	step, ok, err := totp.Validate(mfa.TOTPSecret, code, now, a.mfa.Skew)
+	if err != nil {
+		a.logger.Error().Err(err).Msg("service.useMFACode bad totp secret")
+	}
+	if ok && step > mfa.LastStep {
+		used.LastStep = step
+		return &used, true
+	} else if ok {
+		return nil, false
+	}
+	hash := hashToken(normalizeRecoveryCode(code))
+	for i, stored := range mfa.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			used.RecoveryCodes = append(append([]string{}, mfa.RecoveryCodes[:i]...), mfa.RecoveryCodes[i+1:]...)
+			return &used, true
+		}
+	}
+	return nil, false
+}
+
+// newRecoveryCodes makes codes like abcd-efgh-ijkl-mnop and their hashes to store
+func (a *Auth) newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, a.mfa.RecoveryCodes)
+	hashes := make([]string, a.mfa.RecoveryCodes)
+	for i := range codes {
+		random := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(random); err != nil {
+			a.logger.Error().Err(err).Msg("service.newRecoveryCodes couldn't make code")
+			return nil, nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(random))
+		groups := make([]string, 0, len(code)/recoveryCodeGroupLength)
+		for len(code) > 0 {
+			n := recoveryCodeGroupLength
+			if len(code) < n {
+				n = len(code)
+			}
+			groups = append(groups, code[:n])
+			code = code[n:]
+		}
+		codes[i] = strings.Join(groups, "-")
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode lets user type code in any case, with or without dashes
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/ports"
+
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	defaultOTPLength         = 6
+	maxOTPLength             = 10
+	defaultOTPAttempts       = 5
+	defaultOTPTTL            = 5 * time.Minute
+	defaultOTPResendInterval = 30 * time.Second
+)
+
+// phoneNumber is E.164 number, like +15550100
+var phoneNumber = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+// WithOTP turns on one-time codes sent by notifier, codes are kept in storage
+func WithOTP(storage ports.OTPStorage) Option {
+	return func(a *Auth) {
+		a.otps = storage
+	}
+}
+
+func otpEnabled(user *models.Credentials) bool {
+	return user.OTP != nil && user.OTP.Enabled
+}
+
+// EnrollOTP sets where one-time codes of login go and sends a code there.
+// Codes are not asked at login until ConfirmOTP
+func (a *Auth) EnrollOTP(ctx context.Context, login, channel, address string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth EnrollOTP")
+	defer span.End()
+
+	if a.otps == nil || a.notifier == nil {
+		return e.ErrNoNotifier
+	}
+	if err := checkOTPAddress(channel, address); err != nil {
+		return err
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.EnrollOTP couldn't get user %s", login)
+		return err
+	}
+	if otpEnabled(user) {
+		return e.ErrMFAEnabled
+	}
+	factor := &models.OTPFactor{Channel: channel, Address: address}
+	if err = a.repository.UpdateOTP(ctx, login, factor); err != nil {
+		a.logger.Error().Err(err).Msgf("service.EnrollOTP couldn't save address of %s", login)
+		return err
+	}
+	return a.sendOTP(ctx, login, factor)
+}
+
+// ConfirmOTP turns one-time codes on once user enters the code sent by EnrollOTP
+func (a *Auth) ConfirmOTP(ctx context.Context, login, code string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth ConfirmOTP")
+	defer span.End()
+
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ConfirmOTP couldn't get user %s", login)
+		return err
+	}
+	if otpEnabled(user) {
+		return e.ErrMFAEnabled
+	}
+	if user.OTP == nil {
+		return e.ErrMFANotEnrolled
+	}
+	ok, err := a.useOTP(ctx, login, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return e.ErrBadMFACode
+	}
+	factor := *user.OTP
+	factor.Enabled = true
+	if err = a.repository.UpdateOTP(ctx, login, &factor); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ConfirmOTP couldn't enable one-time codes of %s", login)
+		return err
+	}
+	a.logger.Info().Msgf("service.ConfirmOTP one-time codes of %s are enabled", login)
+	return nil
+}
+
+// DisableOTP stops sending one-time codes to login
+func (a *Auth) DisableOTP(ctx context.Context, login string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth DisableOTP")
+	defer span.End()
+
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.DisableOTP couldn't get user %s", login)
+		return err
+	}
+	if user.OTP == nil {
+		return e.ErrMFANotEnabled
+	}
+	if err = a.repository.UpdateOTP(ctx, login, nil); err != nil {
+		a.logger.Error().Err(err).Msgf("service.DisableOTP couldn't disable one-time codes of %s", login)
+		return err
+	}
+	if a.otps != nil {
+		if err = a.otps.DeleteOTP(ctx, login); err != nil && err != e.ErrNoOTP {
+			a.logger.Error().Err(err).Msgf("service.DisableOTP couldn't delete code of %s", login)
+		}
+	}
+	a.logger.Info().Msgf("service.DisableOTP one-time codes of %s are disabled", login)
+	return nil
+}
+
+// SendMFACode sends one-time code for login started with password. mfaToken is the token given after password,
+// the code is then checked by VerifyMFA like TOTP codes
+func (a *Auth) SendMFACode(ctx context.Context, mfaToken string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth SendMFACode")
+	defer span.End()
+
+	if a.otps == nil || a.notifier == nil {
+		return e.ErrNoNotifier
+	}
+	claims, err := a.ValidateToken(ctx, mfaToken, models.MFAPendingTokenType)
+	if err != nil {
+		a.logger.Debug().Err(err).Msg("service.SendMFACode bad mfa token")
+		return err
+	}
+	user, err := a.repository.GetUser(ctx, claims.Subject)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.SendMFACode couldn't get user %s", claims.Subject)
+		return err
+	}
+	if !otpEnabled(user) {
+		return e.ErrMFANotEnabled
+	}
+	return a.sendOTP(ctx, user.Login, user.OTP)
+}
+
+// sendOTP replaces code of login with a new one and sends it. Codes are not sent more often than resend interval
+func (a *Auth) sendOTP(ctx context.Context, login string, factor *models.OTPFactor) error {
+	now := time.Now()
+	previous, err := a.otps.GetOTP(ctx, login)
+	if err == nil && now.Before(previous.CreatedAt.Add(a.mfa.OTPResendInterval)) {
+		return &e.RetryError{Err: e.ErrOTPSent, RetryAfter: previous.CreatedAt.Add(a.mfa.OTPResendInterval).Sub(now)}
+	} else if err != nil && err != e.ErrNoOTP {
+		a.logger.Error().Err(err).Msgf("service.sendOTP couldn't get code of %s", login)
+		return err
+	}
+	code, err := newOTPCode(a.mfa.OTPLength)
+	if err != nil {
+		a.logger.Error().Err(err).Msg("service.sendOTP couldn't make code")
+		return err
+	}
+	err = a.otps.SaveOTP(ctx, &models.OTPCode{
+		Login:     login,
+		Hash:      hashOTP(login, code),
+		CreatedAt: now,
+		ExpiresAt: now.Add(a.mfa.OTPTTL),
+	})
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.sendOTP couldn't save code of %s", login)
+		return err
+	}
+	err = a.notifier.Notify(ctx, &models.Notification{
+		Recipient: login,
+		Channel:   factor.Channel,
+		Address:   factor.Address,
+		Kind:      models.OTPNotification,
+		Subject:   "Login code",
+		Text:      fmt.Sprintf("Your login code is %s\nIt expires in %s.", code, a.mfa.OTPTTL),
+		Secret:    code,
+		CreatedAt: now,
+	})
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.sendOTP couldn't send code to %s", login)
+		// user may ask for another code right away
+		a.otps.DeleteOTP(ctx, login)
+		return err
+	}
+	a.logger.Debug().Msgf("service.sendOTP code sent to %s by %s", login, factor.Channel)
+	return nil
+}
+
+// useOTP checks code of login. Every guess is counted, the code is gone once used or guessed too often
+func (a *Auth) useOTP(ctx context.Context, login, code string) (bool, error) {
+	if a.otps == nil {
+		return false, nil
+	}
+	stored, err := a.otps.AddOTPAttempt(ctx, login)
+	if err == e.ErrNoOTP {
+		return false, nil
+	} else if err != nil {
+		a.logger.Error().Err(err).Msgf("service.useOTP couldn't count attempt of %s", login)
+		return false, err
+	}
+	matched := subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(hashOTP(login, strings.TrimSpace(code)))) == 1
+	if stored.Attempts > a.mfa.OTPAttempts || !matched && stored.Attempts == a.mfa.OTPAttempts {
+		a.logger.Debug().Msgf("service.useOTP code of %s is guessed too often", login)
+		a.otps.DeleteOTP(ctx, login)
+		return false, nil
+	}
+	if !matched {
+		return false, nil
+	}
+	// concurrent request may have used the code already
+	if err = a.otps.DeleteOTP(ctx, login); err == e.ErrNoOTP {
+		return false, nil
+	} else if err != nil {
+		a.logger.Error().Err(err).Msgf("service.useOTP couldn't delete code of %s", login)
+		return false, err
+	}
+	return true, nil
+}
+
+func checkOTPAddress(channel, address string) error {
+	switch channel {
+	case models.EmailChannel:
+		parsed, err := mail.ParseAddress(address)
+		if err != nil || parsed.Address != address {
+			return e.ErrBadOTPAddress
+		}
+	case models.SMSChannel:
+		if !phoneNumber.MatchString(address) {
+			return e.ErrBadOTPAddress
+		}
+	default:
+		return e.ErrBadOTPChannel
+	}
+	return nil
+}
+
+// newOTPCode makes uniformly random code of length digits
+func newOTPCode(length int) (string, error) {
+	n, err := rand.Int(rand.Reader, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(length)), nil))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", length, n), nil
+}
+
+// hashOTP binds code to login, so the same code of another user has another hash
+func hashOTP(login, code string) string {
+	return hashToken(login + ":" + code)
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/domain/policy"
+
+	"go.opentelemetry.io/otel"
+)
+
+// WithPasswordHistory forbids reuse of the last size passwords and password
+// changes more often than minAge. Zero values turn the checks off
+func WithPasswordHistory(size int, minAge time.Duration) Option {
+	return func(a *Auth) {
+		a.historySize = size
+		a.minPasswordAge = minAge
+	}
+}
+
+// passwordChangeTokenTTL is lifetime of token which only lets user change expired password
+const passwordChangeTokenTTL = 10 * time.Minute
+
+// WithPasswordMaxAge makes passwords older than maxAge expire, they have to be changed at login.
+// Zero maxAge turns expiration off
+func WithPasswordMaxAge(maxAge time.Duration) Option {
+	return func(a *Auth) {
+		a.maxPasswordAge = maxAge
+	}
+}
+
+// passwordExpired tells if user has to change password before getting tokens.
+// Passwords set before the timestamp was kept don't expire by age
+func (a *Auth) passwordExpired(user *models.Credentials) bool {
+	if user.MustChangePassword {
+		return true
+	}
+	return a.maxPasswordAge > 0 && !user.PasswordChangedAt.IsZero() && time.Since(user.PasswordChangedAt) > a.maxPasswordAge
+}
+
+// ExpirePassword makes user change password at the next login, ends all sessions of the user
+// and revokes access tokens issued before
+func (a *Auth) ExpirePassword(ctx context.Context, login string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth ExpirePassword")
+	defer span.End()
+
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ExpirePassword couldn't get user %s", login)
+		return err
+	}
+	updated := *user
+	updated.MustChangePassword = true
+	if err = a.repository.UpdateUser(ctx, &updated); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ExpirePassword couldn't save user %s", login)
+		return err
+	}
+	if err = a.tokenStorage.RevokeUserFamilies(ctx, login); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ExpirePassword couldn't end sessions of %s", login)
+		return err
+	}
+	if err = a.revokeUserTokens(ctx, login, time.Now()); err != nil {
+		return err
+	}
+	a.logger.Info().Msgf("service.ExpirePassword password of %s is expired", login)
+	return nil
+}
+
+// ChangePassword sets new password of login after checking the current one.
+// Wrong current passwords count as failed logins. Every session and token of the user ends,
+// the caller gets tokens of a new session
+func (a *Auth) ChangePassword(ctx context.Context, login, currentPassword, newPassword string) (*models.TokenPair, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth ChangePassword")
+	defer span.End()
+
+	ip := models.ClientIP(ctx)
+	if err := a.checkAttempts(ctx, login, ip); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ChangePassword %s from %s is throttled", login, ip)
+		return nil, err
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ChangePassword couldn't get user %s", login)
+		return nil, err
+	}
+	if ok, _, _ := a.hasher.Verify(currentPassword, user.Password); !ok {
+		a.logger.Debug().Msgf("service.ChangePassword wrong current password of %s", login)
+		a.registerFailure(ctx, login, ip)
+		return nil, e.ErrWrongPass
+	}
+	a.resetLoginFailures(ctx, login)
+	if err = a.policy.Check(login, newPassword); err != nil {
+		return nil, err
+	}
+	// expired password can be changed right away, even if it was set a moment ago
+	minAge := a.minPasswordAge
+	if a.passwordExpired(user) {
+		minAge = 0
+	}
+	if err = a.checkPasswordChange(user, newPassword, minAge); err != nil {
+		return nil, err
+	}
+	hash, err := a.hasher.Hash(newPassword)
+	if err != nil {
+		return nil, err
+	}
+	updated := *user
+	a.setPassword(&updated, user, hash)
+	updated.MustChangePassword = false
+	if err = a.repository.UpdateUser(ctx, &updated); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ChangePassword couldn't save password of %s", login)
+		return nil, err
+	}
+	if err = a.tokenStorage.RevokeUserFamilies(ctx, login); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ChangePassword couldn't end sessions of %s", login)
+		return nil, err
+	}
+	if err = a.revokeUserTokens(ctx, login, updated.PasswordChangedAt); err != nil {
+		return nil, err
+	}
+	// the user has just proved the password, so it is a fresh login
+	authTime, amr := time.Now().Truncate(time.Second), []string{models.AMRPassword}
+	accessToken, claims, err := a.createAccessToken(ctx, login, authTime, amr)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := a.startFamily(ctx, login, authTime, amr)
+	if err != nil {
+		return nil, err
+	}
+	a.logger.Debug().Msgf("service.ChangePassword password of %s is changed", login)
+	return &models.TokenPair{Login: login, AccessToken: accessToken, RefreshToken: refreshToken, Claims: claims}, nil
+}
+
+// checkPasswordChange checks that user may replace current password with password now.
+// Password must be older than minAge, zero minAge is not checked
+func (a *Auth) checkPasswordChange(user *models.Credentials, password string, minAge time.Duration) error {
+	var violations e.ValidationError
+	if minAge > 0 && !user.PasswordChangedAt.IsZero() && time.Since(user.PasswordChangedAt) < minAge {
+		violations.Add(policy.PasswordField, policy.CodePasswordTooNew,
+			fmt.Sprintf("can be changed once in %s", minAge))
+	}
+	for _, hash := range a.recentHashes(user) {
+		if ok, _, _ := a.hasher.Verify(password, hash); ok {
+			violations.Add(policy.PasswordField, policy.CodePasswordReused,
+				fmt.Sprintf("must differ from the last %d passwords", a.historySize))
+			break
+		}
+	}
+	return violations.Err()
+}
+
+// recentHashes are hashes of the last historySize passwords including current one
+func (a *Auth) recentHashes(user *models.Credentials) []string {
+	if a.historySize <= 0 {
+		return nil
+	}
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > a.historySize {
+		hashes = hashes[:a.historySize]
+	}
+	return hashes
+}
+
+// setPassword makes hash the current password of updated and moves current
+// password of user to the history, which keeps historySize-1 previous hashes
+func (a *Auth) setPassword(updated, user *models.Credentials, hash string) {
+	updated.Password = hash
+	updated.PasswordChangedAt = time.Now()
+	updated.PasswordHistory = nil
+	if a.historySize > 1 && user != nil && user.Password != "" {
+		updated.PasswordHistory = a.recentHashes(user)
+		if len(updated.PasswordHistory) > a.historySize-1 {
+			updated.PasswordHistory = updated.PasswordHistory[:a.historySize-1]
+		}
+	}
+}
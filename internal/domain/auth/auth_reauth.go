@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/webauthn"
+
+	"go.opentelemetry.io/otel"
+)
+
+// Reauthenticate checks password of logged in user again, and the code when TOTP or one-time codes are on.
+// It returns methods used, tokens created with them in ctx have fresh auth_time.
+// Users whose only second factor is a security key get ErrMFARequired and use FinishWebAuthnReauth.
+// Failures count as failed logins
+func (a *Auth) Reauthenticate(ctx context.Context, login, password, code string) ([]string, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth Reauthenticate")
+	defer span.End()
+
+	ip := models.ClientIP(ctx)
+	if err := a.checkAttempts(ctx, login, ip); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.Reauthenticate %s from %s is throttled", login, ip)
+		return nil, err
+	}
+	user, err := a.authUser(ctx, &models.Credentials{Login: login, Password: password})
+	if isLoginFailure(err) {
+		a.registerFailure(ctx, login, ip)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+	if !mfaEnabled(user) {
+		a.resetLoginFailures(ctx, login)
+		return []string{models.AMRPassword}, nil
+	}
+	if code == "" || !totpEnabled(user) && !otpEnabled(user) {
+		return nil, e.ErrMFARequired
+	}
+	mfa, err := a.verifyMFACode(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if mfa != nil {
+		if err = a.repository.UpdateMFA(ctx, login, mfa); err != nil {
+			a.logger.Error().Err(err).Msgf("service.Reauthenticate couldn't save second factor of %s", login)
+			return nil, err
+		}
+	}
+	return []string{models.AMRPassword, models.AMROTP}, nil
+}
+
+// SendReauthCode sends one-time code to logged in user for Reauthenticate
+func (a *Auth) SendReauthCode(ctx context.Context, login string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth SendReauthCode")
+	defer span.End()
+
+	if a.otps == nil || a.notifier == nil {
+		return e.ErrNoNotifier
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.SendReauthCode couldn't get user %s", login)
+		return err
+	}
+	if !otpEnabled(user) {
+		return e.ErrMFANotEnabled
+	}
+	return a.sendOTP(ctx, login, user.OTP)
+}
+
+// BeginWebAuthnReauth gives options to authenticate logged in user again with passkey or security key
+func (a *Auth) BeginWebAuthnReauth(ctx context.Context, login string) (*webauthn.RequestOptions, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth BeginWebAuthnReauth")
+	defer span.End()
+
+	if a.webauthn == nil {
+		return nil, e.ErrWebAuthnDisabled
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.BeginWebAuthnReauth couldn't get user %s", login)
+		return nil, err
+	}
+	if len(user.WebAuthn) == 0 {
+		return nil, e.ErrNoWebAuthnCredential
+	}
+	challenge, err := a.newChallenge(ctx, login, models.WebAuthnReauth)
+	if err != nil {
+		return nil, err
+	}
+	return a.webauthn.RequestOptions(challenge, descriptors(user.WebAuthn), webauthn.VerificationRequired), nil
+}
+
+// FinishWebAuthnReauth verifies assertion like passwordless login does and returns methods used.
+// Failures count as failed logins
+func (a *Auth) FinishWebAuthnReauth(ctx context.Context, login string, resp *webauthn.AssertionResponse) ([]string, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth FinishWebAuthnReauth")
+	defer span.End()
+
+	if a.webauthn == nil {
+		return nil, e.ErrWebAuthnDisabled
+	}
+	challenge, err := a.takeChallenge(ctx, resp.Response.ClientDataJSON, models.WebAuthnReauth, login)
+	if err != nil {
+		return nil, err
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.FinishWebAuthnReauth couldn't get user %s", login)
+		return nil, err
+	}
+	if err = a.verifyAssertion(ctx, user, resp, challenge, true); err != nil {
+		return nil, err
+	}
+	return []string{models.AMRHardwareKey}, nil
+}
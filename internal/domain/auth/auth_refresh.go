@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	uuid "github.com/satori/go.uuid"
+	"go.opentelemetry.io/otel"
+)
+
+// RefreshTokens rotates refresh token and returns new pair of tokens.
+// Refresh token of the previous generation is accepted only during grace window
+// (concurrent requests racing to refresh), then only access token is issued.
+// Any other old refresh token revokes the whole family.
+// Session started by login ends after SessionLifetime or IdleTimeout without refreshes
+func (a *Auth) RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth RefreshTokens")
+	defer span.End()
+
+	claims, err := a.parseToken(ctx, refreshToken, models.RefreshTokenType)
+	if err != nil {
+		a.logger.Debug().Err(err).Msg("service.RefreshTokens couldn't validate refresh token")
+		return nil, err
+	}
+	if claims.Family == "" {
+		a.logger.Debug().Msg("service.RefreshTokens token has no family")
+		return nil, tokens.ErrBadClaimsInToken
+	}
+	if err = a.checkRevoked(ctx, claims); err != nil {
+		return nil, err
+	}
+	login, familyID, generation := claims.Subject, claims.Family, claims.Generation
+	family, err := a.tokenStorage.GetFamily(ctx, familyID)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.RefreshTokens couldn't get family %s", familyID)
+		return nil, err
+	}
+	if family.Revoked {
+		a.logger.Debug().Msgf("service.RefreshTokens family %s is revoked", familyID)
+		return nil, e.ErrTokenFamilyRevoked
+	}
+	if family.Login != login {
+		a.logger.Warn().Msgf("service.RefreshTokens family %s belongs to another login", familyID)
+		return nil, e.ErrBadCreds
+	}
+	authTime := sessionStart(claims, family)
+	if err = a.checkSession(family, authTime); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.RefreshTokens family %s session is over", familyID)
+		return nil, err
+	}
+	if generation == family.Generation {
+		pair, err := a.rotateFamily(ctx, family, authTime)
+		if err != e.ErrTokenFamilyRotated {
+			return pair, err
+		}
+		// somebody rotated it right before us
+		if family, err = a.tokenStorage.GetFamily(ctx, familyID); err != nil {
+			return nil, err
+		}
+	}
+	if generation == family.Generation-1 && time.Since(family.RotatedAt) <= a.jwtcfg.RefreshGrace {
+		a.logger.Debug().Msgf("service.RefreshTokens family %s: previous generation within grace", familyID)
+		accessToken, accessClaims, err := a.createAccessToken(ctx, login, authTime, family.AMR)
+		if err != nil {
+			return nil, err
+		}
+		return &models.TokenPair{Login: login, AccessToken: accessToken, Claims: accessClaims}, nil
+	}
+	a.logger.Warn().Msgf("service.RefreshTokens reuse of refresh token! family %s login %s gen %d, current %d",
+		familyID, login, generation, family.Generation)
+	if err = a.tokenStorage.RevokeFamily(ctx, familyID); err != nil {
+		a.logger.Error().Err(err).Msgf("service.RefreshTokens couldn't revoke family %s", familyID)
+		return nil, err
+	}
+	return nil, e.ErrRefreshTokenReused
+}
+
+// checkFamily tells if refresh token is still the current one of its family, without rotating it
+func (a *Auth) checkFamily(ctx context.Context, claims *tokens.Claims) error {
+	if claims.Family == "" {
+		return tokens.ErrBadClaimsInToken
+	}
+	family, err := a.tokenStorage.GetFamily(ctx, claims.Family)
+	if err != nil {
+		return err
+	}
+	switch {
+	case family.Revoked:
+		return e.ErrTokenFamilyRevoked
+	case family.Login != claims.Subject:
+		return e.ErrBadCreds
+	case family.Generation != claims.Generation:
+		return e.ErrRefreshTokenReused
+	}
+	return a.checkSession(family, sessionStart(claims, family))
+}
+
+func (a *Auth) startFamily(ctx context.Context, login string, authTime time.Time, amr []string) (string, error) {
+	if login == "" {
+		return "", e.ErrNoLoginTokenCreation
+	}
+	now := time.Now()
+	ttl := a.sessionTTL(a.jwtcfg.RefreshTTL, authTime)
+	family := &models.TokenFamily{
+		ID:        uuid.NewV4().String(),
+		Login:     login,
+		AuthTime:  authTime,
+		AMR:       amr,
+		RotatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	claims := a.newClaims(login, models.RefreshTokenType)
+	claims.Family, claims.AuthTime = family.ID, authTime
+	token, err := a.issuerFor(models.RefreshTokenType).Issue(ctx, claims, ttl)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.startFamily couldn't create refresh token login: %s", login)
+		return "", err
+	}
+	if err = a.tokenStorage.CreateFamily(ctx, family); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.startFamily couldn't save family login: %s", login)
+		return "", err
+	}
+	return token, nil
+}
+
+func (a *Auth) rotateFamily(ctx context.Context, family *models.TokenFamily, authTime time.Time) (*models.TokenPair, error) {
+	now := time.Now()
+	ttl := a.sessionTTL(a.jwtcfg.RefreshTTL, authTime)
+	rotated := *family
+	rotated.Generation++
+	rotated.RotatedAt = now
+	rotated.ExpiresAt = now.Add(ttl)
+	claims := a.newClaims(family.Login, models.RefreshTokenType)
+	claims.Family, claims.Generation, claims.AuthTime = family.ID, rotated.Generation, authTime
+	refreshToken, err := a.issuerFor(models.RefreshTokenType).Issue(ctx, claims, ttl)
+	if err != nil {
+		return nil, err
+	}
+	accessToken, accessClaims, err := a.createAccessToken(ctx, family.Login, authTime, family.AMR)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.tokenStorage.RotateFamily(ctx, &rotated, family.Generation); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.rotateFamily couldn't rotate family %s", family.ID)
+		return nil, err
+	}
+	return &models.TokenPair{
+		Login:        family.Login,
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Claims:       accessClaims,
+	}, nil
+}
+
+// sessionStart is auth_time of refresh token. Families started before auth_time was
+// introduced have it in neither place, their session starts at the last rotation
+func sessionStart(claims *tokens.Claims, family *models.TokenFamily) time.Time {
+	switch {
+	case !claims.AuthTime.IsZero():
+		return claims.AuthTime
+	case !family.AuthTime.IsZero():
+		return family.AuthTime
+	default:
+		return family.RotatedAt
+	}
+}
+
+func (a *Auth) checkSession(family *models.TokenFamily, authTime time.Time) error {
+	now := time.Now()
+	if a.jwtcfg.SessionLifetime > 0 && !now.Before(authTime.Add(a.jwtcfg.SessionLifetime)) {
+		return e.ErrSessionExpired
+	}
+	if a.jwtcfg.IdleTimeout > 0 && now.Sub(family.RotatedAt) > a.jwtcfg.IdleTimeout {
+		return e.ErrSessionIdle
+	}
+	return nil
+}
+
+// sessionTTL is ttl cut down to the end of session started at authTime
+func (a *Auth) sessionTTL(ttl time.Duration, authTime time.Time) time.Duration {
+	if a.jwtcfg.SessionLifetime == 0 {
+		return ttl
+	}
+	if left := time.Until(authTime.Add(a.jwtcfg.SessionLifetime)); left < ttl {
+		return left
+	}
+	return ttl
+}
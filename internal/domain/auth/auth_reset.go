@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/ports"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	resetTokenBytes = 32
+	defaultResetTTL = 15 * time.Minute
+)
+
+// WithNotifier sets notifier which delivers password reset tokens
+func WithNotifier(notifier ports.Notifier) Option {
+	return func(a *Auth) {
+		a.notifier = notifier
+	}
+}
+
+// WithResetTokenTTL sets lifetime of password reset tokens, 15 minutes by default
+func WithResetTokenTTL(ttl time.Duration) Option {
+	return func(a *Auth) {
+		a.resetTTL = ttl
+	}
+}
+
+// ForgotPassword sends single-use reset token to the user.
+// Unknown login is not an error, and the token is saved and sent in background,
+// so neither answer nor its timing tells callers which logins exist
+func (a *Auth) ForgotPassword(ctx context.Context, login string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth ForgotPassword")
+	defer span.End()
+
+	if a.notifier == nil {
+		return e.ErrNoNotifier
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err == e.ErrNoUserInDB {
+		a.logger.Debug().Msgf("service.ForgotPassword unknown login %s", login)
+		return nil
+	} else if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ForgotPassword couldn't get user %s", login)
+		return err
+	}
+	a.deliveries.Add(1)
+	go func() {
+		defer a.deliveries.Done()
+		// the request may be over before the token is delivered
+		a.sendResetToken(trace.ContextWithSpanContext(context.Background(), span.SpanContext()), user.Login)
+	}()
+	return nil
+}
+
+// sendResetToken saves new reset token of login and sends it through notifier. Failures are only logged
+func (a *Auth) sendResetToken(ctx context.Context, login string) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth sendResetToken")
+	defer span.End()
+
+	token, err := newSecretToken(resetTokenBytes)
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.sendResetToken couldn't create reset token of %s", login)
+		return
+	}
+	now := time.Now()
+	err = a.tokenStorage.SaveResetToken(ctx, &models.ResetToken{
+		Hash:      hashToken(token),
+		Login:     login,
+		CreatedAt: now,
+		ExpiresAt: now.Add(a.resetTTL),
+	})
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.sendResetToken couldn't save reset token of %s", login)
+		return
+	}
+	err = a.notifier.Notify(ctx, &models.Notification{
+		Recipient: login,
+		Kind:      models.PasswordResetNotification,
+		Subject:   "Password reset",
+		Text:      fmt.Sprintf("Use this token to set a new password: %s\nIt expires in %s.", token, a.resetTTL),
+		Secret:    token,
+		CreatedAt: now,
+	})
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.sendResetToken couldn't notify %s", login)
+		return
+	}
+	a.logger.Debug().Msgf("service.sendResetToken reset token sent to %s", login)
+}
+
+// ResetPassword sets new password of reset token owner and ends all sessions and tokens of the user.
+// Token is used up only when the password is accepted, other reset tokens of the user are dropped then
+func (a *Auth) ResetPassword(ctx context.Context, token, password string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth ResetPassword")
+	defer span.End()
+
+	tokenHash := hashToken(token)
+	stored, err := a.tokenStorage.GetResetToken(ctx, tokenHash)
+	if err != nil {
+		a.logger.Debug().Err(err).Msg("service.ResetPassword couldn't get reset token")
+		return err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return e.ErrBadResetToken
+	}
+	user, err := a.repository.GetUser(ctx, stored.Login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ResetPassword couldn't get user %s", stored.Login)
+		return err
+	}
+	if err = a.policy.Check(user.Login, password); err != nil {
+		return err
+	}
+	// the user doesn't know current password, so it may be changed at any time
+	if err = a.checkPasswordChange(user, password, 0); err != nil {
+		return err
+	}
+	if err = a.tokenStorage.DeleteResetToken(ctx, tokenHash); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.ResetPassword reset token of %s is already used", user.Login)
+		return err
+	}
+	hash, err := a.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	updated := *user
+	a.setPassword(&updated, user, hash)
+	updated.MustChangePassword = false
+	if err = a.repository.UpdateUser(ctx, &updated); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ResetPassword couldn't save password of %s", user.Login)
+		return err
+	}
+	if err = a.tokenStorage.DeleteResetTokens(ctx, user.Login); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ResetPassword couldn't drop reset tokens of %s", user.Login)
+		return err
+	}
+	if err = a.tokenStorage.RevokeUserFamilies(ctx, user.Login); err != nil {
+		a.logger.Error().Err(err).Msgf("service.ResetPassword couldn't end sessions of %s", user.Login)
+		return err
+	}
+	if err = a.revokeUserTokens(ctx, user.Login, updated.PasswordChangedAt); err != nil {
+		return err
+	}
+	a.logger.Debug().Msgf("service.ResetPassword password of %s is reset", user.Login)
+	return nil
+}
+
+// newSecretToken is a random url-safe string of size random bytes
+func newSecretToken(size int) (string, error) {
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"go.opentelemetry.io/otel"
+)
+
+// RevokeTokens removes given access and refresh tokens or puts their jti to revocation list
+// and revokes refresh token family. Already invalid tokens are skipped
+func (a *Auth) RevokeTokens(ctx context.Context, accessToken, refreshToken string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth RevokeTokens")
+	defer span.End()
+
+	revoked := 0
+	toRevoke := []struct {
+		token     string
+		tokenType models.TokenType
+	}{
+		{token: accessToken, tokenType: models.AccessTokenType},
+		{token: refreshToken, tokenType: models.RefreshTokenType},
+	}
+	for _, t := range toRevoke {
+		if t.token == "" {
+			continue
+		}
+		claims, err := a.parseToken(ctx, t.token, t.tokenType)
+		if err != nil {
+			a.logger.Debug().Err(err).Msg("service.RevokeTokens skip invalid token")
+			continue
+		}
+		if err = a.revokeToken(ctx, t.token, claims, t.tokenType); err != nil {
+			return err
+		}
+		if claims.Family != "" {
+			if err = a.tokenStorage.RevokeFamily(ctx, claims.Family); err != nil {
+				a.logger.Error().Err(err).Msgf("service.RevokeTokens couldn't revoke family %s", claims.Family)
+				return err
+			}
+		}
+		a.logger.Debug().Msgf("service.RevokeTokens token %s of %s is revoked", claims.ID, claims.Subject)
+		revoked++
+	}
+	if revoked == 0 {
+		return e.ErrNoTokensToRevoke
+	}
+	return nil
+}
+
+// revokeToken removes stored token or puts its jti to revocation list
+func (a *Auth) revokeToken(ctx context.Context, token string, claims *tokens.Claims, tokenType models.TokenType) error {
+	removed, err := a.issuerFor(tokenType).Revoke(ctx, token)
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.RevokeTokens couldn't remove token %s", claims.ID)
+		return err
+	}
+	if removed {
+		return nil
+	}
+	if err = a.revocations.Revoke(ctx, claims.ID, claims.ExpiresAt); err != nil {
+		a.logger.Error().Err(err).Msgf("service.RevokeTokens couldn't revoke token %s", claims.ID)
+		return err
+	}
+	return nil
+}
+
+// checkRevoked rejects token whose jti is revoked or which was issued before tokens of its subject were revoked
+func (a *Auth) checkRevoked(ctx context.Context, claims *tokens.Claims) error {
+	revoked, err := a.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.checkRevoked couldn't check token %s", claims.ID)
+		return err
+	}
+	if revoked {
+		return e.ErrTokenRevoked
+	}
+	issuedBefore, err := a.revocations.UserRevokedBefore(ctx, claims.Subject)
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.checkRevoked couldn't check tokens of %s", claims.Subject)
+		return err
+	}
+	if claims.IssuedAt.Before(issuedBefore) {
+		a.logger.Debug().Msgf("service.checkRevoked token %s was issued before tokens of %s were revoked", claims.ID, claims.Subject)
+		return e.ErrTokenRevoked
+	}
+	return nil
+}
+
+// revokeUserTokens revokes every token of login issued before now. Tokens issued in the same second stay valid,
+// their iat has no fractions. The cutoff is kept as long as access or refresh token of login may live
+func (a *Auth) revokeUserTokens(ctx context.Context, login string, now time.Time) error {
+	ttl := a.jwtcfg.AccesTTL
+	if a.jwtcfg.RefreshTTL > ttl {
+		ttl = a.jwtcfg.RefreshTTL
+	}
+	if err := a.revocations.RevokeUser(ctx, login, now.Truncate(time.Second), now.Add(ttl)); err != nil {
+		a.logger.Error().Err(err).Msgf("service.revokeUserTokens couldn't revoke tokens of %s", login)
+		return err
+	}
+	return nil
+}
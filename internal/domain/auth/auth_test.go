@@ -1,19 +1,31 @@
 package auth
 
 import (
+	"github.com/DMA8/authService/internal/adapters/memory"
+	"github.com/DMA8/authService/internal/adapters/notifier"
 	"github.com/DMA8/authService/internal/config"
 	e "github.com/DMA8/authService/internal/domain/errors"
 	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/domain/policy"
 	"github.com/DMA8/authService/pkg/logging"
 	mock_ports "github.com/DMA8/authService/internal/mocks"
+	"github.com/DMA8/authService/pkg/passwords"
+	"github.com/DMA8/authService/pkg/totp"
+	"github.com/DMA8/authService/pkg/tokens"
+	"github.com/DMA8/authService/pkg/webauthn"
+	"github.com/DMA8/authService/pkg/webauthn/webauthntest"
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestHashPassword(t *testing.T) {
@@ -45,7 +57,10 @@ func TestAuthUser(t *testing.T) {
 	controller := gomock.NewController(t)
 	defer controller.Finish()
 	repoMock := mock_ports.NewMockAuthStorage(controller)
-	auth := NewAuth(cfg, repoMock, l)
+	tokenRepoMock := mock_ports.NewMockTokenStorage(controller)
+	revocationsMock := mock_ports.NewMockRevocationStorage(controller)
+	auth, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repoMock, tokenRepoMock, revocationsMock, l)
+	assert.NoError(t, err)
 
 	//success auth:
 	inputCreds := models.Credentials{
@@ -77,17 +92,36 @@ func TestAuthUser(t *testing.T) {
 		Login:    "test3",
 		Password: "test3",
 	}
-	testHash3, err := HashPassword(inputCreds3.Password)
+	testHash3, err := HashPassword(inputCreds3.Password + "other")
 	assert.NoError(t, err)
 	dbAns3 := models.Credentials{
 		Login:    "test3",
-		Password: testHash3[:len(testHash3)-1] + "a",
+		Password: testHash3,
 	}
 	repoMock.EXPECT().GetUser(gomock.Any(), inputCreds3.Login).Return(&dbAns3, nil).Times(1)
 	authErr3 := auth.AuthUser(ctx, &inputCreds3)
 	assert.Equal(t, e.ErrWrongPass, authErr3)
 }
 
+func TestAuthUserUnknownLogin(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	authService, err := NewAuth(config.JWTConfig{}, nil, nil, repo, nil, nil, logging.New("debug"), WithHasher(hasher))
+	assert.NoError(t, err)
+	repo.EXPECT().GetUser(gomock.Any(), "nobody").Return(nil, e.ErrNoUserInDB).Times(2)
+
+	//unknown login is checked against dummy hash of the same algorithm
+	creds := &models.Credentials{Login: "nobody", Password: "password"}
+	assert.ErrorIs(t, authService.AuthUser(context.Background(), creds), e.ErrNoUserInDB)
+	dummyHash := authService.dummyHash
+	assert.NotEmpty(t, dummyHash)
+	assert.False(t, hasher.NeedsRehash(dummyHash))
+	assert.ErrorIs(t, authService.AuthUser(context.Background(), creds), e.ErrNoUserInDB)
+	assert.Equal(t, dummyHash, authService.dummyHash)
+}
+
 func TestCreateToken(t *testing.T) {
 	TestCases := []struct {
 		login    string
@@ -114,9 +148,17 @@ func TestCreateToken(t *testing.T) {
 	}
 	ctrl := gomock.NewController(t)
 	repo := mock_ports.NewMockAuthStorage(ctrl)
-	authService := NewAuth(cfg, repo, logging.New("debug"))
-	authServiceDiffSecret := NewAuth(cfg2, repo, logging.New("debug"))
-	authServiceDiffTTL := NewAuth(cfg3, repo, logging.New("debug"))
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(&models.Credentials{}, nil).AnyTimes()
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	authServiceDiffSecret, err := NewAuth(cfg2, hmacKeyring(t, cfg2.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	authServiceDiffTTL, err := NewAuth(cfg3, hmacKeyring(t, cfg3.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
 
 	ctx := context.Background()
 	//testing errors
@@ -124,10 +166,10 @@ func TestCreateToken(t *testing.T) {
 		_, err := authService.CreateToken(ctx, testCase.login, models.AccessTokenType)
 		assert.EqualError(t, err, testCase.expErr.Error())
 	}
-	//testing same inputs generates same outupts and diff inputs generates diff outputs
+	//every token has its own jti, so same inputs generates diff outputs too
 	token1, _ := authService.CreateToken(ctx, "test1", models.AccessTokenType)
 	token2, _ := authService.CreateToken(ctx, "test1", models.AccessTokenType)
-	assert.Equal(t, token1, token2)
+	assert.NotEqual(t, token1, token2)
 	//ttl matter
 	token1, _ = authService.CreateToken(ctx, "test", models.AccessTokenType)
 	token2, _ = authServiceDiffTTL.CreateToken(ctx, "test1", models.AccessTokenType)
@@ -171,15 +213,21 @@ func TestValidateTokenOK(t *testing.T) {
 	}
 	ctrl := gomock.NewController(t)
 	repo := mock_ports.NewMockAuthStorage(ctrl)
-	authService := NewAuth(cfg, repo, logging.New("debug"))
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).Return(&models.Credentials{}, nil).AnyTimes()
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
 	ctx := context.Background()
 	for _, testcase := range testCases {
 		token, err := authService.CreateToken(ctx, testcase.login, models.AccessTokenType)
 		testcase.token = token
 		testcase.expectedError = err
-		returnedLogin, returnedError := authService.ValidateToken(ctx, testcase.token)
+		returnedClaims, returnedError := authService.ValidateToken(ctx, testcase.token, models.AccessTokenType)
 		assert.NoError(t, returnedError)
-		assert.Equal(t, returnedLogin, testcase.login)
+		assert.Equal(t, returnedClaims.Subject, testcase.login)
 	}
 }
 
@@ -194,23 +242,36 @@ func TestValidateTokenErr(t *testing.T) {
 	assert.NoError(t, err)
 	ctrl := gomock.NewController(t)
 	repo := mock_ports.NewMockAuthStorage(ctrl)
-	authService := NewAuth(cfg, repo, logging.New("debug"))
-	login, err := authService.ValidateToken(ctx, expiredToken)
-	assert.Equal(t, login, "")
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	claims, err := authService.ValidateToken(ctx, expiredToken, models.AccessTokenType)
+	assert.Nil(t, claims)
 	assert.EqualError(t, err, "Token is expired")
 
 	corruptedToken, err := createTokenTest("admin", time.Now().Add(time.Minute*10), cfg.Secret)
 	assert.NoError(t, err)
-	login, err = authService.ValidateToken(ctx, corruptedToken[:30]+"YWRtaW4yCg"+corruptedToken[30:])
-	assert.Equal(t, login, "")
+	claims, err = authService.ValidateToken(ctx, corruptedToken[:30]+"YWRtaW4yCg"+corruptedToken[30:], models.AccessTokenType)
+	assert.Nil(t, claims)
 	assert.Error(t, err)
 
-	login, err = authService.CreateToken(ctx, "testLogin", models.TokenType("unexpected tokentype"))
+	login, err := authService.CreateToken(ctx, "testLogin", models.TokenType("unexpected tokentype"))
 	assert.Equal(t, login, "")
 	assert.Error(t, err)
 
 }
 
+func hmacKeyring(t *testing.T, secret string) *tokens.Keyring {
+	key, err := tokens.NewHMACKey(secret)
+	assert.NoError(t, err)
+	keys, err := tokens.NewKeyring(time.Hour, key)
+	assert.NoError(t, err)
+	return keys
+}
+
 func createTokenTest(usrName string, time time.Time, secret string) (string, error) {
 	if usrName == "" {
 		return "", e.ErrNoLoginTokenCreation
@@ -219,5 +280,1578 @@ func createTokenTest(usrName string, time time.Time, secret string) (string, err
 		ExpiresAt: time.Unix(),
 		Subject:   usrName,
 	})
+	tokenAccess.Header["kid"] = tokens.DefaultKeyID
 	return tokenAccess.SignedString([]byte(secret))
 }
+
+func TestRefreshTokens(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{
+		Secret:       "test",
+		AccesTTL:     time.Minute,
+		RefreshTTL:   time.Hour,
+		RefreshGrace: time.Minute,
+	}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(&models.Credentials{Login: "admin"}, nil).AnyTimes()
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+
+	var family models.TokenFamily
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, f *models.TokenFamily) error {
+			family = *f
+			return nil
+		}).Times(1)
+	refresh0, err := authService.CreateToken(ctx, "admin", models.RefreshTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", family.Login)
+	assert.Equal(t, 0, family.Generation)
+
+	//current generation -> rotation
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&family, nil).Times(1)
+	tokenRepo.EXPECT().RotateFamily(gomock.Any(), gomock.Any(), 0).DoAndReturn(
+		func(_ context.Context, f *models.TokenFamily, _ int) error {
+			family = *f
+			return nil
+		}).Times(1)
+	pair, err := authService.RefreshTokens(ctx, refresh0)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", pair.Login)
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.NotEmpty(t, pair.RefreshToken)
+	assert.Equal(t, "admin", pair.Claims.Subject)
+	assert.Equal(t, string(models.AccessTokenType), pair.Claims.TokenType)
+	assert.Equal(t, 1, family.Generation)
+	refresh1 := pair.RefreshToken
+
+	//previous generation inside grace window -> only access token
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&family, nil).Times(1)
+	pair, err = authService.RefreshTokens(ctx, refresh0)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.Empty(t, pair.RefreshToken)
+
+	//lost the race for rotation -> treated as grace
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&family, nil).Times(1)
+	tokenRepo.EXPECT().RotateFamily(gomock.Any(), gomock.Any(), 1).Return(e.ErrTokenFamilyRotated).Times(1)
+	racedFamily := family
+	racedFamily.Generation = 2
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&racedFamily, nil).Times(1)
+	pair, err = authService.RefreshTokens(ctx, refresh1)
+	assert.NoError(t, err)
+	assert.Empty(t, pair.RefreshToken)
+
+	//previous generation after grace window -> family revoked
+	family.RotatedAt = time.Now().Add(-time.Hour)
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&family, nil).Times(1)
+	tokenRepo.EXPECT().RevokeFamily(gomock.Any(), family.ID).Return(nil).Times(1)
+	_, err = authService.RefreshTokens(ctx, refresh0)
+	assert.Equal(t, e.ErrRefreshTokenReused, err)
+
+	//revoked family rejects even the latest token
+	family.Revoked = true
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&family, nil).Times(1)
+	_, err = authService.RefreshTokens(ctx, refresh1)
+	assert.Equal(t, e.ErrTokenFamilyRevoked, err)
+}
+
+func TestSessionLifetime(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{
+		Secret:          "test",
+		AccesTTL:        time.Minute,
+		RefreshTTL:      24 * time.Hour,
+		SessionLifetime: time.Hour,
+		IdleTimeout:     10 * time.Minute,
+	}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(&models.Credentials{Login: "admin"}, nil).AnyTimes()
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+
+	var family models.TokenFamily
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, f *models.TokenFamily) error {
+			family = *f
+			return nil
+		}).Times(1)
+	refreshToken, err := authService.CreateToken(ctx, "admin", models.RefreshTokenType)
+	assert.NoError(t, err)
+	//refresh token doesn't outlive the session
+	claims, err := authService.parseToken(ctx, refreshToken, models.RefreshTokenType)
+	assert.NoError(t, err)
+	authTime := claims.AuthTime
+	assert.Equal(t, family.AuthTime, authTime)
+	assert.Equal(t, false, claims.ExpiresAt.After(authTime.Add(cfg.SessionLifetime)))
+
+	//auth_time is carried to rotated tokens
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&family, nil).Times(1)
+	tokenRepo.EXPECT().RotateFamily(gomock.Any(), gomock.Any(), 0).DoAndReturn(
+		func(_ context.Context, f *models.TokenFamily, _ int) error {
+			family = *f
+			return nil
+		}).Times(1)
+	pair, err := authService.RefreshTokens(ctx, refreshToken)
+	assert.NoError(t, err)
+	assert.Equal(t, authTime, pair.Claims.AuthTime)
+	claims, err = authService.parseToken(ctx, pair.RefreshToken, models.RefreshTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, authTime, claims.AuthTime)
+
+	//no refreshes for longer than idle timeout
+	idleFamily := family
+	idleFamily.RotatedAt = time.Now().Add(-cfg.IdleTimeout - time.Minute)
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&idleFamily, nil).Times(1)
+	_, err = authService.RefreshTokens(ctx, pair.RefreshToken)
+	assert.Equal(t, e.ErrSessionIdle, err)
+
+	//session started long ago ends however active it is
+	cfg.SessionLifetime = time.Nanosecond
+	authService, err = NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), family.ID).Return(&family, nil).Times(1)
+	_, err = authService.RefreshTokens(ctx, pair.RefreshToken)
+	assert.Equal(t, e.ErrSessionExpired, err)
+}
+
+func TestTokenLeeway(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{
+		Secret:   "test",
+		AccesTTL: time.Minute,
+		Leeway:   30 * time.Second,
+	}
+	ctrl := gomock.NewController(t)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	keys := hmacKeyring(t, cfg.Secret)
+	authService, err := NewAuth(cfg, keys, nil, nil, nil, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+
+	claims := &tokens.Claims{Subject: "admin", TokenType: string(models.AccessTokenType)}
+	skewed, err := tokens.CreateToken(claims, keys, -10*time.Second)
+	assert.NoError(t, err)
+	_, err = authService.ValidateToken(ctx, skewed, models.AccessTokenType)
+	assert.NoError(t, err)
+	expired, err := tokens.CreateToken(claims, keys, -time.Minute)
+	assert.NoError(t, err)
+	_, err = authService.ValidateToken(ctx, expired, models.AccessTokenType)
+	assert.Equal(t, tokens.ErrTokenExpired, err)
+}
+
+func TestRevokeTokens(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{
+		Secret:     "test",
+		AccesTTL:   time.Minute,
+		RefreshTTL: time.Hour,
+	}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(&models.Credentials{Login: "admin"}, nil).AnyTimes()
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+
+	var family models.TokenFamily
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, f *models.TokenFamily) error {
+			family = *f
+			return nil
+		}).Times(1)
+	accessToken, err := authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	refreshToken, err := authService.CreateToken(ctx, "admin", models.RefreshTokenType)
+	assert.NoError(t, err)
+
+	revoked := make(map[string]time.Time)
+	revocations.EXPECT().Revoke(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, tokenID string, expiresAt time.Time) error {
+			revoked[tokenID] = expiresAt
+			return nil
+		}).Times(2)
+	tokenRepo.EXPECT().RevokeFamily(gomock.Any(), family.ID).Return(nil).Times(1)
+	err = authService.RevokeTokens(ctx, accessToken, refreshToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(revoked))
+	for _, expiresAt := range revoked {
+		assert.Equal(t, true, expiresAt.After(time.Now()))
+	}
+
+	//revoked token is not valid anymore
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, tokenID string) (bool, error) {
+			_, ok := revoked[tokenID]
+			return ok, nil
+		}).Times(2)
+	claims, err := authService.ValidateToken(ctx, accessToken, models.AccessTokenType)
+	assert.Nil(t, claims)
+	assert.Equal(t, e.ErrTokenRevoked, err)
+	_, err = authService.RefreshTokens(ctx, refreshToken)
+	assert.Equal(t, e.ErrTokenRevoked, err)
+
+	//nothing to revoke
+	err = authService.RevokeTokens(ctx, "", "corrupted")
+	assert.Equal(t, e.ErrNoTokensToRevoke, err)
+}
+
+func TestTokenClaims(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{
+		Secret:     "test",
+		AccesTTL:   time.Minute,
+		RefreshTTL: time.Hour,
+		Issuer:     "auth.prod",
+		Audience:   "orders",
+	}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	user := &models.Credentials{
+		ID:           primitive.NewObjectID(),
+		Login:        "admin",
+		Roles:        []string{"admin", "support"},
+		CustomClaims: map[string]interface{}{"tenant": "team31", "sub": "spoofed"},
+	}
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(user, nil).AnyTimes()
+	keys := hmacKeyring(t, cfg.Secret)
+	authService, err := NewAuth(cfg, keys, nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+
+	token, err := authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	claims, err := authService.ValidateToken(ctx, token, models.AccessTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", claims.Subject)
+	assert.Equal(t, user.ID.Hex(), claims.UserID)
+	assert.Equal(t, "auth.prod", claims.Issuer)
+	assert.Equal(t, []string{"orders"}, claims.Audience)
+	assert.Equal(t, string(models.AccessTokenType), claims.TokenType)
+	assert.Equal(t, true, claims.HasRole("support"))
+	assert.Equal(t, map[string]interface{}{"tenant": "team31"}, claims.Custom)
+	assert.NotEmpty(t, claims.ID)
+	assert.Equal(t, false, claims.IssuedAt.IsZero())
+	assert.Equal(t, claims.IssuedAt, claims.NotBefore)
+
+	//same keys, but another environment or audience
+	stageCfg := cfg
+	stageCfg.Issuer = "auth.stage"
+	stageService, err := NewAuth(stageCfg, keys, nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	_, err = stageService.ValidateToken(ctx, token, models.AccessTokenType)
+	assert.Equal(t, tokens.ErrWrongIssuer, err)
+	paymentsCfg := cfg
+	paymentsCfg.Audience = "payments"
+	paymentsService, err := NewAuth(paymentsCfg, keys, nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	_, err = paymentsService.ValidateToken(ctx, token, models.AccessTokenType)
+	assert.Equal(t, tokens.ErrWrongAudience, err)
+}
+
+func TestTokenTypes(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{
+		Secret:          "test",
+		AccesTTL:        time.Minute,
+		RefreshTTL:      time.Hour,
+		Audience:        "orders",
+		RefreshAudience: "auth",
+	}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(&models.Credentials{Login: "admin"}, nil).AnyTimes()
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	refreshKey, err := tokens.NewHMACKey("refresh secret")
+	assert.NoError(t, err)
+	refreshKey.ID = "refresh"
+	refreshKeys, err := tokens.NewKeyring(time.Hour, refreshKey)
+	assert.NoError(t, err)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), refreshKeys, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+
+	accessToken, err := authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	refreshToken, err := authService.CreateToken(ctx, "admin", models.RefreshTokenType)
+	assert.NoError(t, err)
+
+	claims, err := authService.ValidateToken(ctx, refreshToken, models.RefreshTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"auth"}, claims.Audience)
+	assert.Equal(t, string(models.RefreshTokenType), claims.TokenType)
+
+	//refresh token is not an access token and vice versa
+	_, err = authService.ValidateToken(ctx, refreshToken, models.AccessTokenType)
+	assert.Error(t, err)
+	_, err = authService.ValidateToken(ctx, accessToken, models.RefreshTokenType)
+	assert.Error(t, err)
+	_, err = authService.RefreshTokens(ctx, accessToken)
+	assert.Error(t, err)
+	_, err = authService.ValidateToken(ctx, accessToken, models.TokenType("id"))
+	assert.Equal(t, e.ErrWrongTokenType, err)
+
+	//same key for both types: type claim still tells them apart
+	sameKeyService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	refreshToken, err = sameKeyService.CreateToken(ctx, "admin", models.RefreshTokenType)
+	assert.NoError(t, err)
+	cfgSameAudience := cfg
+	cfgSameAudience.RefreshAudience = ""
+	sameAudienceService, err := NewAuth(cfgSameAudience, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	sameRefreshToken, err := sameAudienceService.CreateToken(ctx, "admin", models.RefreshTokenType)
+	assert.NoError(t, err)
+	_, err = sameAudienceService.ValidateToken(ctx, sameRefreshToken, models.AccessTokenType)
+	assert.Equal(t, tokens.ErrWrongTokenType, err)
+	_, err = sameKeyService.ValidateToken(ctx, refreshToken, models.AccessTokenType)
+	assert.Equal(t, tokens.ErrWrongAudience, err)
+}
+
+func TestIntrospect(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{
+		Secret:               "test",
+		AccesTTL:             time.Minute,
+		RefreshTTL:           time.Hour,
+		Audience:             "team31",
+		IntrospectionClients: map[string]string{"gateway": "gateway secret"},
+	}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	var family models.TokenFamily
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, f *models.TokenFamily) error {
+			family = *f
+			return nil
+		}).AnyTimes()
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(context.Context, string) (*models.TokenFamily, error) {
+			f := family
+			return &f, nil
+		}).AnyTimes()
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(
+		&models.Credentials{Login: "admin", Roles: []string{"admin", "support"}}, nil).AnyTimes()
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+
+	assert.NoError(t, authService.AuthClient(ctx, "gateway", "gateway secret"))
+	assert.Equal(t, e.ErrBadClientCreds, authService.AuthClient(ctx, "gateway", "wrong"))
+	assert.Equal(t, e.ErrBadClientCreds, authService.AuthClient(ctx, "unknown", ""))
+
+	accessToken, err := authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	refreshToken, err := authService.CreateToken(ctx, "admin", models.RefreshTokenType)
+	assert.NoError(t, err)
+
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).Times(2)
+	introspection := authService.Introspect(ctx, accessToken, "")
+	assert.Equal(t, true, introspection.Active)
+	assert.Equal(t, "admin", introspection.Subject)
+	assert.Equal(t, "admin support", introspection.Scope)
+	assert.Equal(t, "access_token", introspection.TokenType)
+	assert.Equal(t, "team31", introspection.ClientID)
+	assert.Equal(t, true, introspection.ExpiresAt > introspection.IssuedAt)
+
+	introspection = authService.Introspect(ctx, refreshToken, "refresh_token")
+	assert.Equal(t, true, introspection.Active)
+	assert.Equal(t, "refresh_token", introspection.TokenType)
+
+	//refresh token of rotated or revoked family is not active
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).Times(2)
+	family.Generation++
+	assert.Equal(t, &models.Introspection{Active: false}, authService.Introspect(ctx, refreshToken, "refresh_token"))
+	family.Generation--
+	family.Revoked = true
+	assert.Equal(t, &models.Introspection{Active: false}, authService.Introspect(ctx, refreshToken, "refresh_token"))
+
+	//revoked and corrupted tokens tell nothing
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(true, nil).Times(1)
+	assert.Equal(t, &models.Introspection{Active: false}, authService.Introspect(ctx, accessToken, "access_token"))
+	assert.Equal(t, &models.Introspection{Active: false}, authService.Introspect(ctx, "corrupted", ""))
+}
+
+func TestOpaqueTokens(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{
+		Secret:      "test",
+		AccesTTL:    time.Minute,
+		RefreshTTL:  time.Hour,
+		Audience:    "orders",
+		TokenFormat: config.OpaqueTokenFormat,
+	}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(
+		&models.Credentials{Login: "admin", Roles: []string{"admin"}}, nil).AnyTimes()
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	stored := make(map[string]models.OpaqueToken)
+	tokenRepo.EXPECT().SaveOpaqueToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, token *models.OpaqueToken) error {
+			stored[token.Hash] = *token
+			return nil
+		}).AnyTimes()
+	tokenRepo.EXPECT().GetOpaqueToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, hash string) (*models.OpaqueToken, error) {
+			token, ok := stored[hash]
+			if !ok {
+				return nil, e.ErrNoOpaqueToken
+			}
+			return &token, nil
+		}).AnyTimes()
+	tokenRepo.EXPECT().DeleteOpaqueToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, hash string) error {
+			delete(stored, hash)
+			return nil
+		}).AnyTimes()
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+
+	accessToken, err := authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	refreshToken, err := authService.CreateToken(ctx, "admin", models.RefreshTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(stored))
+	//token tells nothing and only its hash is stored
+	assert.Equal(t, false, strings.Contains(accessToken, "."))
+	for hash := range stored {
+		assert.NotEqual(t, accessToken, hash)
+	}
+
+	claims, err := authService.ValidateToken(ctx, accessToken, models.AccessTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", claims.Subject)
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+	assert.Equal(t, []string{"orders"}, claims.Audience)
+	_, err = authService.ValidateToken(ctx, refreshToken, models.AccessTokenType)
+	assert.Equal(t, tokens.ErrWrongTokenType, err)
+	_, err = authService.ValidateToken(ctx, accessToken+"x", models.AccessTokenType)
+	assert.Equal(t, e.ErrNoOpaqueToken, err)
+
+	//revocation removes token without revocation list
+	err = authService.RevokeTokens(ctx, accessToken, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(stored))
+	_, err = authService.ValidateToken(ctx, accessToken, models.AccessTokenType)
+	assert.Equal(t, e.ErrNoOpaqueToken, err)
+}
+
+func TestTokenFormatMigration(t *testing.T) {
+	ctx := context.Background()
+	jwtCfg := config.JWTConfig{
+		Secret:      "test",
+		AccesTTL:    time.Minute,
+		RefreshTTL:  time.Hour,
+		TokenFormat: config.JWTTokenFormat,
+	}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(&models.Credentials{Login: "admin"}, nil).AnyTimes()
+	revocations := mock_ports.NewMockRevocationStorage(ctrl)
+	revocations.EXPECT().UserRevokedBefore(gomock.Any(), gomock.Any()).Return(time.Time{}, nil).AnyTimes()
+	revocations.EXPECT().IsRevoked(gomock.Any(), gomock.Any()).Return(false, nil).AnyTimes()
+	keys := hmacKeyring(t, jwtCfg.Secret)
+	jwtService, err := NewAuth(jwtCfg, keys, nil, repo, nil, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	jwtToken, err := jwtService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+
+	//paseto format needs keys of its kind, it fails at start instead of at first token
+	publicCfg := jwtCfg
+	publicCfg.TokenFormat = config.PasetoPublicTokenFormat
+	_, err = NewAuth(publicCfg, keys, nil, repo, nil, revocations, logging.New("debug"))
+	assert.ErrorIs(t, err, tokens.ErrUnexpectedSigningMethod)
+
+	pasetoCfg := jwtCfg
+	pasetoCfg.TokenFormat = config.PasetoLocalTokenFormat
+	pasetoCfg.AcceptTokenFormats = []string{config.JWTTokenFormat}
+	authService, err := NewAuth(pasetoCfg, keys, nil, repo, nil, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	pasetoToken, err := authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, true, strings.HasPrefix(pasetoToken, tokens.PasetoLocal+"."))
+
+	//both formats are accepted during migration
+	for _, token := range []string{jwtToken, pasetoToken} {
+		claims, err := authService.ValidateToken(ctx, token, models.AccessTokenType)
+		assert.NoError(t, err)
+		assert.Equal(t, "admin", claims.Subject)
+		assert.Equal(t, string(models.AccessTokenType), claims.TokenType)
+	}
+
+	//after the window only the current format is accepted
+	pasetoCfg.AcceptUntil = time.Now().Add(-time.Minute)
+	authService, err = NewAuth(pasetoCfg, keys, nil, repo, nil, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	_, err = authService.ValidateToken(ctx, jwtToken, models.AccessTokenType)
+	assert.Equal(t, tokens.ErrNotPaseto, err)
+	_, err = authService.ValidateToken(ctx, pasetoToken, models.AccessTokenType)
+	assert.NoError(t, err)
+}
+
+func TestAuthUserRehash(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{Secret: "test", AccesTTL: time.Minute}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Scrypt, Scrypt: passwords.ScryptParams{LogN: 10}})
+	assert.NoError(t, err)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, nil, nil, logging.New("debug"), WithHasher(hasher))
+	assert.NoError(t, err)
+
+	//outdated bcrypt hash is replaced after successful login
+	oldHash, err := bcrypt.GenerateFromPassword([]byte("secret"), 4)
+	assert.NoError(t, err)
+	user := &models.Credentials{Login: "admin", Password: string(oldHash)}
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(user, nil).Times(1)
+	var saved *models.Credentials
+	repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, u *models.Credentials) error {
+			saved = u
+			return nil
+		}).Times(1)
+	err = authService.AuthUser(ctx, &models.Credentials{Login: "admin", Password: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", saved.Login)
+	assert.Equal(t, true, strings.HasPrefix(saved.Password, "$scrypt$ln=10,r=8,p=1$"))
+
+	//up to date hash is left as is, wrong password doesn't touch the hash
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(saved, nil).Times(2)
+	err = authService.AuthUser(ctx, &models.Credentials{Login: "admin", Password: "secret"})
+	assert.NoError(t, err)
+	err = authService.AuthUser(ctx, &models.Credentials{Login: "admin", Password: "wrong"})
+	assert.Equal(t, e.ErrWrongPass, err)
+}
+
+func TestCreateUserPasswordPolicy(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	passwordPolicy, err := policy.New(config.PasswordPolicyConfig{MinLength: 10, RequireDigit: true})
+	assert.NoError(t, err)
+	authService, err := NewAuth(config.JWTConfig{}, nil, nil, repo, nil, nil, logging.New("debug"),
+		WithPasswordPolicy(passwordPolicy))
+	assert.NoError(t, err)
+
+	//weak password never reaches the storage
+	err = authService.CreateUser(ctx, &models.Credentials{Login: "admin", Password: "1"})
+	var validationErr *e.ValidationError
+	assert.Equal(t, true, errors.As(err, &validationErr))
+	assert.Equal(t, 1, len(validationErr.Violations))
+	assert.Equal(t, policy.CodeTooShort, validationErr.Violations[0].Code)
+	err = authService.UpdateUser(ctx, &models.Credentials{Login: "admin", Password: "long password"})
+	assert.Equal(t, true, errors.As(err, &validationErr))
+	assert.Equal(t, policy.CodeMissingDigit, validationErr.Violations[0].Code)
+
+	repo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	err = authService.CreateUser(ctx, &models.Credentials{Login: "admin", Password: "long password 1"})
+	assert.NoError(t, err)
+}
+
+func TestPasswordHistory(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	authService, err := NewAuth(config.JWTConfig{}, nil, nil, repo, nil, nil, logging.New("debug"),
+		WithHasher(hasher), WithPasswordHistory(3, time.Hour))
+	assert.NoError(t, err)
+
+	var user models.Credentials
+	repo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, u *models.Credentials) error {
+			user = *u
+			return nil
+		}).Times(1)
+	repo.EXPECT().GetUser(gomock.Any(), "admin").DoAndReturn(
+		func(context.Context, string) (*models.Credentials, error) {
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, u *models.Credentials) error {
+			user.Password, user.PasswordHistory, user.PasswordChangedAt = u.Password, u.PasswordHistory, u.PasswordChangedAt
+			return nil
+		}).AnyTimes()
+	changePassword := func(password string) error {
+		err := authService.UpdateUser(ctx, &models.Credentials{Login: "admin", Password: password})
+		//pretend min age passed
+		user.PasswordChangedAt = user.PasswordChangedAt.Add(-2 * time.Hour)
+		return err
+	}
+	violationCode := func(err error) string {
+		var validationErr *e.ValidationError
+		if !errors.As(err, &validationErr) {
+			return ""
+		}
+		return validationErr.Violations[0].Code
+	}
+
+	err = authService.CreateUser(ctx, &models.Credentials{Login: "admin", Password: "first password"})
+	assert.NoError(t, err)
+	assert.Equal(t, false, user.PasswordChangedAt.IsZero())
+
+	//password can't be changed right after it was set
+	err = authService.UpdateUser(ctx, &models.Credentials{Login: "admin", Password: "second password"})
+	assert.Equal(t, policy.CodePasswordTooNew, violationCode(err))
+	user.PasswordChangedAt = user.PasswordChangedAt.Add(-2 * time.Hour)
+
+	assert.NoError(t, changePassword("second password"))
+	assert.NoError(t, changePassword("third password"))
+	assert.Equal(t, 2, len(user.PasswordHistory))
+	for _, password := range []string{"first password", "second password", "third password"} {
+		assert.Equal(t, policy.CodePasswordReused, violationCode(changePassword(password)), password)
+	}
+	//history is bounded, the oldest password is forgotten
+	assert.NoError(t, changePassword("fourth password"))
+	assert.Equal(t, 2, len(user.PasswordHistory))
+	assert.NoError(t, changePassword("first password"))
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenStorage := mock_ports.NewMockTokenStorage(ctrl)
+	notifier := mock_ports.NewMockNotifier(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	authService, err := NewAuth(config.JWTConfig{AccesTTL: time.Minute, RefreshTTL: time.Hour}, nil, nil, repo, tokenStorage, revocations, logging.New("debug"),
+		WithHasher(hasher), WithPasswordHistory(3, time.Hour), WithNotifier(notifier), WithResetTokenTTL(time.Minute))
+	assert.NoError(t, err)
+
+	oldHash, err := hasher.Hash("old password")
+	assert.NoError(t, err)
+	user := models.Credentials{Login: "admin", Password: oldHash, PasswordChangedAt: time.Now()}
+	resets := make(map[string]models.ResetToken)
+	repo.EXPECT().GetUser(gomock.Any(), "admin").DoAndReturn(
+		func(context.Context, string) (*models.Credentials, error) {
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), "nobody").Return(nil, e.ErrNoUserInDB).AnyTimes()
+	repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, u *models.Credentials) error {
+			user = *u
+			return nil
+		}).AnyTimes()
+	tokenStorage.EXPECT().SaveResetToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, token *models.ResetToken) error {
+			resets[token.Hash] = *token
+			return nil
+		}).AnyTimes()
+	tokenStorage.EXPECT().GetResetToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, hash string) (*models.ResetToken, error) {
+			token, ok := resets[hash]
+			if !ok {
+				return nil, e.ErrBadResetToken
+			}
+			return &token, nil
+		}).AnyTimes()
+	tokenStorage.EXPECT().DeleteResetToken(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, hash string) error {
+			if _, ok := resets[hash]; !ok {
+				return e.ErrBadResetToken
+			}
+			delete(resets, hash)
+			return nil
+		}).AnyTimes()
+	var sent []*models.Notification
+	notifier.EXPECT().Notify(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, n *models.Notification) error {
+			sent = append(sent, n)
+			return nil
+		}).AnyTimes()
+
+	//unknown login looks the same to caller, but nothing is sent
+	assert.NoError(t, authService.ForgotPassword(ctx, "nobody"))
+	assert.Equal(t, 0, len(sent))
+
+	assert.NoError(t, authService.ForgotPassword(ctx, "admin"))
+	authService.deliveries.Wait()
+	assert.Equal(t, 1, len(sent))
+	token := sent[0].Secret
+	assert.Equal(t, "admin", sent[0].Recipient)
+	assert.Equal(t, models.PasswordResetNotification, sent[0].Kind)
+	//only hash of token is stored
+	_, stored := resets[token]
+	assert.Equal(t, false, stored)
+
+	assert.ErrorIs(t, authService.ResetPassword(ctx, "wrong token", "new password"), e.ErrBadResetToken)
+	//weak password doesn't use up token
+	var validationErr *e.ValidationError
+	assert.True(t, errors.As(authService.ResetPassword(ctx, token, "qwerty"), &validationErr))
+
+	//another token is asked for meanwhile
+	assert.NoError(t, authService.ForgotPassword(ctx, "admin"))
+	authService.deliveries.Wait()
+	assert.Equal(t, 2, len(sent))
+
+	//min age doesn't apply to reset, sessions are ended
+	tokenStorage.EXPECT().DeleteResetTokens(gomock.Any(), "admin").DoAndReturn(
+		func(_ context.Context, login string) error {
+			for hash, reset := range resets {
+				if reset.Login == login {
+					delete(resets, hash)
+				}
+			}
+			return nil
+		}).Times(1)
+	tokenStorage.EXPECT().RevokeUserFamilies(gomock.Any(), "admin").Return(nil).Times(1)
+	assert.NoError(t, authService.ResetPassword(ctx, token, "new password"))
+	ok, _, err := hasher.Verify("new password", user.Password)
+	assert.NoError(t, err)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, []string{oldHash}, user.PasswordHistory)
+	//tokens issued before the reset are revoked
+	revokedBefore, err := revocations.UserRevokedBefore(ctx, "admin")
+	assert.NoError(t, err)
+	assert.True(t, revokedBefore.Equal(user.PasswordChangedAt.Truncate(time.Second)))
+
+	//token is single-use, and other tokens of the user are dropped with it
+	assert.ErrorIs(t, authService.ResetPassword(ctx, token, "newer password"), e.ErrBadResetToken)
+	assert.ErrorIs(t, authService.ResetPassword(ctx, sent[1].Secret, "newer password"), e.ErrBadResetToken)
+
+	//expired token is rejected
+	assert.NoError(t, authService.ForgotPassword(ctx, "admin"))
+	authService.deliveries.Wait()
+	for hash, reset := range resets {
+		reset.ExpiresAt = time.Now().Add(-time.Second)
+		resets[hash] = reset
+	}
+	assert.ErrorIs(t, authService.ResetPassword(ctx, sent[2].Secret, "newer password"), e.ErrBadResetToken)
+
+	//failed delivery is not told to caller
+	failing := mock_ports.NewMockNotifier(ctrl)
+	failing.EXPECT().Notify(gomock.Any(), gomock.Any()).Return(errors.New("smtp is down")).Times(1)
+	withFailingNotifier, err := NewAuth(config.JWTConfig{}, nil, nil, repo, tokenStorage, nil, logging.New("debug"),
+		WithNotifier(failing))
+	assert.NoError(t, err)
+	assert.NoError(t, withFailingNotifier.ForgotPassword(ctx, "admin"))
+	withFailingNotifier.deliveries.Wait()
+
+	withoutNotifier, err := NewAuth(config.JWTConfig{}, nil, nil, repo, tokenStorage, nil, logging.New("debug"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, withoutNotifier.ForgotPassword(ctx, "admin"), e.ErrNoNotifier)
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := config.JWTConfig{Secret: "test", AccesTTL: time.Minute, RefreshTTL: time.Hour}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"),
+		WithHasher(hasher), WithPasswordHistory(3, time.Hour),
+		WithLockout(memory.NewAttemptStorage(ctx, time.Minute), config.LockoutConfig{
+			MaxFailures:   2,
+			IPMaxFailures: 10,
+			BaseDelay:     time.Millisecond,
+			MaxDelay:      time.Millisecond,
+			LockDuration:  time.Hour,
+		}))
+	assert.NoError(t, err)
+
+	oldHash, err := hasher.Hash("old password")
+	assert.NoError(t, err)
+	user := models.Credentials{Login: "admin", Password: oldHash, PasswordChangedAt: time.Now().Add(-2 * time.Hour)}
+	repo.EXPECT().GetUser(gomock.Any(), "admin").DoAndReturn(
+		func(context.Context, string) (*models.Credentials, error) {
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, u *models.Credentials) error {
+			user = *u
+			return nil
+		}).Times(1)
+
+	changePassword := func(current, password string) (*models.TokenPair, error) {
+		time.Sleep(2 * time.Millisecond) //longer than backoff
+		return authService.ChangePassword(ctx, "admin", current, password)
+	}
+
+	_, err = changePassword("wrong password", "new password")
+	assert.ErrorIs(t, err, e.ErrWrongPass)
+	_, err = changePassword("old password", "old password")
+	var validationErr *e.ValidationError
+	assert.True(t, errors.As(err, &validationErr))
+
+	//other sessions end, the caller gets a new one
+	tokenRepo.EXPECT().RevokeUserFamilies(gomock.Any(), "admin").Return(nil).Times(1)
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	pair, err := changePassword("old password", "new password")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pair.AccessToken)
+	assert.NotEmpty(t, pair.RefreshToken)
+	ok, _, err := hasher.Verify("new password", user.Password)
+	assert.NoError(t, err)
+	assert.Equal(t, true, ok)
+
+	//access tokens issued before the change are revoked, the new one is valid
+	err = authService.checkRevoked(ctx, &tokens.Claims{ID: "old", Subject: "admin", IssuedAt: time.Now().Add(-time.Minute)})
+	assert.ErrorIs(t, err, e.ErrTokenRevoked)
+	_, err = authService.ValidateToken(ctx, pair.AccessToken, models.AccessTokenType)
+	assert.NoError(t, err)
+
+	//min age applies to users changing their own password
+	_, err = changePassword("new password", "newer password")
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, policy.CodePasswordTooNew, validationErr.Violations[0].Code)
+
+	//wrong current passwords lock the login like failed logins do
+	for i := 0; i < 2; i++ {
+		_, err = changePassword("wrong password", "newer password")
+		assert.ErrorIs(t, err, e.ErrWrongPass)
+	}
+	_, err = changePassword("new password", "newer password")
+	assert.ErrorIs(t, err, e.ErrAccountLocked)
+}
+
+func TestPasswordExpiration(t *testing.T) {
+	ctx := context.Background()
+	cfg := config.JWTConfig{Secret: "test", AccesTTL: time.Minute, RefreshTTL: time.Hour}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"),
+		WithHasher(hasher), WithPasswordHistory(3, time.Hour), WithPasswordMaxAge(24*time.Hour))
+	assert.NoError(t, err)
+
+	hash, err := hasher.Hash("temporary password")
+	assert.NoError(t, err)
+	user := models.Credentials{Login: "admin", Password: hash, PasswordChangedAt: time.Now()}
+	repo.EXPECT().GetUser(gomock.Any(), "admin").DoAndReturn(
+		func(context.Context, string) (*models.Credentials, error) {
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, u *models.Credentials) error {
+			user = *u
+			return nil
+		}).AnyTimes()
+	tokenRepo.EXPECT().RevokeUserFamilies(gomock.Any(), "admin").Return(nil).AnyTimes()
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	login := func(password string) error {
+		return authService.AuthUser(ctx, &models.Credentials{Login: "admin", Password: password})
+	}
+
+	assert.NoError(t, login("temporary password"))
+	accessToken, err := authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	_, err = authService.ValidateToken(ctx, accessToken, models.AccessTokenType)
+	assert.NoError(t, err)
+	//tokens of the same second as the cutoff are kept, expire in the next one
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	assert.NoError(t, authService.ExpirePassword(ctx, "admin"))
+	assert.True(t, user.MustChangePassword)
+	//access token issued before the expiry is revoked
+	_, err = authService.ValidateToken(ctx, accessToken, models.AccessTokenType)
+	assert.ErrorIs(t, err, e.ErrTokenRevoked)
+	assert.ErrorIs(t, login("temporary password"), e.ErrPasswordChangeRequired)
+	assert.ErrorIs(t, login("wrong password"), e.ErrWrongPass)
+
+	//restricted token is not an access token
+	token, err := authService.CreateToken(ctx, "admin", models.PasswordChangeTokenType)
+	assert.NoError(t, err)
+	claims, err := authService.ValidateToken(ctx, token, models.PasswordChangeTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", claims.Subject)
+	_, err = authService.ValidateToken(ctx, token, models.AccessTokenType)
+	assert.Error(t, err)
+
+	//forced change ignores min age and clears the flag
+	_, err = authService.ChangePassword(ctx, "admin", "temporary password", "permanent password")
+	assert.NoError(t, err)
+	assert.False(t, user.MustChangePassword)
+	assert.NoError(t, login("permanent password"))
+
+	//old password expires by age
+	user.PasswordChangedAt = time.Now().Add(-25 * time.Hour)
+	assert.ErrorIs(t, login("permanent password"), e.ErrPasswordChangeRequired)
+	_, err = authService.ChangePassword(ctx, "admin", "permanent password", "fresh password")
+	assert.NoError(t, err)
+	assert.NoError(t, login("fresh password"))
+
+	//users without timestamp don't expire by age
+	user.PasswordChangedAt = time.Time{}
+	assert.NoError(t, login("fresh password"))
+
+	repo.EXPECT().GetUser(gomock.Any(), "nobody").Return(nil, e.ErrNoUserInDB)
+	assert.ErrorIs(t, authService.ExpirePassword(ctx, "nobody"), e.ErrNoUserInDB)
+}
+
+func TestLockout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	hash, err := hasher.Hash("password")
+	assert.NoError(t, err)
+	repo.EXPECT().GetUser(gomock.Any(), "admin").Return(&models.Credentials{Login: "admin", Password: hash}, nil).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), "nobody").Return(nil, e.ErrNoUserInDB).AnyTimes()
+	attempts := memory.NewAttemptStorage(ctx, time.Minute)
+	authService, err := NewAuth(config.JWTConfig{}, nil, nil, repo, nil, nil, logging.New("debug"), WithHasher(hasher),
+		WithLockout(attempts, config.LockoutConfig{
+			MaxFailures:   3,
+			IPMaxFailures: 5,
+			BaseDelay:     time.Millisecond,
+			MaxDelay:      4 * time.Millisecond,
+			LockDuration:  time.Hour,
+		}))
+	assert.NoError(t, err)
+	login := func(ip, login, password string) error {
+		time.Sleep(5 * time.Millisecond) //longer than backoff
+		return authService.AuthUser(models.WithClientIP(ctx, ip), &models.Credentials{Login: login, Password: password})
+	}
+	assert.Equal(t, []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 4 * time.Millisecond},
+		[]time.Duration{authService.backoff(1), authService.backoff(2), authService.backoff(3), authService.backoff(10)})
+
+	//attempt right after failure is throttled
+	assert.ErrorIs(t, login("10.0.0.1", "admin", "wrong"), e.ErrWrongPass)
+	err = authService.AuthUser(models.WithClientIP(ctx, "10.0.0.2"), &models.Credentials{Login: "admin", Password: "password"})
+	var retryErr *e.RetryError
+	assert.True(t, errors.As(err, &retryErr))
+	assert.ErrorIs(t, err, e.ErrTooManyAttempts)
+
+	//success resets failures of login
+	assert.NoError(t, login("10.0.0.1", "admin", "password"))
+	assert.ErrorIs(t, login("10.0.0.1", "admin", "wrong"), e.ErrWrongPass)
+	assert.ErrorIs(t, login("10.0.0.2", "admin", "wrong"), e.ErrWrongPass)
+	assert.ErrorIs(t, login("10.0.0.3", "admin", "wrong"), e.ErrWrongPass)
+	//login is locked from any ip, even with right password
+	err = login("10.0.0.4", "admin", "password")
+	assert.ErrorIs(t, err, e.ErrAccountLocked)
+	assert.True(t, errors.As(err, &retryErr))
+	assert.True(t, retryErr.RetryAfter > 59*time.Minute)
+
+	//ip is locked after its own threshold, unknown logins count too
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, login("10.0.0.1", "nobody", "wrong"), e.ErrNoUserInDB)
+		assert.NoError(t, authService.Unlock(ctx, "nobody", ""))
+	}
+	err = login("10.0.0.1", "nobody", "wrong")
+	assert.ErrorIs(t, err, e.ErrTooManyAttempts)
+	assert.True(t, errors.As(err, &retryErr))
+	assert.True(t, retryErr.RetryAfter > 59*time.Minute)
+
+	//admin unlocks
+	assert.NoError(t, authService.Unlock(ctx, "admin", "10.0.0.1"))
+	assert.NoError(t, login("10.0.0.1", "admin", "password"))
+}
+
+func TestImportUsers(t *testing.T) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	authService, err := NewAuth(config.JWTConfig{}, nil, nil, repo, nil, nil, logging.New("debug"))
+	assert.NoError(t, err)
+
+	created := make(map[string]*models.Credentials)
+	repo.EXPECT().CreateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, u *models.Credentials) error {
+			created[u.Login] = u
+			return nil
+		}).Times(2)
+	setAt := time.Date(2020, time.May, 1, 0, 0, 0, 0, time.UTC)
+	start := time.Now()
+	result := authService.ImportUsers(ctx, []models.ImportedUser{
+		{Login: "django", Algorithm: passwords.DjangoPBKDF2SHA256, Hash: "pbkdf2_sha256$1000$seasalt$R1/GfWtwog1T8Ev9VndgDjzkiRzbFr8JpmJtL9cMmQU=",
+			Roles: []string{"support"}},
+		{Login: "", Algorithm: passwords.Bcrypt, Hash: "$2a$04$tLuPagOw4RvPriKLE5Rr5eTzuJ1/w2eAUVxAegIcJm7EJ2E4IgyY."},
+		{Login: "md5", Algorithm: "md5", Hash: "0d107d09f5bbe40cade3de5c71e9e9b7"},
+		{Login: "old", Algorithm: passwords.Bcrypt, Hash: "$2a$04$tLuPagOw4RvPriKLE5Rr5eTzuJ1/w2eAUVxAegIcJm7EJ2E4IgyY.",
+			PasswordChangedAt: setAt},
+	})
+	assert.Equal(t, 2, result.Imported)
+	assert.Equal(t, 2, len(result.Failed))
+	assert.Equal(t, "md5", result.Failed[1].Login)
+	assert.Equal(t, []string{"support"}, created["django"].Roles)
+	//password age is known from the source system or starts at import
+	assert.False(t, created["django"].PasswordChangedAt.Before(start))
+	assert.True(t, created["old"].PasswordChangedAt.Equal(setAt))
+
+	//the first login upgrades the hash
+	repo.EXPECT().GetUser(gomock.Any(), "django").Return(created["django"], nil).Times(1)
+	repo.EXPECT().UpdateUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, u *models.Credentials) error {
+			assert.True(t, strings.HasPrefix(u.Password, "$argon2id$"), u.Password)
+			return nil
+		}).Times(1)
+	assert.NoError(t, authService.AuthUser(ctx, &models.Credentials{Login: "django", Password: "letmein"}))
+}
+
+func TestMFA(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := config.JWTConfig{Secret: "test", AccesTTL: time.Minute, RefreshTTL: time.Hour}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	hash, err := hasher.Hash("password")
+	assert.NoError(t, err)
+	user := models.Credentials{Login: "admin", Password: hash}
+	repo.EXPECT().GetUser(gomock.Any(), "admin").DoAndReturn(
+		func(context.Context, string) (*models.Credentials, error) {
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	repo.EXPECT().UpdateMFA(gomock.Any(), "admin", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, mfa *models.MFA) error {
+			user.MFA = mfa
+			return nil
+		}).AnyTimes()
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, nil, revocations, logging.New("debug"),
+		WithHasher(hasher), WithMFA(config.MFAConfig{Issuer: "team31", RecoveryCodes: 3}))
+	assert.NoError(t, err)
+	code := func(shift int64) string {
+		c, err := totp.Code(user.MFA.TOTPSecret, totp.Step(time.Now())+shift)
+		assert.NoError(t, err)
+		return c
+	}
+	login := func() error {
+		return authService.AuthUser(ctx, &models.Credentials{Login: "admin", Password: "password"})
+	}
+
+	//enrollment is not used until confirmed
+	enrollment, err := authService.EnrollTOTP(ctx, "admin")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/team31:admin?"))
+	assert.Equal(t, enrollment.Secret, user.MFA.TOTPSecret)
+	assert.NoError(t, login())
+	_, err = authService.ConfirmTOTP(ctx, "admin", code(5))
+	assert.ErrorIs(t, err, e.ErrBadMFACode)
+	recoveryCodes, err := authService.ConfirmTOTP(ctx, "admin", code(-1))
+	assert.NoError(t, err)
+	assert.Len(t, recoveryCodes, 3)
+	assert.NotContains(t, user.MFA.RecoveryCodes, recoveryCodes[0])
+	_, err = authService.EnrollTOTP(ctx, "admin")
+	assert.ErrorIs(t, err, e.ErrMFAEnabled)
+
+	//password is not enough anymore
+	assert.ErrorIs(t, login(), e.ErrMFARequired)
+	mfaToken, err := authService.CreateToken(ctx, "admin", models.MFAPendingTokenType)
+	assert.NoError(t, err)
+	_, err = authService.ValidateToken(ctx, mfaToken, models.AccessTokenType)
+	assert.Error(t, err)
+
+	//code of already used step is rejected
+	_, err = authService.VerifyMFA(ctx, mfaToken, code(-1))
+	assert.ErrorIs(t, err, e.ErrBadMFACode)
+	loggedIn, err := authService.VerifyMFA(ctx, mfaToken, code(0))
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", loggedIn)
+	//mfa token is single use
+	_, err = authService.VerifyMFA(ctx, mfaToken, code(1))
+	assert.ErrorIs(t, err, e.ErrTokenRevoked)
+
+	//recovery code works once, in any case and without dashes
+	mfaToken, err = authService.CreateToken(ctx, "admin", models.MFAPendingTokenType)
+	assert.NoError(t, err)
+	_, err = authService.VerifyMFA(ctx, mfaToken, strings.ToUpper(strings.ReplaceAll(recoveryCodes[1], "-", "")))
+	assert.NoError(t, err)
+	assert.Len(t, user.MFA.RecoveryCodes, 2)
+
+	//wrong codes use up mfa token even without lockout
+	mfaToken, err = authService.CreateToken(ctx, "admin", models.MFAPendingTokenType)
+	assert.NoError(t, err)
+	for i := 0; i < defaultPendingAttempts; i++ {
+		_, err = authService.VerifyMFA(ctx, mfaToken, "wrong")
+		assert.ErrorIs(t, err, e.ErrBadMFACode)
+	}
+	_, err = authService.VerifyMFA(ctx, mfaToken, recoveryCodes[2])
+	assert.ErrorIs(t, err, e.ErrTokenRevoked)
+	assert.Len(t, user.MFA.RecoveryCodes, 2)
+
+	_, err = authService.RegenerateRecoveryCodes(ctx, "admin", recoveryCodes[1])
+	assert.ErrorIs(t, err, e.ErrBadMFACode)
+
+	newCodes, err := authService.RegenerateRecoveryCodes(ctx, "admin", recoveryCodes[0])
+	assert.NoError(t, err)
+	assert.Len(t, user.MFA.RecoveryCodes, 3)
+	assert.ErrorIs(t, authService.DisableMFA(ctx, "admin", recoveryCodes[2]), e.ErrBadMFACode)
+	assert.NoError(t, authService.DisableMFA(ctx, "admin", newCodes[0]))
+	assert.Nil(t, user.MFA)
+	assert.NoError(t, login())
+	assert.ErrorIs(t, authService.DisableMFA(ctx, "admin", newCodes[1]), e.ErrMFANotEnabled)
+}
+
+
+func TestWebAuthn(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := config.JWTConfig{Secret: "test", AccesTTL: time.Minute, RefreshTTL: time.Hour}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	hash, err := hasher.Hash("password")
+	assert.NoError(t, err)
+	user := models.Credentials{ID: primitive.NewObjectID(), Login: "admin", Password: hash}
+	repo.EXPECT().GetUser(gomock.Any(), "admin").DoAndReturn(
+		func(context.Context, string) (*models.Credentials, error) {
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	repo.EXPECT().GetUser(gomock.Any(), "nobody").Return(nil, e.ErrNoUserInDB).AnyTimes()
+	repo.EXPECT().UpdateWebAuthn(gomock.Any(), "admin", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, credentials []models.WebAuthnCredential) error {
+			user.WebAuthn = credentials
+			return nil
+		}).AnyTimes()
+	repo.EXPECT().GetUserByCredentialID(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, id string) (*models.Credentials, error) {
+			if findCredential(user.WebAuthn, id) < 0 {
+				return nil, e.ErrNoUserInDB
+			}
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	challenges := mock_ports.NewMockChallengeStorage(ctrl)
+	issued := map[string]*models.WebAuthnChallenge{}
+	challenges.EXPECT().SaveChallenge(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, challenge *models.WebAuthnChallenge) error {
+			issued[challenge.ID] = challenge
+			return nil
+		}).AnyTimes()
+	challenges.EXPECT().TakeChallenge(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, id string) (*models.WebAuthnChallenge, error) {
+			challenge, ok := issued[id]
+			if !ok {
+				return nil, e.ErrBadChallenge
+			}
+			delete(issued, id)
+			return challenge, nil
+		}).AnyTimes()
+	rp, err := webauthn.New(webauthn.Config{RPID: "example.com", Origins: []string{"https://example.com"}})
+	assert.NoError(t, err)
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, nil, revocations, logging.New("debug"),
+		WithHasher(hasher), WithWebAuthn(rp, challenges))
+	assert.NoError(t, err)
+	authenticator := webauthntest.New("https://example.com")
+	assertion := func(options *webauthn.RequestOptions, err error) *webauthn.AssertionResponse {
+		assert.NoError(t, err)
+		resp, err := authenticator.Get(options)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	withoutWebAuthn, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, nil, revocations, logging.New("debug"))
+	assert.NoError(t, err)
+	_, err = withoutWebAuthn.BeginWebAuthnLogin(ctx, "")
+	assert.ErrorIs(t, err, e.ErrWebAuthnDisabled)
+
+	//registration response is accepted once
+	creation, err := authService.BeginWebAuthnRegistration(ctx, "admin")
+	assert.NoError(t, err)
+	assert.Equal(t, webauthn.EncodeID(user.ID[:]), creation.User.ID)
+	attestation, err := authenticator.Create(creation)
+	assert.NoError(t, err)
+	credential, err := authService.FinishWebAuthnRegistration(ctx, "admin", "laptop", attestation)
+	assert.NoError(t, err)
+	assert.Equal(t, "laptop", credential.Name)
+	assert.Equal(t, webauthn.FormatNone, credential.Attestation)
+	_, err = authService.FinishWebAuthnRegistration(ctx, "admin", "laptop", attestation)
+	assert.ErrorIs(t, err, e.ErrBadChallenge)
+	creation, err = authService.BeginWebAuthnRegistration(ctx, "admin")
+	assert.NoError(t, err)
+	assert.Len(t, creation.ExcludeCredentials, 1)
+	credentials, err := authService.WebAuthnCredentials(ctx, "admin")
+	assert.NoError(t, err)
+	assert.Len(t, credentials, 1)
+
+	//password is not enough anymore, TOTP codes are not accepted without TOTP
+	assert.ErrorIs(t, authService.AuthUser(ctx, &models.Credentials{Login: "admin", Password: "password"}), e.ErrMFARequired)
+	mfaToken, err := authService.CreateToken(ctx, "admin", models.MFAPendingTokenType)
+	assert.NoError(t, err)
+	_, err = authService.VerifyMFA(ctx, mfaToken, "123456")
+	assert.ErrorIs(t, err, e.ErrMFANotEnabled)
+
+	//second factor
+	resp := assertion(authService.BeginWebAuthnMFA(ctx, mfaToken))
+	loggedIn, err := authService.FinishWebAuthnMFA(ctx, mfaToken, resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", loggedIn)
+	_, err = authService.ValidateToken(ctx, mfaToken, models.MFAPendingTokenType)
+	assert.ErrorIs(t, err, e.ErrTokenRevoked)
+
+	//passwordless login with picked passkey or credentials of login
+	resp = assertion(authService.BeginWebAuthnLogin(ctx, ""))
+	loggedIn, err = authService.FinishWebAuthnLogin(ctx, resp)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", loggedIn)
+	_, err = authService.FinishWebAuthnLogin(ctx, resp)
+	assert.ErrorIs(t, err, e.ErrBadChallenge)
+	options, err := authService.BeginWebAuthnLogin(ctx, "admin")
+	assert.Len(t, options.AllowCredentials, 1)
+	loggedIn, err = authService.FinishWebAuthnLogin(ctx, assertion(options, err))
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", loggedIn)
+	options, err = authService.BeginWebAuthnLogin(ctx, "nobody")
+	assert.NoError(t, err)
+	assert.Empty(t, options.AllowCredentials)
+
+	//passwordless login needs user verification
+	authenticator.UserVerified = false
+	_, err = authService.FinishWebAuthnLogin(ctx, assertion(authService.BeginWebAuthnLogin(ctx, "")))
+	assert.ErrorIs(t, err, e.ErrWebAuthnFailed)
+	authenticator.UserVerified = true
+
+	//sign counter which went back means a clone
+	authenticator.Counter = true
+	_, err = authService.FinishWebAuthnLogin(ctx, assertion(authService.BeginWebAuthnLogin(ctx, "")))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), user.WebAuthn[0].SignCount)
+	assert.False(t, user.WebAuthn[0].LastUsedAt.IsZero())
+	authenticator.SetSignCount(0)
+	_, err = authService.FinishWebAuthnLogin(ctx, assertion(authService.BeginWebAuthnLogin(ctx, "")))
+	assert.ErrorIs(t, err, e.ErrWebAuthnFailed)
+
+	assert.ErrorIs(t, authService.DeleteWebAuthnCredential(ctx, "admin", "unknown"), e.ErrNoWebAuthnCredential)
+	assert.NoError(t, authService.DeleteWebAuthnCredential(ctx, "admin", credential.ID))
+	assert.Empty(t, user.WebAuthn)
+	assert.NoError(t, authService.AuthUser(ctx, &models.Credentials{Login: "admin", Password: "password"}))
+}
+
+func TestOTP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := config.JWTConfig{Secret: "test", AccesTTL: time.Minute, RefreshTTL: time.Hour}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	hash, err := hasher.Hash("password")
+	assert.NoError(t, err)
+	user := models.Credentials{Login: "admin", Password: hash}
+	repo.EXPECT().GetUser(gomock.Any(), "admin").DoAndReturn(
+		func(context.Context, string) (*models.Credentials, error) {
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	repo.EXPECT().UpdateOTP(gomock.Any(), "admin", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, otp *models.OTPFactor) error {
+			user.OTP = otp
+			return nil
+		}).AnyTimes()
+	otps := mock_ports.NewMockOTPStorage(ctrl)
+	codes := map[string]*models.OTPCode{}
+	otps.EXPECT().SaveOTP(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, code *models.OTPCode) error {
+			codes[code.Login] = code
+			return nil
+		}).AnyTimes()
+	otps.EXPECT().GetOTP(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, login string) (*models.OTPCode, error) {
+			if code, ok := codes[login]; ok {
+				return code, nil
+			}
+			return nil, e.ErrNoOTP
+		}).AnyTimes()
+	otps.EXPECT().AddOTPAttempt(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, login string) (*models.OTPCode, error) {
+			code, ok := codes[login]
+			if !ok {
+				return nil, e.ErrNoOTP
+			}
+			code.Attempts++
+			counted := *code
+			return &counted, nil
+		}).AnyTimes()
+	otps.EXPECT().DeleteOTP(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, login string) error {
+			if _, ok := codes[login]; !ok {
+				return e.ErrNoOTP
+			}
+			delete(codes, login)
+			return nil
+		}).AnyTimes()
+	outbox := notifier.NewMemoryNotifier()
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, nil, revocations, logging.New("debug"),
+		WithHasher(hasher), WithNotifier(outbox), WithOTP(otps), WithMFA(config.MFAConfig{OTPAttempts: 3}))
+	assert.NoError(t, err)
+	lastCode := func() string {
+		sent, ok := outbox.Last("admin")
+		assert.True(t, ok)
+		assert.Equal(t, models.OTPNotification, sent.Kind)
+		assert.Contains(t, sent.Text, sent.Secret)
+		return sent.Secret
+	}
+	wrongCode := func() string {
+		if lastCode() == "000000" {
+			return "111111"
+		}
+		return "000000"
+	}
+	login := func() error {
+		return authService.AuthUser(ctx, &models.Credentials{Login: "admin", Password: "password"})
+	}
+
+	assert.ErrorIs(t, authService.EnrollOTP(ctx, "admin", "pigeon", "roof"), e.ErrBadOTPChannel)
+	assert.ErrorIs(t, authService.EnrollOTP(ctx, "admin", models.SMSChannel, "5550100"), e.ErrBadOTPAddress)
+	assert.ErrorIs(t, authService.EnrollOTP(ctx, "admin", models.EmailChannel, "Admin <admin@example.com>"), e.ErrBadOTPAddress)
+
+	//enrollment is not used until confirmed, codes are not sent too often
+	assert.NoError(t, authService.EnrollOTP(ctx, "admin", models.EmailChannel, "admin@example.com"))
+	sent, _ := outbox.Last("admin")
+	assert.Equal(t, models.EmailChannel, sent.Channel)
+	assert.Equal(t, "admin@example.com", sent.Address)
+	assert.Len(t, sent.Secret, 6)
+	assert.Equal(t, hashOTP("admin", sent.Secret), codes["admin"].Hash)
+	assert.NoError(t, login())
+	err = authService.EnrollOTP(ctx, "admin", models.EmailChannel, "admin@example.com")
+	var retryErr *e.RetryError
+	assert.ErrorAs(t, err, &retryErr)
+	assert.ErrorIs(t, err, e.ErrOTPSent)
+	assert.ErrorIs(t, authService.ConfirmOTP(ctx, "admin", wrongCode()), e.ErrBadMFACode)
+	assert.NoError(t, authService.ConfirmOTP(ctx, "admin", lastCode()))
+	assert.True(t, user.OTP.Enabled)
+
+	//password is not enough anymore, code is sent on request and works once
+	assert.ErrorIs(t, login(), e.ErrMFARequired)
+	mfaToken, err := authService.CreateToken(ctx, "admin", models.MFAPendingTokenType)
+	assert.NoError(t, err)
+	_, err = authService.VerifyMFA(ctx, mfaToken, lastCode())
+	assert.ErrorIs(t, err, e.ErrBadMFACode)
+	assert.NoError(t, authService.SendMFACode(ctx, mfaToken))
+	loggedIn, err := authService.VerifyMFA(ctx, mfaToken, lastCode())
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", loggedIn)
+	assert.ErrorIs(t, authService.SendMFACode(ctx, mfaToken), e.ErrTokenRevoked)
+
+	//code is gone after too many wrong guesses
+	mfaToken, err = authService.CreateToken(ctx, "admin", models.MFAPendingTokenType)
+	assert.NoError(t, err)
+	assert.NoError(t, authService.SendMFACode(ctx, mfaToken))
+	for i := 0; i < 3; i++ {
+		_, err = authService.VerifyMFA(ctx, mfaToken, wrongCode())
+		assert.ErrorIs(t, err, e.ErrBadMFACode)
+	}
+	_, err = authService.VerifyMFA(ctx, mfaToken, lastCode())
+	assert.ErrorIs(t, err, e.ErrBadMFACode)
+
+	assert.NoError(t, authService.SendMFACode(ctx, mfaToken))
+	assert.NoError(t, authService.DisableOTP(ctx, "admin"))
+	assert.Nil(t, user.OTP)
+	assert.Empty(t, codes)
+	assert.NoError(t, login())
+}
+
+func TestStepUp(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := config.JWTConfig{Secret: "test", AccesTTL: time.Minute, RefreshTTL: time.Hour}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	hash, err := hasher.Hash("password")
+	assert.NoError(t, err)
+	user := models.Credentials{Login: "admin", Password: hash}
+	repo.EXPECT().GetUser(gomock.Any(), "admin").DoAndReturn(
+		func(context.Context, string) (*models.Credentials, error) {
+			u := user
+			return &u, nil
+		}).AnyTimes()
+	repo.EXPECT().UpdateMFA(gomock.Any(), "admin", gomock.Any()).DoAndReturn(
+		func(_ context.Context, _ string, mfa *models.MFA) error {
+			user.MFA = mfa
+			return nil
+		}).AnyTimes()
+	tokenRepo := mock_ports.NewMockTokenStorage(ctrl)
+	var family models.TokenFamily
+	tokenRepo.EXPECT().CreateFamily(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, f *models.TokenFamily) error {
+			family = *f
+			return nil
+		}).AnyTimes()
+	tokenRepo.EXPECT().GetFamily(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(context.Context, string) (*models.TokenFamily, error) {
+			f := family
+			return &f, nil
+		}).AnyTimes()
+	tokenRepo.EXPECT().RotateFamily(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, tokenRepo, revocations, logging.New("debug"),
+		WithHasher(hasher), WithMFA(config.MFAConfig{Issuer: "team31"}))
+	assert.NoError(t, err)
+
+	//methods of login go to access token
+	before := time.Now().Truncate(time.Second)
+	token, err := authService.CreateToken(models.WithAuthMethods(ctx, models.AMRPassword), "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	claims, err := authService.ValidateToken(ctx, token, models.AccessTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.AMRPassword}, claims.AMR)
+	assert.Equal(t, models.ACRSingleFactor, claims.ACR)
+	assert.False(t, claims.AuthTime.Before(before))
+	//token created without methods has no assurance level
+	token, err = authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	claims, err = authService.ValidateToken(ctx, token, models.AccessTokenType)
+	assert.NoError(t, err)
+	assert.Empty(t, claims.ACR)
+
+	//two methods are mfa, refreshed tokens keep methods and auth_time of login
+	refresh, err := authService.CreateToken(models.WithAuthMethods(ctx, models.AMRPassword, models.AMROTP), "admin",
+		models.RefreshTokenType)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.AMRPassword, models.AMROTP, models.AMRMultiFactor}, family.AMR)
+	pair, err := authService.RefreshTokens(ctx, refresh)
+	assert.NoError(t, err)
+	assert.Equal(t, family.AMR, pair.Claims.AMR)
+	assert.Equal(t, models.ACRMultiFactor, pair.Claims.ACR)
+	assert.Equal(t, family.AuthTime, pair.Claims.AuthTime)
+	introspection := authService.Introspect(ctx, pair.AccessToken, "")
+	assert.Equal(t, models.ACRMultiFactor, introspection.ACR)
+	assert.Equal(t, family.AuthTime.Unix(), introspection.AuthTime)
+
+	//re-authentication asks the same factors as login
+	_, err = authService.Reauthenticate(ctx, "admin", "wrong", "")
+	assert.ErrorIs(t, err, e.ErrWrongPass)
+	amr, err := authService.Reauthenticate(ctx, "admin", "password", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.AMRPassword}, amr)
+
+	enrollment, err := authService.EnrollTOTP(ctx, "admin")
+	assert.NoError(t, err)
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now())-1)
+	assert.NoError(t, err)
+	_, err = authService.ConfirmTOTP(ctx, "admin", code)
+	assert.NoError(t, err)
+	_, err = authService.Reauthenticate(ctx, "admin", "password", "")
+	assert.ErrorIs(t, err, e.ErrMFARequired)
+	_, err = authService.Reauthenticate(ctx, "admin", "password", code)
+	assert.ErrorIs(t, err, e.ErrBadMFACode)
+	code, err = totp.Code(enrollment.Secret, totp.Step(time.Now()))
+	assert.NoError(t, err)
+	_, err = authService.Reauthenticate(ctx, "admin", "wrong", code)
+	assert.ErrorIs(t, err, e.ErrWrongPass)
+	amr, err = authService.Reauthenticate(ctx, "admin", "password", code)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{models.AMRPassword, models.AMROTP}, amr)
+}
+
+func TestTrustedDevices(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cfg := config.JWTConfig{Secret: "test", AccesTTL: time.Minute, RefreshTTL: time.Hour}
+	ctrl := gomock.NewController(t)
+	repo := mock_ports.NewMockAuthStorage(ctrl)
+	hasher, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	assert.NoError(t, err)
+	hash, err := hasher.Hash("password")
+	assert.NoError(t, err)
+	users := map[string]*models.Credentials{
+		"admin": {Login: "admin", Password: hash, MFA: &models.MFA{Enabled: true}},
+		"user":  {Login: "user", Password: hash, MFA: &models.MFA{Enabled: true}},
+	}
+	repo.EXPECT().GetUser(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, login string) (*models.Credentials, error) {
+			u := *users[login]
+			return &u, nil
+		}).AnyTimes()
+	devices := mock_ports.NewMockDeviceStorage(ctrl)
+	saved := map[string]models.TrustedDevice{}
+	devices.EXPECT().SaveDevice(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, device *models.TrustedDevice) error {
+			saved[device.ID] = *device
+			return nil
+		}).AnyTimes()
+	devices.EXPECT().GetDevice(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, id string) (*models.TrustedDevice, error) {
+			device, ok := saved[id]
+			if !ok {
+				return nil, e.ErrNoTrustedDevice
+			}
+			return &device, nil
+		}).AnyTimes()
+	devices.EXPECT().TouchDevice(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, id string, usedAt time.Time) error {
+			device := saved[id]
+			device.LastUsedAt = usedAt
+			saved[id] = device
+			return nil
+		}).AnyTimes()
+	revocations := memory.NewRevocationStorage(ctx, time.Minute)
+	authService, err := NewAuth(cfg, hmacKeyring(t, cfg.Secret), nil, repo, nil, revocations, logging.New("debug"),
+		WithHasher(hasher), WithMFA(config.MFAConfig{Issuer: "team31"}))
+	assert.NoError(t, err)
+	login := func(login, password, deviceToken string) error {
+		return authService.AuthUser(models.WithDeviceToken(ctx, deviceToken), &models.Credentials{Login: login, Password: password})
+	}
+
+	_, _, err = authService.TrustDevice(ctx, "admin", "laptop")
+	assert.ErrorIs(t, err, e.ErrTrustedDevicesDisabled)
+	WithTrustedDevices(devices)(authService)
+
+	before := time.Now().Add(30 * 24 * time.Hour).Add(-time.Minute)
+	token, device, err := authService.TrustDevice(ctx, "admin", strings.Repeat("я", 200))
+	assert.NoError(t, err)
+	assert.Equal(t, device.ID, saved[device.ID].ID)
+	assert.Len(t, []rune(device.Name), 128)
+	assert.True(t, device.ExpiresAt.After(before))
+
+	//trusted device skips second factor, password is still checked
+	assert.NoError(t, login("admin", "password", token))
+	assert.False(t, saved[device.ID].LastUsedAt.IsZero())
+	assert.ErrorIs(t, login("admin", "wrong", token), e.ErrWrongPass)
+	//other devices and other users still pass second factor
+	assert.ErrorIs(t, login("admin", "password", ""), e.ErrMFARequired)
+	assert.ErrorIs(t, login("user", "password", token), e.ErrMFARequired)
+	//tokens of other types are not device tokens
+	access, err := authService.CreateToken(ctx, "admin", models.AccessTokenType)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, login("admin", "password", access), e.ErrMFARequired)
+
+	//revoked device passes second factor again
+	devices.EXPECT().DeleteDevice(gomock.Any(), "admin", device.ID).DoAndReturn(
+		func(_ context.Context, _, id string) error {
+			delete(saved, id)
+			return nil
+		}).Times(1)
+	assert.NoError(t, authService.RevokeDevice(ctx, "admin", device.ID))
+	assert.ErrorIs(t, login("admin", "password", token), e.ErrMFARequired)
+}
@@ -1,15 +1,16 @@
 package auth
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"github.com/DMA8/authService/pkg/passwords"
 )
 
+// HashPassword hashes password with default hasher without pepper.
+// Auth uses hasher from config, which peppers passwords when pepper is configured
 func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 4)
-	return string(bytes), err
+	return passwords.Default().Hash(password)
 }
 
 func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	ok, _, err := passwords.Default().Verify(password, hash)
+	return ok && err == nil
 }
@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/ports"
+	"github.com/DMA8/authService/pkg/webauthn"
+
+	"go.opentelemetry.io/otel"
+)
+
+const maxWebAuthnNameLength = 64
+
+// WithWebAuthn turns on passkeys and security keys. Issued challenges are kept in challenges
+func WithWebAuthn(rp *webauthn.RelyingParty, challenges ports.ChallengeStorage) Option {
+	return func(a *Auth) {
+		a.webauthn = rp
+		a.challenges = challenges
+	}
+}
+
+// BeginWebAuthnRegistration gives options for navigator.credentials.create() to add a credential to login.
+// Credentials are made discoverable if authenticator can, so they work for passwordless login
+func (a *Auth) BeginWebAuthnRegistration(ctx context.Context, login string) (*webauthn.CreationOptions, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth BeginWebAuthnRegistration")
+	defer span.End()
+
+	if a.webauthn == nil {
+		return nil, e.ErrWebAuthnDisabled
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.BeginWebAuthnRegistration couldn't get user %s", login)
+		return nil, err
+	}
+	challenge, err := a.newChallenge(ctx, login, models.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	userEntity := webauthn.UserEntity{ID: webauthn.EncodeID(user.ID[:]), Name: login, DisplayName: login}
+	return a.webauthn.CreationOptions(challenge, userEntity, descriptors(user.WebAuthn),
+		webauthn.VerificationPreferred, webauthn.VerificationPreferred), nil
+}
+
+// FinishWebAuthnRegistration verifies attestation and saves the new credential of login under name
+func (a *Auth) FinishWebAuthnRegistration(ctx context.Context, login, name string,
+	resp *webauthn.AttestationResponse) (*models.WebAuthnCredential, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth FinishWebAuthnRegistration")
+	defer span.End()
+
+	if a.webauthn == nil {
+		return nil, e.ErrWebAuthnDisabled
+	}
+	challenge, err := a.takeChallenge(ctx, resp.Response.ClientDataJSON, models.WebAuthnRegistration, login)
+	if err != nil {
+		return nil, err
+	}
+	verified, err := a.webauthn.VerifyRegistration(resp, challenge, false)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.FinishWebAuthnRegistration bad attestation of %s", login)
+		return nil, e.ErrWebAuthnFailed
+	}
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.FinishWebAuthnRegistration couldn't get user %s", login)
+		return nil, err
+	}
+	id := webauthn.EncodeID(verified.ID)
+	if findCredential(user.WebAuthn, id) >= 0 {
+		return nil, e.ErrWebAuthnCredentialExists
+	}
+	if len(name) > maxWebAuthnNameLength {
+		name = name[:maxWebAuthnNameLength]
+	}
+	if name == "" {
+		name = fmt.Sprintf("Security key %d", len(user.WebAuthn)+1)
+	}
+	credential := models.WebAuthnCredential{
+		ID:          id,
+		Name:        name,
+		PublicKey:   verified.PublicKey,
+		SignCount:   verified.SignCount,
+		AAGUID:      hex.EncodeToString(verified.AAGUID),
+		Attestation: verified.Format,
+		Transports:  verified.Transports,
+		CreatedAt:   time.Now(),
+	}
+	credentials := append(append([]models.WebAuthnCredential{}, user.WebAuthn...), credential)
+	if err = a.repository.UpdateWebAuthn(ctx, login, credentials); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.FinishWebAuthnRegistration couldn't save credential of %s", login)
+		return nil, err
+	}
+	a.logger.Info().Msgf("service.FinishWebAuthnRegistration %s registered credential %s", login, id)
+	return &credential, nil
+}
+
+// WebAuthnCredentials lists passkeys and security keys of login
+func (a *Auth) WebAuthnCredentials(ctx context.Context, login string) ([]models.WebAuthnCredential, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth WebAuthnCredentials")
+	defer span.End()
+
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.WebAuthnCredentials couldn't get user %s", login)
+		return nil, err
+	}
+	if user.WebAuthn == nil {
+		return []models.WebAuthnCredential{}, nil
+	}
+	return user.WebAuthn, nil
+}
+
+// DeleteWebAuthnCredential removes credential id of login
+func (a *Auth) DeleteWebAuthnCredential(ctx context.Context, login, id string) error {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth DeleteWebAuthnCredential")
+	defer span.End()
+
+	user, err := a.repository.GetUser(ctx, login)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.DeleteWebAuthnCredential couldn't get user %s", login)
+		return err
+	}
+	i := findCredential(user.WebAuthn, id)
+	if i < 0 {
+		return e.ErrNoWebAuthnCredential
+	}
+	credentials := append(append([]models.WebAuthnCredential{}, user.WebAuthn[:i]...), user.WebAuthn[i+1:]...)
+	if err = a.repository.UpdateWebAuthn(ctx, login, credentials); err != nil {
+		a.logger.Error().Err(err).Msgf("service.DeleteWebAuthnCredential couldn't delete credential of %s", login)
+		return err
+	}
+	a.logger.Info().Msgf("service.DeleteWebAuthnCredential %s deleted credential %s", login, id)
+	return nil
+}
+
+// BeginWebAuthnLogin gives options for passwordless login. With empty login user picks a passkey,
+// otherwise credentials of login are allowed. Unknown logins get options as if login were empty
+func (a *Auth) BeginWebAuthnLogin(ctx context.Context, login string) (*webauthn.RequestOptions, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth BeginWebAuthnLogin")
+	defer span.End()
+
+	if a.webauthn == nil {
+		return nil, e.ErrWebAuthnDisabled
+	}
+	var allow []webauthn.CredentialDescriptor
+	if login != "" {
+		user, err := a.repository.GetUser(ctx, login)
+		if err != nil && err != e.ErrNoUserInDB {
+			a.logger.Debug().Err(err).Msgf("service.BeginWebAuthnLogin couldn't get user %s", login)
+			return nil, err
+		}
+		if user != nil && len(user.WebAuthn) > 0 {
+			allow = descriptors(user.WebAuthn)
+		} else {
+			login = ""
+		}
+	}
+	challenge, err := a.newChallenge(ctx, login, models.WebAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+	return a.webauthn.RequestOptions(challenge, allow, webauthn.VerificationRequired), nil
+}
+
+// FinishWebAuthnLogin verifies assertion of passwordless login and returns login of credential owner.
+// User verification stands for the second factor. Failures count as failed logins
+func (a *Auth) FinishWebAuthnLogin(ctx context.Context, resp *webauthn.AssertionResponse) (string, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth FinishWebAuthnLogin")
+	defer span.End()
+
+	if a.webauthn == nil {
+		return "", e.ErrWebAuthnDisabled
+	}
+	stored, challenge, err := a.takeChallengeOf(ctx, resp.Response.ClientDataJSON, models.WebAuthnLogin)
+	if err != nil {
+		return "", err
+	}
+	user, err := a.repository.GetUserByCredentialID(ctx, normalizeCredentialID(resp.ID))
+	if err == e.ErrNoUserInDB {
+		a.logger.Debug().Msgf("service.FinishWebAuthnLogin unknown credential %s", resp.ID)
+		return "", e.ErrWebAuthnFailed
+	} else if err != nil {
+		return "", err
+	}
+	if stored.Login != "" && stored.Login != user.Login {
+		a.logger.Debug().Msgf("service.FinishWebAuthnLogin credential of %s answered challenge of %s", user.Login, stored.Login)
+		return "", e.ErrWebAuthnFailed
+	}
+	if err = a.verifyAssertion(ctx, user, resp, challenge, true); err != nil {
+		return "", err
+	}
+	if a.passwordExpired(user) {
+		return user.Login, e.ErrPasswordChangeRequired
+	}
+	return user.Login, nil
+}
+
+// BeginWebAuthnMFA gives options for second factor of login started with password
+func (a *Auth) BeginWebAuthnMFA(ctx context.Context, mfaToken string) (*webauthn.RequestOptions, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth BeginWebAuthnMFA")
+	defer span.End()
+
+	if a.webauthn == nil {
+		return nil, e.ErrWebAuthnDisabled
+	}
+	claims, err := a.ValidateToken(ctx, mfaToken, models.MFAPendingTokenType)
+	if err != nil {
+		a.logger.Debug().Err(err).Msg("service.BeginWebAuthnMFA bad mfa token")
+		return nil, err
+	}
+	user, err := a.repository.GetUser(ctx, claims.Subject)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.BeginWebAuthnMFA couldn't get user %s", claims.Subject)
+		return nil, err
+	}
+	if len(user.WebAuthn) == 0 {
+		return nil, e.ErrNoWebAuthnCredential
+	}
+	challenge, err := a.newChallenge(ctx, user.Login, models.WebAuthnMFA)
+	if err != nil {
+		return nil, err
+	}
+	return a.webauthn.RequestOptions(challenge, descriptors(user.WebAuthn), webauthn.VerificationDiscouraged), nil
+}
+
+// FinishWebAuthnMFA finishes login started with password like VerifyMFA does, with a credential instead of code
+func (a *Auth) FinishWebAuthnMFA(ctx context.Context, mfaToken string, resp *webauthn.AssertionResponse) (string, error) {
+	ctx, span := otel.Tracer("team31_auth").Start(ctx, "service auth FinishWebAuthnMFA")
+	defer span.End()
+
+	if a.webauthn == nil {
+		return "", e.ErrWebAuthnDisabled
+	}
+	claims, err := a.ValidateToken(ctx, mfaToken, models.MFAPendingTokenType)
+	if err != nil {
+		a.logger.Debug().Err(err).Msg("service.FinishWebAuthnMFA bad mfa token")
+		return "", err
+	}
+	challenge, err := a.takeChallenge(ctx, resp.Response.ClientDataJSON, models.WebAuthnMFA, claims.Subject)
+	if err != nil {
+		return "", err
+	}
+	user, err := a.repository.GetUser(ctx, claims.Subject)
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.FinishWebAuthnMFA couldn't get user %s", claims.Subject)
+		return "", err
+	}
+	if err = a.verifyAssertion(ctx, user, resp, challenge, false); err != nil {
+		return "", err
+	}
+	if err = a.revokeToken(ctx, mfaToken, claims, models.MFAPendingTokenType); err != nil {
+		return "", err
+	}
+	if a.passwordExpired(user) {
+		return user.Login, e.ErrPasswordChangeRequired
+	}
+	return user.Login, nil
+}
+
+// verifyAssertion checks assertion of user's credential under lockout rules and saves its new sign counter
+func (a *Auth) verifyAssertion(ctx context.Context, user *models.Credentials, resp *webauthn.AssertionResponse,
+	challenge string, requireUV bool) error {
+	ip := models.ClientIP(ctx)
+	if err := a.checkAttempts(ctx, user.Login, ip); err != nil {
+		a.logger.Debug().Err(err).Msgf("service.verifyAssertion %s from %s is throttled", user.Login, ip)
+		return err
+	}
+	i := findCredential(user.WebAuthn, normalizeCredentialID(resp.ID))
+	if i < 0 {
+		a.registerFailure(ctx, user.Login, ip)
+		return e.ErrWebAuthnFailed
+	}
+	credential := user.WebAuthn[i]
+	assertion, err := a.webauthn.VerifyAssertion(resp, challenge, credential.PublicKey, credential.SignCount, requireUV)
+	if err == nil && len(assertion.UserHandle) > 0 && !bytes.Equal(assertion.UserHandle, user.ID[:]) {
+		err = webauthn.ErrBadResponse
+	}
+	if err != nil {
+		if err == webauthn.ErrSignCount {
+			a.logger.Warn().Msgf("service.verifyAssertion sign counter of %s credential %s went back, it may be cloned",
+				user.Login, credential.ID)
+		}
+		a.registerFailure(ctx, user.Login, ip)
+		a.logger.Debug().Err(err).Msgf("service.verifyAssertion bad assertion of %s", user.Login)
+		return e.ErrWebAuthnFailed
+	}
+	a.resetLoginFailures(ctx, user.Login)
+	credentials := append([]models.WebAuthnCredential{}, user.WebAuthn...)
+	credentials[i].SignCount = assertion.SignCount
+	credentials[i].LastUsedAt = time.Now()
+	if err = a.repository.UpdateWebAuthn(ctx, user.Login, credentials); err != nil {
+		// sign counter has to be saved, otherwise a clone wouldn't be noticed
+		a.logger.Error().Err(err).Msgf("service.verifyAssertion couldn't save credential of %s", user.Login)
+		return err
+	}
+	return nil
+}
+
+// newChallenge issues challenge of ceremony and keeps its hash until response comes
+func (a *Auth) newChallenge(ctx context.Context, login, ceremony string) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		a.logger.Error().Err(err).Msg("service.newChallenge couldn't make challenge")
+		return "", err
+	}
+	err = a.challenges.SaveChallenge(ctx, &models.WebAuthnChallenge{
+		ID:        hashToken(challenge),
+		Login:     login,
+		Ceremony:  ceremony,
+		ExpiresAt: time.Now().Add(a.webauthn.Timeout()),
+	})
+	if err != nil {
+		a.logger.Error().Err(err).Msgf("service.newChallenge couldn't save challenge of %s", login)
+		return "", err
+	}
+	return challenge, nil
+}
+
+// takeChallenge uses up challenge of ceremony issued to login
+func (a *Auth) takeChallenge(ctx context.Context, clientDataJSON, ceremony, login string) (string, error) {
+	stored, challenge, err := a.takeChallengeOf(ctx, clientDataJSON, ceremony)
+	if err != nil {
+		return "", err
+	}
+	if stored.Login != login {
+		a.logger.Debug().Msgf("service.takeChallenge challenge of %s is answered by %s", stored.Login, login)
+		return "", e.ErrBadChallenge
+	}
+	return challenge, nil
+}
+
+// takeChallengeOf finds challenge client data answers and removes it, so the response can't be replayed
+func (a *Auth) takeChallengeOf(ctx context.Context, clientDataJSON, ceremony string) (*models.WebAuthnChallenge, string, error) {
+	challenge, err := webauthn.ChallengeOf(clientDataJSON)
+	if err != nil {
+		return nil, "", e.ErrBadChallenge
+	}
+	stored, err := a.challenges.TakeChallenge(ctx, hashToken(challenge))
+	if err != nil {
+		a.logger.Debug().Err(err).Msgf("service.takeChallenge couldn't take %s challenge", ceremony)
+		return nil, "", err
+	}
+	if stored.Ceremony != ceremony {
+		return nil, "", e.ErrBadChallenge
+	}
+	return stored, challenge, nil
+}
+
+func descriptors(credentials []models.WebAuthnCredential) []webauthn.CredentialDescriptor {
+	list := make([]webauthn.CredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		if id, err := webauthn.DecodeID(c.ID); err == nil {
+			list = append(list, webauthn.Descriptor(id, c.Transports))
+		}
+	}
+	return list
+}
+
+func findCredential(credentials []models.WebAuthnCredential, id string) int {
+	for i, c := range credentials {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// normalizeCredentialID drops padding browsers may add, so ids compare as stored
+func normalizeCredentialID(id string) string {
+	raw, err := webauthn.DecodeID(id)
+	if err != nil {
+		return id
+	}
+	return webauthn.EncodeID(raw)
+}
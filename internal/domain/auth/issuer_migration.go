@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DMA8/authService/internal/config"
+	"github.com/DMA8/authService/internal/ports"
+	"github.com/DMA8/authService/pkg/tokens"
+)
+
+// newIssuer makes issuer of tokens in format from config
+func newIssuer(format string, keys *tokens.Keyring, storage ports.TokenStorage) (ports.TokenIssuer, error) {
+	switch format {
+	case config.OpaqueTokenFormat:
+		return newOpaqueIssuer(storage), nil
+	case config.PasetoPublicTokenFormat:
+		return tokens.NewPasetoIssuer(tokens.PasetoPublic, keys)
+	case config.PasetoLocalTokenFormat:
+		return tokens.NewPasetoIssuer(tokens.PasetoLocal, keys)
+	default:
+		return tokens.NewJWTIssuer(keys), nil
+	}
+}
+
+// migratingIssuer issues tokens with current issuer and verifies tokens of previous formats
+// until the migration window is over. Zero until means window never ends
+type migratingIssuer struct {
+	current  ports.TokenIssuer
+	previous []ports.TokenIssuer
+	until    time.Time
+}
+
+func (i *migratingIssuer) Issue(ctx context.Context, claims *tokens.Claims, dur time.Duration) (string, error) {
+	return i.current.Issue(ctx, claims, dur)
+}
+
+// Verify returns error of current issuer when no issuer accepts the token
+func (i *migratingIssuer) Verify(ctx context.Context, tokenStr string, opts tokens.ValidationOptions) (*tokens.Claims, error) {
+	claims, err := i.current.Verify(ctx, tokenStr, opts)
+	if err == nil || !i.migrating() {
+		return claims, err
+	}
+	for _, issuer := range i.previous {
+		if claims, prevErr := issuer.Verify(ctx, tokenStr, opts); prevErr == nil {
+			return claims, nil
+		}
+	}
+	return nil, err
+}
+
+// Revoke is done by issuer of the token
+func (i *migratingIssuer) Revoke(ctx context.Context, tokenStr string) (bool, error) {
+	if i.migrating() {
+		for _, issuer := range i.previous {
+			if _, err := issuer.Verify(ctx, tokenStr, tokens.ValidationOptions{}); err == nil {
+				return issuer.Revoke(ctx, tokenStr)
+			}
+		}
+	}
+	return i.current.Revoke(ctx, tokenStr)
+}
+
+func (i *migratingIssuer) migrating() bool {
+	return i.until.IsZero() || time.Now().Before(i.until)
+}
+
+// newIssuerWithMigration makes issuer for cfg.TokenFormat which also accepts cfg.AcceptTokenFormats
+func newIssuerWithMigration(cfg config.JWTConfig, keys *tokens.Keyring, storage ports.TokenStorage) (ports.TokenIssuer, error) {
+	current, err := newIssuer(cfg.TokenFormat, keys, storage)
+	if err != nil {
+		return nil, fmt.Errorf("token format %s: %w", cfg.TokenFormat, err)
+	}
+	if len(cfg.AcceptTokenFormats) == 0 {
+		return current, nil
+	}
+	issuer := &migratingIssuer{current: current, until: cfg.AcceptUntil}
+	for _, format := range cfg.AcceptTokenFormats {
+		if format == cfg.TokenFormat {
+			continue
+		}
+		previous, err := newIssuer(format, keys, storage)
+		if err != nil {
+			return nil, fmt.Errorf("accepted token format %s: %w", format, err)
+		}
+		issuer.previous = append(issuer.previous, previous)
+	}
+	return issuer, nil
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/internal/ports"
+	"github.com/DMA8/authService/pkg/tokens"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+const (
+	opaqueTokenPrefix = "opq_"
+	opaqueTokenBytes  = 32
+)
+
+// opaqueIssuer issues random tokens which tell nothing to the client.
+// Claims are stored by sha256 of the token, so a leaked db doesn't leak usable tokens
+type opaqueIssuer struct {
+	storage ports.TokenStorage
+}
+
+func newOpaqueIssuer(storage ports.TokenStorage) *opaqueIssuer {
+	return &opaqueIssuer{storage: storage}
+}
+
+func (i *opaqueIssuer) Issue(ctx context.Context, claims *tokens.Claims, dur time.Duration) (string, error) {
+	switch {
+	case dur == 0:
+		return "", e.ErrZeroDuration
+	case claims == nil || claims.Subject == "":
+		return "", e.ErrNoLoginTokenCreation
+	}
+	random := make([]byte, opaqueTokenBytes)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	token := opaqueTokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+	now := time.Now().Truncate(time.Second)
+	claims.ID = uuid.NewV4().String()
+	claims.IssuedAt = now
+	claims.NotBefore = now
+	claims.ExpiresAt = now.Add(dur)
+	err := i.storage.SaveOpaqueToken(ctx, &models.OpaqueToken{
+		Hash:      hashToken(token),
+		Claims:    *claims,
+		ExpiresAt: claims.ExpiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (i *opaqueIssuer) Verify(ctx context.Context, tokenStr string, opts tokens.ValidationOptions) (*tokens.Claims, error) {
+	if !strings.HasPrefix(tokenStr, opaqueTokenPrefix) {
+		return nil, e.ErrTokenCorrupted
+	}
+	stored, err := i.storage.GetOpaqueToken(ctx, hashToken(tokenStr))
+	if err != nil {
+		return nil, err
+	}
+	claims := stored.Claims
+	if err = opts.Check(&claims, time.Now()); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// Revoke removes the token, so it is unknown from now on
+func (i *opaqueIssuer) Revoke(ctx context.Context, tokenStr string) (bool, error) {
+	if !strings.HasPrefix(tokenStr, opaqueTokenPrefix) {
+		return false, e.ErrTokenCorrupted
+	}
+	return true, i.storage.DeleteOpaqueToken(ctx, hashToken(tokenStr))
+}
+
+func hashToken(tokenStr string) string {
+	hash := sha256.Sum256([]byte(tokenStr))
+	return hex.EncodeToString(hash[:])
+}
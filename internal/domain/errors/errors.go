@@ -4,10 +4,59 @@ import "errors"
 
 var (
 	ErrNoUserInDB error = errors.New("couldn't find the user")
-	ErrWrongPass error = errors.New("bad password")
-	ErrBadCreds error = errors.New("bad creds")
+	ErrWrongPass  error = errors.New("bad password")
+	ErrBadCreds   error = errors.New("bad creds")
+	ErrNoLogin          = errors.New("login is empty")
+	ErrUserExists       = errors.New("user already exists")
+	// ErrBadLoginOrPassword is told to clients instead of ErrNoUserInDB and ErrWrongPass
+	ErrBadLoginOrPassword = errors.New("wrong login or password")
 
-	ErrTokenCorrupted = errors.New("jwt token is corrupted")
+	ErrPasswordChangeRequired = errors.New("password change required")
+
+	ErrMFARequired    = errors.New("second factor required")
+	ErrBadMFACode     = errors.New("wrong second factor code")
+	ErrMFAEnabled     = errors.New("second factor is already enabled")
+	ErrMFANotEnabled  = errors.New("second factor is not enabled")
+	ErrMFANotEnrolled = errors.New("second factor enrollment is not started")
+	// ErrStepUpRequired is told when session is older or weaker than the route needs
+	ErrStepUpRequired = errors.New("recent stronger authentication required")
+
+	ErrWebAuthnDisabled         = errors.New("webauthn is not configured")
+	ErrWebAuthnFailed           = errors.New("webauthn verification failed")
+	ErrBadChallenge             = errors.New("webauthn challenge is unknown or expired")
+	ErrNoWebAuthnCredential     = errors.New("unknown webauthn credential")
+	ErrWebAuthnCredentialExists = errors.New("webauthn credential is already registered")
+
+	ErrNoOTP         = errors.New("one-time code is not sent or expired")
+	ErrOTPSent       = errors.New("one-time code was sent recently")
+	ErrBadOTPChannel = errors.New("one-time codes can be sent by email or sms")
+	ErrBadOTPAddress = errors.New("address can't receive one-time codes")
+
+	ErrTrustedDevicesDisabled = errors.New("trusted devices are not configured")
+	ErrNoTrustedDevice        = errors.New("unknown trusted device")
+
+	ErrBadClientCreds = errors.New("bad client credentials")
+
+	ErrTooManyAttempts = errors.New("too many login attempts, try again later")
+	ErrAccountLocked   = errors.New("account is temporarily locked")
+
+	ErrTokenCorrupted       = errors.New("jwt token is corrupted")
 	ErrNoLoginTokenCreation = errors.New("can not create token without login")
-	ErrZeroDuration = errors.New("token should live more then 0")
+	ErrZeroDuration         = errors.New("token should live more then 0")
+	ErrWrongTokenType       = errors.New("wrong token type")
+
+	ErrNoTokenFamily      = errors.New("couldn't find refresh token family")
+	ErrTokenFamilyRevoked = errors.New("refresh token family is revoked")
+	ErrTokenFamilyRotated = errors.New("refresh token family is already rotated")
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	ErrSessionExpired     = errors.New("session lifetime is over")
+	ErrSessionIdle        = errors.New("session was idle for too long")
+
+	ErrNoOpaqueToken = errors.New("unknown opaque token")
+
+	ErrBadResetToken = errors.New("password reset token is invalid or expired")
+	ErrNoNotifier    = errors.New("notifier is not configured")
+
+	ErrTokenRevoked     = errors.New("token is revoked")
+	ErrNoTokensToRevoke = errors.New("no valid tokens to revoke")
 )
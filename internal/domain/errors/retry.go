@@ -0,0 +1,20 @@
+package errors
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryError is Err which goes away by itself after RetryAfter
+type RetryError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (r *RetryError) Error() string {
+	return fmt.Sprintf("%s (retry after %s)", r.Err, r.RetryAfter)
+}
+
+func (r *RetryError) Unwrap() error {
+	return r.Err
+}
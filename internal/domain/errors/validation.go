@@ -0,0 +1,37 @@
+package errors
+
+import "strings"
+
+// FieldViolation is a single problem with a field of user input.
+// Code is machine-readable, Message is for humans
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError keeps every violation found in user input
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (v *ValidationError) Error() string {
+	messages := make([]string, 0, len(v.Violations))
+	for _, violation := range v.Violations {
+		messages = append(messages, violation.Field+": "+violation.Message)
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Add appends violation of field
+func (v *ValidationError) Add(field, code, message string) {
+	v.Violations = append(v.Violations, FieldViolation{Field: field, Code: code, Message: message})
+}
+
+// Err returns v if it has violations, nil otherwise
+func (v *ValidationError) Err() error {
+	if len(v.Violations) == 0 {
+		return nil
+	}
+	return v
+}
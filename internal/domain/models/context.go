@@ -0,0 +1,42 @@
+package models
+
+import "context"
+
+type clientIPKey struct{}
+
+type authMethodsKey struct{}
+
+type deviceTokenKey struct{}
+
+// WithClientIP keeps ip of the client who made request in ctx
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIP is ip put by WithClientIP, empty if there is none
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// WithAuthMethods keeps methods the user has just authenticated with, tokens created with ctx carry them
+func WithAuthMethods(ctx context.Context, amr ...string) context.Context {
+	return context.WithValue(ctx, authMethodsKey{}, amr)
+}
+
+// AuthMethods are methods put by WithAuthMethods, nil if there are none
+func AuthMethods(ctx context.Context) []string {
+	amr, _ := ctx.Value(authMethodsKey{}).([]string)
+	return amr
+}
+
+// WithDeviceToken keeps token of trusted device the request came from
+func WithDeviceToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, deviceTokenKey{}, token)
+}
+
+// DeviceToken is token put by WithDeviceToken, empty if there is none
+func DeviceToken(ctx context.Context) string {
+	token, _ := ctx.Value(deviceTokenKey{}).(string)
+	return token
+}
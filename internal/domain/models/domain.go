@@ -1,16 +1,282 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type TokenType string
 
+// PasswordChangeTokenType is a restricted token which is accepted only to change expired password.
+// MFAPendingTokenType is given after password when second factor is still required.
+// TrustedDeviceTokenType lets device skip second factor while its TrustedDevice is stored
+const (
+	AccessTokenType         TokenType = "access"
+	RefreshTokenType        TokenType = "refresh"
+	PasswordChangeTokenType TokenType = "password_change"
+	MFAPendingTokenType     TokenType = "mfa_pending"
+	TrustedDeviceTokenType  TokenType = "trusted_device"
+)
+
+// Authentication methods of RFC 8176, they go to amr claim
 const (
-	AccessTokenType TokenType = "access"
-	RefreshTokenType TokenType = "refresh"
+	AMRPassword    = "pwd"
+	AMROTP         = "otp"
+	AMRHardwareKey = "hwk"
+	AMRMultiFactor = "mfa"
 )
 
+// Assurance levels put to acr claim. Passkey alone gives ACRMultiFactor,
+// it is accepted without password only with user verification
+const (
+	ACRSingleFactor = "aal1"
+	ACRMultiFactor  = "aal2"
+)
+
+// ACR is assurance level of amr, empty when methods are unknown
+func ACR(amr []string) string {
+	for _, method := range amr {
+		if method == AMRMultiFactor || method == AMRHardwareKey {
+			return ACRMultiFactor
+		}
+	}
+	if len(amr) > 0 {
+		return ACRSingleFactor
+	}
+	return ""
+}
+
+// ACRLevel orders assurance levels, unknown acr is the lowest
+func ACRLevel(acr string) int {
+	switch acr {
+	case ACRSingleFactor:
+		return 1
+	case ACRMultiFactor:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Credentials is a user. Roles and CustomClaims go to access tokens,
+// they are managed in db only and never taken from requests.
+// PasswordHistory keeps hashes of previous passwords, the latest first.
+// User with MustChangePassword can't get tokens until the password is changed.
+// MFA is nil until user starts TOTP enrollment, WebAuthn are registered passkeys and security keys,
+// OTP is where one-time codes are sent
 type Credentials struct {
-	ID       primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Login    string             `json:"login" bson:"login"`
-	Password string             `json:"password" bson:"pswrd_hash"`
+	ID                 primitive.ObjectID     `json:"id,omitempty" bson:"_id,omitempty"`
+	Login              string                 `json:"login" bson:"login"`
+	Password           string                 `json:"password" bson:"pswrd_hash"`
+	MustChangePassword bool                   `json:"must_change_password,omitempty" bson:"must_change_password,omitempty"`
+	Roles              []string               `json:"-" bson:"roles,omitempty"`
+	CustomClaims       map[string]interface{} `json:"-" bson:"custom_claims,omitempty"`
+	PasswordHistory    []string               `json:"-" bson:"password_history,omitempty"`
+	PasswordChangedAt  time.Time              `json:"-" bson:"password_changed_at,omitempty"`
+	MFA                *MFA                   `json:"-" bson:"mfa,omitempty"`
+	WebAuthn           []WebAuthnCredential   `json:"-" bson:"webauthn,omitempty"`
+	OTP                *OTPFactor             `json:"-" bson:"otp,omitempty"`
+}
+
+// MFA is second factor of user. TOTPSecret is kept unconfirmed, with Enabled false,
+// until user proves the app has it with a code. LastStep is TOTP step of the last
+// accepted code, codes of it and earlier steps are rejected.
+// RecoveryCodes are hashes of unused recovery codes
+type MFA struct {
+	Enabled       bool     `bson:"enabled"`
+	TOTPSecret    string   `bson:"totp_secret"`
+	LastStep      int64    `bson:"last_step,omitempty"`
+	RecoveryCodes []string `bson:"recovery_codes,omitempty"`
+}
+
+// OTPFactor sends one-time codes by Channel to Address. It is used at login once
+// user confirms the address with a code
+type OTPFactor struct {
+	Channel string `json:"channel" bson:"channel"`
+	Address string `json:"address" bson:"address"`
+	Enabled bool   `json:"enabled" bson:"enabled"`
+}
+
+// OTPCode is the last one-time code sent to login, only its hash is kept.
+// It is deleted once used, expired or guessed wrong too many times
+type OTPCode struct {
+	Login     string    `bson:"_id"`
+	Hash      string    `bson:"hash"`
+	Attempts  int       `bson:"attempts"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// WebAuthnCredential is passkey or security key of user. ID is credential id in base64url,
+// PublicKey is its COSE key. SignCount is signature counter, zero if authenticator has none
+type WebAuthnCredential struct {
+	ID          string    `json:"id" bson:"id"`
+	Name        string    `json:"name" bson:"name"`
+	PublicKey   []byte    `json:"-" bson:"public_key"`
+	SignCount   uint32    `json:"-" bson:"sign_count"`
+	AAGUID      string    `json:"aaguid" bson:"aaguid"`
+	Attestation string    `json:"attestation" bson:"attestation"`
+	Transports  []string  `json:"transports,omitempty" bson:"transports,omitempty"`
+	CreatedAt   time.Time `json:"createdAt" bson:"created_at"`
+	LastUsedAt  time.Time `json:"lastUsedAt,omitempty" bson:"last_used_at,omitempty"`
+}
+
+// TrustedDevice is a device where Login skips second factor until ExpiresAt. ID is jti of its token,
+// deleting the device revokes the token. UserID keeps the device from a re-created user of the same login
+type TrustedDevice struct {
+	ID         string    `json:"id" bson:"_id"`
+	Login      string    `json:"-" bson:"login"`
+	UserID     string    `json:"-" bson:"user_id,omitempty"`
+	Name       string    `json:"name" bson:"name"`
+	CreatedAt  time.Time `json:"createdAt" bson:"created_at"`
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty" bson:"last_used_at,omitempty"`
+	ExpiresAt  time.Time `json:"expiresAt" bson:"expires_at"`
+}
+
+// WebAuthn ceremonies
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+	WebAuthnMFA          = "mfa"
+	WebAuthnReauth       = "reauth"
+)
+
+// WebAuthnChallenge is issued challenge waiting for response, it is used once.
+// ID is hash of the challenge. Login is empty when user picks passkey at passwordless login
+type WebAuthnChallenge struct {
+	ID        string    `bson:"_id"`
+	Login     string    `bson:"login,omitempty"`
+	Ceremony  string    `bson:"ceremony"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// TOTPEnrollment is what authenticator app needs, URI is usually shown as QR code
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TokenFamily is a chain of refresh tokens started by a single login.
+// Every refresh moves the family to the next generation, so only the latest
+// refresh token is usable. Presenting an older one means the token leaked.
+// AuthTime is the login time, the session can't outlive it by more than its lifetime.
+// AMR are methods of the login, access tokens of the family carry them
+type TokenFamily struct {
+	ID         string    `bson:"_id"`
+	Login      string    `bson:"login"`
+	Generation int       `bson:"generation"`
+	AuthTime   time.Time `bson:"auth_time"`
+	AMR        []string  `bson:"amr,omitempty"`
+	RotatedAt  time.Time `bson:"rotated_at"`
+	ExpiresAt  time.Time `bson:"expires_at"`
+	Revoked    bool      `bson:"revoked"`
+}
+
+// OpaqueToken is what is stored for opaque token. The token itself is never stored, only its hash
+type OpaqueToken struct {
+	Hash      string        `bson:"_id"`
+	Claims    tokens.Claims `bson:"claims"`
+	ExpiresAt time.Time     `bson:"expires_at"`
+}
+
+// TokenPair is a result of refresh token rotation.
+// RefreshToken is empty when the client should keep its current one.
+// Claims are claims of the new access token
+type TokenPair struct {
+	Login        string
+	AccessToken  string
+	RefreshToken string
+	Claims       *tokens.Claims
+}
+
+// Introspection is OAuth 2.0 token introspection response (RFC 7662).
+// Inactive token has only Active set
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	TokenID   string   `json:"jti,omitempty"`
+	AuthTime  int64    `json:"auth_time,omitempty"`
+	AMR       []string `json:"amr,omitempty"`
+	ACR       string   `json:"acr,omitempty"`
+}
+
+// ResetToken lets login set a new password once. Only hash of the token is stored
+type ResetToken struct {
+	Hash      string    `bson:"_id"`
+	Login     string    `bson:"login"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// NotificationKind tells what notification is about
+type NotificationKind string
+
+const (
+	PasswordResetNotification NotificationKind = "password_reset"
+	OTPNotification           NotificationKind = "otp"
+)
+
+// Channels one-time codes are sent by
+const (
+	EmailChannel = "email"
+	SMSChannel   = "sms"
+)
+
+// Notification is a message to user. Recipient is user login, notifier knows how to reach it
+// unless Channel and Address tell where to send it, like email and user@example.com.
+// Secret is what user has to enter, like reset token, it is also a part of Text
+type Notification struct {
+	Recipient string           `json:"recipient"`
+	Channel   string           `json:"channel,omitempty"`
+	Address   string           `json:"address,omitempty"`
+	Kind      NotificationKind `json:"kind"`
+	Subject   string           `json:"subject"`
+	Text      string           `json:"text"`
+	Secret    string           `json:"secret,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// LoginAttempts counts failed logins of a login or a client ip (Key is "login:..." or "ip:...").
+// Counter is forgotten at ExpiresAt, LockedUntil is set when there were too many failures
+type LoginAttempts struct {
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LastFailure time.Time `bson:"last_failure"`
+	LockedUntil time.Time `bson:"locked_until"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// ImportedUser is a user moved from another system with password hash of that system.
+// Algorithm is bcrypt, pbkdf2_sha256, django_scrypt or sha1, Salt is for bare sha1 digests.
+// PasswordChangedAt is when the password was set in that system, import time when it is not known
+type ImportedUser struct {
+	Login             string    `json:"login"`
+	Algorithm         string    `json:"algorithm"`
+	Hash              string    `json:"hash"`
+	Salt              string    `json:"salt,omitempty"`
+	Roles             []string  `json:"roles,omitempty"`
+	PasswordChangedAt time.Time `json:"password_changed_at,omitempty"`
+}
+
+// ImportResult tells how many users were imported and why the others were not
+type ImportResult struct {
+	Imported int             `json:"imported"`
+	Failed   []ImportFailure `json:"failed,omitempty"`
+}
+
+type ImportFailure struct {
+	Login string `json:"login"`
+	Error string `json:"error"`
 }
@@ -0,0 +1,243 @@
+package policy
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+)
+
+const PasswordField = "password"
+
+// Violation codes of password policy
+const (
+	CodeTooShort         = "too_short"
+	CodeTooLong          = "too_long"
+	CodeMissingLowercase = "missing_lowercase"
+	CodeMissingUppercase = "missing_uppercase"
+	CodeMissingDigit     = "missing_digit"
+	CodeMissingSymbol    = "missing_symbol"
+	CodeSimilarToLogin   = "similar_to_login"
+	CodeCommonPassword   = "common_password"
+	CodeBreachedPassword = "breached_password"
+	CodePasswordReused   = "password_reused"
+	CodePasswordTooNew   = "password_too_new"
+)
+
+const (
+	defaultMinLength = 8
+	defaultMaxLength = 128
+	// maxLoginDistance is edit distance at which password is still considered similar to login
+	maxLoginDistance = 2
+	// minSimilarLen is login length from which password containing login is similar to it
+	minSimilarLen = 3
+	hibpPrefixLen = 5
+)
+
+var ErrNoBreachedCorpus = errors.New("breached passwords directory is not available")
+
+// commonPasswords are always blocked, blocklist file adds to them
+var commonPasswords = []string{
+	"123456", "12345678", "123456789", "1234567890", "password", "password1", "qwerty", "qwerty123",
+	"qwertyuiop", "111111", "11111111", "000000", "00000000", "abc123", "iloveyou", "admin", "admin123",
+	"letmein", "welcome", "monkey", "dragon", "football", "baseball", "sunshine", "princess", "passw0rd",
+}
+
+// Policy checks new passwords. Zero lengths in config take defaults
+type Policy struct {
+	cfg       config.PasswordPolicyConfig
+	blocklist map[string]struct{}
+}
+
+// Default is policy with default lengths and built-in blocklist only
+func Default() *Policy {
+	p, _ := New(config.PasswordPolicyConfig{})
+	return p
+}
+
+// New loads blocklist file and checks that breached passwords directory exists
+func New(cfg config.PasswordPolicyConfig) (*Policy, error) {
+	if cfg.MinLength == 0 {
+		cfg.MinLength = defaultMinLength
+	}
+	if cfg.MaxLength == 0 {
+		cfg.MaxLength = defaultMaxLength
+	}
+	if cfg.MaxLength < cfg.MinLength {
+		return nil, fmt.Errorf("password policy max_length %d is less than min_length %d", cfg.MaxLength, cfg.MinLength)
+	}
+	p := &Policy{cfg: cfg, blocklist: make(map[string]struct{}, len(commonPasswords))}
+	for _, password := range commonPasswords {
+		p.blocklist[password] = struct{}{}
+	}
+	if cfg.BlocklistPath != "" {
+		if err := p.loadBlocklist(cfg.BlocklistPath); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.BreachedPath != "" {
+		if info, err := os.Stat(cfg.BreachedPath); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("%w: %s", ErrNoBreachedCorpus, cfg.BreachedPath)
+		}
+	}
+	return p, nil
+}
+
+// Check returns *errors.ValidationError with every rule password breaks.
+// Other errors mean the check couldn't be done
+func (p *Policy) Check(login, password string) error {
+	var violations e.ValidationError
+	length := utf8.RuneCountInString(password)
+	if length < p.cfg.MinLength {
+		violations.Add(PasswordField, CodeTooShort, fmt.Sprintf("must be at least %d characters", p.cfg.MinLength))
+	}
+	if length > p.cfg.MaxLength {
+		violations.Add(PasswordField, CodeTooLong, fmt.Sprintf("must be at most %d characters", p.cfg.MaxLength))
+	}
+	p.checkClasses(password, &violations)
+	if p.cfg.RejectSimilarToLogin && similar(strings.ToLower(login), strings.ToLower(password)) {
+		violations.Add(PasswordField, CodeSimilarToLogin, "must not be similar to login")
+	}
+	if _, ok := p.blocklist[strings.ToLower(password)]; ok {
+		violations.Add(PasswordField, CodeCommonPassword, "is too common")
+	}
+	if p.cfg.BreachedPath != "" {
+		breached, err := p.breached(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations.Add(PasswordField, CodeBreachedPassword, "appeared in a data breach")
+		}
+	}
+	return violations.Err()
+}
+
+func (p *Policy) checkClasses(password string, violations *e.ValidationError) {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.cfg.RequireLowercase && !lower {
+		violations.Add(PasswordField, CodeMissingLowercase, "must contain a lowercase letter")
+	}
+	if p.cfg.RequireUppercase && !upper {
+		violations.Add(PasswordField, CodeMissingUppercase, "must contain an uppercase letter")
+	}
+	if p.cfg.RequireDigit && !digit {
+		violations.Add(PasswordField, CodeMissingDigit, "must contain a digit")
+	}
+	if p.cfg.RequireSymbol && !symbol {
+		violations.Add(PasswordField, CodeMissingSymbol, "must contain a symbol")
+	}
+}
+
+// loadBlocklist reads one password per line, empty lines and # comments are skipped
+func (p *Policy) loadBlocklist(path string) error {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.blocklist[strings.ToLower(line)] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// breached looks for password in HIBP range files. The directory has a file per
+// 5 hex chars prefix of uppercase sha1 named PREFIX or PREFIX.txt with SUFFIX:COUNT lines
+func (p *Policy) breached(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:hibpPrefixLen], hash[hibpPrefixLen:]
+	var file *os.File
+	var err error
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		if file, err = os.Open(filepath.Join(p.cfg.BreachedPath, name)); err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+	}
+	if file == nil {
+		return false, nil
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(lineSuffix, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// similar is true when one contains the other or they differ in a couple of edits
+func similar(login, password string) bool {
+	if login == "" || password == "" {
+		return false
+	}
+	if utf8.RuneCountInString(login) >= minSimilarLen && strings.Contains(password, login) ||
+		utf8.RuneCountInString(password) >= minSimilarLen && strings.Contains(login, password) {
+		return true
+	}
+	return distance(login, password) <= maxLoginDistance
+}
+
+// distance is Levenshtein distance in runes
+func distance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,93 @@
+package policy_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DMA8/authService/internal/config"
+	e "github.com/DMA8/authService/internal/domain/errors"
+	"github.com/DMA8/authService/internal/domain/policy"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func violationCodes(t *testing.T, err error) []string {
+	var validationErr *e.ValidationError
+	require.True(t, errors.As(err, &validationErr), "%v", err)
+	codes := make([]string, 0, len(validationErr.Violations))
+	for _, v := range validationErr.Violations {
+		assert.Equal(t, policy.PasswordField, v.Field)
+		codes = append(codes, v.Code)
+	}
+	return codes
+}
+
+func TestPolicy(t *testing.T) {
+	p, err := policy.New(config.PasswordPolicyConfig{
+		MinLength:            10,
+		MaxLength:            20,
+		RequireLowercase:     true,
+		RequireUppercase:     true,
+		RequireDigit:         true,
+		RequireSymbol:        true,
+		RejectSimilarToLogin: true,
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, p.Check("john", "Correct-Horse-9"))
+	testCases := []struct {
+		login    string
+		password string
+		codes    []string
+	}{
+		{login: "john", password: "1", codes: []string{policy.CodeTooShort, policy.CodeMissingLowercase,
+			policy.CodeMissingUppercase, policy.CodeMissingSymbol}},
+		{login: "john", password: "Correct-Horse-Battery-9", codes: []string{policy.CodeTooLong}},
+		{login: "john", password: "correct-horse-9", codes: []string{policy.CodeMissingUppercase}},
+		{login: "john.smith", password: "John.Smith-42", codes: []string{policy.CodeSimilarToLogin}},
+		{login: "Correct-Horse-9", password: "Correct-Horse-8", codes: []string{policy.CodeSimilarToLogin}},
+		{login: "john", password: "Passw0rd", codes: []string{policy.CodeTooShort, policy.CodeMissingSymbol,
+			policy.CodeCommonPassword}},
+	}
+	for _, testCase := range testCases {
+		err := p.Check(testCase.login, testCase.password)
+		assert.Equal(t, testCase.codes, violationCodes(t, err), testCase.password)
+	}
+
+	//default policy only checks length and common passwords
+	assert.Equal(t, []string{policy.CodeTooShort}, violationCodes(t, policy.Default().Check("u", "1")))
+	assert.Equal(t, []string{policy.CodeCommonPassword}, violationCodes(t, policy.Default().Check("u", "password1")))
+	assert.NoError(t, policy.Default().Check("user", "user-password"))
+
+	_, err = policy.New(config.PasswordPolicyConfig{MinLength: 10, MaxLength: 5})
+	assert.Error(t, err)
+}
+
+func TestPolicyFiles(t *testing.T) {
+	dir := t.TempDir()
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	require.NoError(t, os.WriteFile(blocklist, []byte("# company words\nTeam31Rocks\n\n"), 0600))
+	breached := filepath.Join(dir, "pwned")
+	require.NoError(t, os.Mkdir(breached, 0700))
+	sum := sha1.Sum([]byte("leaked-password"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	rangeFile := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + hash[5:] + ":42\r\n"
+	require.NoError(t, os.WriteFile(filepath.Join(breached, hash[:5]+".txt"), []byte(rangeFile), 0600))
+
+	p, err := policy.New(config.PasswordPolicyConfig{BlocklistPath: blocklist, BreachedPath: breached})
+	require.NoError(t, err)
+	assert.Equal(t, []string{policy.CodeCommonPassword}, violationCodes(t, p.Check("user", "team31rocks")))
+	assert.Equal(t, []string{policy.CodeBreachedPassword}, violationCodes(t, p.Check("user", "leaked-password")))
+	assert.NoError(t, p.Check("user", "not-leaked-password"))
+
+	_, err = policy.New(config.PasswordPolicyConfig{BreachedPath: filepath.Join(dir, "missing")})
+	assert.ErrorIs(t, err, policy.ErrNoBreachedCorpus)
+	_, err = policy.New(config.PasswordPolicyConfig{BlocklistPath: filepath.Join(dir, "missing")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/ports/attempt_storage.go
+
+// Package mock_ports is a generated GoMock package.
+package mock_ports
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	models "github.com/DMA8/authService/internal/domain/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockAttemptStorage is a mock of AttemptStorage interface.
+type MockAttemptStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockAttemptStorageMockRecorder
+}
+
+// MockAttemptStorageMockRecorder is the mock recorder for MockAttemptStorage.
+type MockAttemptStorageMockRecorder struct {
+	mock *MockAttemptStorage
+}
+
+// NewMockAttemptStorage creates a new mock instance.
+func NewMockAttemptStorage(ctrl *gomock.Controller) *MockAttemptStorage {
+	mock := &MockAttemptStorage{ctrl: ctrl}
+	mock.recorder = &MockAttemptStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAttemptStorage) EXPECT() *MockAttemptStorageMockRecorder {
+	return m.recorder
+}
+
+// AddFailure mocks base method.
+func (m *MockAttemptStorage) AddFailure(ctx context.Context, key string, at, expiresAt time.Time) (*models.LoginAttempts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddFailure", ctx, key, at, expiresAt)
+	ret0, _ := ret[0].(*models.LoginAttempts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddFailure indicates an expected call of AddFailure.
+func (mr *MockAttemptStorageMockRecorder) AddFailure(ctx, key, at, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddFailure", reflect.TypeOf((*MockAttemptStorage)(nil).AddFailure), ctx, key, at, expiresAt)
+}
+
+// GetAttempts mocks base method.
+func (m *MockAttemptStorage) GetAttempts(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAttempts", ctx, key)
+	ret0, _ := ret[0].(*models.LoginAttempts)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAttempts indicates an expected call of GetAttempts.
+func (mr *MockAttemptStorageMockRecorder) GetAttempts(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttempts", reflect.TypeOf((*MockAttemptStorage)(nil).GetAttempts), ctx, key)
+}
+
+// Lock mocks base method.
+func (m *MockAttemptStorage) Lock(ctx context.Context, key string, until time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Lock", ctx, key, until)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Lock indicates an expected call of Lock.
+func (mr *MockAttemptStorageMockRecorder) Lock(ctx, key, until interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Lock", reflect.TypeOf((*MockAttemptStorage)(nil).Lock), ctx, key, until)
+}
+
+// ResetAttempts mocks base method.
+func (m *MockAttemptStorage) ResetAttempts(ctx context.Context, key string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetAttempts", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetAttempts indicates an expected call of ResetAttempts.
+func (mr *MockAttemptStorageMockRecorder) ResetAttempts(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetAttempts", reflect.TypeOf((*MockAttemptStorage)(nil).ResetAttempts), ctx, key)
+}
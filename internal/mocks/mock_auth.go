@@ -9,6 +9,8 @@ import (
 	reflect "reflect"
 
 	models "github.com/DMA8/authService/internal/domain/models"
+	tokens "github.com/DMA8/authService/pkg/tokens"
+	webauthn "github.com/DMA8/authService/pkg/webauthn"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -35,6 +37,20 @@ func (m *MockAuth) EXPECT() *MockAuthMockRecorder {
 	return m.recorder
 }
 
+// AuthClient mocks base method.
+func (m *MockAuth) AuthClient(ctx context.Context, clientID, clientSecret string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AuthClient", ctx, clientID, clientSecret)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AuthClient indicates an expected call of AuthClient.
+func (mr *MockAuthMockRecorder) AuthClient(ctx, clientID, clientSecret interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthClient", reflect.TypeOf((*MockAuth)(nil).AuthClient), ctx, clientID, clientSecret)
+}
+
 // AuthUser mocks base method.
 func (m *MockAuth) AuthUser(ctx context.Context, userData *models.Credentials) error {
 	m.ctrl.T.Helper()
@@ -49,6 +65,110 @@ func (mr *MockAuthMockRecorder) AuthUser(ctx, userData interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AuthUser", reflect.TypeOf((*MockAuth)(nil).AuthUser), ctx, userData)
 }
 
+// BeginWebAuthnLogin mocks base method.
+func (m *MockAuth) BeginWebAuthnLogin(ctx context.Context, login string) (*webauthn.RequestOptions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginWebAuthnLogin", ctx, login)
+	ret0, _ := ret[0].(*webauthn.RequestOptions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginWebAuthnLogin indicates an expected call of BeginWebAuthnLogin.
+func (mr *MockAuthMockRecorder) BeginWebAuthnLogin(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginWebAuthnLogin", reflect.TypeOf((*MockAuth)(nil).BeginWebAuthnLogin), ctx, login)
+}
+
+// BeginWebAuthnMFA mocks base method.
+func (m *MockAuth) BeginWebAuthnMFA(ctx context.Context, mfaToken string) (*webauthn.RequestOptions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginWebAuthnMFA", ctx, mfaToken)
+	ret0, _ := ret[0].(*webauthn.RequestOptions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginWebAuthnMFA indicates an expected call of BeginWebAuthnMFA.
+func (mr *MockAuthMockRecorder) BeginWebAuthnMFA(ctx, mfaToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginWebAuthnMFA", reflect.TypeOf((*MockAuth)(nil).BeginWebAuthnMFA), ctx, mfaToken)
+}
+
+// BeginWebAuthnReauth mocks base method.
+func (m *MockAuth) BeginWebAuthnReauth(ctx context.Context, login string) (*webauthn.RequestOptions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginWebAuthnReauth", ctx, login)
+	ret0, _ := ret[0].(*webauthn.RequestOptions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginWebAuthnReauth indicates an expected call of BeginWebAuthnReauth.
+func (mr *MockAuthMockRecorder) BeginWebAuthnReauth(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginWebAuthnReauth", reflect.TypeOf((*MockAuth)(nil).BeginWebAuthnReauth), ctx, login)
+}
+
+// BeginWebAuthnRegistration mocks base method.
+func (m *MockAuth) BeginWebAuthnRegistration(ctx context.Context, login string) (*webauthn.CreationOptions, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BeginWebAuthnRegistration", ctx, login)
+	ret0, _ := ret[0].(*webauthn.CreationOptions)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BeginWebAuthnRegistration indicates an expected call of BeginWebAuthnRegistration.
+func (mr *MockAuthMockRecorder) BeginWebAuthnRegistration(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BeginWebAuthnRegistration", reflect.TypeOf((*MockAuth)(nil).BeginWebAuthnRegistration), ctx, login)
+}
+
+// ChangePassword mocks base method.
+func (m *MockAuth) ChangePassword(ctx context.Context, login, currentPassword, newPassword string) (*models.TokenPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChangePassword", ctx, login, currentPassword, newPassword)
+	ret0, _ := ret[0].(*models.TokenPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ChangePassword indicates an expected call of ChangePassword.
+func (mr *MockAuthMockRecorder) ChangePassword(ctx, login, currentPassword, newPassword interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChangePassword", reflect.TypeOf((*MockAuth)(nil).ChangePassword), ctx, login, currentPassword, newPassword)
+}
+
+// ConfirmOTP mocks base method.
+func (m *MockAuth) ConfirmOTP(ctx context.Context, login, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmOTP", ctx, login, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ConfirmOTP indicates an expected call of ConfirmOTP.
+func (mr *MockAuthMockRecorder) ConfirmOTP(ctx, login, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmOTP", reflect.TypeOf((*MockAuth)(nil).ConfirmOTP), ctx, login, code)
+}
+
+// ConfirmTOTP mocks base method.
+func (m *MockAuth) ConfirmTOTP(ctx context.Context, login, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfirmTOTP", ctx, login, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConfirmTOTP indicates an expected call of ConfirmTOTP.
+func (mr *MockAuthMockRecorder) ConfirmTOTP(ctx, login, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfirmTOTP", reflect.TypeOf((*MockAuth)(nil).ConfirmTOTP), ctx, login, code)
+}
+
 // CreateToken mocks base method.
 func (m *MockAuth) CreateToken(ctx context.Context, login string, tokenType models.TokenType) (string, error) {
 	m.ctrl.T.Helper()
@@ -92,6 +212,165 @@ func (mr *MockAuthMockRecorder) DeleteUser(ctx, login interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteUser", reflect.TypeOf((*MockAuth)(nil).DeleteUser), ctx, login)
 }
 
+// DeleteWebAuthnCredential mocks base method.
+func (m *MockAuth) DeleteWebAuthnCredential(ctx context.Context, login, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteWebAuthnCredential", ctx, login, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteWebAuthnCredential indicates an expected call of DeleteWebAuthnCredential.
+func (mr *MockAuthMockRecorder) DeleteWebAuthnCredential(ctx, login, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteWebAuthnCredential", reflect.TypeOf((*MockAuth)(nil).DeleteWebAuthnCredential), ctx, login, id)
+}
+
+// DisableMFA mocks base method.
+func (m *MockAuth) DisableMFA(ctx context.Context, login, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableMFA", ctx, login, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableMFA indicates an expected call of DisableMFA.
+func (mr *MockAuthMockRecorder) DisableMFA(ctx, login, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableMFA", reflect.TypeOf((*MockAuth)(nil).DisableMFA), ctx, login, code)
+}
+
+// DisableOTP mocks base method.
+func (m *MockAuth) DisableOTP(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DisableOTP", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DisableOTP indicates an expected call of DisableOTP.
+func (mr *MockAuthMockRecorder) DisableOTP(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DisableOTP", reflect.TypeOf((*MockAuth)(nil).DisableOTP), ctx, login)
+}
+
+// EnrollOTP mocks base method.
+func (m *MockAuth) EnrollOTP(ctx context.Context, login, channel, address string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollOTP", ctx, login, channel, address)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// EnrollOTP indicates an expected call of EnrollOTP.
+func (mr *MockAuthMockRecorder) EnrollOTP(ctx, login, channel, address interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollOTP", reflect.TypeOf((*MockAuth)(nil).EnrollOTP), ctx, login, channel, address)
+}
+
+// EnrollTOTP mocks base method.
+func (m *MockAuth) EnrollTOTP(ctx context.Context, login string) (*models.TOTPEnrollment, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnrollTOTP", ctx, login)
+	ret0, _ := ret[0].(*models.TOTPEnrollment)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnrollTOTP indicates an expected call of EnrollTOTP.
+func (mr *MockAuthMockRecorder) EnrollTOTP(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnrollTOTP", reflect.TypeOf((*MockAuth)(nil).EnrollTOTP), ctx, login)
+}
+
+// ExpirePassword mocks base method.
+func (m *MockAuth) ExpirePassword(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpirePassword", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpirePassword indicates an expected call of ExpirePassword.
+func (mr *MockAuthMockRecorder) ExpirePassword(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpirePassword", reflect.TypeOf((*MockAuth)(nil).ExpirePassword), ctx, login)
+}
+
+// FinishWebAuthnLogin mocks base method.
+func (m *MockAuth) FinishWebAuthnLogin(ctx context.Context, resp *webauthn.AssertionResponse) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishWebAuthnLogin", ctx, resp)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishWebAuthnLogin indicates an expected call of FinishWebAuthnLogin.
+func (mr *MockAuthMockRecorder) FinishWebAuthnLogin(ctx, resp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishWebAuthnLogin", reflect.TypeOf((*MockAuth)(nil).FinishWebAuthnLogin), ctx, resp)
+}
+
+// FinishWebAuthnMFA mocks base method.
+func (m *MockAuth) FinishWebAuthnMFA(ctx context.Context, mfaToken string, resp *webauthn.AssertionResponse) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishWebAuthnMFA", ctx, mfaToken, resp)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishWebAuthnMFA indicates an expected call of FinishWebAuthnMFA.
+func (mr *MockAuthMockRecorder) FinishWebAuthnMFA(ctx, mfaToken, resp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishWebAuthnMFA", reflect.TypeOf((*MockAuth)(nil).FinishWebAuthnMFA), ctx, mfaToken, resp)
+}
+
+// FinishWebAuthnReauth mocks base method.
+func (m *MockAuth) FinishWebAuthnReauth(ctx context.Context, login string, resp *webauthn.AssertionResponse) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishWebAuthnReauth", ctx, login, resp)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishWebAuthnReauth indicates an expected call of FinishWebAuthnReauth.
+func (mr *MockAuthMockRecorder) FinishWebAuthnReauth(ctx, login, resp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishWebAuthnReauth", reflect.TypeOf((*MockAuth)(nil).FinishWebAuthnReauth), ctx, login, resp)
+}
+
+// FinishWebAuthnRegistration mocks base method.
+func (m *MockAuth) FinishWebAuthnRegistration(ctx context.Context, login, name string, resp *webauthn.AttestationResponse) (*models.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FinishWebAuthnRegistration", ctx, login, name, resp)
+	ret0, _ := ret[0].(*models.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FinishWebAuthnRegistration indicates an expected call of FinishWebAuthnRegistration.
+func (mr *MockAuthMockRecorder) FinishWebAuthnRegistration(ctx, login, name, resp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FinishWebAuthnRegistration", reflect.TypeOf((*MockAuth)(nil).FinishWebAuthnRegistration), ctx, login, name, resp)
+}
+
+// ForgotPassword mocks base method.
+func (m *MockAuth) ForgotPassword(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForgotPassword", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForgotPassword indicates an expected call of ForgotPassword.
+func (mr *MockAuthMockRecorder) ForgotPassword(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForgotPassword", reflect.TypeOf((*MockAuth)(nil).ForgotPassword), ctx, login)
+}
+
 // GetUser mocks base method.
 func (m *MockAuth) GetUser(ctx context.Context, login string) (*models.Credentials, error) {
 	m.ctrl.T.Helper()
@@ -107,6 +386,237 @@ func (mr *MockAuthMockRecorder) GetUser(ctx, login interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockAuth)(nil).GetUser), ctx, login)
 }
 
+// ImportUsers mocks base method.
+func (m *MockAuth) ImportUsers(ctx context.Context, users []models.ImportedUser) *models.ImportResult {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportUsers", ctx, users)
+	ret0, _ := ret[0].(*models.ImportResult)
+	return ret0
+}
+
+// ImportUsers indicates an expected call of ImportUsers.
+func (mr *MockAuthMockRecorder) ImportUsers(ctx, users interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportUsers", reflect.TypeOf((*MockAuth)(nil).ImportUsers), ctx, users)
+}
+
+// Introspect mocks base method.
+func (m *MockAuth) Introspect(ctx context.Context, tokenStr, tokenTypeHint string) *models.Introspection {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Introspect", ctx, tokenStr, tokenTypeHint)
+	ret0, _ := ret[0].(*models.Introspection)
+	return ret0
+}
+
+// Introspect indicates an expected call of Introspect.
+func (mr *MockAuthMockRecorder) Introspect(ctx, tokenStr, tokenTypeHint interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Introspect", reflect.TypeOf((*MockAuth)(nil).Introspect), ctx, tokenStr, tokenTypeHint)
+}
+
+// JWKS mocks base method.
+func (m *MockAuth) JWKS(ctx context.Context) tokens.JWKSet {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "JWKS", ctx)
+	ret0, _ := ret[0].(tokens.JWKSet)
+	return ret0
+}
+
+// JWKS indicates an expected call of JWKS.
+func (mr *MockAuthMockRecorder) JWKS(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "JWKS", reflect.TypeOf((*MockAuth)(nil).JWKS), ctx)
+}
+
+// Reauthenticate mocks base method.
+func (m *MockAuth) Reauthenticate(ctx context.Context, login, password, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reauthenticate", ctx, login, password, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reauthenticate indicates an expected call of Reauthenticate.
+func (mr *MockAuthMockRecorder) Reauthenticate(ctx, login, password, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reauthenticate", reflect.TypeOf((*MockAuth)(nil).Reauthenticate), ctx, login, password, code)
+}
+
+// RefreshTokens mocks base method.
+func (m *MockAuth) RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshTokens", ctx, refreshToken)
+	ret0, _ := ret[0].(*models.TokenPair)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshTokens indicates an expected call of RefreshTokens.
+func (mr *MockAuthMockRecorder) RefreshTokens(ctx, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshTokens", reflect.TypeOf((*MockAuth)(nil).RefreshTokens), ctx, refreshToken)
+}
+
+// RegenerateRecoveryCodes mocks base method.
+func (m *MockAuth) RegenerateRecoveryCodes(ctx context.Context, login, code string) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RegenerateRecoveryCodes", ctx, login, code)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RegenerateRecoveryCodes indicates an expected call of RegenerateRecoveryCodes.
+func (mr *MockAuthMockRecorder) RegenerateRecoveryCodes(ctx, login, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RegenerateRecoveryCodes", reflect.TypeOf((*MockAuth)(nil).RegenerateRecoveryCodes), ctx, login, code)
+}
+
+// ReloadKeys mocks base method.
+func (m *MockAuth) ReloadKeys(ctx context.Context) ([]tokens.KeyInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReloadKeys", ctx)
+	ret0, _ := ret[0].([]tokens.KeyInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReloadKeys indicates an expected call of ReloadKeys.
+func (mr *MockAuthMockRecorder) ReloadKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReloadKeys", reflect.TypeOf((*MockAuth)(nil).ReloadKeys), ctx)
+}
+
+// ResetPassword mocks base method.
+func (m *MockAuth) ResetPassword(ctx context.Context, token, password string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResetPassword", ctx, token, password)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResetPassword indicates an expected call of ResetPassword.
+func (mr *MockAuthMockRecorder) ResetPassword(ctx, token, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResetPassword", reflect.TypeOf((*MockAuth)(nil).ResetPassword), ctx, token, password)
+}
+
+// RevokeDevice mocks base method.
+func (m *MockAuth) RevokeDevice(ctx context.Context, login, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeDevice", ctx, login, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeDevice indicates an expected call of RevokeDevice.
+func (mr *MockAuthMockRecorder) RevokeDevice(ctx, login, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeDevice", reflect.TypeOf((*MockAuth)(nil).RevokeDevice), ctx, login, id)
+}
+
+// RevokeDevices mocks base method.
+func (m *MockAuth) RevokeDevices(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeDevices", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeDevices indicates an expected call of RevokeDevices.
+func (mr *MockAuthMockRecorder) RevokeDevices(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeDevices", reflect.TypeOf((*MockAuth)(nil).RevokeDevices), ctx, login)
+}
+
+// RevokeTokens mocks base method.
+func (m *MockAuth) RevokeTokens(ctx context.Context, accessToken, refreshToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeTokens", ctx, accessToken, refreshToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeTokens indicates an expected call of RevokeTokens.
+func (mr *MockAuthMockRecorder) RevokeTokens(ctx, accessToken, refreshToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeTokens", reflect.TypeOf((*MockAuth)(nil).RevokeTokens), ctx, accessToken, refreshToken)
+}
+
+// SendMFACode mocks base method.
+func (m *MockAuth) SendMFACode(ctx context.Context, mfaToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendMFACode", ctx, mfaToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendMFACode indicates an expected call of SendMFACode.
+func (mr *MockAuthMockRecorder) SendMFACode(ctx, mfaToken interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendMFACode", reflect.TypeOf((*MockAuth)(nil).SendMFACode), ctx, mfaToken)
+}
+
+// SendReauthCode mocks base method.
+func (m *MockAuth) SendReauthCode(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SendReauthCode", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SendReauthCode indicates an expected call of SendReauthCode.
+func (mr *MockAuthMockRecorder) SendReauthCode(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendReauthCode", reflect.TypeOf((*MockAuth)(nil).SendReauthCode), ctx, login)
+}
+
+// TrustDevice mocks base method.
+func (m *MockAuth) TrustDevice(ctx context.Context, login, name string) (string, *models.TrustedDevice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrustDevice", ctx, login, name)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(*models.TrustedDevice)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// TrustDevice indicates an expected call of TrustDevice.
+func (mr *MockAuthMockRecorder) TrustDevice(ctx, login, name interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrustDevice", reflect.TypeOf((*MockAuth)(nil).TrustDevice), ctx, login, name)
+}
+
+// TrustedDevices mocks base method.
+func (m *MockAuth) TrustedDevices(ctx context.Context, login string) ([]models.TrustedDevice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TrustedDevices", ctx, login)
+	ret0, _ := ret[0].([]models.TrustedDevice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TrustedDevices indicates an expected call of TrustedDevices.
+func (mr *MockAuthMockRecorder) TrustedDevices(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TrustedDevices", reflect.TypeOf((*MockAuth)(nil).TrustedDevices), ctx, login)
+}
+
+// Unlock mocks base method.
+func (m *MockAuth) Unlock(ctx context.Context, login, ip string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unlock", ctx, login, ip)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Unlock indicates an expected call of Unlock.
+func (mr *MockAuthMockRecorder) Unlock(ctx, login, ip interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unlock", reflect.TypeOf((*MockAuth)(nil).Unlock), ctx, login, ip)
+}
+
 // UpdateUser mocks base method.
 func (m *MockAuth) UpdateUser(ctx context.Context, userData *models.Credentials) error {
 	m.ctrl.T.Helper()
@@ -122,16 +632,46 @@ func (mr *MockAuthMockRecorder) UpdateUser(ctx, userData interface{}) *gomock.Ca
 }
 
 // ValidateToken mocks base method.
-func (m *MockAuth) ValidateToken(ctx context.Context, tokenStr string) (string, error) {
+func (m *MockAuth) ValidateToken(ctx context.Context, tokenStr string, tokenType models.TokenType) (*tokens.Claims, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ValidateToken", ctx, tokenStr)
-	ret0, _ := ret[0].(string)
+	ret := m.ctrl.Call(m, "ValidateToken", ctx, tokenStr, tokenType)
+	ret0, _ := ret[0].(*tokens.Claims)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // ValidateToken indicates an expected call of ValidateToken.
-func (mr *MockAuthMockRecorder) ValidateToken(ctx, tokenStr interface{}) *gomock.Call {
+func (mr *MockAuthMockRecorder) ValidateToken(ctx, tokenStr, tokenType interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateToken", reflect.TypeOf((*MockAuth)(nil).ValidateToken), ctx, tokenStr, tokenType)
+}
+
+// VerifyMFA mocks base method.
+func (m *MockAuth) VerifyMFA(ctx context.Context, mfaToken, code string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyMFA", ctx, mfaToken, code)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyMFA indicates an expected call of VerifyMFA.
+func (mr *MockAuthMockRecorder) VerifyMFA(ctx, mfaToken, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyMFA", reflect.TypeOf((*MockAuth)(nil).VerifyMFA), ctx, mfaToken, code)
+}
+
+// WebAuthnCredentials mocks base method.
+func (m *MockAuth) WebAuthnCredentials(ctx context.Context, login string) ([]models.WebAuthnCredential, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WebAuthnCredentials", ctx, login)
+	ret0, _ := ret[0].([]models.WebAuthnCredential)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WebAuthnCredentials indicates an expected call of WebAuthnCredentials.
+func (mr *MockAuthMockRecorder) WebAuthnCredentials(ctx, login interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateToken", reflect.TypeOf((*MockAuth)(nil).ValidateToken), ctx, tokenStr)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WebAuthnCredentials", reflect.TypeOf((*MockAuth)(nil).WebAuthnCredentials), ctx, login)
 }
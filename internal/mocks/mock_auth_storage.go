@@ -78,6 +78,49 @@ func (mr *MockAuthStorageMockRecorder) GetUser(ctx, login interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockAuthStorage)(nil).GetUser), ctx, login)
 }
 
+// GetUserByCredentialID mocks base method.
+func (m *MockAuthStorage) GetUserByCredentialID(ctx context.Context, credentialID string) (*models.Credentials, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByCredentialID", ctx, credentialID)
+	ret0, _ := ret[0].(*models.Credentials)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByCredentialID indicates an expected call of GetUserByCredentialID.
+func (mr *MockAuthStorageMockRecorder) GetUserByCredentialID(ctx, credentialID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByCredentialID", reflect.TypeOf((*MockAuthStorage)(nil).GetUserByCredentialID), ctx, credentialID)
+}
+
+// UpdateMFA mocks base method.
+func (m *MockAuthStorage) UpdateMFA(ctx context.Context, login string, mfa *models.MFA) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateMFA", ctx, login, mfa)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateMFA indicates an expected call of UpdateMFA.
+func (mr *MockAuthStorageMockRecorder) UpdateMFA(ctx, login, mfa interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateMFA", reflect.TypeOf((*MockAuthStorage)(nil).UpdateMFA), ctx, login, mfa)
+}
+
+// UpdateOTP mocks base method.
+func (m *MockAuthStorage) UpdateOTP(ctx context.Context, login string, otp *models.OTPFactor) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateOTP", ctx, login, otp)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateOTP indicates an expected call of UpdateOTP.
+func (mr *MockAuthStorageMockRecorder) UpdateOTP(ctx, login, otp interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateOTP", reflect.TypeOf((*MockAuthStorage)(nil).UpdateOTP), ctx, login, otp)
+}
+
 // UpdateUser mocks base method.
 func (m *MockAuthStorage) UpdateUser(ctx context.Context, user *models.Credentials) error {
 	m.ctrl.T.Helper()
@@ -91,3 +134,17 @@ func (mr *MockAuthStorageMockRecorder) UpdateUser(ctx, user interface{}) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUser", reflect.TypeOf((*MockAuthStorage)(nil).UpdateUser), ctx, user)
 }
+
+// UpdateWebAuthn mocks base method.
+func (m *MockAuthStorage) UpdateWebAuthn(ctx context.Context, login string, credentials []models.WebAuthnCredential) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebAuthn", ctx, login, credentials)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebAuthn indicates an expected call of UpdateWebAuthn.
+func (mr *MockAuthStorageMockRecorder) UpdateWebAuthn(ctx, login, credentials interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebAuthn", reflect.TypeOf((*MockAuthStorage)(nil).UpdateWebAuthn), ctx, login, credentials)
+}
@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/ports/challenge_storage.go
+
+// Package mock_ports is a generated GoMock package.
+package mock_ports
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/DMA8/authService/internal/domain/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockChallengeStorage is a mock of ChallengeStorage interface.
+type MockChallengeStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockChallengeStorageMockRecorder
+}
+
+// MockChallengeStorageMockRecorder is the mock recorder for MockChallengeStorage.
+type MockChallengeStorageMockRecorder struct {
+	mock *MockChallengeStorage
+}
+
+// NewMockChallengeStorage creates a new mock instance.
+func NewMockChallengeStorage(ctrl *gomock.Controller) *MockChallengeStorage {
+	mock := &MockChallengeStorage{ctrl: ctrl}
+	mock.recorder = &MockChallengeStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockChallengeStorage) EXPECT() *MockChallengeStorageMockRecorder {
+	return m.recorder
+}
+
+// SaveChallenge mocks base method.
+func (m *MockChallengeStorage) SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveChallenge", ctx, challenge)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveChallenge indicates an expected call of SaveChallenge.
+func (mr *MockChallengeStorageMockRecorder) SaveChallenge(ctx, challenge interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveChallenge", reflect.TypeOf((*MockChallengeStorage)(nil).SaveChallenge), ctx, challenge)
+}
+
+// TakeChallenge mocks base method.
+func (m *MockChallengeStorage) TakeChallenge(ctx context.Context, id string) (*models.WebAuthnChallenge, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeChallenge", ctx, id)
+	ret0, _ := ret[0].(*models.WebAuthnChallenge)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeChallenge indicates an expected call of TakeChallenge.
+func (mr *MockChallengeStorageMockRecorder) TakeChallenge(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeChallenge", reflect.TypeOf((*MockChallengeStorage)(nil).TakeChallenge), ctx, id)
+}
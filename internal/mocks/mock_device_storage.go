@@ -0,0 +1,123 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/ports/device_storage.go
+
+// Package mock_ports is a generated GoMock package.
+package mock_ports
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	models "github.com/DMA8/authService/internal/domain/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockDeviceStorage is a mock of DeviceStorage interface.
+type MockDeviceStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockDeviceStorageMockRecorder
+}
+
+// MockDeviceStorageMockRecorder is the mock recorder for MockDeviceStorage.
+type MockDeviceStorageMockRecorder struct {
+	mock *MockDeviceStorage
+}
+
+// NewMockDeviceStorage creates a new mock instance.
+func NewMockDeviceStorage(ctrl *gomock.Controller) *MockDeviceStorage {
+	mock := &MockDeviceStorage{ctrl: ctrl}
+	mock.recorder = &MockDeviceStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockDeviceStorage) EXPECT() *MockDeviceStorageMockRecorder {
+	return m.recorder
+}
+
+// DeleteDevice mocks base method.
+func (m *MockDeviceStorage) DeleteDevice(ctx context.Context, login, id string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDevice", ctx, login, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDevice indicates an expected call of DeleteDevice.
+func (mr *MockDeviceStorageMockRecorder) DeleteDevice(ctx, login, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDevice", reflect.TypeOf((*MockDeviceStorage)(nil).DeleteDevice), ctx, login, id)
+}
+
+// DeleteDevices mocks base method.
+func (m *MockDeviceStorage) DeleteDevices(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDevices", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteDevices indicates an expected call of DeleteDevices.
+func (mr *MockDeviceStorageMockRecorder) DeleteDevices(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDevices", reflect.TypeOf((*MockDeviceStorage)(nil).DeleteDevices), ctx, login)
+}
+
+// GetDevice mocks base method.
+func (m *MockDeviceStorage) GetDevice(ctx context.Context, id string) (*models.TrustedDevice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDevice", ctx, id)
+	ret0, _ := ret[0].(*models.TrustedDevice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDevice indicates an expected call of GetDevice.
+func (mr *MockDeviceStorageMockRecorder) GetDevice(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDevice", reflect.TypeOf((*MockDeviceStorage)(nil).GetDevice), ctx, id)
+}
+
+// ListDevices mocks base method.
+func (m *MockDeviceStorage) ListDevices(ctx context.Context, login string) ([]models.TrustedDevice, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDevices", ctx, login)
+	ret0, _ := ret[0].([]models.TrustedDevice)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDevices indicates an expected call of ListDevices.
+func (mr *MockDeviceStorageMockRecorder) ListDevices(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDevices", reflect.TypeOf((*MockDeviceStorage)(nil).ListDevices), ctx, login)
+}
+
+// SaveDevice mocks base method.
+func (m *MockDeviceStorage) SaveDevice(ctx context.Context, device *models.TrustedDevice) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveDevice", ctx, device)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveDevice indicates an expected call of SaveDevice.
+func (mr *MockDeviceStorageMockRecorder) SaveDevice(ctx, device interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveDevice", reflect.TypeOf((*MockDeviceStorage)(nil).SaveDevice), ctx, device)
+}
+
+// TouchDevice mocks base method.
+func (m *MockDeviceStorage) TouchDevice(ctx context.Context, id string, usedAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TouchDevice", ctx, id, usedAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TouchDevice indicates an expected call of TouchDevice.
+func (mr *MockDeviceStorageMockRecorder) TouchDevice(ctx, id, usedAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TouchDevice", reflect.TypeOf((*MockDeviceStorage)(nil).TouchDevice), ctx, id, usedAt)
+}
@@ -0,0 +1,94 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/ports/otp_storage.go
+
+// Package mock_ports is a generated GoMock package.
+package mock_ports
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/DMA8/authService/internal/domain/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockOTPStorage is a mock of OTPStorage interface.
+type MockOTPStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockOTPStorageMockRecorder
+}
+
+// MockOTPStorageMockRecorder is the mock recorder for MockOTPStorage.
+type MockOTPStorageMockRecorder struct {
+	mock *MockOTPStorage
+}
+
+// NewMockOTPStorage creates a new mock instance.
+func NewMockOTPStorage(ctrl *gomock.Controller) *MockOTPStorage {
+	mock := &MockOTPStorage{ctrl: ctrl}
+	mock.recorder = &MockOTPStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockOTPStorage) EXPECT() *MockOTPStorageMockRecorder {
+	return m.recorder
+}
+
+// AddOTPAttempt mocks base method.
+func (m *MockOTPStorage) AddOTPAttempt(ctx context.Context, login string) (*models.OTPCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOTPAttempt", ctx, login)
+	ret0, _ := ret[0].(*models.OTPCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddOTPAttempt indicates an expected call of AddOTPAttempt.
+func (mr *MockOTPStorageMockRecorder) AddOTPAttempt(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOTPAttempt", reflect.TypeOf((*MockOTPStorage)(nil).AddOTPAttempt), ctx, login)
+}
+
+// DeleteOTP mocks base method.
+func (m *MockOTPStorage) DeleteOTP(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOTP", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOTP indicates an expected call of DeleteOTP.
+func (mr *MockOTPStorageMockRecorder) DeleteOTP(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOTP", reflect.TypeOf((*MockOTPStorage)(nil).DeleteOTP), ctx, login)
+}
+
+// GetOTP mocks base method.
+func (m *MockOTPStorage) GetOTP(ctx context.Context, login string) (*models.OTPCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOTP", ctx, login)
+	ret0, _ := ret[0].(*models.OTPCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOTP indicates an expected call of GetOTP.
+func (mr *MockOTPStorageMockRecorder) GetOTP(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOTP", reflect.TypeOf((*MockOTPStorage)(nil).GetOTP), ctx, login)
+}
+
+// SaveOTP mocks base method.
+func (m *MockOTPStorage) SaveOTP(ctx context.Context, code *models.OTPCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOTP", ctx, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOTP indicates an expected call of SaveOTP.
+func (mr *MockOTPStorageMockRecorder) SaveOTP(ctx, code interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOTP", reflect.TypeOf((*MockOTPStorage)(nil).SaveOTP), ctx, code)
+}
@@ -0,0 +1,109 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/ports/revocation_storage.go
+
+// Package mock_ports is a generated GoMock package.
+package mock_ports
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockRevocationStorage is a mock of RevocationStorage interface.
+type MockRevocationStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockRevocationStorageMockRecorder
+}
+
+// MockRevocationStorageMockRecorder is the mock recorder for MockRevocationStorage.
+type MockRevocationStorageMockRecorder struct {
+	mock *MockRevocationStorage
+}
+
+// NewMockRevocationStorage creates a new mock instance.
+func NewMockRevocationStorage(ctrl *gomock.Controller) *MockRevocationStorage {
+	mock := &MockRevocationStorage{ctrl: ctrl}
+	mock.recorder = &MockRevocationStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRevocationStorage) EXPECT() *MockRevocationStorageMockRecorder {
+	return m.recorder
+}
+
+// AddTokenAttempt mocks base method.
+func (m *MockRevocationStorage) AddTokenAttempt(ctx context.Context, tokenID string, expiresAt time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTokenAttempt", ctx, tokenID, expiresAt)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddTokenAttempt indicates an expected call of AddTokenAttempt.
+func (mr *MockRevocationStorageMockRecorder) AddTokenAttempt(ctx, tokenID, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTokenAttempt", reflect.TypeOf((*MockRevocationStorage)(nil).AddTokenAttempt), ctx, tokenID, expiresAt)
+}
+
+// IsRevoked mocks base method.
+func (m *MockRevocationStorage) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsRevoked", ctx, tokenID)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsRevoked indicates an expected call of IsRevoked.
+func (mr *MockRevocationStorageMockRecorder) IsRevoked(ctx, tokenID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsRevoked", reflect.TypeOf((*MockRevocationStorage)(nil).IsRevoked), ctx, tokenID)
+}
+
+// Revoke mocks base method.
+func (m *MockRevocationStorage) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revoke", ctx, tokenID, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Revoke indicates an expected call of Revoke.
+func (mr *MockRevocationStorageMockRecorder) Revoke(ctx, tokenID, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revoke", reflect.TypeOf((*MockRevocationStorage)(nil).Revoke), ctx, tokenID, expiresAt)
+}
+
+// RevokeUser mocks base method.
+func (m *MockRevocationStorage) RevokeUser(ctx context.Context, login string, issuedBefore, expiresAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUser", ctx, login, issuedBefore, expiresAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUser indicates an expected call of RevokeUser.
+func (mr *MockRevocationStorageMockRecorder) RevokeUser(ctx, login, issuedBefore, expiresAt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUser", reflect.TypeOf((*MockRevocationStorage)(nil).RevokeUser), ctx, login, issuedBefore, expiresAt)
+}
+
+// UserRevokedBefore mocks base method.
+func (m *MockRevocationStorage) UserRevokedBefore(ctx context.Context, login string) (time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UserRevokedBefore", ctx, login)
+	ret0, _ := ret[0].(time.Time)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UserRevokedBefore indicates an expected call of UserRevokedBefore.
+func (mr *MockRevocationStorageMockRecorder) UserRevokedBefore(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UserRevokedBefore", reflect.TypeOf((*MockRevocationStorage)(nil).UserRevokedBefore), ctx, login)
+}
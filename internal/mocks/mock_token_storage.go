@@ -0,0 +1,207 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/ports/token_storage.go
+
+// Package mock_ports is a generated GoMock package.
+package mock_ports
+
+import (
+	context "context"
+	reflect "reflect"
+
+	models "github.com/DMA8/authService/internal/domain/models"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockTokenStorage is a mock of TokenStorage interface.
+type MockTokenStorage struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenStorageMockRecorder
+}
+
+// MockTokenStorageMockRecorder is the mock recorder for MockTokenStorage.
+type MockTokenStorageMockRecorder struct {
+	mock *MockTokenStorage
+}
+
+// NewMockTokenStorage creates a new mock instance.
+func NewMockTokenStorage(ctrl *gomock.Controller) *MockTokenStorage {
+	mock := &MockTokenStorage{ctrl: ctrl}
+	mock.recorder = &MockTokenStorageMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenStorage) EXPECT() *MockTokenStorageMockRecorder {
+	return m.recorder
+}
+
+// CreateFamily mocks base method.
+func (m *MockTokenStorage) CreateFamily(ctx context.Context, family *models.TokenFamily) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateFamily", ctx, family)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateFamily indicates an expected call of CreateFamily.
+func (mr *MockTokenStorageMockRecorder) CreateFamily(ctx, family interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateFamily", reflect.TypeOf((*MockTokenStorage)(nil).CreateFamily), ctx, family)
+}
+
+// DeleteOpaqueToken mocks base method.
+func (m *MockTokenStorage) DeleteOpaqueToken(ctx context.Context, tokenHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOpaqueToken", ctx, tokenHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOpaqueToken indicates an expected call of DeleteOpaqueToken.
+func (mr *MockTokenStorageMockRecorder) DeleteOpaqueToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOpaqueToken", reflect.TypeOf((*MockTokenStorage)(nil).DeleteOpaqueToken), ctx, tokenHash)
+}
+
+// DeleteResetToken mocks base method.
+func (m *MockTokenStorage) DeleteResetToken(ctx context.Context, tokenHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteResetToken", ctx, tokenHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteResetToken indicates an expected call of DeleteResetToken.
+func (mr *MockTokenStorageMockRecorder) DeleteResetToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteResetToken", reflect.TypeOf((*MockTokenStorage)(nil).DeleteResetToken), ctx, tokenHash)
+}
+
+// DeleteResetTokens mocks base method.
+func (m *MockTokenStorage) DeleteResetTokens(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteResetTokens", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteResetTokens indicates an expected call of DeleteResetTokens.
+func (mr *MockTokenStorageMockRecorder) DeleteResetTokens(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteResetTokens", reflect.TypeOf((*MockTokenStorage)(nil).DeleteResetTokens), ctx, login)
+}
+
+// GetFamily mocks base method.
+func (m *MockTokenStorage) GetFamily(ctx context.Context, familyID string) (*models.TokenFamily, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetFamily", ctx, familyID)
+	ret0, _ := ret[0].(*models.TokenFamily)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetFamily indicates an expected call of GetFamily.
+func (mr *MockTokenStorageMockRecorder) GetFamily(ctx, familyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetFamily", reflect.TypeOf((*MockTokenStorage)(nil).GetFamily), ctx, familyID)
+}
+
+// GetOpaqueToken mocks base method.
+func (m *MockTokenStorage) GetOpaqueToken(ctx context.Context, tokenHash string) (*models.OpaqueToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOpaqueToken", ctx, tokenHash)
+	ret0, _ := ret[0].(*models.OpaqueToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOpaqueToken indicates an expected call of GetOpaqueToken.
+func (mr *MockTokenStorageMockRecorder) GetOpaqueToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpaqueToken", reflect.TypeOf((*MockTokenStorage)(nil).GetOpaqueToken), ctx, tokenHash)
+}
+
+// GetResetToken mocks base method.
+func (m *MockTokenStorage) GetResetToken(ctx context.Context, tokenHash string) (*models.ResetToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetResetToken", ctx, tokenHash)
+	ret0, _ := ret[0].(*models.ResetToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetResetToken indicates an expected call of GetResetToken.
+func (mr *MockTokenStorageMockRecorder) GetResetToken(ctx, tokenHash interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetResetToken", reflect.TypeOf((*MockTokenStorage)(nil).GetResetToken), ctx, tokenHash)
+}
+
+// RevokeFamily mocks base method.
+func (m *MockTokenStorage) RevokeFamily(ctx context.Context, familyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeFamily", ctx, familyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeFamily indicates an expected call of RevokeFamily.
+func (mr *MockTokenStorageMockRecorder) RevokeFamily(ctx, familyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeFamily", reflect.TypeOf((*MockTokenStorage)(nil).RevokeFamily), ctx, familyID)
+}
+
+// RevokeUserFamilies mocks base method.
+func (m *MockTokenStorage) RevokeUserFamilies(ctx context.Context, login string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserFamilies", ctx, login)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserFamilies indicates an expected call of RevokeUserFamilies.
+func (mr *MockTokenStorageMockRecorder) RevokeUserFamilies(ctx, login interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserFamilies", reflect.TypeOf((*MockTokenStorage)(nil).RevokeUserFamilies), ctx, login)
+}
+
+// RotateFamily mocks base method.
+func (m *MockTokenStorage) RotateFamily(ctx context.Context, family *models.TokenFamily, fromGeneration int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateFamily", ctx, family, fromGeneration)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RotateFamily indicates an expected call of RotateFamily.
+func (mr *MockTokenStorageMockRecorder) RotateFamily(ctx, family, fromGeneration interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateFamily", reflect.TypeOf((*MockTokenStorage)(nil).RotateFamily), ctx, family, fromGeneration)
+}
+
+// SaveOpaqueToken mocks base method.
+func (m *MockTokenStorage) SaveOpaqueToken(ctx context.Context, token *models.OpaqueToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveOpaqueToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveOpaqueToken indicates an expected call of SaveOpaqueToken.
+func (mr *MockTokenStorageMockRecorder) SaveOpaqueToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveOpaqueToken", reflect.TypeOf((*MockTokenStorage)(nil).SaveOpaqueToken), ctx, token)
+}
+
+// SaveResetToken mocks base method.
+func (m *MockTokenStorage) SaveResetToken(ctx context.Context, token *models.ResetToken) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveResetToken", ctx, token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveResetToken indicates an expected call of SaveResetToken.
+func (mr *MockTokenStorageMockRecorder) SaveResetToken(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveResetToken", reflect.TypeOf((*MockTokenStorage)(nil).SaveResetToken), ctx, token)
+}
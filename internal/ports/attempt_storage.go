@@ -0,0 +1,21 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// AttemptStorage counts failed logins. It has to be shared by replicas for lockout to hold.
+// Counters are forgotten at their ExpiresAt
+type AttemptStorage interface {
+	// GetAttempts returns empty attempts with Key set if there are none
+	GetAttempts(ctx context.Context, key string) (*models.LoginAttempts, error)
+	// AddFailure atomically increments failures and moves expiration to expiresAt
+	AddFailure(ctx context.Context, key string, at, expiresAt time.Time) (*models.LoginAttempts, error)
+	// Lock resets failures and locks key until until
+	Lock(ctx context.Context, key string, until time.Time) error
+	// ResetAttempts removes failures and lock of key
+	ResetAttempts(ctx context.Context, key string) error
+}
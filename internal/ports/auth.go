@@ -1,18 +1,63 @@
 package ports
 
 import (
-	"github.com/DMA8/authService/internal/domain/models"
 	"context"
+
+	"github.com/DMA8/authService/internal/domain/models"
+	"github.com/DMA8/authService/pkg/tokens"
+	"github.com/DMA8/authService/pkg/webauthn"
 )
 
-//TODO: split into 2 interfaces. Auth and CRUD
+// TODO: split into 2 interfaces. Auth and CRUD
 type Auth interface {
 	AuthUser(ctx context.Context, userData *models.Credentials) error
 	CreateToken(ctx context.Context, login string, tokenType models.TokenType) (string, error)
-	ValidateToken(ctx context.Context, tokenStr string) (string, error)
+	ValidateToken(ctx context.Context, tokenStr string, tokenType models.TokenType) (*tokens.Claims, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	RevokeTokens(ctx context.Context, accessToken, refreshToken string) error
+	JWKS(ctx context.Context) tokens.JWKSet
+	ReloadKeys(ctx context.Context) ([]tokens.KeyInfo, error)
+	AuthClient(ctx context.Context, clientID, clientSecret string) error
+	Introspect(ctx context.Context, tokenStr, tokenTypeHint string) *models.Introspection
+	Unlock(ctx context.Context, login, ip string) error
 
 	CreateUser(ctx context.Context, userData *models.Credentials) error
 	GetUser(ctx context.Context, login string) (*models.Credentials, error)
 	UpdateUser(ctx context.Context, userData *models.Credentials) error
 	DeleteUser(ctx context.Context, login string) error
+	ImportUsers(ctx context.Context, users []models.ImportedUser) *models.ImportResult
+
+	ExpirePassword(ctx context.Context, login string) error
+	ChangePassword(ctx context.Context, login, currentPassword, newPassword string) (*models.TokenPair, error)
+	ForgotPassword(ctx context.Context, login string) error
+	ResetPassword(ctx context.Context, token, password string) error
+
+	EnrollTOTP(ctx context.Context, login string) (*models.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, login, code string) ([]string, error)
+	RegenerateRecoveryCodes(ctx context.Context, login, code string) ([]string, error)
+	DisableMFA(ctx context.Context, login, code string) error
+	VerifyMFA(ctx context.Context, mfaToken, code string) (string, error)
+	EnrollOTP(ctx context.Context, login, channel, address string) error
+	ConfirmOTP(ctx context.Context, login, code string) error
+	DisableOTP(ctx context.Context, login string) error
+	SendMFACode(ctx context.Context, mfaToken string) error
+
+	BeginWebAuthnRegistration(ctx context.Context, login string) (*webauthn.CreationOptions, error)
+	FinishWebAuthnRegistration(ctx context.Context, login, name string, resp *webauthn.AttestationResponse) (*models.WebAuthnCredential, error)
+	WebAuthnCredentials(ctx context.Context, login string) ([]models.WebAuthnCredential, error)
+	DeleteWebAuthnCredential(ctx context.Context, login, id string) error
+	BeginWebAuthnLogin(ctx context.Context, login string) (*webauthn.RequestOptions, error)
+	FinishWebAuthnLogin(ctx context.Context, resp *webauthn.AssertionResponse) (string, error)
+	BeginWebAuthnMFA(ctx context.Context, mfaToken string) (*webauthn.RequestOptions, error)
+	FinishWebAuthnMFA(ctx context.Context, mfaToken string, resp *webauthn.AssertionResponse) (string, error)
+
+	Reauthenticate(ctx context.Context, login, password, code string) ([]string, error)
+	SendReauthCode(ctx context.Context, login string) error
+	BeginWebAuthnReauth(ctx context.Context, login string) (*webauthn.RequestOptions, error)
+	FinishWebAuthnReauth(ctx context.Context, login string, resp *webauthn.AssertionResponse) ([]string, error)
+
+	TrustDevice(ctx context.Context, login, name string) (string, *models.TrustedDevice, error)
+	TrustedDevices(ctx context.Context, login string) ([]models.TrustedDevice, error)
+	RevokeDevice(ctx context.Context, login, id string) error
+	RevokeDevices(ctx context.Context, login string) error
 }
@@ -10,4 +10,12 @@ type AuthStorage interface {
 	GetUser(ctx context.Context, login string) (*models.Credentials, error)
 	UpdateUser(ctx context.Context, user *models.Credentials) error
 	DeleteUser(ctx context.Context, login string) error
+	// UpdateMFA replaces second factor state of user, nil mfa removes it
+	UpdateMFA(ctx context.Context, login string, mfa *models.MFA) error
+	// UpdateWebAuthn replaces passkeys and security keys of user
+	UpdateWebAuthn(ctx context.Context, login string, credentials []models.WebAuthnCredential) error
+	// GetUserByCredentialID finds owner of webauthn credential, e.ErrNoUserInDB if there is none
+	GetUserByCredentialID(ctx context.Context, credentialID string) (*models.Credentials, error)
+	// UpdateOTP sets where one-time codes of user are sent, nil removes it
+	UpdateOTP(ctx context.Context, login string, otp *models.OTPFactor) error
 }
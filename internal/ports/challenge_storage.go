@@ -0,0 +1,14 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// ChallengeStorage keeps webauthn challenges until response comes
+type ChallengeStorage interface {
+	SaveChallenge(ctx context.Context, challenge *models.WebAuthnChallenge) error
+	// TakeChallenge removes challenge and returns it, e.ErrBadChallenge if it is unknown or expired
+	TakeChallenge(ctx context.Context, id string) (*models.WebAuthnChallenge, error)
+}
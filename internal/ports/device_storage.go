@@ -0,0 +1,20 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// DeviceStorage keeps devices where users skip second factor
+type DeviceStorage interface {
+	SaveDevice(ctx context.Context, device *models.TrustedDevice) error
+	// GetDevice returns e.ErrNoTrustedDevice if device is unknown or expired
+	GetDevice(ctx context.Context, id string) (*models.TrustedDevice, error)
+	ListDevices(ctx context.Context, login string) ([]models.TrustedDevice, error)
+	TouchDevice(ctx context.Context, id string, usedAt time.Time) error
+	// DeleteDevice returns e.ErrNoTrustedDevice if login has no device id
+	DeleteDevice(ctx context.Context, login, id string) error
+	DeleteDevices(ctx context.Context, login string) error
+}
@@ -0,0 +1,12 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// Notifier delivers notifications to users
+type Notifier interface {
+	Notify(ctx context.Context, notification *models.Notification) error
+}
@@ -0,0 +1,19 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+// OTPStorage keeps the last one-time code sent to each login
+type OTPStorage interface {
+	// SaveOTP replaces previous code of the login
+	SaveOTP(ctx context.Context, code *models.OTPCode) error
+	// GetOTP returns e.ErrNoOTP if there is no code or it expired
+	GetOTP(ctx context.Context, login string) (*models.OTPCode, error)
+	// AddOTPAttempt counts a guess and returns code with the guess counted
+	AddOTPAttempt(ctx context.Context, login string) (*models.OTPCode, error)
+	// DeleteOTP returns e.ErrNoOTP if there was nothing to delete
+	DeleteOTP(ctx context.Context, login string) error
+}
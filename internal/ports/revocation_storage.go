@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// RevocationStorage keeps ids (jti) of revoked tokens until the tokens expire
+type RevocationStorage interface {
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+	// RevokeUser revokes every token of login issued before issuedBefore, it is kept till expiresAt
+	RevokeUser(ctx context.Context, login string, issuedBefore, expiresAt time.Time) error
+	// UserRevokedBefore is the latest issuedBefore of login, zero time if tokens of login are not revoked
+	UserRevokedBefore(ctx context.Context, login string) (time.Time, error)
+	// AddTokenAttempt counts a use of token and returns how many there were, the count is kept till expiresAt
+	AddTokenAttempt(ctx context.Context, tokenID string, expiresAt time.Time) (int, error)
+}
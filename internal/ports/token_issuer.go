@@ -0,0 +1,18 @@
+package ports
+
+import (
+	"context"
+	"time"
+
+	"github.com/DMA8/authService/pkg/tokens"
+)
+
+// TokenIssuer creates tokens with given claims and gives claims back for valid tokens
+type TokenIssuer interface {
+	// Issue sets jti, iat, nbf and exp of claims
+	Issue(ctx context.Context, claims *tokens.Claims, dur time.Duration) (string, error)
+	Verify(ctx context.Context, tokenStr string, opts tokens.ValidationOptions) (*tokens.Claims, error)
+	// Revoke invalidates token at once. It returns false if issuer can't do it
+	// and token should be put to revocation list
+	Revoke(ctx context.Context, tokenStr string) (bool, error)
+}
@@ -0,0 +1,30 @@
+package ports
+
+import (
+	"context"
+
+	"github.com/DMA8/authService/internal/domain/models"
+)
+
+type TokenStorage interface {
+	CreateFamily(ctx context.Context, family *models.TokenFamily) error
+	GetFamily(ctx context.Context, familyID string) (*models.TokenFamily, error)
+	// RotateFamily saves family only if stored generation is still fromGeneration
+	RotateFamily(ctx context.Context, family *models.TokenFamily, fromGeneration int) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeUserFamilies ends every session of login
+	RevokeUserFamilies(ctx context.Context, login string) error
+
+	// opaque tokens are stored by hash of the token
+	SaveOpaqueToken(ctx context.Context, token *models.OpaqueToken) error
+	GetOpaqueToken(ctx context.Context, tokenHash string) (*models.OpaqueToken, error)
+	DeleteOpaqueToken(ctx context.Context, tokenHash string) error
+
+	// password reset tokens are stored by hash of the token.
+	// DeleteResetToken returns ErrBadResetToken if there was no such token, so only one caller can use it
+	SaveResetToken(ctx context.Context, token *models.ResetToken) error
+	GetResetToken(ctx context.Context, tokenHash string) (*models.ResetToken, error)
+	DeleteResetToken(ctx context.Context, tokenHash string) error
+	// DeleteResetTokens drops every reset token of login
+	DeleteResetTokens(ctx context.Context, login string) error
+}
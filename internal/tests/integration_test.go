@@ -14,15 +14,16 @@ import (
 	"strings"
 	"testing"
 	"time"
-	
+
 	"github.com/stretchr/testify/suite"
-	
+
 	entrypoint "github.com/DMA8/authService/internal/adapters/http"
 	repository "github.com/DMA8/authService/internal/adapters/mongodb"
 	"github.com/DMA8/authService/internal/config"
 	"github.com/DMA8/authService/internal/domain/auth"
 	"github.com/DMA8/authService/internal/domain/models"
 	logger "github.com/DMA8/authService/pkg/logging"
+	"github.com/DMA8/authService/pkg/tokens"
 )
 
 type integraTestSuite struct {
@@ -52,10 +53,18 @@ func testConfig() *config.Config {
 			RefreshTTL: time.Hour,
 		},
 		Mongo: config.MongoConfig{
-			URI:            "localhost:27017",
-			URIFul:         "mongodb://localhost:27017",
-			UserCollection: "usersTest",
-			DB:             "test",
+			URI:                 "localhost:27017",
+			URIFull:             "mongodb://localhost:27017",
+			UserCollection:      "usersTest",
+			FamilyCollection:    "familiesTest",
+			RevokedCollection:   "revokedTest",
+			OpaqueCollection:    "opaqueTest",
+			ResetCollection:     "resetTest",
+			AttemptCollection:   "attemptTest",
+			ChallengeCollection: "challengeTest",
+			OTPCollection:       "otpTest",
+			DeviceCollection:    "deviceTest",
+			DB:                  "test",
 		},
 		Log: config.LogConfig{Level: "debug"},
 	}
@@ -74,7 +83,18 @@ func (s *integraTestSuite) SetupSuite() {
 	}
 	s.r = repo
 	l.Info().Msg("Hello server")
-	authService := auth.NewAuth(s.cfg.JWT, repo, l)
+	secret, err := tokens.NewHMACKey(cfg.JWT.Secret)
+	if err != nil {
+		l.Fatal().Err(err)
+	}
+	key, err := tokens.NewKeyring(cfg.JWT.RefreshTTL, secret)
+	if err != nil {
+		l.Fatal().Err(err)
+	}
+	authService, err := auth.NewAuth(s.cfg.JWT, key, nil, repo, repo, repo, l)
+	if err != nil {
+		l.Fatal().Err(err)
+	}
 	handler := entrypoint.NewHandler(cfg.HTTP, authService, l)
 	server := entrypoint.NewHTTPServer(cfg.HTTP, handler)
 	s.app = server
@@ -111,17 +131,13 @@ func (s *integraTestSuite) TestScenario() {
 		Login: testUserCreate.Login,
 	}
 
-	//2. Читаем созданного выше пользователя
+	//2. Без токена пользователя не прочитать
 	req2, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost%s%s/user/%s", s.app.Addr, s.cfg.HTTP.APIVersion, testUserCreate.Login), nil)
 	s.NoError(err)
 	client2 := http.Client{}
 	response2, err := client2.Do(req2)
 	s.NoError(err)
-	s.Equal(http.StatusOK, response2.StatusCode)
-	var msg2 entrypoint.Message
-	s.NoError(json.NewDecoder(response2.Body).Decode(&msg2))
-	s.Equal(http.StatusOK, msg2.StatusCode)
-	s.Contains(msg2.Message, testGetUser.Login)
+	s.Equal(http.StatusForbidden, response2.StatusCode)
 	response2.Body.Close()
 
 	//3. Логинимся с кредами пользователя выше
@@ -152,6 +168,18 @@ func (s *integraTestSuite) TestScenario() {
 	s.NoError(json.NewDecoder(response4.Body).Decode(&msg4))
 	s.Equal(http.StatusOK, msg4.StatusCode)
 
+	//Читаем созданного выше пользователя с его токеном
+	req6, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://localhost%s%s/user/%s", s.app.Addr, s.cfg.HTTP.APIVersion, testGetUser.Login), nil)
+	s.NoError(err)
+	response6, err := client4.Do(req6)
+	s.NoError(err)
+	s.Equal(http.StatusOK, response6.StatusCode)
+	var msg6 entrypoint.Message
+	s.NoError(json.NewDecoder(response6.Body).Decode(&msg6))
+	s.Equal(http.StatusOK, msg6.StatusCode)
+	s.Contains(msg6.Message, testGetUser.Login)
+	response6.Body.Close()
+
 	//логаут
 	req5, err := http.NewRequest("GET", fmt.Sprintf("http://localhost%s%s/logout", s.app.Addr, s.cfg.HTTP.APIVersion), nil)
 	response5, err := client4.Do(req5)
@@ -167,7 +195,7 @@ func (s *integraTestSuite) TestScenario() {
 	s.Equal(response5.Cookies()[1].Expires, time.UnixMicro(0).UTC())
 }
 
-//MongoCruds
+// MongoCruds
 func (s *integraTestSuite) TestRepo() {
 	ctx := context.TODO()
 	usr1 := models.Credentials{
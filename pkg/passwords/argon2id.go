@@ -0,0 +1,86 @@
+package passwords
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2idParams default to OWASP minimum: 19 MiB of memory, 2 iterations, 1 thread
+type Argon2idParams struct {
+	Memory      uint32 `yaml:"memory"` // KiB
+	Iterations  uint32 `yaml:"iterations"`
+	Parallelism uint8  `yaml:"parallelism"`
+}
+
+const (
+	defaultArgon2Memory      = 19 * 1024
+	defaultArgon2Iterations  = 2
+	defaultArgon2Parallelism = 1
+)
+
+type argon2idAlgorithm struct {
+	params Argon2idParams
+}
+
+func NewArgon2id(params Argon2idParams) Algorithm {
+	if params.Memory == 0 {
+		params.Memory = defaultArgon2Memory
+	}
+	if params.Iterations == 0 {
+		params.Iterations = defaultArgon2Iterations
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = defaultArgon2Parallelism
+	}
+	return &argon2idAlgorithm{params: params}
+}
+
+func (a *argon2idAlgorithm) Name() string {
+	return Argon2id
+}
+
+func (a *argon2idAlgorithm) Hash(password []byte) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	p := a.params
+	hash := argon2.IDKey(password, salt, p.Iterations, p.Memory, p.Parallelism, keySize)
+	return (&phc{
+		id:      Argon2id,
+		version: strconv.Itoa(argon2.Version),
+		params:  map[string]int{"m": int(p.Memory), "t": int(p.Iterations), "p": int(p.Parallelism)},
+		salt:    salt,
+		hash:    hash,
+	}).String(), nil
+}
+
+func (a *argon2idAlgorithm) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$"+Argon2id+"$")
+}
+
+func (a *argon2idAlgorithm) Verify(password []byte, hash string) (bool, error) {
+	p, err := parsePHC(Argon2id, hash)
+	if err != nil {
+		return false, err
+	}
+	if p.version != strconv.Itoa(argon2.Version) || p.params["m"] <= 0 || p.params["t"] <= 0 ||
+		p.params["p"] <= 0 || p.params["p"] > 255 {
+		return false, ErrBadHash
+	}
+	computed := argon2.IDKey(password, p.salt, uint32(p.params["t"]), uint32(p.params["m"]), uint8(p.params["p"]),
+		uint32(len(p.hash)))
+	return subtle.ConstantTimeCompare(computed, p.hash) == 1, nil
+}
+
+func (a *argon2idAlgorithm) Outdated(hash string) bool {
+	p, err := parsePHC(Argon2id, hash)
+	if err != nil {
+		return true
+	}
+	return p.params["m"] != int(a.params.Memory) || p.params["t"] != int(a.params.Iterations) ||
+		p.params["p"] != int(a.params.Parallelism) || len(p.hash) != keySize || len(p.salt) < saltSize
+}
@@ -0,0 +1,68 @@
+package passwords
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptParams default to cost 12. Bcrypt hashes keep their own modular crypt format
+type BcryptParams struct {
+	Cost int `yaml:"cost"`
+}
+
+const defaultBcryptCost = 12
+
+func (p BcryptParams) validate() error {
+	if p.Cost != 0 && (p.Cost < bcrypt.MinCost || p.Cost > bcrypt.MaxCost) {
+		return ErrBadParams
+	}
+	return nil
+}
+
+type bcryptAlgorithm struct {
+	cost int
+}
+
+func NewBcrypt(cost int) Algorithm {
+	if cost == 0 {
+		cost = defaultBcryptCost
+	}
+	return &bcryptAlgorithm{cost: cost}
+}
+
+func (a *bcryptAlgorithm) Name() string {
+	return Bcrypt
+}
+
+func (a *bcryptAlgorithm) Hash(password []byte) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword(password, a.cost)
+	return string(hash), err
+}
+
+func (a *bcryptAlgorithm) Owns(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *bcryptAlgorithm) Verify(password []byte, hash string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), password)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return false, nil
+	default:
+		return false, ErrBadHash
+	}
+}
+
+func (a *bcryptAlgorithm) Outdated(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != a.cost
+}
@@ -0,0 +1,281 @@
+package passwords
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Legacy algorithms only verify hashes imported from other systems, new hashes are never made
+// with them. Hashes keep their Django formats, the first field is algorithm tag:
+//
+//	pbkdf2_sha256$<iterations>$<salt>$<base64 hash>
+//	scrypt$<N>$<salt>$<r>$<p>$<base64 hash>
+//	sha1$<salt>$<hex of sha1(salt + password)>
+const (
+	DjangoPBKDF2SHA256 = "pbkdf2_sha256"
+	DjangoScrypt       = "django_scrypt"
+	SaltedSHA1         = "sha1"
+)
+
+const (
+	djangoPBKDF2Iterations = 600000
+	djangoScryptN          = 1 << 14
+	djangoScryptR          = 8
+	djangoScryptP          = 1
+	djangoScryptKeySize    = 64
+	// limits keep a crafted hash from eating all cpu and memory.
+	// scrypt needs 128*N*r bytes and does N*r*p work, 128*N*r*p bounds both
+	maxPBKDF2Iterations   = 10000000
+	maxLegacyScryptN      = 1 << 20
+	maxLegacyScryptMemory = 64 << 20
+	maxLegacyBcryptCost   = 14
+)
+
+// ImportHash checks that hash of another system is of algorithm and returns it as it is stored.
+// Salted SHA-1 may come as a bare hex digest with separate salt
+func ImportHash(algorithm, hash, salt string) (string, error) {
+	var alg Algorithm
+	switch algorithm {
+	case Bcrypt:
+		if cost, err := bcrypt.Cost([]byte(hash)); err != nil || cost > maxLegacyBcryptCost {
+			return "", ErrBadHash
+		}
+		return hash, nil
+	case DjangoPBKDF2SHA256:
+		alg = NewDjangoPBKDF2SHA256()
+	case DjangoScrypt:
+		alg = NewDjangoScrypt()
+	case SaltedSHA1:
+		alg = NewSaltedSHA1()
+		if salt != "" {
+			if strings.Contains(salt, "$") {
+				return "", ErrBadHash
+			}
+			hash = SaltedSHA1 + "$" + salt + "$" + strings.ToLower(hash)
+		}
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+	if !alg.Owns(hash) {
+		return "", ErrBadHash
+	}
+	fields, err := splitLegacy(hash, alg)
+	if err != nil {
+		return "", err
+	}
+	// hashes which would be rejected at login are rejected now
+	switch alg.Name() {
+	case DjangoPBKDF2SHA256:
+		_, err = pbkdf2Iterations(fields)
+	case DjangoScrypt:
+		_, _, _, err = legacyScryptParams(fields)
+	}
+	if err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// splitLegacy returns fields of legacy hash after algorithm tag
+func splitLegacy(hash string, alg Algorithm) ([]string, error) {
+	parts := strings.Split(hash, "$")
+	var fields int
+	switch alg.Name() {
+	case DjangoPBKDF2SHA256:
+		fields = 3
+	case DjangoScrypt:
+		fields = 5
+	case SaltedSHA1:
+		fields = 2
+		if len(parts) == 3 {
+			if digest, err := hex.DecodeString(parts[2]); err != nil || len(digest) != sha1.Size {
+				return nil, ErrBadHash
+			}
+		}
+	}
+	if len(parts) != fields+1 {
+		return nil, ErrBadHash
+	}
+	return parts[1:], nil
+}
+
+// pbkdf2Iterations is iteration count of pbkdf2_sha256 hash fields
+func pbkdf2Iterations(fields []string) (int, error) {
+	return legacyInt(fields[0], 1, maxPBKDF2Iterations)
+}
+
+// legacyScryptParams are N, r and p of django scrypt hash fields. N is a power of two
+// and 128*N*r*p is at most maxLegacyScryptMemory
+func legacyScryptParams(fields []string) (n, r, p int, err error) {
+	if n, err = legacyInt(fields[0], 2, maxLegacyScryptN); err != nil || n&(n-1) != 0 {
+		return 0, 0, 0, ErrBadHash
+	}
+	if r, err = legacyInt(fields[2], 1, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if p, err = legacyInt(fields[3], 1, 64); err != nil {
+		return 0, 0, 0, err
+	}
+	if int64(128)*int64(n)*int64(r)*int64(p) > maxLegacyScryptMemory {
+		return 0, 0, 0, ErrBadHash
+	}
+	return n, r, p, nil
+}
+
+func legacyInt(s string, min, max int) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, ErrBadHash
+	}
+	return n, nil
+}
+
+type djangoPBKDF2 struct{}
+
+func NewDjangoPBKDF2SHA256() Algorithm {
+	return djangoPBKDF2{}
+}
+
+func (djangoPBKDF2) Name() string {
+	return DjangoPBKDF2SHA256
+}
+
+func (djangoPBKDF2) Hash(password []byte) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	saltStr := base64.RawURLEncoding.EncodeToString(salt)
+	key := pbkdf2.Key(password, []byte(saltStr), djangoPBKDF2Iterations, sha256.Size, sha256.New)
+	return fmt.Sprintf("%s$%d$%s$%s", DjangoPBKDF2SHA256, djangoPBKDF2Iterations, saltStr,
+		base64.StdEncoding.EncodeToString(key)), nil
+}
+
+func (djangoPBKDF2) Owns(hash string) bool {
+	return strings.HasPrefix(hash, DjangoPBKDF2SHA256+"$")
+}
+
+func (a djangoPBKDF2) Verify(password []byte, hash string) (bool, error) {
+	fields, err := splitLegacy(hash, a)
+	if err != nil {
+		return false, err
+	}
+	iterations, err := pbkdf2Iterations(fields)
+	if err != nil {
+		return false, err
+	}
+	expected, err := base64.StdEncoding.DecodeString(fields[2])
+	if err != nil || len(expected) == 0 {
+		return false, ErrBadHash
+	}
+	key := pbkdf2.Key(password, []byte(fields[1]), iterations, len(expected), sha256.New)
+	return subtle.ConstantTimeCompare(key, expected) == 1, nil
+}
+
+func (djangoPBKDF2) Outdated(hash string) bool {
+	return true
+}
+
+type djangoScrypt struct{}
+
+func NewDjangoScrypt() Algorithm {
+	return djangoScrypt{}
+}
+
+func (djangoScrypt) Name() string {
+	return DjangoScrypt
+}
+
+func (djangoScrypt) Hash(password []byte) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	saltStr := base64.RawURLEncoding.EncodeToString(salt)
+	key, err := scrypt.Key(password, []byte(saltStr), djangoScryptN, djangoScryptR, djangoScryptP, djangoScryptKeySize)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("scrypt$%d$%s$%d$%d$%s", djangoScryptN, saltStr, djangoScryptR, djangoScryptP,
+		base64.StdEncoding.EncodeToString(key)), nil
+}
+
+// Owns doesn't clash with PHC scrypt hashes, those start with $
+func (djangoScrypt) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "scrypt$")
+}
+
+func (a djangoScrypt) Verify(password []byte, hash string) (bool, error) {
+	fields, err := splitLegacy(hash, a)
+	if err != nil {
+		return false, err
+	}
+	n, r, p, err := legacyScryptParams(fields)
+	if err != nil {
+		return false, err
+	}
+	expected, err := base64.StdEncoding.DecodeString(fields[4])
+	if err != nil || len(expected) == 0 {
+		return false, ErrBadHash
+	}
+	key, err := scrypt.Key(password, []byte(fields[1]), n, r, p, len(expected))
+	if err != nil {
+		return false, ErrBadHash
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1, nil
+}
+
+func (djangoScrypt) Outdated(hash string) bool {
+	return true
+}
+
+type saltedSHA1 struct{}
+
+func NewSaltedSHA1() Algorithm {
+	return saltedSHA1{}
+}
+
+func (saltedSHA1) Name() string {
+	return SaltedSHA1
+}
+
+func (saltedSHA1) Hash(password []byte) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	saltStr := hex.EncodeToString(salt)
+	sum := sha1.Sum(append([]byte(saltStr), password...))
+	return SaltedSHA1 + "$" + saltStr + "$" + hex.EncodeToString(sum[:]), nil
+}
+
+func (saltedSHA1) Owns(hash string) bool {
+	return strings.HasPrefix(hash, SaltedSHA1+"$")
+}
+
+func (a saltedSHA1) Verify(password []byte, hash string) (bool, error) {
+	fields, err := splitLegacy(hash, a)
+	if err != nil {
+		return false, err
+	}
+	expected, err := hex.DecodeString(fields[1])
+	if err != nil {
+		return false, ErrBadHash
+	}
+	sum := sha1.Sum(append([]byte(fields[0]), password...))
+	return subtle.ConstantTimeCompare(sum[:], expected) == 1, nil
+}
+
+func (saltedSHA1) Outdated(hash string) bool {
+	return true
+}
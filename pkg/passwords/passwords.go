@@ -0,0 +1,231 @@
+package passwords
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	Argon2id = "argon2id"
+	Scrypt   = "scrypt"
+	Bcrypt   = "bcrypt"
+)
+
+var (
+	ErrUnknownAlgorithm  = errors.New("unknown password hashing algorithm")
+	ErrUnknownHashFormat = errors.New("password hash has unknown format")
+	ErrBadHash           = errors.New("password hash is corrupted")
+	ErrBadParams         = errors.New("bad password hashing parameters")
+)
+
+const (
+	saltSize = 16
+	keySize  = 32
+)
+
+// Algorithm hashes passwords into self-describing strings, so a hash can be verified
+// whatever parameters were configured when it was made
+type Algorithm interface {
+	Name() string
+	Hash(password []byte) (string, error)
+	// Owns tells if hash was made by this algorithm
+	Owns(hash string) bool
+	Verify(password []byte, hash string) (bool, error)
+	// Outdated tells if hash was made with other parameters than configured now
+	Outdated(hash string) bool
+}
+
+// Hasher hashes passwords with preferred algorithm and verifies hashes of every known one
+type Hasher struct {
+	preferred Algorithm
+	known     []Algorithm
+	peppers   *Peppers
+}
+
+// NewHasher makes hasher which hashes with preferred and verifies also default
+// argon2id, scrypt and bcrypt hashes, imported legacy hashes and hashes of extra algorithms
+func NewHasher(preferred Algorithm, extra ...Algorithm) *Hasher {
+	h := &Hasher{preferred: preferred}
+	h.known = append(append([]Algorithm{preferred}, extra...), NewArgon2id(Argon2idParams{}),
+		NewScrypt(ScryptParams{}), NewBcrypt(0), NewDjangoPBKDF2SHA256(), NewDjangoScrypt(), NewSaltedSHA1())
+	return h
+}
+
+// Default is argon2id hasher with default parameters
+func Default() *Hasher {
+	return NewHasher(NewArgon2id(Argon2idParams{}))
+}
+
+// Config is what is set in yaml for hashing passwords. Zero parameters take defaults
+type Config struct {
+	Algorithm string         `yaml:"algorithm"`
+	Argon2id  Argon2idParams `yaml:"argon2id"`
+	Scrypt    ScryptParams   `yaml:"scrypt"`
+	Bcrypt    BcryptParams   `yaml:"bcrypt"`
+}
+
+// New makes hasher for algorithm from cfg. Empty algorithm is argon2id
+func New(cfg Config) (*Hasher, error) {
+	switch cfg.Algorithm {
+	case "", Argon2id:
+		return NewHasher(NewArgon2id(cfg.Argon2id)), nil
+	case Scrypt:
+		if err := cfg.Scrypt.validate(); err != nil {
+			return nil, err
+		}
+		return NewHasher(NewScrypt(cfg.Scrypt)), nil
+	case Bcrypt:
+		if err := cfg.Bcrypt.validate(); err != nil {
+			return nil, err
+		}
+		return NewHasher(NewBcrypt(cfg.Bcrypt.Cost)), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+}
+
+// WithPeppers is copy of h which peppers passwords with current pepper before hashing.
+// Nil peppers turn peppering off
+func (h *Hasher) WithPeppers(peppers *Peppers) *Hasher {
+	c := *h
+	c.peppers = peppers
+	return &c
+}
+
+// Hash hashes password with preferred algorithm. With peppers the hash
+// is of peppered password and starts with pepper version
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.peppers == nil {
+		return h.preferred.Hash([]byte(password))
+	}
+	peppered, err := h.peppers.apply(h.peppers.current, password)
+	if err != nil {
+		return "", err
+	}
+	hash, err := h.preferred.Hash(peppered)
+	if err != nil {
+		return "", err
+	}
+	return pepperPrefix + h.peppers.current + hash, nil
+}
+
+// Verify checks password against hash of any known algorithm, peppered with any loaded pepper or not.
+// rehash is true when password is right, but hash should be replaced by a new one
+func (h *Hasher) Verify(password, hash string) (ok, rehash bool, err error) {
+	input := []byte(password)
+	version, inner, peppered := splitPeppered(hash)
+	if peppered {
+		if input, err = h.peppers.apply(version, password); err != nil {
+			return false, false, err
+		}
+	}
+	for _, alg := range h.known {
+		if !alg.Owns(inner) {
+			continue
+		}
+		if ok, err = alg.Verify(input, inner); !ok || err != nil {
+			return false, false, err
+		}
+		return true, h.NeedsRehash(hash), nil
+	}
+	return false, false, ErrUnknownHashFormat
+}
+
+// NeedsRehash tells if hash isn't made by preferred algorithm with its current parameters
+// or isn't peppered with current pepper
+func (h *Hasher) NeedsRehash(hash string) bool {
+	version, inner, peppered := splitPeppered(hash)
+	if h.peppers != nil && (!peppered || version != h.peppers.current) {
+		return true
+	}
+	return !h.preferred.Owns(inner) || h.preferred.Outdated(inner)
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	_, err := rand.Read(salt)
+	return salt, err
+}
+
+// phc is hash in PHC string format: $id$v=version$param=value,...$salt$hash
+type phc struct {
+	id      string
+	version string
+	params  map[string]int
+	salt    []byte
+	hash    []byte
+}
+
+func (p *phc) String() string {
+	var b strings.Builder
+	b.WriteString("$" + p.id)
+	if p.version != "" {
+		b.WriteString("$v=" + p.version)
+	}
+	b.WriteString("$")
+	for i, name := range sortedParams(p.id) {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(name + "=" + strconv.Itoa(p.params[name]))
+	}
+	b.WriteString("$" + base64.RawStdEncoding.EncodeToString(p.salt))
+	b.WriteString("$" + base64.RawStdEncoding.EncodeToString(p.hash))
+	return b.String()
+}
+
+// parsePHC parses hash of algorithm id. Every parameter of id must be present
+func parsePHC(id, hash string) (*phc, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) < 5 || parts[0] != "" || parts[1] != id {
+		return nil, ErrBadHash
+	}
+	p := &phc{id: id, params: make(map[string]int)}
+	rest := parts[2:]
+	if strings.HasPrefix(rest[0], "v=") {
+		p.version = strings.TrimPrefix(rest[0], "v=")
+		rest = rest[1:]
+	}
+	if len(rest) != 3 {
+		return nil, ErrBadHash
+	}
+	for _, param := range strings.Split(rest[0], ",") {
+		name, value, found := strings.Cut(param, "=")
+		if !found {
+			return nil, ErrBadHash
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, ErrBadHash
+		}
+		p.params[name] = n
+	}
+	for _, name := range sortedParams(id) {
+		if _, ok := p.params[name]; !ok {
+			return nil, ErrBadHash
+		}
+	}
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(rest[1]); err != nil {
+		return nil, ErrBadHash
+	}
+	if p.hash, err = base64.RawStdEncoding.DecodeString(rest[2]); err != nil || len(p.hash) == 0 {
+		return nil, ErrBadHash
+	}
+	return p, nil
+}
+
+// sortedParams are parameter names of algorithm in the order they are written
+func sortedParams(id string) []string {
+	switch id {
+	case Argon2id:
+		return []string{"m", "t", "p"}
+	case Scrypt:
+		return []string{"ln", "r", "p"}
+	}
+	return nil
+}
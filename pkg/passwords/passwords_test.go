@@ -0,0 +1,202 @@
+package passwords_test
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DMA8/authService/pkg/passwords"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasher(t *testing.T) {
+	testCases := []struct {
+		cfg    passwords.Config
+		prefix string
+	}{
+		{cfg: passwords.Config{}, prefix: "$argon2id$v=19$m=19456,t=2,p=1$"},
+		{cfg: passwords.Config{Algorithm: passwords.Argon2id, Argon2id: passwords.Argon2idParams{Memory: 8192, Iterations: 3, Parallelism: 2}},
+			prefix: "$argon2id$v=19$m=8192,t=3,p=2$"},
+		{cfg: passwords.Config{Algorithm: passwords.Scrypt, Scrypt: passwords.ScryptParams{LogN: 10}}, prefix: "$scrypt$ln=10,r=8,p=1$"},
+		{cfg: passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 5}}, prefix: "$2a$05$"},
+	}
+	for _, testCase := range testCases {
+		hasher, err := passwords.New(testCase.cfg)
+		require.NoError(t, err, testCase.cfg.Algorithm)
+		hash, err := hasher.Hash("secret")
+		require.NoError(t, err, testCase.cfg.Algorithm)
+		assert.True(t, strings.HasPrefix(hash, testCase.prefix), hash)
+
+		ok, rehash, err := hasher.Verify("secret", hash)
+		assert.NoError(t, err)
+		assert.Equal(t, true, ok, testCase.cfg.Algorithm)
+		assert.Equal(t, false, rehash, testCase.cfg.Algorithm)
+
+		ok, _, err = hasher.Verify("secret2", hash)
+		assert.NoError(t, err)
+		assert.Equal(t, false, ok, testCase.cfg.Algorithm)
+
+		//every hasher verifies hashes of the others
+		other, err := passwords.New(passwords.Config{Algorithm: passwords.Scrypt, Scrypt: passwords.ScryptParams{LogN: 11}})
+		require.NoError(t, err)
+		ok, rehash, err = other.Verify("secret", hash)
+		assert.NoError(t, err)
+		assert.Equal(t, true, ok, testCase.cfg.Algorithm)
+		assert.Equal(t, true, rehash, testCase.cfg.Algorithm)
+	}
+}
+
+func TestHasherOutdatedParams(t *testing.T) {
+	weak, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	require.NoError(t, err)
+	hash, err := weak.Hash("secret")
+	require.NoError(t, err)
+	strong, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 6}})
+	require.NoError(t, err)
+	ok, rehash, err := strong.Verify("secret", hash)
+	assert.NoError(t, err)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, rehash)
+
+	//wrong password never asks for rehash
+	ok, rehash, err = strong.Verify("other", hash)
+	assert.NoError(t, err)
+	assert.Equal(t, false, ok)
+	assert.Equal(t, false, rehash)
+}
+
+func TestHasherErrors(t *testing.T) {
+	_, err := passwords.New(passwords.Config{Algorithm: "md5"})
+	assert.ErrorIs(t, err, passwords.ErrUnknownAlgorithm)
+	_, err = passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 100}})
+	assert.ErrorIs(t, err, passwords.ErrBadParams)
+
+	hasher := passwords.Default()
+	_, _, err = hasher.Verify("secret", "plain text")
+	assert.ErrorIs(t, err, passwords.ErrUnknownHashFormat)
+	_, _, err = hasher.Verify("secret", "$argon2id$v=19$m=19456,t=2$c2FsdA$aGFzaA")
+	assert.ErrorIs(t, err, passwords.ErrBadHash)
+	_, _, err = hasher.Verify("secret", "$scrypt$ln=99,r=8,p=1$c2FsdA$aGFzaA")
+	assert.ErrorIs(t, err, passwords.ErrBadHash)
+}
+
+func TestHasherPeppers(t *testing.T) {
+	key1 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("1", 32)))
+	key2 := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("2", 32)))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "peppers.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`"1": `+key1+"\n"), 0600))
+
+	plain, err := passwords.New(passwords.Config{Algorithm: passwords.Bcrypt, Bcrypt: passwords.BcryptParams{Cost: 4}})
+	require.NoError(t, err)
+	peppers1, err := passwords.LoadPeppers(passwords.PepperConfig{Current: "1", Path: path})
+	require.NoError(t, err)
+	hasher1 := plain.WithPeppers(peppers1)
+	//second version comes from env, first one stays to verify old hashes
+	peppers2, err := passwords.LoadPeppers(passwords.PepperConfig{Current: "2", Path: path, Keys: "2:" + key2})
+	require.NoError(t, err)
+	hasher2 := plain.WithPeppers(peppers2)
+
+	plainHash, err := plain.Hash("secret")
+	require.NoError(t, err)
+	hash1, err := hasher1.Hash("secret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash1, "$pepper$v=1$2a$04$"), hash1)
+	//peppered hash can't be checked without pepper
+	_, _, err = plain.Verify("secret", hash1)
+	assert.ErrorIs(t, err, passwords.ErrUnknownPepper)
+
+	testCases := []struct {
+		hasher *passwords.Hasher
+		hash   string
+		rehash bool
+	}{
+		{hasher: hasher1, hash: hash1, rehash: false},
+		{hasher: hasher1, hash: plainHash, rehash: true},
+		{hasher: hasher2, hash: hash1, rehash: true},
+	}
+	for i, testCase := range testCases {
+		ok, rehash, err := testCase.hasher.Verify("secret", testCase.hash)
+		assert.NoError(t, err, i)
+		assert.Equal(t, true, ok, i)
+		assert.Equal(t, testCase.rehash, rehash, i)
+		ok, _, err = testCase.hasher.Verify("secret2", testCase.hash)
+		assert.NoError(t, err, i)
+		assert.Equal(t, false, ok, i)
+	}
+
+	_, err = passwords.LoadPeppers(passwords.PepperConfig{Current: "3", Path: path})
+	assert.ErrorIs(t, err, passwords.ErrBadPepper)
+	_, err = passwords.LoadPeppers(passwords.PepperConfig{Current: "short", Keys: "short:c2hvcnQ="})
+	assert.ErrorIs(t, err, passwords.ErrBadPepper)
+	peppers, err := passwords.LoadPeppers(passwords.PepperConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, peppers)
+}
+
+func TestLegacyHashes(t *testing.T) {
+	//hashes made by python hashlib the way Django does
+	testCases := []struct {
+		algorithm string
+		hash      string
+		salt      string
+		stored    string
+	}{
+		{algorithm: passwords.DjangoPBKDF2SHA256, hash: "pbkdf2_sha256$1000$seasalt$R1/GfWtwog1T8Ev9VndgDjzkiRzbFr8JpmJtL9cMmQU="},
+		{algorithm: passwords.DjangoScrypt, hash: "scrypt$16384$seasalt$8$1$aw3bUxWFC3Xfheg2aovvLtnT0Y/ygxtIQOGecmEip41obMVVDU79jbPvHz9Arhl5DJSOPtnhOicOs9M/j7Q8oQ=="},
+		{algorithm: passwords.SaltedSHA1, hash: "sha1$seasalt$fec3530984afba6bade3347b7140d1a7da7da8c7"},
+		{algorithm: passwords.SaltedSHA1, hash: "FEC3530984AFBA6BADE3347B7140D1A7DA7DA8C7", salt: "seasalt",
+			stored: "sha1$seasalt$fec3530984afba6bade3347b7140d1a7da7da8c7"},
+		{algorithm: passwords.Bcrypt, hash: "$2a$04$tLuPagOw4RvPriKLE5Rr5eTzuJ1/w2eAUVxAegIcJm7EJ2E4IgyY."},
+	}
+	hasher := passwords.Default()
+	for _, testCase := range testCases {
+		stored, err := passwords.ImportHash(testCase.algorithm, testCase.hash, testCase.salt)
+		require.NoError(t, err, testCase.algorithm)
+		if testCase.stored == "" {
+			testCase.stored = testCase.hash
+		}
+		assert.Equal(t, testCase.stored, stored)
+
+		ok, rehash, err := hasher.Verify("letmein", stored)
+		assert.NoError(t, err, testCase.algorithm)
+		assert.Equal(t, true, ok, testCase.algorithm)
+		assert.Equal(t, true, rehash, testCase.algorithm)
+		ok, _, err = hasher.Verify("letmein2", stored)
+		assert.NoError(t, err, testCase.algorithm)
+		assert.Equal(t, false, ok, testCase.algorithm)
+	}
+
+	_, err := passwords.ImportHash("md5", "1a1dc91c907325c69271ddf0c944bc72", "")
+	assert.ErrorIs(t, err, passwords.ErrUnknownAlgorithm)
+	_, err = passwords.ImportHash(passwords.DjangoPBKDF2SHA256, "sha1$seasalt$fec3530984afba6bade3347b7140d1a7da7da8c7", "")
+	assert.ErrorIs(t, err, passwords.ErrBadHash)
+	_, err = passwords.ImportHash(passwords.SaltedSHA1, "sha1$seasalt$xyz", "")
+	assert.ErrorIs(t, err, passwords.ErrBadHash)
+	_, _, err = hasher.Verify("letmein", "scrypt$1073741824$seasalt$8$1$aGFzaA==")
+	assert.ErrorIs(t, err, passwords.ErrBadHash)
+
+	//costs which would be rejected at login are rejected at import: N up to the limit
+	//with big r and p needs gigabytes, N must be a power of two, iterations and bcrypt cost are bounded
+	for _, hash := range []string{
+		"scrypt$1048576$seasalt$64$64$aGFzaA==",
+		"scrypt$65536$seasalt$8$2$aGFzaA==",
+		"scrypt$16383$seasalt$8$1$aGFzaA==",
+		"scrypt$1073741824$seasalt$8$1$aGFzaA==",
+	} {
+		_, err = passwords.ImportHash(passwords.DjangoScrypt, hash, "")
+		assert.ErrorIs(t, err, passwords.ErrBadHash, hash)
+		_, _, err = hasher.Verify("letmein", hash)
+		assert.ErrorIs(t, err, passwords.ErrBadHash, hash)
+	}
+	_, err = passwords.ImportHash(passwords.DjangoScrypt, "scrypt$65536$seasalt$8$1$aGFzaA==", "")
+	assert.NoError(t, err)
+	_, err = passwords.ImportHash(passwords.DjangoPBKDF2SHA256, "pbkdf2_sha256$100000000$seasalt$aGFzaA==", "")
+	assert.ErrorIs(t, err, passwords.ErrBadHash)
+	_, err = passwords.ImportHash(passwords.Bcrypt, "$2a$31$tLuPagOw4RvPriKLE5Rr5eTzuJ1/w2eAUVxAegIcJm7EJ2E4IgyY.", "")
+	assert.ErrorIs(t, err, passwords.ErrBadHash)
+}
@@ -0,0 +1,124 @@
+package passwords
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// pepperPrefix starts hash made of peppered password: $pepper$v=VERSION$<hash of the algorithm>
+const pepperPrefix = "$pepper$v="
+
+const minPepperSize = 32
+
+var (
+	ErrUnknownPepper = errors.New("pepper of password hash is not loaded")
+	ErrBadPepper     = errors.New("bad password pepper")
+)
+
+// PepperConfig tells where pepper keys are. Path is yaml file of version: base64 key,
+// Keys are "version:base64 key" pairs separated by commas, they come from env.
+// Current is version new hashes are made with, no pepper is used when it is empty
+type PepperConfig struct {
+	Current string `yaml:"current"`
+	Path    string `yaml:"path"`
+	Keys    string `yaml:"-"`
+}
+
+// Peppers are versioned HMAC keys applied to passwords before hashing.
+// They are kept apart from hashes, so leaked hashes can't be cracked without them.
+// Old versions are kept to verify hashes which are not rehashed yet
+type Peppers struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewPeppers checks that current key is present and keys are at least 32 bytes
+func NewPeppers(current string, keys map[string][]byte) (*Peppers, error) {
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("%w: no key of current version %q", ErrBadPepper, current)
+	}
+	for version, key := range keys {
+		if version == "" || strings.Contains(version, "$") {
+			return nil, fmt.Errorf("%w: bad version %q", ErrBadPepper, version)
+		}
+		if len(key) < minPepperSize {
+			return nil, fmt.Errorf("%w: key %q is shorter than %d bytes", ErrBadPepper, version, minPepperSize)
+		}
+	}
+	return &Peppers{current: current, keys: keys}, nil
+}
+
+// LoadPeppers reads keys from cfg.Path and cfg.Keys, the latter win.
+// It returns nil peppers when cfg.Current is empty
+func LoadPeppers(cfg PepperConfig) (*Peppers, error) {
+	if cfg.Current == "" {
+		return nil, nil
+	}
+	encoded := make(map[string]string)
+	if cfg.Path != "" {
+		data, err := os.ReadFile(filepath.Clean(cfg.Path))
+		if err != nil {
+			return nil, err
+		}
+		if err = yaml.Unmarshal(data, &encoded); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrBadPepper, err)
+		}
+	}
+	for _, pair := range strings.Split(cfg.Keys, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		version, key, found := strings.Cut(pair, ":")
+		if !found {
+			return nil, fmt.Errorf("%w: %q is not version:key", ErrBadPepper, version)
+		}
+		encoded[version] = key
+	}
+	keys := make(map[string][]byte, len(encoded))
+	for version, key := range encoded {
+		decoded, err := base64.StdEncoding.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: key %q is not base64", ErrBadPepper, version)
+		}
+		keys[version] = decoded
+	}
+	return NewPeppers(cfg.Current, keys)
+}
+
+// apply is HMAC-SHA256 of password with key of version, base64 encoded to be a printable password
+func (p *Peppers) apply(version, password string) ([]byte, error) {
+	if p == nil {
+		return nil, ErrUnknownPepper
+	}
+	key, ok := p.keys[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: version %q", ErrUnknownPepper, version)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(password))
+	sum := mac.Sum(nil)
+	peppered := make([]byte, base64.StdEncoding.EncodedLen(len(sum)))
+	base64.StdEncoding.Encode(peppered, sum)
+	return peppered, nil
+}
+
+// splitPeppered returns pepper version and hash of the algorithm if hash is peppered
+func splitPeppered(hash string) (version, inner string, ok bool) {
+	if !strings.HasPrefix(hash, pepperPrefix) {
+		return "", hash, false
+	}
+	rest := hash[len(pepperPrefix):]
+	i := strings.Index(rest, "$")
+	if i <= 0 {
+		return "", hash, false
+	}
+	return rest[:i], rest[i:], true
+}
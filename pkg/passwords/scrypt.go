@@ -0,0 +1,97 @@
+package passwords
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ScryptParams default to N=2^15, r=8, p=1. LogN is log2 of N
+type ScryptParams struct {
+	LogN int `yaml:"ln"`
+	R    int `yaml:"r"`
+	P    int `yaml:"p"`
+}
+
+const (
+	defaultScryptLogN = 15
+	defaultScryptR    = 8
+	defaultScryptP    = 1
+	maxScryptLogN     = 30
+)
+
+func (p ScryptParams) validate() error {
+	if p.LogN < 0 || p.LogN > maxScryptLogN || p.R < 0 || p.P < 0 {
+		return ErrBadParams
+	}
+	return nil
+}
+
+type scryptAlgorithm struct {
+	params ScryptParams
+}
+
+func NewScrypt(params ScryptParams) Algorithm {
+	if params.LogN == 0 {
+		params.LogN = defaultScryptLogN
+	}
+	if params.R == 0 {
+		params.R = defaultScryptR
+	}
+	if params.P == 0 {
+		params.P = defaultScryptP
+	}
+	return &scryptAlgorithm{params: params}
+}
+
+func (a *scryptAlgorithm) Name() string {
+	return Scrypt
+}
+
+func (a *scryptAlgorithm) Hash(password []byte) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+	p := a.params
+	hash, err := scrypt.Key(password, salt, 1<<p.LogN, p.R, p.P, keySize)
+	if err != nil {
+		return "", err
+	}
+	return (&phc{
+		id:     Scrypt,
+		params: map[string]int{"ln": p.LogN, "r": p.R, "p": p.P},
+		salt:   salt,
+		hash:   hash,
+	}).String(), nil
+}
+
+func (a *scryptAlgorithm) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$"+Scrypt+"$")
+}
+
+func (a *scryptAlgorithm) Verify(password []byte, hash string) (bool, error) {
+	p, err := parsePHC(Scrypt, hash)
+	if err != nil {
+		return false, err
+	}
+	logN := p.params["ln"]
+	if logN <= 0 || logN > maxScryptLogN {
+		return false, ErrBadHash
+	}
+	computed, err := scrypt.Key(password, p.salt, 1<<logN, p.params["r"], p.params["p"], len(p.hash))
+	if err != nil {
+		return false, ErrBadHash
+	}
+	return subtle.ConstantTimeCompare(computed, p.hash) == 1, nil
+}
+
+func (a *scryptAlgorithm) Outdated(hash string) bool {
+	p, err := parsePHC(Scrypt, hash)
+	if err != nil {
+		return true
+	}
+	return p.params["ln"] != a.params.LogN || p.params["r"] != a.params.R || p.params["p"] != a.params.P ||
+		len(p.hash) != keySize || len(p.salt) < saltSize
+}
@@ -0,0 +1,225 @@
+package tokens
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrTokenNotValidYet      = errors.New("token is not valid yet")
+	ErrTokenUsedBeforeIssued = errors.New("token used before issued")
+	ErrWrongIssuer           = errors.New("token is issued by another issuer")
+	ErrWrongAudience         = errors.New("token is issued for another audience")
+	ErrWrongTokenType        = errors.New("token has unexpected type")
+)
+
+// Claims is what we know about a valid token.
+// Subject is user login, AuthTime is when the user logged in and stays the same across refreshes.
+// AMR are methods the user logged in with and ACR is assurance level they give.
+// Family and Generation are set for refresh tokens only.
+// Custom keeps every claim which is not a field here
+type Claims struct {
+	ID         string
+	Issuer     string
+	Audience   []string
+	Subject    string
+	UserID     string
+	TokenType  string
+	Roles      []string
+	IssuedAt   time.Time
+	NotBefore  time.Time
+	ExpiresAt  time.Time
+	AuthTime   time.Time
+	AMR        []string
+	ACR        string
+	Family     string
+	Generation int
+	Custom     map[string]interface{}
+}
+
+func (c *Claims) HasRole(role string) bool {
+	return contains(c.Roles, role)
+}
+
+func (c *Claims) HasAudience(audience string) bool {
+	return contains(c.Audience, audience)
+}
+
+// ValidationOptions are checked against claims of every token. Empty values are not checked.
+// Leeway is allowed clock skew for exp, nbf and iat
+type ValidationOptions struct {
+	Issuer    string
+	Audience  string
+	TokenType string
+	Leeway    time.Duration
+}
+
+// Check validates time claims at now and then claims which are set in options
+func (o ValidationOptions) Check(claims *Claims, now time.Time) error {
+	switch {
+	case claims.ExpiresAt.Before(now.Add(-o.Leeway)):
+		return ErrTokenExpired
+	case claims.NotBefore.After(now.Add(o.Leeway)):
+		return ErrTokenNotValidYet
+	case claims.IssuedAt.After(now.Add(o.Leeway)):
+		return ErrTokenUsedBeforeIssued
+	case o.Issuer != "" && claims.Issuer != o.Issuer:
+		return ErrWrongIssuer
+	case o.Audience != "" && !claims.HasAudience(o.Audience):
+		return ErrWrongAudience
+	case o.TokenType != "" && claims.TokenType != o.TokenType:
+		return ErrWrongTokenType
+	}
+	return nil
+}
+
+// registeredClaims are claim names taken by tokenClaims fields
+var registeredClaims = []string{"jti", "iss", "aud", "sub", "uid", "typ", "roles", "iat", "nbf", "exp", "auth_time", "amr", "acr", "fam", "gen"}
+
+// tokenClaims is a payload of our jwt
+type tokenClaims struct {
+	ID         string   `json:"jti"`
+	Issuer     string   `json:"iss,omitempty"`
+	Audience   audience `json:"aud,omitempty"`
+	Subject    string   `json:"sub"`
+	UserID     string   `json:"uid,omitempty"`
+	TokenType  string   `json:"typ,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+	IssuedAt   int64    `json:"iat,omitempty"`
+	NotBefore  int64    `json:"nbf,omitempty"`
+	ExpiresAt  int64    `json:"exp"`
+	AuthTime   int64    `json:"auth_time,omitempty"`
+	AMR        []string `json:"amr,omitempty"`
+	ACR        string   `json:"acr,omitempty"`
+	Family     string   `json:"fam,omitempty"`
+	Generation int      `json:"gen,omitempty"`
+	custom     map[string]interface{}
+}
+
+func newTokenClaims(c *Claims) *tokenClaims {
+	return &tokenClaims{
+		ID:         c.ID,
+		Issuer:     c.Issuer,
+		Audience:   c.Audience,
+		Subject:    c.Subject,
+		UserID:     c.UserID,
+		TokenType:  c.TokenType,
+		Roles:      c.Roles,
+		IssuedAt:   unix(c.IssuedAt),
+		NotBefore:  unix(c.NotBefore),
+		ExpiresAt:  unix(c.ExpiresAt),
+		AuthTime:   unix(c.AuthTime),
+		AMR:        c.AMR,
+		ACR:        c.ACR,
+		Family:     c.Family,
+		Generation: c.Generation,
+		custom:     c.Custom,
+	}
+}
+
+func (t *tokenClaims) claims() *Claims {
+	return &Claims{
+		ID:         t.ID,
+		Issuer:     t.Issuer,
+		Audience:   t.Audience,
+		Subject:    t.Subject,
+		UserID:     t.UserID,
+		TokenType:  t.TokenType,
+		Roles:      t.Roles,
+		IssuedAt:   fromUnix(t.IssuedAt),
+		NotBefore:  fromUnix(t.NotBefore),
+		ExpiresAt:  fromUnix(t.ExpiresAt),
+		AuthTime:   fromUnix(t.AuthTime),
+		AMR:        t.AMR,
+		ACR:        t.ACR,
+		Family:     t.Family,
+		Generation: t.Generation,
+		Custom:     t.custom,
+	}
+}
+
+// Valid is called by jwt parser after signature check. Time claims are checked
+// later by ValidationOptions.Check, which knows allowed leeway
+func (t *tokenClaims) Valid() error {
+	return nil
+}
+
+// tokenClaimsJSON has no methods, so it is marshaled as a plain struct
+type tokenClaimsJSON tokenClaims
+
+func (t *tokenClaims) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal((*tokenClaimsJSON)(t))
+	if err != nil || len(t.custom) == 0 {
+		return data, err
+	}
+	payload := make(map[string]interface{}, len(t.custom)+len(registeredClaims))
+	for name, value := range t.custom {
+		payload[name] = value
+	}
+	// registered claims win over custom ones with the same name
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	return json.Marshal(payload)
+}
+
+func (t *tokenClaims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*tokenClaimsJSON)(t)); err != nil {
+		return err
+	}
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return err
+	}
+	for _, name := range registeredClaims {
+		delete(payload, name)
+	}
+	t.custom = nil
+	if len(payload) > 0 {
+		t.custom = payload
+	}
+	return nil
+}
+
+// audience is aud claim which is either a string or an array of strings
+type audience []string
+
+func (a audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,55 @@
+package tokens_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaims(t *testing.T) {
+	key, err := tokens.NewHMACKey("secret")
+	require.NoError(t, err)
+	keys, err := tokens.NewKeyring(time.Hour, key)
+	require.NoError(t, err)
+
+	claims := &tokens.Claims{
+		Issuer:    "auth",
+		Audience:  []string{"orders", "payments"},
+		Subject:   "admin",
+		UserID:    "42",
+		TokenType: "access",
+		Roles:     []string{"admin"},
+		AuthTime:  time.Now().Add(-time.Hour).Truncate(time.Second),
+		AMR:       []string{"pwd", "otp", "mfa"},
+		ACR:       "aal2",
+		Custom:    map[string]interface{}{"tenant": "team31", "level": float64(2)},
+	}
+	token, err := tokens.CreateToken(claims, keys, time.Minute)
+	require.NoError(t, err)
+	parsed, err := tokens.ValidateToken(token, keys, tokens.ValidationOptions{Issuer: "auth", Audience: "payments"})
+	require.NoError(t, err)
+	assert.Equal(t, claims, parsed)
+
+	_, err = tokens.ValidateToken(token, keys, tokens.ValidationOptions{Issuer: "auth", Audience: "users"})
+	assert.ErrorIs(t, err, tokens.ErrWrongAudience)
+	_, err = tokens.ValidateToken(token, keys, tokens.ValidationOptions{Issuer: "other"})
+	assert.ErrorIs(t, err, tokens.ErrWrongIssuer)
+
+	//small clock skew is tolerated with leeway
+	expired, err := tokens.CreateToken(&tokens.Claims{Subject: "admin"}, keys, -5*time.Second)
+	require.NoError(t, err)
+	_, err = tokens.ValidateToken(expired, keys, tokens.ValidationOptions{})
+	assert.ErrorIs(t, err, tokens.ErrTokenExpired)
+	_, err = tokens.ValidateToken(expired, keys, tokens.ValidationOptions{Leeway: 10 * time.Second})
+	assert.NoError(t, err)
+	future := &tokens.Claims{Subject: "admin", NotBefore: time.Now().Add(5 * time.Second), ExpiresAt: time.Now().Add(time.Minute)}
+	assert.ErrorIs(t, tokens.ValidationOptions{}.Check(future, time.Now()), tokens.ErrTokenNotValidYet)
+	assert.NoError(t, tokens.ValidationOptions{Leeway: 10 * time.Second}.Check(future, time.Now()))
+
+	_, err = tokens.CreateToken(&tokens.Claims{}, keys, time.Minute)
+	assert.ErrorIs(t, err, tokens.ErrNoLoginTokenCreation)
+}
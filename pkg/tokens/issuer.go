@@ -0,0 +1,28 @@
+package tokens
+
+import (
+	"context"
+	"time"
+)
+
+// JWTIssuer issues self-contained tokens signed with keys from keyring
+type JWTIssuer struct {
+	keys *Keyring
+}
+
+func NewJWTIssuer(keys *Keyring) *JWTIssuer {
+	return &JWTIssuer{keys: keys}
+}
+
+func (i *JWTIssuer) Issue(ctx context.Context, claims *Claims, dur time.Duration) (string, error) {
+	return CreateToken(claims, i.keys, dur)
+}
+
+func (i *JWTIssuer) Verify(ctx context.Context, tokenStr string, opts ValidationOptions) (*Claims, error) {
+	return ValidateToken(tokenStr, i.keys, opts)
+}
+
+// Revoke can't invalidate signed token, so its jti should go to revocation list
+func (i *JWTIssuer) Revoke(ctx context.Context, tokenStr string) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,263 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type KeyStatus string
+
+const (
+	// KeyActive signs tokens since ActivatesAt. Before that it is only published.
+	// Once a newer active key activates, the older one is retired automatically
+	KeyActive KeyStatus = "active"
+	// KeyVerifyOnly never signs but still verifies
+	KeyVerifyOnly KeyStatus = "verify-only"
+	// KeyRetired verifies tokens signed before RetiredAt until they all expire.
+	// Key retired without RetiredAt is taken as retired when it is loaded
+	KeyRetired KeyStatus = "retired"
+)
+
+// DefaultKeyID is given to a key created without id
+const DefaultKeyID = "default"
+
+var (
+	ErrNoSigningKey   = errors.New("no active signing key")
+	ErrUnknownKeyID   = errors.New("unknown or retired kid")
+	ErrNoKeyID        = errors.New("token has no kid header")
+	ErrDuplicateKeyID = errors.New("duplicate kid in keyring")
+	ErrKeyWithoutID   = errors.New("keyring key has no id")
+	ErrNoKeyringFile  = errors.New("keyring is not loaded from file")
+)
+
+// Keyring keeps signing keys during rotation.
+// Tokens are signed with the newest activated key and verified with the key from their kid header.
+// Retired keys verify tokens for maxTokenTTL more and then drop out
+type Keyring struct {
+	mu          sync.RWMutex
+	keys        []*Key
+	maxTokenTTL time.Duration
+	path        string
+}
+
+// KeyInfo is public description of a key in keyring
+type KeyInfo struct {
+	ID          string    `json:"id"`
+	Alg         string    `json:"alg"`
+	Status      KeyStatus `json:"status"`
+	ActivatesAt time.Time `json:"activates_at,omitempty"`
+	RetiredAt   time.Time `json:"retired_at,omitempty"`
+}
+
+// NewKeyring creates keyring which is not backed by a file.
+// Keys without status are active, a key without id gets DefaultKeyID
+func NewKeyring(maxTokenTTL time.Duration, keys ...*Key) (*Keyring, error) {
+	r := &Keyring{maxTokenTTL: maxTokenTTL}
+	if err := r.setKeys(keys); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+type keyringFile struct {
+	Keys []struct {
+		ID             string    `yaml:"id"`
+		Algorithm      string    `yaml:"algorithm"`
+		Secret         string    `yaml:"secret"`
+		PrivateKeyPath string    `yaml:"private_key_path"`
+		Status         KeyStatus `yaml:"status"`
+		ActivatesAt    time.Time `yaml:"activates_at"`
+		RetiredAt      time.Time `yaml:"retired_at"`
+	} `yaml:"keys"`
+}
+
+// LoadKeyring reads keys from yaml file. Call Reload after the file is changed
+func LoadKeyring(path string, maxTokenTTL time.Duration) (*Keyring, error) {
+	r := &Keyring{maxTokenTTL: maxTokenTTL, path: path}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload replaces keys with the ones from keyring file. Keys are kept if the file is broken
+func (r *Keyring) Reload() error {
+	if r.path == "" {
+		return ErrNoKeyringFile
+	}
+	data, err := os.ReadFile(filepath.Clean(r.path))
+	if err != nil {
+		return err
+	}
+	var file keyringFile
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	keys := make([]*Key, 0, len(file.Keys))
+	for _, k := range file.Keys {
+		key, err := NewKey(k.Algorithm, k.Secret, k.PrivateKeyPath)
+		if err != nil {
+			return fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		key.ID, key.Status, key.ActivatesAt, key.RetiredAt = k.ID, k.Status, k.ActivatesAt, k.RetiredAt
+		keys = append(keys, key)
+	}
+	return r.setKeys(keys)
+}
+
+func (r *Keyring) setKeys(keys []*Key) error {
+	ids := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" && r.path == "" {
+			key.ID = DefaultKeyID
+		}
+		if key.ID == "" {
+			return ErrKeyWithoutID
+		}
+		if ids[key.ID] {
+			return fmt.Errorf("%w: %s", ErrDuplicateKeyID, key.ID)
+		}
+		ids[key.ID] = true
+		if key.Status == "" {
+			key.Status = KeyActive
+		}
+		switch key.Status {
+		case KeyActive, KeyVerifyOnly, KeyRetired:
+		default:
+			return fmt.Errorf("key %s: unknown status %q", key.ID, key.Status)
+		}
+	}
+	sorted := append([]*Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActivatesAt.After(sorted[j].ActivatesAt)
+	})
+	r.mu.Lock()
+	r.retireNow(sorted, time.Now())
+	r.keys = sorted
+	r.mu.Unlock()
+	return nil
+}
+
+// retireNow gives retired keys without RetiredAt the time they were first loaded retired,
+// so tokens signed with them keep verifying for maxTokenTTL. Reload keeps the time of previous load
+func (r *Keyring) retireNow(keys []*Key, now time.Time) {
+	for _, key := range keys {
+		if key.Status != KeyRetired || !key.RetiredAt.IsZero() {
+			continue
+		}
+		key.RetiredAt = now
+		for _, loaded := range r.keys {
+			if loaded.ID == key.ID && loaded.Status == KeyRetired {
+				key.RetiredAt = loaded.RetiredAt
+			}
+		}
+	}
+}
+
+// SigningKey is the active key with the latest ActivatesAt not after now
+func (r *Keyring) SigningKey(now time.Time) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.Status == KeyActive && !key.ActivatesAt.After(now) {
+			return key, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// hasAlg tells if keyring has a key of alg
+func (r *Keyring) hasAlg(alg string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.Alg() == alg {
+			return true
+		}
+	}
+	return false
+}
+
+// VerificationKey returns key by kid unless it dropped out of keyring
+func (r *Keyring) VerificationKey(kid string, now time.Time) (*Key, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.ID == kid {
+			if r.dropped(key, now) {
+				break
+			}
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKeyID
+}
+
+// Keys describes every key still in use with its effective status
+func (r *Keyring) Keys(now time.Time) []KeyInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]KeyInfo, 0, len(r.keys))
+	for _, key := range r.keys {
+		if r.dropped(key, now) {
+			continue
+		}
+		status, retiredAt := r.effectiveStatus(key, now)
+		infos = append(infos, KeyInfo{
+			ID:          key.ID,
+			Alg:         key.Alg(),
+			Status:      status,
+			ActivatesAt: key.ActivatesAt,
+			RetiredAt:   retiredAt,
+		})
+	}
+	return infos
+}
+
+// JWKS publishes public keys of every key still in use, including not yet activated ones
+func (r *Keyring) JWKS(now time.Time) JWKSet {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range r.keys {
+		if r.dropped(key, now) {
+			continue
+		}
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// effectiveStatus retires active key superseded by newer activated one. r.keys is sorted by ActivatesAt desc
+func (r *Keyring) effectiveStatus(key *Key, now time.Time) (KeyStatus, time.Time) {
+	if key.Status != KeyActive || key.ActivatesAt.After(now) {
+		return key.Status, key.RetiredAt
+	}
+	var supersededAt time.Time
+	for _, newer := range r.keys {
+		if newer == key {
+			break
+		}
+		if newer.Status == KeyActive && !newer.ActivatesAt.After(now) {
+			supersededAt = newer.ActivatesAt
+		}
+	}
+	if supersededAt.IsZero() {
+		return KeyActive, time.Time{}
+	}
+	return KeyRetired, supersededAt
+}
+
+func (r *Keyring) dropped(key *Key, now time.Time) bool {
+	status, retiredAt := r.effectiveStatus(key, now)
+	return status == KeyRetired && retiredAt.Add(r.maxTokenTTL).Before(now)
+}
@@ -0,0 +1,158 @@
+package tokens_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hmacKeyWithID(t *testing.T, id, secret string, status tokens.KeyStatus, activatesAt time.Time) *tokens.Key {
+	key, err := tokens.NewHMACKey(secret)
+	require.NoError(t, err)
+	key.ID, key.Status, key.ActivatesAt = id, status, activatesAt
+	return key
+}
+
+func TestKeyringRotation(t *testing.T) {
+	now := time.Now()
+	oldKey := hmacKeyWithID(t, "old", "old secret", tokens.KeyActive, now.Add(-48*time.Hour))
+	keys, err := tokens.NewKeyring(time.Hour, oldKey)
+	require.NoError(t, err)
+	oldToken, err := tokens.CreateToken(&tokens.Claims{Subject: "admin"}, keys, time.Minute)
+	require.NoError(t, err)
+
+	//new key is published before activation, old one still signs
+	newKey := hmacKeyWithID(t, "new", "new secret", tokens.KeyActive, now.Add(time.Hour))
+	keys, err = tokens.NewKeyring(time.Hour, oldKey, newKey)
+	require.NoError(t, err)
+	signing, err := keys.SigningKey(now)
+	require.NoError(t, err)
+	assert.Equal(t, "old", signing.ID)
+
+	//after activation new key signs and old one is retired but verifies
+	later := now.Add(90 * time.Minute)
+	signing, err = keys.SigningKey(later)
+	require.NoError(t, err)
+	assert.Equal(t, "new", signing.ID)
+	_, err = keys.VerificationKey("old", later)
+	assert.NoError(t, err)
+	infos := keys.Keys(later)
+	require.Len(t, infos, 2)
+	assert.Equal(t, tokens.KeyActive, infos[0].Status)
+	assert.Equal(t, tokens.KeyRetired, infos[1].Status)
+	assert.Equal(t, newKey.ActivatesAt, infos[1].RetiredAt)
+
+	//old key drops out when its tokens are expired
+	_, err = keys.VerificationKey("old", now.Add(3*time.Hour))
+	assert.ErrorIs(t, err, tokens.ErrUnknownKeyID)
+	assert.Len(t, keys.Keys(now.Add(3*time.Hour)), 1)
+
+	claims, err := tokens.ValidateToken(oldToken, keys, tokens.ValidationOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", claims.Subject)
+
+	//token of unknown key is rejected
+	otherKeys, err := tokens.NewKeyring(time.Hour, newKey)
+	require.NoError(t, err)
+	_, err = tokens.ValidateToken(oldToken, otherKeys, tokens.ValidationOptions{})
+	assert.ErrorIs(t, err, tokens.ErrUnknownKeyID)
+
+	//verify-only key never signs
+	verifyOnly := hmacKeyWithID(t, "verify", "secret", tokens.KeyVerifyOnly, time.Time{})
+	keys, err = tokens.NewKeyring(time.Hour, verifyOnly)
+	require.NoError(t, err)
+	_, err = tokens.CreateToken(&tokens.Claims{Subject: "admin"}, keys, time.Minute)
+	assert.ErrorIs(t, err, tokens.ErrNoSigningKey)
+
+	_, err = tokens.NewKeyring(time.Hour, oldKey, oldKey)
+	assert.ErrorIs(t, err, tokens.ErrDuplicateKeyID)
+}
+
+func TestKeyringReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyring.yaml")
+	writeKeyring := func(body string) {
+		require.NoError(t, os.WriteFile(path, []byte(body), 0600))
+	}
+	writeKeyring(`
+keys:
+  - id: first
+    algorithm: HS256
+    secret: first secret
+    status: active
+`)
+	keys, err := tokens.LoadKeyring(path, time.Hour)
+	require.NoError(t, err)
+	firstToken, err := tokens.CreateToken(&tokens.Claims{Subject: "admin"}, keys, time.Minute)
+	require.NoError(t, err)
+
+	writeKeyring(fmt.Sprintf(`
+keys:
+  - id: first
+    algorithm: HS256
+    secret: first secret
+    status: retired
+    retired_at: %s
+  - id: second
+    algorithm: HS256
+    secret: second secret
+    status: active
+`, time.Now().Format(time.RFC3339)))
+	require.NoError(t, keys.Reload())
+	signing, err := keys.SigningKey(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "second", signing.ID)
+	_, err = tokens.ValidateToken(firstToken, keys, tokens.ValidationOptions{})
+	assert.NoError(t, err)
+
+	//key retired without retired_at keeps verifying from the first load it was retired at
+	writeKeyring(`
+keys:
+  - id: first
+    algorithm: HS256
+    secret: first secret
+    status: retired
+  - id: second
+    algorithm: HS256
+    secret: second secret
+    status: active
+`)
+	require.NoError(t, keys.Reload())
+	_, err = tokens.ValidateToken(firstToken, keys, tokens.ValidationOptions{})
+	assert.NoError(t, err)
+	retiredAt := func() time.Time {
+		for _, info := range keys.Keys(time.Now()) {
+			if info.ID == "first" {
+				return info.RetiredAt
+			}
+		}
+		return time.Time{}
+	}
+	firstRetiredAt := retiredAt()
+	assert.False(t, firstRetiredAt.IsZero())
+	require.NoError(t, keys.Reload())
+	assert.Equal(t, firstRetiredAt, retiredAt())
+	fresh, err := tokens.LoadKeyring(path, time.Hour)
+	require.NoError(t, err)
+	_, err = tokens.ValidateToken(firstToken, fresh, tokens.ValidationOptions{})
+	assert.NoError(t, err)
+	_, err = keys.VerificationKey("first", firstRetiredAt.Add(2*time.Hour))
+	assert.ErrorIs(t, err, tokens.ErrUnknownKeyID)
+
+	//broken file keeps current keys
+	writeKeyring("keys: [")
+	assert.Error(t, keys.Reload())
+	signing, err = keys.SigningKey(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "second", signing.ID)
+
+	single, err := tokens.NewKeyring(time.Hour, hmacKeyWithID(t, "", "secret", "", time.Time{}))
+	require.NoError(t, err)
+	assert.ErrorIs(t, single.Reload(), tokens.ErrNoKeyringFile)
+}
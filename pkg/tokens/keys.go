@@ -0,0 +1,123 @@
+package tokens
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrNoPrivateKey         = errors.New("private key for token generation is not provided")
+	ErrBadPrivateKey        = errors.New("couldn't parse private key PEM")
+	ErrKeyAlgMismatch       = errors.New("private key doesn't match signing algorithm")
+)
+
+// Key signs tokens and verifies their signatures.
+// For HS256 both are done with the secret, otherwise with private/public key pair.
+// ID goes to kid header, the rest is used by Keyring
+type Key struct {
+	ID          string
+	Status      KeyStatus
+	ActivatesAt time.Time
+	RetiredAt   time.Time
+
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+func NewHMACKey(secret string) (*Key, error) {
+	if secret == "" {
+		return nil, ErrNoSecret
+	}
+	return &Key{
+		method:    jwt.SigningMethodHS256,
+		signKey:   []byte(secret),
+		verifyKey: []byte(secret),
+	}, nil
+}
+
+// NewKey makes signing key for alg. HS256 (default) uses secret,
+// RS256, ES256 and EdDSA load private key from PEM file
+func NewKey(alg, secret, privateKeyPath string) (*Key, error) {
+	if alg == "" || alg == AlgHS256 {
+		return NewHMACKey(secret)
+	}
+	if privateKeyPath == "" {
+		return nil, ErrNoPrivateKey
+	}
+	pemBytes, err := os.ReadFile(filepath.Clean(privateKeyPath))
+	if err != nil {
+		return nil, err
+	}
+	return ParsePrivateKey(alg, pemBytes)
+}
+
+// ParsePrivateKey accepts PKCS#8, PKCS#1 (RSA) and SEC 1 (EC) PEM blocks
+func ParsePrivateKey(alg string, pemBytes []byte) (*Key, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, ErrBadPrivateKey
+	}
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadPrivateKey, err)
+	}
+	switch alg {
+	case AlgRS256:
+		if k, ok := privateKey.(*rsa.PrivateKey); ok {
+			return &Key{method: jwt.SigningMethodRS256, signKey: k, verifyKey: &k.PublicKey}, nil
+		}
+	case AlgES256:
+		if k, ok := privateKey.(*ecdsa.PrivateKey); ok && k.Curve == elliptic.P256() {
+			return &Key{method: jwt.SigningMethodES256, signKey: k, verifyKey: &k.PublicKey}, nil
+		}
+	case AlgEdDSA:
+		if k, ok := privateKey.(ed25519.PrivateKey); ok {
+			return &Key{method: EdDSA, signKey: k, verifyKey: k.Public()}, nil
+		}
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	return nil, ErrKeyAlgMismatch
+}
+
+func (k *Key) Alg() string {
+	return k.method.Alg()
+}
+
+// PublicKey returns key for signature verification. It is nil for HS256
+func (k *Key) PublicKey() crypto.PublicKey {
+	if _, ok := k.method.(*jwt.SigningMethodHMAC); ok {
+		return nil
+	}
+	return k.verifyKey
+}
@@ -0,0 +1,85 @@
+package tokens_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePKCS8(t *testing.T, privateKey interface{}) string {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err = os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+	require.NoError(t, err)
+	return path
+}
+
+func TestAsymmetricKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		alg        string
+		privateKey interface{}
+		kty        string
+	}{
+		{alg: tokens.AlgRS256, privateKey: rsaKey, kty: "RSA"},
+		{alg: tokens.AlgES256, privateKey: ecKey, kty: "EC"},
+		{alg: tokens.AlgEdDSA, privateKey: edKey, kty: "OKP"},
+	}
+	hmacKey, err := tokens.NewHMACKey("secret")
+	require.NoError(t, err)
+	hmacKeys, err := tokens.NewKeyring(time.Hour, hmacKey)
+	require.NoError(t, err)
+	for _, testCase := range testCases {
+		key, err := tokens.NewKey(testCase.alg, "", writePKCS8(t, testCase.privateKey))
+		require.NoError(t, err, testCase.alg)
+		assert.Equal(t, testCase.alg, key.Alg())
+
+		keys, err := tokens.NewKeyring(time.Hour, key)
+		require.NoError(t, err, testCase.alg)
+		token, err := tokens.CreateToken(&tokens.Claims{Subject: "admin"}, keys, time.Minute)
+		require.NoError(t, err, testCase.alg)
+		claims, err := tokens.ValidateToken(token, keys, tokens.ValidationOptions{})
+		assert.NoError(t, err, testCase.alg)
+		assert.Equal(t, "admin", claims.Subject)
+
+		//token signed with another algorithm is rejected even if kid matches
+		_, err = tokens.ValidateToken(token, hmacKeys, tokens.ValidationOptions{})
+		assert.Error(t, err, testCase.alg)
+
+		jwk, ok := key.JWK()
+		assert.Equal(t, true, ok)
+		assert.Equal(t, testCase.kty, jwk.Kty)
+		assert.Equal(t, testCase.alg, jwk.Alg)
+		assert.Equal(t, "sig", jwk.Use)
+	}
+	_, ok := hmacKey.JWK()
+	assert.Equal(t, false, ok)
+
+	//key of other type than algorithm expects
+	_, err = tokens.NewKey(tokens.AlgES256, "", writePKCS8(t, rsaKey))
+	assert.ErrorIs(t, err, tokens.ErrKeyAlgMismatch)
+	_, err = tokens.NewKey(tokens.AlgRS256, "", "")
+	assert.ErrorIs(t, err, tokens.ErrNoPrivateKey)
+	_, err = tokens.NewKey("none", "", writePKCS8(t, rsaKey))
+	assert.ErrorIs(t, err, tokens.ErrUnsupportedAlgorithm)
+}
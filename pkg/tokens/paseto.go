@@ -0,0 +1,374 @@
+package tokens
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/chacha20"
+)
+
+// PASETO v4 purposes. They are also headers of tokens without trailing dot
+const (
+	PasetoPublic = "v4.public"
+	PasetoLocal  = "v4.local"
+)
+
+var (
+	ErrNotPaseto          = errors.New("token is not a paseto token of expected purpose")
+	ErrBadPasetoSignature = errors.New("paseto token signature is invalid")
+)
+
+const (
+	pasetoNonceSize = 32
+	pasetoMACSize   = 32
+)
+
+// pasetoTimeClaims are registered claims which paseto keeps as RFC 3339 strings
+var pasetoTimeClaims = []string{"iat", "nbf", "exp", "auth_time"}
+
+// pasetoFooter is unencrypted but authenticated part of token
+type pasetoFooter struct {
+	KeyID string `json:"kid"`
+}
+
+// PasetoIssuer issues PASETO v4 tokens with keys from keyring.
+// v4.public signs tokens with EdDSA keys, v4.local encrypts them with
+// a 32 byte key derived from HS256 secret. Key id goes to token footer
+type PasetoIssuer struct {
+	purpose string
+	keys    *Keyring
+}
+
+// NewPasetoIssuer makes issuer for PasetoPublic or PasetoLocal purpose.
+// keys must have a key of the purpose, EdDSA for PasetoPublic and HS256 for PasetoLocal
+func NewPasetoIssuer(purpose string, keys *Keyring) (*PasetoIssuer, error) {
+	alg := AlgEdDSA
+	switch purpose {
+	case PasetoPublic:
+	case PasetoLocal:
+		alg = AlgHS256
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if keys == nil {
+		return nil, ErrNoSecret
+	}
+	if !keys.hasAlg(alg) {
+		return nil, fmt.Errorf("%w: %s needs %s key", ErrUnexpectedSigningMethod, purpose, alg)
+	}
+	return &PasetoIssuer{purpose: purpose, keys: keys}, nil
+}
+
+// Issue sets jti, iat, nbf and exp like CreateToken does
+func (i *PasetoIssuer) Issue(ctx context.Context, claims *Claims, dur time.Duration) (string, error) {
+	switch {
+	case i.keys == nil:
+		return "", ErrNoSecret
+	case dur == 0:
+		return "", ErrZeroDuration
+	case claims == nil || claims.Subject == "":
+		return "", ErrNoLoginTokenCreation
+	}
+	key, err := i.keys.SigningKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().Truncate(time.Second)
+	claims.ID = uuid.NewV4().String()
+	claims.IssuedAt = now
+	claims.NotBefore = now
+	claims.ExpiresAt = now.Add(dur)
+	payload, err := marshalPasetoClaims(claims)
+	if err != nil {
+		return "", err
+	}
+	footer, err := json.Marshal(pasetoFooter{KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	if i.purpose == PasetoPublic {
+		return pasetoSign(key, payload, footer)
+	}
+	return pasetoEncrypt(key, payload, footer)
+}
+
+// Verify checks token with keyring key from its footer, then checks claims like ValidateToken does
+func (i *PasetoIssuer) Verify(ctx context.Context, tokenStr string, opts ValidationOptions) (*Claims, error) {
+	if i.keys == nil {
+		return nil, ErrNoSecret
+	}
+	header := i.purpose + "."
+	if !strings.HasPrefix(tokenStr, header) {
+		return nil, ErrNotPaseto
+	}
+	parts := strings.Split(tokenStr[len(header):], ".")
+	if len(parts) != 2 {
+		return nil, ErrNoKeyID
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrTokenCorrupted
+	}
+	footer, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenCorrupted
+	}
+	var f pasetoFooter
+	if err = json.Unmarshal(footer, &f); err != nil || f.KeyID == "" {
+		return nil, ErrNoKeyID
+	}
+	key, err := i.keys.VerificationKey(f.KeyID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	var payload []byte
+	if i.purpose == PasetoPublic {
+		payload, err = pasetoVerify(key, body, footer)
+	} else {
+		payload, err = pasetoDecrypt(key, body, footer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	claims, err := unmarshalPasetoClaims(payload)
+	if err != nil {
+		return nil, err
+	}
+	if err = opts.Check(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, ErrBadClaimsInToken
+	}
+	return claims, nil
+}
+
+// Revoke can't invalidate paseto token, so its jti should go to revocation list
+func (i *PasetoIssuer) Revoke(ctx context.Context, tokenStr string) (bool, error) {
+	return false, nil
+}
+
+// pasetoSign is v4.public sign operation without implicit assertion
+func pasetoSign(key *Key, payload, footer []byte) (string, error) {
+	privateKey, ok := key.signKey.(ed25519.PrivateKey)
+	if !ok {
+		return "", ErrUnexpectedSigningMethod
+	}
+	header := PasetoPublic + "."
+	sig := ed25519.Sign(privateKey, pae([]byte(header), payload, footer, nil))
+	return pasetoToken(header, append(payload, sig...), footer), nil
+}
+
+func pasetoVerify(key *Key, body, footer []byte) ([]byte, error) {
+	publicKey, ok := key.verifyKey.(ed25519.PublicKey)
+	if !ok {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	if len(body) < ed25519.SignatureSize {
+		return nil, ErrTokenCorrupted
+	}
+	payload, sig := body[:len(body)-ed25519.SignatureSize], body[len(body)-ed25519.SignatureSize:]
+	if !ed25519.Verify(publicKey, pae([]byte(PasetoPublic+"."), payload, footer, nil), sig) {
+		return nil, ErrBadPasetoSignature
+	}
+	return payload, nil
+}
+
+// pasetoEncrypt is v4.local encrypt operation without implicit assertion
+func pasetoEncrypt(key *Key, payload, footer []byte) (string, error) {
+	secret, err := localKey(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, pasetoNonceSize)
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return pasetoEncryptWithNonce(secret, nonce, payload, footer)
+}
+
+func pasetoEncryptWithNonce(secret, nonce, payload, footer []byte) (string, error) {
+	encKey, encNonce, authKey, err := pasetoSplitKey(secret, nonce)
+	if err != nil {
+		return "", err
+	}
+	cipher, err := chacha20.NewUnauthenticatedCipher(encKey, encNonce)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(payload))
+	cipher.XORKeyStream(ciphertext, payload)
+	header := PasetoLocal + "."
+	mac, err := pasetoMAC(authKey, header, nonce, ciphertext, footer)
+	if err != nil {
+		return "", err
+	}
+	body := make([]byte, 0, len(nonce)+len(ciphertext)+len(mac))
+	body = append(append(append(body, nonce...), ciphertext...), mac...)
+	return pasetoToken(header, body, footer), nil
+}
+
+func pasetoDecrypt(key *Key, body, footer []byte) ([]byte, error) {
+	secret, err := localKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pasetoDecryptWithSecret(secret, body, footer)
+}
+
+func pasetoDecryptWithSecret(secret, body, footer []byte) ([]byte, error) {
+	if len(body) < pasetoNonceSize+pasetoMACSize {
+		return nil, ErrTokenCorrupted
+	}
+	nonce := body[:pasetoNonceSize]
+	ciphertext := body[pasetoNonceSize : len(body)-pasetoMACSize]
+	mac := body[len(body)-pasetoMACSize:]
+	encKey, encNonce, authKey, err := pasetoSplitKey(secret, nonce)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := pasetoMAC(authKey, PasetoLocal+".", nonce, ciphertext, footer)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(mac, expected) != 1 {
+		return nil, ErrBadPasetoSignature
+	}
+	cipher, err := chacha20.NewUnauthenticatedCipher(encKey, encNonce)
+	if err != nil {
+		return nil, err
+	}
+	payload := make([]byte, len(ciphertext))
+	cipher.XORKeyStream(payload, ciphertext)
+	return payload, nil
+}
+
+// localKey derives 32 byte v4.local key from HS256 secret
+func localKey(key *Key) ([]byte, error) {
+	secret, ok := key.signKey.([]byte)
+	if !ok || key.method != jwt.SigningMethodHS256 {
+		return nil, ErrUnexpectedSigningMethod
+	}
+	sum := blake2b.Sum256(secret)
+	return sum[:], nil
+}
+
+// pasetoSplitKey derives encryption key, XChaCha20 nonce and authentication key for token nonce
+func pasetoSplitKey(secret, nonce []byte) (encKey, encNonce, authKey []byte, err error) {
+	tmp, err := blake2bMAC(secret, 56, []byte("paseto-encryption-key"), nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	authKey, err = blake2bMAC(secret, 32, []byte("paseto-auth-key-for-aead"), nonce)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return tmp[:32], tmp[32:], authKey, nil
+}
+
+func pasetoMAC(authKey []byte, header string, nonce, ciphertext, footer []byte) ([]byte, error) {
+	return blake2bMAC(authKey, pasetoMACSize, pae([]byte(header), nonce, ciphertext, footer, nil))
+}
+
+func blake2bMAC(key []byte, size int, parts ...[]byte) ([]byte, error) {
+	h, err := blake2b.New(size, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range parts {
+		h.Write(p)
+	}
+	return h.Sum(nil), nil
+}
+
+func pasetoToken(header string, body, footer []byte) string {
+	token := header + base64.RawURLEncoding.EncodeToString(body)
+	if len(footer) == 0 {
+		return token
+	}
+	return token + "." + base64.RawURLEncoding.EncodeToString(footer)
+}
+
+// pae is paseto pre-authentication encoding of pieces
+func pae(pieces ...[]byte) []byte {
+	var buf bytes.Buffer
+	le64 := func(n int) {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(n)&(1<<63-1))
+		buf.Write(b[:])
+	}
+	le64(len(pieces))
+	for _, p := range pieces {
+		le64(len(p))
+		buf.Write(p)
+	}
+	return buf.Bytes()
+}
+
+// marshalPasetoClaims encodes claims as jwt payload with time claims in RFC 3339
+func marshalPasetoClaims(claims *Claims) ([]byte, error) {
+	data, err := json.Marshal(newTokenClaims(claims))
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]interface{}
+	if err = json.Unmarshal(data, &payload); err != nil {
+		return nil, err
+	}
+	times := map[string]time.Time{
+		"iat":       claims.IssuedAt,
+		"nbf":       claims.NotBefore,
+		"exp":       claims.ExpiresAt,
+		"auth_time": claims.AuthTime,
+	}
+	for name, t := range times {
+		if !t.IsZero() {
+			payload[name] = t.UTC().Format(time.RFC3339)
+		}
+	}
+	return json.Marshal(payload)
+}
+
+func unmarshalPasetoClaims(data []byte) (*Claims, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, ErrBadClaimsInToken
+	}
+	for _, name := range pasetoTimeClaims {
+		value, ok := payload[name]
+		if !ok {
+			continue
+		}
+		s, ok := value.(string)
+		if !ok {
+			return nil, ErrBadClaimsInToken
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, ErrBadClaimsInToken
+		}
+		payload[name] = t.Unix()
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	var t tokenClaims
+	if err = json.Unmarshal(data, &t); err != nil {
+		return nil, ErrBadClaimsInToken
+	}
+	return t.claims(), nil
+}
@@ -0,0 +1,99 @@
+package tokens_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMA8/authService/pkg/tokens"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasetoIssuer(t *testing.T) {
+	ctx := context.Background()
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	publicKey, err := tokens.NewKey(tokens.AlgEdDSA, "", writePKCS8(t, edKey))
+	require.NoError(t, err)
+	publicKeys, err := tokens.NewKeyring(time.Hour, publicKey)
+	require.NoError(t, err)
+	localKeys, err := tokens.NewKeyring(time.Hour, hmacKeyWithID(t, "local", "secret", tokens.KeyActive, time.Time{}))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		purpose string
+		keys    *tokens.Keyring
+	}{
+		{purpose: tokens.PasetoPublic, keys: publicKeys},
+		{purpose: tokens.PasetoLocal, keys: localKeys},
+	}
+	for _, testCase := range testCases {
+		issuer, err := tokens.NewPasetoIssuer(testCase.purpose, testCase.keys)
+		require.NoError(t, err)
+		claims := &tokens.Claims{
+			Subject:   "admin",
+			Issuer:    "auth",
+			Audience:  []string{"team31"},
+			TokenType: "access",
+			Roles:     []string{"admin"},
+			Custom:    map[string]interface{}{"tenant": "t1"},
+		}
+		token, err := issuer.Issue(ctx, claims, time.Minute)
+		require.NoError(t, err, testCase.purpose)
+		assert.True(t, strings.HasPrefix(token, testCase.purpose+"."), testCase.purpose)
+		if testCase.purpose == tokens.PasetoLocal {
+			assert.False(t, strings.HasPrefix(strings.Split(token, ".")[2], "eyJ"), "local token payload is encrypted")
+		}
+
+		parsed, err := issuer.Verify(ctx, token, tokens.ValidationOptions{Issuer: "auth", Audience: "team31", TokenType: "access"})
+		require.NoError(t, err, testCase.purpose)
+		assert.Equal(t, claims, parsed, testCase.purpose)
+
+		_, err = issuer.Verify(ctx, token, tokens.ValidationOptions{TokenType: "refresh"})
+		assert.ErrorIs(t, err, tokens.ErrWrongTokenType, testCase.purpose)
+
+		//any changed byte breaks signature or mac
+		body := strings.Split(token, ".")[2]
+		i := len(body) / 2
+		flipped := byte('A')
+		if body[i] == 'A' {
+			flipped = 'B'
+		}
+		tampered := strings.Replace(token, body, body[:i]+string(flipped)+body[i+1:], 1)
+		_, err = issuer.Verify(ctx, tampered, tokens.ValidationOptions{})
+		assert.Error(t, err, testCase.purpose)
+
+		//jwt and tokens of another purpose are not accepted
+		jwtToken, err := tokens.CreateToken(&tokens.Claims{Subject: "admin"}, testCase.keys, time.Minute)
+		require.NoError(t, err)
+		_, err = issuer.Verify(ctx, jwtToken, tokens.ValidationOptions{})
+		assert.ErrorIs(t, err, tokens.ErrNotPaseto, testCase.purpose)
+
+		expired, err := issuer.Issue(ctx, &tokens.Claims{Subject: "admin"}, -time.Minute)
+		require.NoError(t, err)
+		_, err = issuer.Verify(ctx, expired, tokens.ValidationOptions{})
+		assert.ErrorIs(t, err, tokens.ErrTokenExpired, testCase.purpose)
+	}
+
+	//keyring without key of the purpose is rejected right away
+	_, err = tokens.NewPasetoIssuer(tokens.PasetoPublic, localKeys)
+	assert.ErrorIs(t, err, tokens.ErrUnexpectedSigningMethod)
+	_, err = tokens.NewPasetoIssuer(tokens.PasetoLocal, publicKeys)
+	assert.ErrorIs(t, err, tokens.ErrUnexpectedSigningMethod)
+	//key of wrong type can't issue tokens
+	mixedKeys, err := tokens.NewKeyring(time.Hour, hmacKeyWithID(t, "local", "secret", tokens.KeyActive, time.Now()),
+		publicKey)
+	require.NoError(t, err)
+	mixedIssuer, err := tokens.NewPasetoIssuer(tokens.PasetoPublic, mixedKeys)
+	require.NoError(t, err)
+	_, err = mixedIssuer.Issue(ctx, &tokens.Claims{Subject: "admin"}, time.Minute)
+	assert.ErrorIs(t, err, tokens.ErrUnexpectedSigningMethod)
+
+	_, err = tokens.NewPasetoIssuer("v3.local", localKeys)
+	assert.ErrorIs(t, err, tokens.ErrUnsupportedAlgorithm)
+}
@@ -0,0 +1,116 @@
+package tokens
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// PASETO v4 test vectors without implicit assertion, github.com/paseto-standard/test-vectors
+const (
+	vectorLocalKey  = "707172737475767778797a7b7c7d7e7f808182838485868788898a8b8c8d8e8f"
+	vectorSecretKey = "b4cbfb43df4ce210727d953e4a713307fa19bb7d9f85041438d9e11b942a3774" +
+		"1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+	vectorPublicKey = "1eb9dbbbbc047c03fd70604e0071f0987e16b28b757225c11f00415d0e20b1a2"
+	vectorFooter    = `{"kid":"zVhMiPBP9fRf2snEcT7gFTioeA9COcNy9DfgL1W60haN"}`
+)
+
+func TestPasetoLocalVectors(t *testing.T) {
+	secret := decodeHex(t, vectorLocalKey)
+	testCases := []struct {
+		name    string
+		nonce   string
+		payload string
+		token   string
+	}{
+		{
+			name:    "4-E-1",
+			nonce:   "0000000000000000000000000000000000000000000000000000000000000000",
+			payload: `{"data":"this is a secret message","exp":"2022-01-01T00:00:00+00:00"}`,
+			token: "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvSwscFlAl1pk5HC0e8kApeaqMfGo_7Op" +
+				"BnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XJ5hOb_4v9RmDkneN0S92dx0OW4pgy7omxgf3S8c3LlQg",
+		},
+		{
+			name:    "4-E-2",
+			nonce:   "0000000000000000000000000000000000000000000000000000000000000000",
+			payload: `{"data":"this is a hidden message","exp":"2022-01-01T00:00:00+00:00"}`,
+			token: "v4.local.AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAQAr68PS4AXe7If_ZgesdkUMvS2csCgglvpk5HC0e8kApeaqMfGo_7Op" +
+				"BnwJOAbY9V7WU6abu74MmcUE8YWAiaArVI8XIemu9chy3WVKvRBfg6t8wwYHK0ArLxxfZP73W_vfwt5A",
+		},
+	}
+	for _, tc := range testCases {
+		token, err := pasetoEncryptWithNonce(secret, decodeHex(t, tc.nonce), []byte(tc.payload), nil)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.token, token, tc.name)
+		body, footer := splitVector(t, tc.token, PasetoLocal)
+		payload, err := pasetoDecryptWithSecret(secret, body, footer)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.payload, string(payload), tc.name)
+
+		//any changed byte breaks authentication
+		body[len(body)/2] ^= 1
+		_, err = pasetoDecryptWithSecret(secret, body, footer)
+		assert.ErrorIs(t, err, ErrBadPasetoSignature, tc.name)
+	}
+}
+
+func TestPasetoPublicVectors(t *testing.T) {
+	privateKey := ed25519.PrivateKey(decodeHex(t, vectorSecretKey))
+	publicKey := ed25519.PublicKey(decodeHex(t, vectorPublicKey))
+	require.Equal(t, publicKey, privateKey.Public())
+	key := &Key{signKey: privateKey, verifyKey: publicKey}
+	payload := `{"data":"this is a signed message","exp":"2022-01-01T00:00:00+00:00"}`
+	testCases := []struct {
+		name   string
+		footer string
+		token  string
+	}{
+		{
+			name: "4-S-1",
+			token: "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9" +
+				"bg_XBBzds8lTZShVlwwKSgeKpLT3yukTw6JUz3W4h_ExsQV-P0V54zemZDcAxFaSeef1QlXEFtkqxT1ciiQEDA",
+		},
+		{
+			name:   "4-S-2",
+			footer: vectorFooter,
+			token: "v4.public.eyJkYXRhIjoidGhpcyBpcyBhIHNpZ25lZCBtZXNzYWdlIiwiZXhwIjoiMjAyMi0wMS0wMVQwMDowMDowMCswMDowMCJ9" +
+				"v3Jt8mx_TdM2ceTGoqwrh4yDFn0XsHvvV_D0DtwQxVrJEBMl0F2caAdgnpKlt4p7xBnx1HcO-SPo8FPp214HDw" +
+				".eyJraWQiOiJ6VmhNaVBCUDlmUmYyc25FY1Q3Z0ZUaW9lQTlDT2NOeTlEZmdMMVc2MGhhTiJ9",
+		},
+	}
+	for _, tc := range testCases {
+		token, err := pasetoSign(key, []byte(payload), []byte(tc.footer))
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.token, token, tc.name)
+		body, footer := splitVector(t, tc.token, PasetoPublic)
+		verified, err := pasetoVerify(key, body, footer)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, payload, string(verified), tc.name)
+
+		_, err = pasetoVerify(key, body, []byte(`{"kid":"other"}`))
+		assert.ErrorIs(t, err, ErrBadPasetoSignature, tc.name)
+	}
+}
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	require.NoError(t, err)
+	return b
+}
+
+// splitVector decodes body and footer of token
+func splitVector(t *testing.T, token, header string) (body, footer []byte) {
+	parts := strings.Split(strings.TrimPrefix(token, header+"."), ".")
+	body, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	if len(parts) > 1 {
+		footer, err = base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+	}
+	return body, footer
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	uuid "github.com/satori/go.uuid"
 )
 
 var (
@@ -12,42 +13,80 @@ var (
 	ErrNoLoginTokenCreation    = errors.New("can not create token without login")
 	ErrZeroDuration            = errors.New("token should live more then 0")
 	ErrNoSecret                = errors.New("secret for token generation is not provided")
-	ErrUnexpectedSigningMethod = errors.New("unexpected signing method! shoud be jwt.SigningMethodHMAC")
+	ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
 	ErrBadClaimsInToken        = errors.New("error get user claims from token")
+	ErrTokenExpired            = errors.New("Token is expired")
 )
 
-func CreateToken(login, secret string, dur time.Duration) (string, error) {
+// CreateToken signs claims with the current signing key.
+// jti, iat, nbf and exp are set here and written back to claims
+func CreateToken(claims *Claims, keys *Keyring, dur time.Duration) (string, error) {
 	switch {
-	case secret == "":
+	case keys == nil:
 		return "", ErrNoSecret
 	case dur == 0:
 		return "", ErrZeroDuration
-	case login == "":
+	case claims == nil || claims.Subject == "":
 		return "", ErrNoLoginTokenCreation
 	}
-	tokenAccess := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
-		ExpiresAt: time.Now().Add(dur).Unix(),
-		Subject:   login,
-	})
-	return tokenAccess.SignedString([]byte(secret))
+	// token keeps seconds only
+	now := time.Now().Truncate(time.Second)
+	claims.ID = uuid.NewV4().String()
+	claims.IssuedAt = now
+	claims.NotBefore = now
+	claims.ExpiresAt = now.Add(dur)
+	return sign(keys, newTokenClaims(claims))
 }
 
-func ValidateToken(tokenStr, secret string) (string, error) {
-	token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+// ValidateToken checks token and returns its claims.
+// Token must be signed with the keyring key from its kid header using that key's algorithm,
+// have sub and jti, and match opts
+func ValidateToken(tokenStr string, keys *Keyring, opts ValidationOptions) (*Claims, error) {
+	var payload tokenClaims
+	if keys == nil {
+		return nil, ErrNoSecret
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, &payload, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, ErrNoKeyID
+		}
+		key, err := keys.VerificationKey(kid, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		if t.Method.Alg() != key.Alg() {
 			return nil, ErrUnexpectedSigningMethod
 		}
-		return []byte(secret), nil
+		return key.verifyKey, nil
 	})
+	var validationErr *jwt.ValidationError
+	if errors.As(err, &validationErr) && validationErr.Inner != nil {
+		return nil, validationErr.Inner
+	}
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if !token.Valid {
-		return "", ErrTokenCorrupted
+		return nil, ErrTokenCorrupted
 	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return "", ErrBadClaimsInToken
+	claims := payload.claims()
+	if err = opts.Check(claims, time.Now()); err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" || claims.ID == "" {
+		return nil, ErrBadClaimsInToken
+	}
+	return claims, nil
+}
+
+// sign signs claims with the current signing key and puts its id to kid header
+func sign(keys *Keyring, claims *tokenClaims) (string, error) {
+	key, err := keys.SigningKey(time.Now())
+	if err != nil {
+		return "", err
 	}
-	return claims["sub"].(string), nil
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey)
 }
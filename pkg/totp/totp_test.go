@@ -0,0 +1,60 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHOTP(t *testing.T) {
+	key := []byte("12345678901234567890")
+	//RFC 4226 appendix D
+	for counter, code := range []string{"755224", "287082", "359152", "969429", "338314"} {
+		assert.Equal(t, code, hotp(key, uint64(counter), 6))
+	}
+	//RFC 6238 appendix B, SHA1
+	for unix, code := range map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	} {
+		assert.Equal(t, code, hotp(key, uint64(Step(time.Unix(unix, 0))), 8))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, Step(now)-1)
+	assert.NoError(t, err)
+
+	step, ok, err := Validate(secret, code, now, 1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Step(now)-1, step)
+	_, ok, _ = Validate(secret, code, now, 0)
+	assert.False(t, ok)
+	_, ok, _ = Validate(secret, code, now.Add(2*Period), 1)
+	assert.False(t, ok)
+	_, ok, _ = Validate(secret, "12345", now, 1)
+	assert.False(t, ok)
+
+	//apps show secret in lowercase groups
+	_, ok, err = Validate(strings.ToLower(secret[:4]+" "+secret[4:]), code, now, 1)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	_, _, err = Validate("not base32!", code, now, 1)
+	assert.ErrorIs(t, err, ErrBadSecret)
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Team 31", "user@example.com", "JBSWY3DPEHPK3PXP")
+	assert.Equal(t, "otpauth://totp/Team%2031:user@example.com?algorithm=SHA1&digits=6&issuer=Team+31&period=30&secret=JBSWY3DPEHPK3PXP", uri)
+}
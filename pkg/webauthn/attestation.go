@@ -0,0 +1,97 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+)
+
+// Attestation statement formats
+const (
+	FormatNone   = "none"
+	FormatPacked = "packed"
+)
+
+var (
+	ErrBadAttestation         = errors.New("attestation statement is not valid")
+	ErrUnsupportedAttestation = errors.New("unsupported attestation format")
+)
+
+// idFidoGenCeAAGUID is certificate extension with AAGUID of authenticator model
+var idFidoGenCeAAGUID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}
+
+// verifyAttestation checks attestation statement over authenticator data and hash of client data.
+// Certificates of packed attestation are checked, but their chain is not verified against vendor roots
+func verifyAttestation(format string, statement map[interface{}]interface{}, data *authData,
+	credentialKey *PublicKey, clientDataHash []byte) error {
+	switch format {
+	case FormatNone:
+		if len(statement) != 0 {
+			return ErrBadAttestation
+		}
+		return nil
+	case FormatPacked:
+		return verifyPacked(statement, data, credentialKey, clientDataHash)
+	}
+	return ErrUnsupportedAttestation
+}
+
+func verifyPacked(statement map[interface{}]interface{}, data *authData, credentialKey *PublicKey, clientDataHash []byte) error {
+	alg, _ := statement["alg"].(int64)
+	sig, _ := statement["sig"].([]byte)
+	if alg == 0 || len(sig) == 0 {
+		return ErrBadAttestation
+	}
+	if _, ok := statement["ecdaaKeyId"]; ok {
+		return ErrUnsupportedAttestation
+	}
+	signed := append(append([]byte{}, data.raw...), clientDataHash...)
+	x5c, hasCerts := statement["x5c"].([]interface{})
+	if !hasCerts {
+		// self attestation is signed with the credential key itself
+		if alg != credentialKey.Algorithm {
+			return ErrBadAttestation
+		}
+		if err := credentialKey.Verify(signed, sig); err != nil {
+			return ErrBadAttestation
+		}
+		return nil
+	}
+	if len(x5c) == 0 {
+		return ErrBadAttestation
+	}
+	der, _ := x5c[0].([]byte)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return ErrBadAttestation
+	}
+	if err = verifySignature(alg, cert.PublicKey, signed, sig); err != nil {
+		return ErrBadAttestation
+	}
+	return checkPackedCertificate(cert, data.aaguid)
+}
+
+// checkPackedCertificate checks requirements of WebAuthn 8.2.1 to attestation certificate
+func checkPackedCertificate(cert *x509.Certificate, aaguid []byte) error {
+	if cert.Version != 3 || cert.IsCA {
+		return ErrBadAttestation
+	}
+	subject := cert.Subject
+	if len(subject.Country) == 0 || len(subject.Organization) == 0 || len(subject.CommonName) == 0 {
+		return ErrBadAttestation
+	}
+	if len(subject.OrganizationalUnit) != 1 || subject.OrganizationalUnit[0] != "Authenticator Attestation" {
+		return ErrBadAttestation
+	}
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(idFidoGenCeAAGUID) {
+			continue
+		}
+		var certAAGUID []byte
+		if _, err := asn1.Unmarshal(ext.Value, &certAAGUID); err != nil || ext.Critical || !bytes.Equal(certAAGUID, aaguid) {
+			return ErrBadAttestation
+		}
+	}
+	return nil
+}
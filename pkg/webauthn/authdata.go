@@ -0,0 +1,83 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// authenticator data flags
+const (
+	flagUserPresent    = 0x01
+	flagUserVerified   = 0x04
+	flagBackupEligible = 0x08
+	flagAttestedData   = 0x40
+	flagExtensions     = 0x80
+)
+
+const (
+	rpIDHashSize     = 32
+	aaguidSize       = 16
+	minAuthDataSize  = rpIDHashSize + 1 + 4
+	maxCredentialID  = 1023
+	credentialIDSize = 2
+)
+
+var ErrBadAuthData = errors.New("malformed authenticator data")
+
+// authData is parsed authenticator data. Attested credential is present only at registration
+type authData struct {
+	raw          []byte
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (d *authData) has(flag byte) bool {
+	return d.flags&flag != 0
+}
+
+func parseAuthData(raw []byte) (*authData, error) {
+	if len(raw) < minAuthDataSize {
+		return nil, ErrBadAuthData
+	}
+	d := &authData{
+		raw:       raw,
+		rpIDHash:  raw[:rpIDHashSize],
+		flags:     raw[rpIDHashSize],
+		signCount: binary.BigEndian.Uint32(raw[rpIDHashSize+1 : minAuthDataSize]),
+	}
+	rest := raw[minAuthDataSize:]
+	if d.has(flagAttestedData) {
+		if len(rest) < aaguidSize+credentialIDSize {
+			return nil, ErrBadAuthData
+		}
+		d.aaguid = rest[:aaguidSize]
+		idLen := int(binary.BigEndian.Uint16(rest[aaguidSize:]))
+		rest = rest[aaguidSize+credentialIDSize:]
+		if idLen == 0 || idLen > maxCredentialID || len(rest) < idLen {
+			return nil, ErrBadAuthData
+		}
+		d.credentialID = rest[:idLen]
+		rest = rest[idLen:]
+		_, afterKey, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, ErrBadAuthData
+		}
+		d.publicKey = rest[:len(rest)-len(afterKey)]
+		rest = afterKey
+	}
+	if d.has(flagExtensions) {
+		_, afterExtensions, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, ErrBadAuthData
+		}
+		rest = afterExtensions
+	}
+	if len(rest) != 0 {
+		return nil, ErrBadAuthData
+	}
+	return d, nil
+}
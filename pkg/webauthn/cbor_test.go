@@ -0,0 +1,37 @@
+package webauthn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	//{1: 2, "a": [-1, h'0102', true]} and a trailing byte
+	item, rest, err := decodeCBOR([]byte{0xa2, 0x01, 0x02, 0x61, 'a', 0x83, 0x20, 0x42, 0x01, 0x02, 0xf5, 0xff})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff}, rest)
+	assert.Equal(t, map[interface{}]interface{}{
+		int64(1): int64(2),
+		"a":      []interface{}{int64(-1), []byte{1, 2}, true},
+	}, item)
+
+	for _, bad := range [][]byte{
+		{},
+		{0x5f},       //indefinite byte string
+		{0x43, 0x01}, //byte string longer than data
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, //huge array
+		{0xa2, 0x01, 0x02, 0x01, 0x03},                         //duplicate key
+		{0xa1, 0x40, 0x01},                                     //byte string key
+		{0xfb, 0, 0, 0, 0, 0, 0, 0, 0},                         //float
+	} {
+		_, _, err = decodeCBOR(bad)
+		assert.ErrorIs(t, err, ErrBadCBOR, "%x", bad)
+	}
+	deep := make([]byte, maxCBORDepth+2)
+	for i := range deep {
+		deep[i] = 0x81
+	}
+	_, _, err = decodeCBOR(deep)
+	assert.ErrorIs(t, err, ErrBadCBOR)
+}
@@ -0,0 +1,128 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithms (RFC 9053) accepted for credentials, in order of preference
+const (
+	AlgES256 int64 = -7
+	AlgEdDSA int64 = -8
+	AlgRS256 int64 = -257
+)
+
+// SupportedAlgorithms go to pubKeyCredParams of registration options
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+var (
+	ErrUnsupportedKey = errors.New("unsupported credential public key")
+	ErrBadSignature   = errors.New("signature verification failed")
+)
+
+// COSE_Key labels
+const (
+	coseKty = 1
+	coseAlg = 3
+	// key type parameters, meaning depends on kty
+	coseCrv = -1
+	coseX   = -2
+	coseY   = -3
+	coseN   = -1
+	coseE   = -2
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+
+	minRSABits = 2048
+)
+
+// PublicKey is credential public key with the algorithm it signs with
+type PublicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// ParsePublicKey parses COSE_Key kept for credential
+func ParsePublicKey(coseKey []byte) (*PublicKey, error) {
+	m, err := cborMap(coseKey)
+	if err != nil {
+		return nil, err
+	}
+	return publicKeyFromMap(m)
+}
+
+func publicKeyFromMap(m map[interface{}]interface{}) (*PublicKey, error) {
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+	switch {
+	case kty == coseKtyEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseCrvP256 || len(x) != 32 || len(y) != 32 {
+			return nil, ErrUnsupportedKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, ErrUnsupportedKey
+		}
+		return &PublicKey{Algorithm: alg, Key: key}, nil
+	case kty == coseKtyOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCrv)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != coseCrvEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, ErrUnsupportedKey
+		}
+		return &PublicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, nil
+	case kty == coseKtyRSA && alg == AlgRS256:
+		n, _ := m[int64(coseN)].([]byte)
+		e, _ := m[int64(coseE)].([]byte)
+		exponent := new(big.Int).SetBytes(e)
+		if len(n)*8 < minRSABits || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, ErrUnsupportedKey
+		}
+		return &PublicKey{Algorithm: alg, Key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}}, nil
+	}
+	return nil, ErrUnsupportedKey
+}
+
+// Verify checks signature of data made with the key
+func (k *PublicKey) Verify(data, signature []byte) error {
+	return verifySignature(k.Algorithm, k.Key, data, signature)
+}
+
+func verifySignature(alg int64, key crypto.PublicKey, data, signature []byte) error {
+	digest := sha256.Sum256(data)
+	ok := false
+	switch alg {
+	case AlgES256:
+		if pub, isECDSA := key.(*ecdsa.PublicKey); isECDSA {
+			ok = ecdsa.VerifyASN1(pub, digest[:], signature)
+		}
+	case AlgEdDSA:
+		if pub, isEd25519 := key.(ed25519.PublicKey); isEd25519 {
+			ok = ed25519.Verify(pub, data, signature)
+		}
+	case AlgRS256:
+		if pub, isRSA := key.(*rsa.PublicKey); isRSA {
+			ok = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil
+		}
+	default:
+		return ErrUnsupportedKey
+	}
+	if !ok {
+		return ErrBadSignature
+	}
+	return nil
+}
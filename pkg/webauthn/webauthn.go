@@ -0,0 +1,400 @@
+// Package webauthn is relying party side of WebAuthn (https://www.w3.org/TR/webauthn-2/).
+// It makes options for navigator.credentials.create() and get() and verifies what browser
+// sends back in the JSON form of PublicKeyCredential, binary fields in base64url.
+// Challenges have to be kept by the caller and used once
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// User verification requirements
+const (
+	VerificationRequired    = "required"
+	VerificationPreferred   = "preferred"
+	VerificationDiscouraged = "discouraged"
+)
+
+const (
+	publicKeyType = "public-key"
+	typeCreate    = "webauthn.create"
+	typeGet       = "webauthn.get"
+	challengeSize = 32
+
+	defaultTimeout = 5 * time.Minute
+)
+
+var (
+	ErrBadConfig       = errors.New("webauthn needs rp id and at least one origin")
+	ErrBadResponse     = errors.New("malformed webauthn response")
+	ErrBadClientData   = errors.New("client data doesn't match the ceremony")
+	ErrBadOrigin       = errors.New("origin is not allowed")
+	ErrBadRPID         = errors.New("credential is scoped to another relying party")
+	ErrUserNotPresent  = errors.New("user presence is not confirmed")
+	ErrUserNotVerified = errors.New("user verification is required")
+	ErrSignCount       = errors.New("sign counter went back, authenticator may be cloned")
+)
+
+// Config of relying party. RPID is domain credentials are scoped to, Origins are full origins
+// of pages allowed to run ceremonies, like https://login.example.com.
+// Attestation is conveyance preference: "none", "indirect" or "direct".
+// Timeout is hint for browser, five minutes if zero
+type Config struct {
+	RPID        string
+	RPName      string
+	Origins     []string
+	Timeout     time.Duration
+	Attestation string
+}
+
+type RelyingParty struct {
+	cfg      Config
+	rpIDHash []byte
+}
+
+func New(cfg Config) (*RelyingParty, error) {
+	if cfg.RPID == "" || len(cfg.Origins) == 0 {
+		return nil, ErrBadConfig
+	}
+	if cfg.RPName == "" {
+		cfg.RPName = cfg.RPID
+	}
+	if cfg.Attestation == "" {
+		cfg.Attestation = "none"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	hash := sha256.Sum256([]byte(cfg.RPID))
+	return &RelyingParty{cfg: cfg, rpIDHash: hash[:]}, nil
+}
+
+// Timeout is how long ceremony may take, challenges shouldn't be kept longer
+func (rp *RelyingParty) Timeout() time.Duration {
+	return rp.cfg.Timeout
+}
+
+type RPEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity ID is user handle in base64url, it shouldn't contain personal data
+type UserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int64  `json:"alg"`
+}
+
+// CredentialDescriptor ID is credential id in base64url
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         string   `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey,omitempty"`
+	UserVerification string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions is PublicKeyCredentialCreationOptionsJSON
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RP                     RPEntity               `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is PublicKeyCredentialRequestOptionsJSON. Empty AllowCredentials
+// lets user pick any discoverable credential (passkey) of the relying party
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// AttestationResponse is RegistrationResponseJSON, result of navigator.credentials.create()
+type AttestationResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string   `json:"clientDataJSON"`
+		AttestationObject string   `json:"attestationObject"`
+		Transports        []string `json:"transports,omitempty"`
+	} `json:"response"`
+}
+
+// AssertionResponse is AuthenticationResponseJSON, result of navigator.credentials.get()
+type AssertionResponse struct {
+	ID       string `json:"id"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON    string `json:"clientDataJSON"`
+		AuthenticatorData string `json:"authenticatorData"`
+		Signature         string `json:"signature"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// Credential is a registered credential. PublicKey is COSE_Key, parse it with ParsePublicKey
+type Credential struct {
+	ID             []byte
+	PublicKey      []byte
+	Algorithm      int64
+	SignCount      uint32
+	AAGUID         []byte
+	Format         string
+	Transports     []string
+	UserVerified   bool
+	BackupEligible bool
+}
+
+// Assertion is result of successful authentication
+type Assertion struct {
+	SignCount    uint32
+	UserVerified bool
+	UserHandle   []byte
+}
+
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// NewChallenge makes random challenge in base64url
+func NewChallenge() (string, error) {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		return "", err
+	}
+	return EncodeID(challenge), nil
+}
+
+// EncodeID is base64url without padding, the form of binary fields in JSON
+func EncodeID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// DecodeID accepts base64url with or without padding
+func DecodeID(id string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(id, "="))
+}
+
+// CreationOptions are options of registration for user. exclude are credentials user already has.
+// residentKey "required" makes passkey usable without login
+func (rp *RelyingParty) CreationOptions(challenge string, user UserEntity, exclude []CredentialDescriptor,
+	userVerification, residentKey string) *CreationOptions {
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: publicKeyType, Alg: alg})
+	}
+	if exclude == nil {
+		exclude = []CredentialDescriptor{}
+	}
+	return &CreationOptions{
+		Challenge:          challenge,
+		RP:                 RPEntity{ID: rp.cfg.RPID, Name: rp.cfg.RPName},
+		User:               user,
+		PubKeyCredParams:   params,
+		Timeout:            rp.cfg.Timeout.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      residentKey,
+			UserVerification: userVerification,
+		},
+		Attestation: rp.cfg.Attestation,
+	}
+}
+
+// RequestOptions are options of authentication with one of allow credentials
+func (rp *RelyingParty) RequestOptions(challenge string, allow []CredentialDescriptor, userVerification string) *RequestOptions {
+	if allow == nil {
+		allow = []CredentialDescriptor{}
+	}
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.cfg.Timeout.Milliseconds(),
+		RPID:             rp.cfg.RPID,
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// Descriptor describes registered credential for allow and exclude lists
+func Descriptor(id []byte, transports []string) CredentialDescriptor {
+	return CredentialDescriptor{Type: publicKeyType, ID: EncodeID(id), Transports: transports}
+}
+
+// ChallengeOf takes challenge from client data, so the caller can find the ceremony it belongs to.
+// Nothing is verified here
+func ChallengeOf(clientDataJSON string) (string, error) {
+	raw, err := DecodeID(clientDataJSON)
+	if err != nil {
+		return "", ErrBadResponse
+	}
+	var data clientData
+	if err = json.Unmarshal(raw, &data); err != nil || data.Challenge == "" {
+		return "", ErrBadResponse
+	}
+	return data.Challenge, nil
+}
+
+// VerifyRegistration verifies response to CreationOptions with challenge and returns the new credential
+func (rp *RelyingParty) VerifyRegistration(resp *AttestationResponse, challenge string, requireUV bool) (*Credential, error) {
+	if resp.Type != publicKeyType {
+		return nil, ErrBadResponse
+	}
+	clientDataHash, err := rp.verifyClientData(resp.Response.ClientDataJSON, typeCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+	rawObject, err := DecodeID(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, ErrBadResponse
+	}
+	object, err := cborMap(rawObject)
+	if err != nil {
+		return nil, ErrBadResponse
+	}
+	format, _ := object["fmt"].(string)
+	statement, _ := object["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := object["authData"].([]byte)
+	if format == "" || statement == nil || rawAuthData == nil {
+		return nil, ErrBadResponse
+	}
+	data, err := rp.verifyAuthData(rawAuthData, requireUV)
+	if err != nil {
+		return nil, err
+	}
+	if !data.has(flagAttestedData) {
+		return nil, ErrBadAuthData
+	}
+	if id, err := DecodeID(resp.ID); err != nil || !bytes.Equal(id, data.credentialID) {
+		return nil, ErrBadResponse
+	}
+	key, err := ParsePublicKey(data.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyAttestation(format, statement, data, key, clientDataHash); err != nil {
+		return nil, err
+	}
+	return &Credential{
+		ID:             data.credentialID,
+		PublicKey:      data.publicKey,
+		Algorithm:      key.Algorithm,
+		SignCount:      data.signCount,
+		AAGUID:         data.aaguid,
+		Format:         format,
+		Transports:     resp.Response.Transports,
+		UserVerified:   data.has(flagUserVerified),
+		BackupEligible: data.has(flagBackupEligible),
+	}, nil
+}
+
+// VerifyAssertion verifies response to RequestOptions with challenge, signed by credential
+// with publicKey which signed signCount times before
+func (rp *RelyingParty) VerifyAssertion(resp *AssertionResponse, challenge string, publicKey []byte,
+	signCount uint32, requireUV bool) (*Assertion, error) {
+	if resp.Type != publicKeyType {
+		return nil, ErrBadResponse
+	}
+	clientDataHash, err := rp.verifyClientData(resp.Response.ClientDataJSON, typeGet, challenge)
+	if err != nil {
+		return nil, err
+	}
+	rawAuthData, err := DecodeID(resp.Response.AuthenticatorData)
+	if err != nil {
+		return nil, ErrBadResponse
+	}
+	signature, err := DecodeID(resp.Response.Signature)
+	if err != nil {
+		return nil, ErrBadResponse
+	}
+	userHandle, err := DecodeID(resp.Response.UserHandle)
+	if err != nil {
+		return nil, ErrBadResponse
+	}
+	data, err := rp.verifyAuthData(rawAuthData, requireUV)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	if err = key.Verify(append(append([]byte{}, rawAuthData...), clientDataHash...), signature); err != nil {
+		return nil, err
+	}
+	// authenticators without counter always send zero
+	if (data.signCount != 0 || signCount != 0) && data.signCount <= signCount {
+		return nil, ErrSignCount
+	}
+	return &Assertion{SignCount: data.signCount, UserVerified: data.has(flagUserVerified), UserHandle: userHandle}, nil
+}
+
+// verifyClientData checks client data and returns its hash, authenticator signs it
+func (rp *RelyingParty) verifyClientData(clientDataJSON, ceremony, challenge string) ([]byte, error) {
+	raw, err := DecodeID(clientDataJSON)
+	if err != nil {
+		return nil, ErrBadResponse
+	}
+	var data clientData
+	if err = json.Unmarshal(raw, &data); err != nil {
+		return nil, ErrBadResponse
+	}
+	if data.Type != ceremony || data.CrossOrigin ||
+		subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return nil, ErrBadClientData
+	}
+	allowed := false
+	for _, origin := range rp.cfg.Origins {
+		allowed = allowed || origin == data.Origin
+	}
+	if !allowed {
+		return nil, ErrBadOrigin
+	}
+	hash := sha256.Sum256(raw)
+	return hash[:], nil
+}
+
+func (rp *RelyingParty) verifyAuthData(raw []byte, requireUV bool) (*authData, error) {
+	data, err := parseAuthData(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(data.rpIDHash, rp.rpIDHash) {
+		return nil, ErrBadRPID
+	}
+	if !data.has(flagUserPresent) {
+		return nil, ErrUserNotPresent
+	}
+	if requireUV && !data.has(flagUserVerified) {
+		return nil, ErrUserNotVerified
+	}
+	return data, nil
+}
@@ -0,0 +1,133 @@
+package webauthn_test
+
+import (
+	"testing"
+
+	"github.com/DMA8/authService/pkg/webauthn"
+	"github.com/DMA8/authService/pkg/webauthn/webauthntest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const origin = "https://login.example.com"
+
+func newRP(t *testing.T) *webauthn.RelyingParty {
+	rp, err := webauthn.New(webauthn.Config{RPID: "example.com", Origins: []string{origin}})
+	assert.NoError(t, err)
+	return rp
+}
+
+func register(t *testing.T, rp *webauthn.RelyingParty, authenticator *webauthntest.Authenticator) (*webauthn.Credential, error) {
+	challenge, err := webauthn.NewChallenge()
+	assert.NoError(t, err)
+	user := webauthn.UserEntity{ID: webauthn.EncodeID([]byte("user id")), Name: "user", DisplayName: "user"}
+	resp, err := authenticator.Create(rp.CreationOptions(challenge, user, nil, webauthn.VerificationPreferred, ""))
+	assert.NoError(t, err)
+	return rp.VerifyRegistration(resp, challenge, false)
+}
+
+func TestRegistration(t *testing.T) {
+	rp := newRP(t)
+	authenticator := webauthntest.New(origin)
+	cred, err := register(t, rp, authenticator)
+	assert.NoError(t, err)
+	assert.Equal(t, webauthn.FormatNone, cred.Format)
+	assert.Equal(t, webauthn.AlgES256, cred.Algorithm)
+	assert.True(t, cred.UserVerified)
+	_, err = webauthn.ParsePublicKey(cred.PublicKey)
+	assert.NoError(t, err)
+
+	//packed self attestation
+	authenticator.Format = webauthn.FormatPacked
+	cred, err = register(t, rp, authenticator)
+	assert.NoError(t, err)
+	assert.Equal(t, webauthn.FormatPacked, cred.Format)
+
+	//packed with attestation certificate, aaguid in certificate has to match
+	authenticator.AAGUID = []byte("0123456789abcdef")
+	authenticator.AttestationKey, authenticator.AttestationCert, err = webauthntest.NewAttestationCertificate(authenticator.AAGUID)
+	assert.NoError(t, err)
+	_, err = register(t, rp, authenticator)
+	assert.NoError(t, err)
+	authenticator.AAGUID = []byte("fedcba9876543210")
+	_, err = register(t, rp, authenticator)
+	assert.ErrorIs(t, err, webauthn.ErrBadAttestation)
+}
+
+func TestRegistrationErrors(t *testing.T) {
+	rp := newRP(t)
+	user := webauthn.UserEntity{ID: webauthn.EncodeID([]byte("user id")), Name: "user"}
+	challenge, err := webauthn.NewChallenge()
+	assert.NoError(t, err)
+	options := rp.CreationOptions(challenge, user, nil, webauthn.VerificationRequired, "")
+
+	resp, err := webauthntest.New("https://evil.example.org").Create(options)
+	assert.NoError(t, err)
+	_, err = rp.VerifyRegistration(resp, challenge, true)
+	assert.ErrorIs(t, err, webauthn.ErrBadOrigin)
+
+	authenticator := webauthntest.New(origin)
+	resp, err = authenticator.Create(options)
+	assert.NoError(t, err)
+	other, err := webauthn.NewChallenge()
+	assert.NoError(t, err)
+	_, err = rp.VerifyRegistration(resp, other, true)
+	assert.ErrorIs(t, err, webauthn.ErrBadClientData)
+
+	otherRP, err := webauthn.New(webauthn.Config{RPID: "example.org", Origins: []string{origin}})
+	assert.NoError(t, err)
+	_, err = otherRP.VerifyRegistration(resp, challenge, true)
+	assert.ErrorIs(t, err, webauthn.ErrBadRPID)
+
+	authenticator.UserVerified = false
+	resp, err = authenticator.Create(options)
+	assert.NoError(t, err)
+	_, err = rp.VerifyRegistration(resp, challenge, true)
+	assert.ErrorIs(t, err, webauthn.ErrUserNotVerified)
+
+	resp.Response.AttestationObject = resp.Response.AttestationObject[:40]
+	_, err = rp.VerifyRegistration(resp, challenge, false)
+	assert.ErrorIs(t, err, webauthn.ErrBadResponse)
+}
+
+func TestAssertion(t *testing.T) {
+	rp := newRP(t)
+	authenticator := webauthntest.New(origin)
+	authenticator.Counter = true
+	cred, err := register(t, rp, authenticator)
+	assert.NoError(t, err)
+	login := func(signCount uint32) (*webauthn.Assertion, error) {
+		challenge, err := webauthn.NewChallenge()
+		assert.NoError(t, err)
+		allow := []webauthn.CredentialDescriptor{webauthn.Descriptor(cred.ID, nil)}
+		resp, err := authenticator.Get(rp.RequestOptions(challenge, allow, webauthn.VerificationRequired))
+		assert.NoError(t, err)
+		return rp.VerifyAssertion(resp, challenge, cred.PublicKey, signCount, true)
+	}
+
+	assertion, err := login(cred.SignCount)
+	assert.NoError(t, err)
+	assert.Equal(t, cred.SignCount+1, assertion.SignCount)
+	assert.Equal(t, []byte("user id"), assertion.UserHandle)
+
+	//counter which didn't grow means cloned authenticator
+	authenticator.SetSignCount(0)
+	_, err = login(assertion.SignCount)
+	assert.ErrorIs(t, err, webauthn.ErrSignCount)
+
+	//signature of another key
+	other := webauthntest.New(origin)
+	otherCred, err := register(t, rp, other)
+	assert.NoError(t, err)
+	challenge, err := webauthn.NewChallenge()
+	assert.NoError(t, err)
+	resp, err := other.Get(rp.RequestOptions(challenge, nil, webauthn.VerificationRequired))
+	assert.NoError(t, err)
+	_, err = rp.VerifyAssertion(resp, challenge, cred.PublicKey, 0, true)
+	assert.ErrorIs(t, err, webauthn.ErrBadSignature)
+	_, err = rp.VerifyAssertion(resp, challenge, otherCred.PublicKey, 0, true)
+	assert.NoError(t, err)
+	got, err := webauthn.ChallengeOf(resp.Response.ClientDataJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, challenge, got)
+}
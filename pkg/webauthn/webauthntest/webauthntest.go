@@ -0,0 +1,289 @@
+// Package webauthntest is software authenticator for tests of WebAuthn relying party.
+// It answers options like a browser with security key or passkey would
+package webauthntest
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/DMA8/authService/pkg/webauthn"
+)
+
+var ErrNoCredential = errors.New("authenticator has no credential for the request")
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// Authenticator keeps ES256 credentials. Format is attestation format of new credentials,
+// packed is self attestation unless AttestationKey and AttestationCert are set.
+// Counter makes sign counter grow, otherwise it is always zero like in most passkeys
+type Authenticator struct {
+	Origin          string
+	Format          string
+	UserVerified    bool
+	Counter         bool
+	AAGUID          []byte
+	AttestationKey  *ecdsa.PrivateKey
+	AttestationCert []byte
+	credentials     []*credential
+}
+
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// New creates authenticator which runs ceremonies of pages at origin, with user verification
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin, Format: webauthn.FormatNone, UserVerified: true, AAGUID: make([]byte, 16)}
+}
+
+// Create makes new credential like navigator.credentials.create()
+func (a *Authenticator) Create(options *webauthn.CreationOptions) (*webauthn.AttestationResponse, error) {
+	for _, excluded := range options.ExcludeCredentials {
+		if a.find(options.RP.ID, excluded.ID) != nil {
+			return nil, errors.New("credential is already registered")
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 32)
+	if _, err = rand.Read(id); err != nil {
+		return nil, err
+	}
+	userHandle, err := webauthn.DecodeID(options.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	cred := &credential{id: id, rpID: options.RP.ID, userHandle: userHandle, key: key}
+	clientDataJSON, clientDataHash := a.clientData("webauthn.create", options.Challenge)
+
+	attested := append(append([]byte{}, a.AAGUID...), byte(len(id)>>8), byte(len(id)))
+	attested = append(append(attested, id...), coseKey(&key.PublicKey)...)
+	authData := a.authData(cred, flagAttestedData, attested)
+	statement := map[interface{}]interface{}{}
+	if a.Format == webauthn.FormatPacked {
+		signer := key
+		if a.AttestationKey != nil {
+			signer = a.AttestationKey
+		}
+		sig, err := sign(signer, append(append([]byte{}, authData...), clientDataHash...))
+		if err != nil {
+			return nil, err
+		}
+		statement["alg"] = webauthn.AlgES256
+		statement["sig"] = sig
+		if a.AttestationCert != nil {
+			statement["x5c"] = []interface{}{a.AttestationCert}
+		}
+	}
+	object := EncodeCBOR(map[interface{}]interface{}{"fmt": a.Format, "attStmt": statement, "authData": authData})
+	a.credentials = append(a.credentials, cred)
+
+	resp := &webauthn.AttestationResponse{ID: webauthn.EncodeID(id), RawID: webauthn.EncodeID(id), Type: "public-key"}
+	resp.Response.ClientDataJSON = webauthn.EncodeID(clientDataJSON)
+	resp.Response.AttestationObject = webauthn.EncodeID(object)
+	resp.Response.Transports = []string{"internal"}
+	return resp, nil
+}
+
+// Get signs challenge like navigator.credentials.get(). With empty allow list
+// the first credential of relying party is used, as if user picked a passkey
+func (a *Authenticator) Get(options *webauthn.RequestOptions) (*webauthn.AssertionResponse, error) {
+	var cred *credential
+	for _, allowed := range options.AllowCredentials {
+		if cred = a.find(options.RPID, allowed.ID); cred != nil {
+			break
+		}
+	}
+	if len(options.AllowCredentials) == 0 {
+		for _, c := range a.credentials {
+			if c.rpID == options.RPID {
+				cred = c
+				break
+			}
+		}
+	}
+	if cred == nil {
+		return nil, ErrNoCredential
+	}
+	clientDataJSON, clientDataHash := a.clientData("webauthn.get", options.Challenge)
+	authData := a.authData(cred, 0, nil)
+	sig, err := sign(cred.key, append(append([]byte{}, authData...), clientDataHash...))
+	if err != nil {
+		return nil, err
+	}
+	resp := &webauthn.AssertionResponse{ID: webauthn.EncodeID(cred.id), RawID: webauthn.EncodeID(cred.id), Type: "public-key"}
+	resp.Response.ClientDataJSON = webauthn.EncodeID(clientDataJSON)
+	resp.Response.AuthenticatorData = webauthn.EncodeID(authData)
+	resp.Response.Signature = webauthn.EncodeID(sig)
+	resp.Response.UserHandle = webauthn.EncodeID(cred.userHandle)
+	return resp, nil
+}
+
+// SetSignCount sets counter of all credentials, e.g. to make a clone
+func (a *Authenticator) SetSignCount(count uint32) {
+	for _, c := range a.credentials {
+		c.signCount = count
+	}
+}
+
+func (a *Authenticator) find(rpID, id string) *credential {
+	for _, c := range a.credentials {
+		if c.rpID == rpID && webauthn.EncodeID(c.id) == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) ([]byte, []byte) {
+	raw, _ := json.Marshal(map[string]interface{}{"type": ceremony, "challenge": challenge, "origin": a.Origin})
+	hash := sha256.Sum256(raw)
+	return raw, hash[:]
+}
+
+func (a *Authenticator) authData(cred *credential, flags byte, attested []byte) []byte {
+	if a.Counter {
+		cred.signCount++
+	}
+	flags |= flagUserPresent
+	if a.UserVerified {
+		flags |= flagUserVerified
+	}
+	rpIDHash := sha256.Sum256([]byte(cred.rpID))
+	data := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[len(data)-4:], cred.signCount)
+	return append(data, attested...)
+}
+
+func sign(key *ecdsa.PrivateKey, data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	return ecdsa.SignASN1(rand.Reader, key, digest[:])
+}
+
+func coseKey(key *ecdsa.PublicKey) []byte {
+	x, y := make([]byte, 32), make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	return EncodeCBOR(map[interface{}]interface{}{
+		int64(1): int64(2), int64(3): webauthn.AlgES256, int64(-1): int64(1), int64(-2): x, int64(-3): y,
+	})
+}
+
+// NewAttestationCertificate makes key and self-signed packed attestation certificate for aaguid
+func NewAttestationCertificate(aaguid []byte) (*ecdsa.PrivateKey, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	aaguidExt, err := asn1.Marshal(aaguid)
+	if err != nil {
+		return nil, nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			Country:            []string{"US"},
+			Organization:       []string{"Test Vendor"},
+			OrganizationalUnit: []string{"Authenticator Attestation"},
+			CommonName:         "Test Authenticator",
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		ExtraExtensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 45724, 1, 1, 4}, Value: aaguidExt},
+		},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	return key, der, err
+}
+
+// EncodeCBOR encodes int64, int, string, []byte, bool, []interface{} and maps of them.
+// Map keys are sorted by their encoding as CTAP2 canonical form requires
+func EncodeCBOR(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		return EncodeCBOR(int64(v))
+	case int64:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case bool:
+		if v {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case []interface{}:
+		out := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, EncodeCBOR(item)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		type pair struct{ key, value []byte }
+		pairs := make([]pair, 0, len(v))
+		for key, value := range v {
+			pairs = append(pairs, pair{EncodeCBOR(key), EncodeCBOR(value)})
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			if len(pairs[i].key) != len(pairs[j].key) {
+				return len(pairs[i].key) < len(pairs[j].key)
+			}
+			return bytes.Compare(pairs[i].key, pairs[j].key) < 0
+		})
+		out := cborHead(5, uint64(len(v)))
+		for _, p := range pairs {
+			out = append(append(out, p.key...), p.value...)
+		}
+		return out
+	}
+	panic("webauthntest: can't encode to cbor")
+}
+
+func cborHead(major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return []byte{major<<5 | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major<<5 | 24, byte(arg)}
+	case arg <= 0xffff:
+		head := []byte{major<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(head[1:], uint16(arg))
+		return head
+	case arg <= 0xffffffff:
+		head := []byte{major<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(head[1:], uint32(arg))
+		return head
+	}
+	head := make([]byte, 9)
+	head[0] = major<<5 | 27
+	binary.BigEndian.PutUint64(head[1:], arg)
+	return head
+}